@@ -9,6 +9,8 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dhenkes/gofman/pkg/auth"
 	"github.com/dhenkes/gofman/pkg/gofman"
@@ -30,6 +32,12 @@ const (
 	DefaultDatabaseDSN = "~/.gofman/db"
 	DefaultHTTPAddress = "127.0.0.1"
 	DefaultHTTPPort    = 8080
+
+	DefaultRetentionInterval = 3600
+
+	DefaultSessionPurgeInterval = 3600
+
+	DefaultPurgeOlderThan = 30 * 24 * time.Hour
 )
 
 func main() {
@@ -44,6 +52,7 @@ func main() {
 	m := NewMain()
 
 	m.DB.AuthService = m.AuthService
+	m.DB.PathTraversalService = m.PathTraversalService
 
 	fs := flag.NewFlagSet("gofman", flag.ContinueOnError)
 	fs.StringVar(&m.ConfigPath, "config", DefaultConfigPath, "config path")
@@ -71,6 +80,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	switch fs.Arg(0) {
+	case "":
+	case "purge":
+		err := m.runPurgeCommand(ctx, fs.Args()[1:])
+		if e := m.Close(); err == nil {
+			err = e
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", fs.Arg(0))
+		os.Exit(1)
+	}
+
 	if err := m.Run(ctx); err != nil {
 		m.Close()
 		fmt.Fprintln(os.Stderr, err)
@@ -96,6 +124,9 @@ type Main struct {
 
 	AuthService          gofman.AuthService
 	PathTraversalService gofman.PathTraversalService
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // NewMain returns a new instance of Main.
@@ -116,13 +147,123 @@ func NewMain() *Main {
 // Config represents the CLI configuration file.
 type Config struct {
 	HTTP struct {
-		Address string `toml:"address"`
-		Port    int    `toml:"port"`
+		Address       string `toml:"address"`
+		Port          int    `toml:"port"`
+		DownloadRate  int64  `toml:"download_rate"`
+		APITimeout    int64  `toml:"api_timeout"`
+		UploadTimeout int64  `toml:"upload_timeout"`
+
+		// Seconds to wait for in-flight requests on shutdown.
+		ShutdownTimeout int64 `toml:"shutdown_timeout"`
+
+		RequestIDFormat string `toml:"request_id_format"`
+
+		// Reverse proxies whose X-Request-Id and X-Forwarded-For headers
+		// are trusted. No client is trusted if empty.
+		TrustedProxies []string `toml:"trusted_proxies"`
+
+		// Origins of frontends on other hosts allowed to call the API.
+		CORSOrigins []string `toml:"cors_origins"`
+
+		// Only send cookies over HTTPS. Disable for local development over
+		// plain HTTP. Always enabled when serving HTTPS.
+		Secure bool `toml:"secure"`
+
+		// Serve HTTPS with the given certificate or with a generated self
+		// signed one for local development.
+		CertFile   string `toml:"cert_file"`
+		KeyFile    string `toml:"key_file"`
+		SelfSigned bool   `toml:"self_signed"`
+
+		// Failed logins per username allowed within the window in seconds.
+		// Zero attempts disable the limit.
+		LoginMaxAttempts int   `toml:"login_max_attempts"`
+		LoginWindow      int64 `toml:"login_window"`
+		LoginLimitByIP   bool  `toml:"login_limit_by_ip"`
+
+		// Requests per second and requests at once allowed per user or remote
+		// IP on the API. A rate of zero disables the limit.
+		RateLimit      float64 `toml:"rate_limit"`
+		RateLimitBurst int     `toml:"rate_limit_burst"`
+
+		// Log one line per request to the standard logger.
+		AccessLog bool `toml:"access_log"`
+
+		// Expose Prometheus metrics at /debug/metrics.
+		Metrics bool `toml:"metrics"`
 	} `toml:"http"`
 
 	Database struct {
 		DSN string `toml:"dsn"`
+
+		// Threshold in milliseconds above which queries are logged.
+		SlowQueryThreshold int64 `toml:"slow_query_threshold"`
+
+		// Milliseconds a connection waits for a locked database before
+		// giving up.
+		BusyTimeout int64 `toml:"busy_timeout"`
+
+		// Use a rollback journal instead of write-ahead logging, e.g. if the
+		// database is stored on a network filesystem.
+		DisableWAL bool `toml:"disable_wal"`
 	} `toml:"database"`
+
+	Storage struct {
+		Root          string `toml:"root"`
+		RelativePaths bool   `toml:"relative_paths"`
+
+		// Media types files may have, e.g. "image/*". "*/*" allows every
+		// type.
+		AllowedTypes []string `toml:"allowed_types"`
+
+		// Maximum size of an uploaded file in bytes.
+		MaxUploadSize int64 `toml:"max_upload_size"`
+	} `toml:"storage"`
+
+	Session struct {
+		MaxSessions         int    `toml:"max_sessions"`
+		MaxSessionsStrategy string `toml:"max_sessions_strategy"`
+
+		// Lifetime of new sessions in seconds, depending on whether the user
+		// asked to be remembered.
+		TTL         int64 `toml:"ttl"`
+		RememberTTL int64 `toml:"remember_ttl"`
+
+		// Seconds between purges of expired sessions. Zero disables purging.
+		PurgeInterval int64 `toml:"purge_interval"`
+	} `toml:"session"`
+
+	Users struct {
+		// Strategy used when a user is created with the username of a
+		// removed user, either "release" or "reactivate".
+		UsernameReuseStrategy string `toml:"username_reuse_strategy"`
+	} `toml:"users"`
+
+	Retention struct {
+		Interval      int64 `toml:"interval"`
+		SessionMaxAge int64 `toml:"session_max_age"`
+	} `toml:"retention"`
+
+	Auth struct {
+		// Argon2 parameters for new password hashes. Memory is given in KiB.
+		ArgonTime    uint32 `toml:"argon_time"`
+		ArgonMemory  uint32 `toml:"argon_memory"`
+		ArgonThreads uint8  `toml:"argon_threads"`
+		ArgonKeyLen  uint32 `toml:"argon_key_len"`
+		SaltLen      int    `toml:"salt_len"`
+		TokenLen     int    `toml:"token_len"`
+
+		// Rules for new passwords. Set all to zero or false to accept every
+		// password that passes the basic user validation.
+		PasswordMinLength  int  `toml:"password_min_length"`
+		PasswordMinClasses int  `toml:"password_min_classes"`
+		PasswordBlocklist  bool `toml:"password_blocklist"`
+
+		// Secret mixed into passwords before hashing. Passwords hashed
+		// before it was set keep working. Changing or removing it
+		// invalidates all passwords hashed with it.
+		Pepper string `toml:"pepper"`
+	} `toml:"auth"`
 }
 
 // NewConfig returns a new instance of Config with defaults set.
@@ -130,50 +271,142 @@ func NewConfig() Config {
 	var config Config
 
 	config.Database.DSN = DefaultDatabaseDSN
+	config.Database.BusyTimeout = int64(sqlite.DefaultBusyTimeout / time.Millisecond)
 
 	config.HTTP.Address = DefaultHTTPAddress
 	config.HTTP.Port = DefaultHTTPPort
+	config.HTTP.Secure = true
+	config.HTTP.APITimeout = int64(http.DefaultAPITimeout / time.Second)
+	config.HTTP.ShutdownTimeout = int64(http.DefaultShutdownTimeout / time.Second)
+	config.HTTP.LoginMaxAttempts = http.DefaultLoginMaxAttempts
+	config.HTTP.LoginWindow = int64(http.DefaultLoginWindow / time.Second)
+	config.HTTP.RateLimitBurst = http.DefaultRateLimitBurst
+
+	config.Retention.Interval = DefaultRetentionInterval
+
+	config.Storage.AllowedTypes = gofman.DefaultFileConfig().AllowedTypes
+	config.Storage.MaxUploadSize = http.DefaultMaxUploadSize
+
+	config.Session.TTL = int64(http.DefaultSessionTTL / time.Second)
+	config.Session.RememberTTL = int64(http.DefaultRememberTTL / time.Second)
+	config.Session.PurgeInterval = DefaultSessionPurgeInterval
+
+	argon := auth.DefaultArgonConfig()
+	config.Auth.ArgonTime = argon.Time
+	config.Auth.ArgonMemory = argon.Memory
+	config.Auth.ArgonThreads = argon.Threads
+	config.Auth.ArgonKeyLen = argon.KeyLen
+	config.Auth.SaltLen = argon.SaltLen
+	config.Auth.TokenLen = argon.TokenLen
+
+	policy := auth.DefaultPasswordPolicy()
+	config.Auth.PasswordMinLength = policy.MinLength
+	config.Auth.PasswordMinClasses = policy.MinClasses
+	config.Auth.PasswordBlocklist = policy.Blocklist
 
 	return config
 }
 
-// Close gracefully stops the program.
+// Close gracefully stops the program. The HTTP server is drained before the
+// database is closed so in-flight requests never hit a closed database. It is
+// safe to call Close multiple times, only the first call has an effect.
 func (m *Main) Close() error {
+	m.closeOnce.Do(func() {
+		m.closeErr = m.close()
+	})
+
+	return m.closeErr
+}
+
+// close stops the HTTP server and closes the database. The database is closed
+// even if the HTTP server could not be stopped cleanly.
+func (m *Main) close() (err error) {
 	if m.HTTPServer != nil {
-		if err := m.HTTPServer.Close(); err != nil {
-			return err
-		}
+		err = m.HTTPServer.Close()
 	}
 
 	if m.DB != nil {
-		if err := m.DB.Close(); err != nil {
-			return err
+		if e := m.DB.Close(); e != nil && err == nil {
+			err = e
 		}
 	}
 
-	return nil
+	return err
 }
 
 // Run executes the program. The configuration should already be set up before
 // calling this function.
 func (m *Main) Run(ctx context.Context) (err error) {
-	if m.DB.DSN, err = m.PathTraversalService.Expand(m.Config.Database.DSN); err != nil {
+	if err := m.openDB(); err != nil {
 		return err
 	}
 
-	if err := m.DB.Open(); err != nil {
+	if err := m.setupAdmin(ctx, sqlite.NewSetupService(m.DB)); err != nil {
 		return err
 	}
 
 	m.HTTPServer.Address = m.Config.HTTP.Address
 	m.HTTPServer.Port = m.Config.HTTP.Port
+	m.HTTPServer.DownloadRate = m.Config.HTTP.DownloadRate
+	m.HTTPServer.StorageRoot = m.Config.Storage.Root
+	m.HTTPServer.MaxUploadSize = m.Config.Storage.MaxUploadSize
+	m.HTTPServer.APITimeout = time.Duration(m.Config.HTTP.APITimeout) * time.Second
+	m.HTTPServer.UploadTimeout = time.Duration(m.Config.HTTP.UploadTimeout) * time.Second
+	m.HTTPServer.RequestIDFormat = m.Config.HTTP.RequestIDFormat
+	m.HTTPServer.TrustedProxies = m.Config.HTTP.TrustedProxies
+	m.HTTPServer.CORSOrigins = m.Config.HTTP.CORSOrigins
+	m.HTTPServer.CertFile = m.Config.HTTP.CertFile
+	m.HTTPServer.KeyFile = m.Config.HTTP.KeyFile
+	m.HTTPServer.SelfSigned = m.Config.HTTP.SelfSigned
+	m.HTTPServer.SecureCookies = m.Config.HTTP.Secure || m.HTTPServer.TLS()
+	m.HTTPServer.LoginLimiter.MaxAttempts = m.Config.HTTP.LoginMaxAttempts
+	m.HTTPServer.LoginLimiter.Window = time.Duration(m.Config.HTTP.LoginWindow) * time.Second
+	m.HTTPServer.LoginLimiter.ByIP = m.Config.HTTP.LoginLimitByIP
+	m.HTTPServer.RateLimiter.Rate = m.Config.HTTP.RateLimit
+	m.HTTPServer.RateLimiter.Burst = m.Config.HTTP.RateLimitBurst
+
+	if m.Config.HTTP.AccessLog {
+		m.HTTPServer.AccessLog = log.Default()
+	}
+
+	m.HTTPServer.Metrics = m.Config.HTTP.Metrics
+
+	if m.Config.HTTP.ShutdownTimeout > 0 {
+		m.HTTPServer.ShutdownTimeout = time.Duration(m.Config.HTTP.ShutdownTimeout) * time.Second
+	}
+
+	// A TTL of zero keeps the default instead of expiring sessions as soon as
+	// they are created, the same as for the session service below.
+	if m.Config.Session.TTL > 0 {
+		m.HTTPServer.SessionTTL = time.Duration(m.Config.Session.TTL) * time.Second
+	}
+
+	if m.Config.Session.RememberTTL > 0 {
+		m.HTTPServer.RememberTTL = time.Duration(m.Config.Session.RememberTTL) * time.Second
+	}
+
+	sessionService := sqlite.NewSessionService(m.DB)
+	sessionService.MaxSessions = m.Config.Session.MaxSessions
+	sessionService.MaxSessionsStrategy = m.Config.Session.MaxSessionsStrategy
+	if m.Config.Session.TTL > 0 {
+		sessionService.TTL = time.Duration(m.Config.Session.TTL) * time.Second
+	}
+
+	userService := sqlite.NewUserService(m.DB)
+	userService.UsernameReuseStrategy = m.Config.Users.UsernameReuseStrategy
 
 	m.HTTPServer.ActorService = sqlite.NewActorService(m.DB)
 	m.HTTPServer.FileService = sqlite.NewFileService(m.DB)
-	m.HTTPServer.SessionService = sqlite.NewSessionService(m.DB)
+	m.HTTPServer.FileTagService = sqlite.NewFileTagService(m.DB)
+	m.HTTPServer.FolderService = sqlite.NewFolderService(m.DB)
+	m.HTTPServer.HealthService = sqlite.NewHealthService(m.DB)
+	m.HTTPServer.MigrationService = sqlite.NewMigrationService(m.DB)
+	m.HTTPServer.SessionService = sessionService
 	m.HTTPServer.SetupService = sqlite.NewSetupService(m.DB)
+	m.HTTPServer.StatsService = sqlite.NewStatsService(m.DB)
 	m.HTTPServer.TagService = sqlite.NewTagService(m.DB)
-	m.HTTPServer.UserService = sqlite.NewUserService(m.DB)
+	m.HTTPServer.TrashService = sqlite.NewTrashService(m.DB)
+	m.HTTPServer.UserService = userService
 	m.HTTPServer.AuthService = m.AuthService
 	m.HTTPServer.PathTraversalService = m.PathTraversalService
 
@@ -183,5 +416,162 @@ func (m *Main) Run(ctx context.Context) (err error) {
 
 	log.Printf("Running: url=%q dsn=%q", m.HTTPServer.URL(), m.Config.Database.DSN)
 
+	policy := gofman.RetentionPolicy{
+		SessionMaxAge: m.Config.Retention.SessionMaxAge,
+	}
+
+	if policy.IsEnabled() {
+		go m.runRetention(ctx, sqlite.NewRetentionService(m.DB), policy)
+	}
+
+	if m.Config.Session.PurgeInterval > 0 {
+		go m.runSessionPurge(ctx, sessionService)
+	}
+
 	return nil
 }
+
+// openDB configures the database and authentication from the config and
+// opens the database.
+func (m *Main) openDB() (err error) {
+	if m.AuthService, err = auth.NewAuthServiceWithConfig(auth.ArgonConfig{
+		Time:     m.Config.Auth.ArgonTime,
+		Memory:   m.Config.Auth.ArgonMemory,
+		Threads:  m.Config.Auth.ArgonThreads,
+		KeyLen:   m.Config.Auth.ArgonKeyLen,
+		SaltLen:  m.Config.Auth.SaltLen,
+		TokenLen: m.Config.Auth.TokenLen,
+		Pepper:   []byte(m.Config.Auth.Pepper),
+	}); err != nil {
+		return err
+	}
+
+	m.DB.AuthService = m.AuthService
+	m.DB.CheckPassword = auth.PasswordPolicy{
+		MinLength:  m.Config.Auth.PasswordMinLength,
+		MinClasses: m.Config.Auth.PasswordMinClasses,
+		Blocklist:  m.Config.Auth.PasswordBlocklist,
+	}.Check
+
+	if m.DB.DSN, err = m.PathTraversalService.Expand(m.Config.Database.DSN); err != nil {
+		return err
+	}
+
+	m.DB.StorageRoot = m.Config.Storage.Root
+	m.DB.RelativePaths = m.Config.Storage.RelativePaths
+	m.DB.FileConfig = gofman.FileConfig{AllowedTypes: m.Config.Storage.AllowedTypes}
+	m.DB.SlowQueryThreshold = time.Duration(m.Config.Database.SlowQueryThreshold) * time.Millisecond
+	m.DB.BusyTimeout = time.Duration(m.Config.Database.BusyTimeout) * time.Millisecond
+	m.DB.DisableWAL = m.Config.Database.DisableWAL
+
+	return m.DB.Open()
+}
+
+// Purge permanently removes the files, actors and tags of all users that were
+// removed more than olderThan ago. If dryRun is set nothing is changed and
+// only the number of affected rows is returned. The database must be open.
+func (m *Main) Purge(ctx context.Context, olderThan time.Duration, dryRun bool) (*gofman.TrashResult, error) {
+	admin := gofman.NewContextWithUser(ctx, &gofman.User{IsAdmin: true})
+
+	return sqlite.NewTrashService(m.DB).PurgeRemoved(admin, m.DB.Now()-int64(olderThan/time.Second), dryRun)
+}
+
+// runPurgeCommand parses the flags of the purge command, opens the database
+// and runs Purge.
+func (m *Main) runPurgeCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("gofman purge", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", DefaultPurgeOlderThan, "purge content removed longer ago")
+	dryRun := fs.Bool("dry-run", false, "only count the content that would be purged")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := m.openDB(); err != nil {
+		return err
+	}
+
+	result, err := m.Purge(ctx, *olderThan, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Purged"
+	if *dryRun {
+		verb = "Would purge"
+	}
+
+	fmt.Printf("%s: files=%d folders=%d actors=%d tags=%d disk_files=%d\n", verb, result.Files, result.Folders, result.Actors, result.Tags, result.DiskFiles)
+
+	return nil
+}
+
+// setupAdmin creates the first admin from the GOFMAN_ADMIN_USERNAME and
+// GOFMAN_ADMIN_PASSWORD environment variables if the database has no users
+// yet. Nothing happens if the variables are not set.
+func (m *Main) setupAdmin(ctx context.Context, s gofman.SetupService) error {
+	username, password := os.Getenv("GOFMAN_ADMIN_USERNAME"), os.Getenv("GOFMAN_ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return nil
+	}
+
+	err := s.RunSetup(ctx, &gofman.User{Username: username, Password: password})
+	if gofman.ErrorCode(err) == gofman.ECONFLICT {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	log.Printf("Created admin from environment: username=%q", username)
+
+	return nil
+}
+
+// runRetention prunes old rows on every tick of the configured retention
+// interval until the context is cancelled.
+func (m *Main) runRetention(ctx context.Context, s gofman.RetentionService, policy gofman.RetentionPolicy) {
+	interval := time.Duration(m.Config.Retention.Interval) * time.Second
+	if interval <= 0 {
+		interval = DefaultRetentionInterval * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.Prune(ctx, policy)
+			if err != nil {
+				log.Printf("Retention failed: %v", err)
+				continue
+			}
+
+			log.Printf("Retention pruned: sessions=%d", result.Sessions)
+		}
+	}
+}
+
+// runSessionPurge deletes expired sessions on every tick of the configured
+// purge interval until the context is cancelled.
+func (m *Main) runSessionPurge(ctx context.Context, s gofman.SessionService) {
+	ticker := time.NewTicker(time.Duration(m.Config.Session.PurgeInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.PurgeExpired(ctx)
+			if err != nil {
+				log.Printf("Session purge failed: %v", err)
+				continue
+			}
+
+			log.Printf("Session purge: deleted=%d", n)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestMain_Close(t *testing.T) {
+	t.Run("NotRunning", func(t *testing.T) {
+		m := NewMain()
+
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		} else if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Running", func(t *testing.T) {
+		m := NewMain()
+		m.DB.AuthService = m.AuthService
+		m.DB.PathTraversalService = m.PathTraversalService
+		m.Config.Database.DSN = filepath.Join(t.TempDir(), "db")
+		m.Config.HTTP.Port = 0
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if err := m.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		} else if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestMain_SetupAdmin(t *testing.T) {
+	t.Setenv("GOFMAN_ADMIN_USERNAME", "admin")
+	t.Setenv("GOFMAN_ADMIN_PASSWORD", "correct-Horse-battery")
+
+	m := NewMain()
+	m.DB.AuthService = m.AuthService
+	m.DB.PathTraversalService = m.PathTraversalService
+	m.Config.Database.DSN = filepath.Join(t.TempDir(), "db")
+	m.Config.HTTP.Port = 0
+
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+	user, err := sqlite.NewUserService(m.DB).FindUserByUsername(admin, "admin")
+	if err != nil {
+		t.Fatal(err)
+	} else if !user.IsAdmin {
+		t.Fatal("Expected user to be an admin.")
+	}
+
+	// A second run against the same database keeps the existing admin.
+	if err := m.setupAdmin(context.Background(), sqlite.NewSetupService(m.DB)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMain_Run_InvalidAuthConfig(t *testing.T) {
+	m := NewMain()
+	m.Config.Database.DSN = filepath.Join(t.TempDir(), "db")
+	m.Config.Auth.ArgonThreads = 0
+
+	if err := m.Run(context.Background()); gofman.ErrorCode(err) != gofman.EINVALID {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
+
+func TestMain_Purge(t *testing.T) {
+	m := NewMain()
+	m.DB.PathTraversalService = m.PathTraversalService
+	m.Config.Database.DSN = filepath.Join(t.TempDir(), "db")
+
+	if err := m.openDB(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	m.DB.Now = func() int64 { return 1000 }
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+	user := &gofman.User{Username: "jane", Password: "correct-Horse-battery"}
+	if err := sqlite.NewUserService(m.DB).CreateUser(admin, user); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := gofman.NewContextWithUser(context.Background(), user)
+	tags := sqlite.NewTagService(m.DB)
+
+	tag := &gofman.Tag{UserID: user.ID, Name: "holiday"}
+	if err := tags.CreateTag(ctx, tag); err != nil {
+		t.Fatal(err)
+	} else if err := tags.RemoveTag(ctx, tag.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	m.DB.Now = func() int64 { return 1000 + 3600 }
+
+	if result, err := m.Purge(context.Background(), 2*time.Hour, false); err != nil {
+		t.Fatal(err)
+	} else if *result != (gofman.TrashResult{}) {
+		t.Fatalf("Unexpected result: %#v", result)
+	}
+
+	if result, err := m.Purge(context.Background(), time.Hour/2, false); err != nil {
+		t.Fatal(err)
+	} else if *result != (gofman.TrashResult{Tags: 1}) {
+		t.Fatalf("Unexpected result: %#v", result)
+	}
+}
@@ -3,9 +3,10 @@ module github.com/dhenkes/gofman
 go 1.16
 
 require (
-	github.com/google/uuid v1.2.0 // indirect
-	github.com/gorilla/mux v1.8.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.6 // indirect
-	github.com/pelletier/go-toml v1.8.1 // indirect
-	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83 // indirect
+	github.com/google/uuid v1.2.0
+	github.com/gorilla/mux v1.8.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/pelletier/go-toml v1.8.1
+	github.com/prometheus/client_golang v1.11.1
+	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83
 )
@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"golang.org/x/crypto/argon2"
+)
+
+// Auth constants.
+const (
+	ArgonTime    = 1
+	ArgonMemory  = 64 * 1024
+	ArgonThreads = 4
+	ArgonKeyLen  = 32
+	ArgonSaltLen = 16
+
+	TokenLen = 32
+)
+
+// MinTokenLen is the minimum number of random bytes of a token. Tokens are
+// base64 encoded and must be at least gofman.MinTokenLen characters long to
+// pass Session.Validate.
+const MinTokenLen = (gofman.MinTokenLen*3 + 3) / 4
+
+// ArgonConfig represents the parameters used to hash new passwords and
+// generate tokens. Memory is given in KiB, salt and token length in bytes.
+type ArgonConfig struct {
+	Time     uint32
+	Memory   uint32
+	Threads  uint8
+	KeyLen   uint32
+	SaltLen  int
+	TokenLen int
+
+	// Optional secret that is mixed into every password using HMAC-SHA256
+	// before hashing. It is not stored in the database, so a database dump
+	// alone is not enough to brute force passwords. Keys hashed with the
+	// pepper are marked, keys without the mark, like imported ones, are
+	// verified without it and reported by NeedsRehash. Changing or removing
+	// the pepper invalidates all keys hashed with it.
+	Pepper []byte
+}
+
+// DefaultArgonConfig returns the config built from the auth constants.
+func DefaultArgonConfig() ArgonConfig {
+	return ArgonConfig{
+		Time:     ArgonTime,
+		Memory:   ArgonMemory,
+		Threads:  ArgonThreads,
+		KeyLen:   ArgonKeyLen,
+		SaltLen:  ArgonSaltLen,
+		TokenLen: TokenLen,
+	}
+}
+
+// Validate returns an error if the config contains invalid parameters.
+func (c ArgonConfig) Validate() error {
+	if c.Time < 1 {
+		return gofman.NewError(gofman.EINVALID, "Argon2 time must be at least 1.")
+	}
+
+	if c.Memory == 0 {
+		return gofman.NewError(gofman.EINVALID, "Argon2 memory required.")
+	}
+
+	if c.Threads < 1 {
+		return gofman.NewError(gofman.EINVALID, "Argon2 threads must be at least 1.")
+	}
+
+	if c.KeyLen == 0 {
+		return gofman.NewError(gofman.EINVALID, "Argon2 key length required.")
+	}
+
+	if c.SaltLen < 1 {
+		return gofman.NewError(gofman.EINVALID, "Salt length required.")
+	}
+
+	if c.TokenLen < MinTokenLen {
+		return gofman.NewError(gofman.EINVALID, "Token length must be at least %d bytes.", MinTokenLen)
+	}
+
+	return nil
+}
+
+// Argon2 variants.
+const (
+	ArgonVariantI  = "argon2i"
+	ArgonVariantID = "argon2id"
+)
+
+// ArgonPepperKeyID is the key ID parameter marking keys hashed with the
+// pepper, e.g. "m=65536,t=1,p=4,keyid=pepper".
+const ArgonPepperKeyID = "pepper"
+
+// ArgonSettings is used to extract the basic hash settings from a string.
+type ArgonSettings struct {
+	Variant string
+	Version int
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+
+	// Peppered is true if the key was hashed with the pepper.
+	Peppered bool
+}
+
+// Ensure service implements interface.
+var _ gofman.PasswordHasher = (*Argon2Hasher)(nil)
+
+// Argon2Hasher represents a password hasher using Argon2id.
+type Argon2Hasher struct {
+	config ArgonConfig
+}
+
+// NewArgon2Hasher returns a new instance of Argon2Hasher.
+// Returns EINVALID if the parameters are invalid.
+func NewArgon2Hasher(config ArgonConfig) (*Argon2Hasher, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Argon2Hasher{config: config}, nil
+}
+
+// HashPassword takes a password and a salt and returns an argon2 key that
+// can be saved in a database.
+func (h *Argon2Hasher) HashPassword(password string, salt string) (string, error) {
+	if password == "" {
+		return "", gofman.NewError(gofman.EINVALID, "Password required.")
+	}
+
+	if salt == "" {
+		return "", gofman.NewError(gofman.EINVALID, "Salt required.")
+	}
+
+	hash := argon2.IDKey(
+		h.pepper(password), []byte(salt),
+		h.config.Time, h.config.Memory, h.config.Threads, h.config.KeyLen,
+	)
+
+	b64Salt := EncodeToBase64String([]byte(salt))
+	b64Hash := EncodeToBase64String(hash)
+
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", h.config.Memory, h.config.Time, h.config.Threads)
+	if len(h.config.Pepper) != 0 {
+		params += ",keyid=" + ArgonPepperKeyID
+	}
+
+	key := fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params, b64Salt, b64Hash)
+
+	return key, nil
+}
+
+// VerifyPassword takes a password and an argon2 key and compares both. It will
+// return an error if they are not equal. Keys hashed with argon2i are
+// verified with argon2i so keys imported from other tools keep working. The
+// pepper is only mixed in if the key is marked as peppered.
+func (h *Argon2Hasher) VerifyPassword(password string, key string) error {
+	if password == "" {
+		return gofman.NewError(gofman.EINVALID, "Password required.")
+	}
+
+	if key == "" {
+		return gofman.NewError(gofman.EINVALID, "Argon2 key required.")
+	}
+
+	p, salt, hash, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+
+	secret := []byte(password)
+	if p.Peppered {
+		if len(h.config.Pepper) == 0 {
+			return gofman.NewError(gofman.EINVALID, "Key was hashed with a pepper, but none is configured.")
+		}
+
+		secret = h.pepper(password)
+	}
+
+	var control []byte
+
+	switch p.Variant {
+	case ArgonVariantI:
+		control = argon2.Key(
+			secret, []byte(salt),
+			p.Time, p.Memory, p.Threads, p.KeyLen,
+		)
+	default:
+		control = argon2.IDKey(
+			secret, []byte(salt),
+			p.Time, p.Memory, p.Threads, p.KeyLen,
+		)
+	}
+
+	if subtle.ConstantTimeCompare(hash, control) == 1 {
+		return nil
+	} else {
+		return gofman.NewError(gofman.EINVALID, "Hash not equal password.")
+	}
+}
+
+// pepper returns the password mixed with the pepper. Without a pepper the
+// password is returned unchanged.
+func (h *Argon2Hasher) pepper(password string) []byte {
+	if len(h.config.Pepper) == 0 {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, h.config.Pepper)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// NeedsRehash returns true if the argon2 key was hashed with a variant other
+// than argon2id, with parameters that differ from the current settings of
+// the service or without the configured pepper. Returns an error if the key
+// is malformed.
+func (h *Argon2Hasher) NeedsRehash(key string) (bool, error) {
+	if key == "" {
+		return false, gofman.NewError(gofman.EINVALID, "Argon2 key required.")
+	}
+
+	p, _, _, err := parseKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	return p.Variant != ArgonVariantID ||
+		p.Time != h.config.Time ||
+		p.Memory != h.config.Memory ||
+		p.Threads != h.config.Threads ||
+		p.KeyLen != h.config.KeyLen ||
+		p.Peppered != (len(h.config.Pepper) != 0), nil
+}
+
+// parseKey extracts the settings, salt and hash from an argon2 key.
+func parseKey(key string) (*ArgonSettings, []byte, []byte, error) {
+	decodedKey := strings.Split(key, "$")
+	if len(decodedKey) != 6 {
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Decoded key wrong length.")
+	}
+
+	p := ArgonSettings{Variant: decodedKey[1]}
+
+	if p.Variant != ArgonVariantI && p.Variant != ArgonVariantID {
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Unsupported Argon2 variant %q.", p.Variant)
+	}
+
+	if _, err := fmt.Sscanf(decodedKey[2], "v=%d", &p.Version); err != nil {
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Invalid Argon2 version.")
+	}
+
+	if p.Version != argon2.Version {
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Argon version mismatch.")
+	}
+
+	params := strings.Split(decodedKey[3], ",")
+
+	switch {
+	case len(params) == 4 && params[3] == "keyid="+ArgonPepperKeyID:
+		p.Peppered = true
+	case len(params) != 3:
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Invalid Argon2 parameters.")
+	}
+
+	if _, err := fmt.Sscanf(strings.Join(params[:3], ","), "m=%d,t=%d,p=%d",
+		&p.Memory, &p.Time, &p.Threads,
+	); err != nil {
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Invalid Argon2 parameters.")
+	}
+
+	salt, err := DecodeBase64String(decodedKey[4])
+	if err != nil {
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Invalid Argon2 salt.")
+	}
+
+	hash, err := DecodeBase64String(decodedKey[5])
+	if err != nil {
+		return nil, nil, nil, gofman.NewError(gofman.EINVALID, "Invalid Argon2 hash.")
+	}
+
+	p.KeyLen = uint32(len(hash))
+
+	return &p, salt, hash, nil
+}
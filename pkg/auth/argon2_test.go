@@ -0,0 +1,133 @@
+package auth_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestArgon2Hasher_Pepper(t *testing.T) {
+	// password:salt, hashed without a pepper.
+	key := "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$OWwmnKFemKE2ILjM60j1so1oRXDFJYqvOiYlZTByvuU"
+
+	config := auth.DefaultArgonConfig()
+	config.Pepper = []byte("secret")
+
+	peppered, err := auth.NewArgon2Hasher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("NoPepper", func(t *testing.T) {
+		h, err := auth.NewArgon2Hasher(auth.DefaultArgonConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := h.HashPassword("password", "salt"); err != nil {
+			t.Fatal(err)
+		} else if got != key {
+			t.Fatalf("Unexpected key: %s", got)
+		}
+	})
+
+	t.Run("Pepper", func(t *testing.T) {
+		got, err := peppered.HashPassword("password", "salt")
+		if err != nil {
+			t.Fatal(err)
+		} else if got == key {
+			t.Fatal("Expected pepper to change the key.")
+		} else if !strings.HasPrefix(got, "$argon2id$v=19$m=65536,t=1,p=4,keyid=pepper$") {
+			t.Fatalf("Expected key to be marked as peppered: %s", got)
+		}
+
+		if err := peppered.VerifyPassword("password", got); err != nil {
+			t.Fatal(err)
+		} else if err := peppered.VerifyPassword("password1", got); err == nil {
+			t.Fatal("Expected error.")
+		}
+
+		if ok, err := peppered.NeedsRehash(got); err != nil {
+			t.Fatal(err)
+		} else if ok {
+			t.Fatal("Expected peppered key not to need a rehash.")
+		}
+	})
+
+	t.Run("Unpeppered", func(t *testing.T) {
+		// Keys hashed before the pepper was configured or imported from
+		// other tools are verified without it and need a rehash.
+		if err := peppered.VerifyPassword("password", key); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := peppered.NeedsRehash(key); err != nil {
+			t.Fatal(err)
+		} else if !ok {
+			t.Fatal("Expected key without pepper to need a rehash.")
+		}
+	})
+
+	t.Run("Rotated", func(t *testing.T) {
+		got, err := peppered.HashPassword("password", "salt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config := auth.DefaultArgonConfig()
+		config.Pepper = []byte("rotated")
+
+		rotated, err := auth.NewArgon2Hasher(config)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := rotated.VerifyPassword("password", got); err == nil {
+			t.Fatal("Expected keys hashed with another pepper to fail.")
+		}
+
+		h, err := auth.NewArgon2Hasher(auth.DefaultArgonConfig())
+		if err != nil {
+			t.Fatal(err)
+		} else if err := h.VerifyPassword("password", got); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestArgon2Hasher_Variants(t *testing.T) {
+	h, err := auth.NewArgon2Hasher(auth.DefaultArgonConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// password:salt, hashed with argon2i as another tool would.
+	hash := argon2.Key([]byte("password"), []byte("salt"), 1, 1024, 1, 32)
+	key := fmt.Sprintf("$argon2i$v=19$m=1024,t=1,p=1$%s$%s",
+		auth.EncodeToBase64String([]byte("salt")), auth.EncodeToBase64String(hash),
+	)
+
+	t.Run("Argon2i", func(t *testing.T) {
+		if err := h.VerifyPassword("password", key); err != nil {
+			t.Fatal(err)
+		} else if err := h.VerifyPassword("password1", key); err == nil {
+			t.Fatal("Expected error.")
+		}
+
+		if ok, err := h.NeedsRehash(key); err != nil {
+			t.Fatal(err)
+		} else if !ok {
+			t.Fatal("Expected argon2i key to need a rehash.")
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		for _, variant := range []string{"argon2d", "scrypt"} {
+			if err := h.VerifyPassword("password", "$"+variant+"$v=19$m=1024,t=1,p=1$c2FsdA$c2FsdA"); gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Unexpected error for %s: %#v", variant, err)
+			}
+		}
+	})
+}
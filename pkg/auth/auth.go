@@ -2,47 +2,63 @@ package auth
 
 import (
 	"crypto/rand"
-	"crypto/subtle"
 	"encoding/base64"
-	"fmt"
-	"strings"
+	"sync"
 
 	"github.com/dhenkes/gofman/pkg/gofman"
-	"golang.org/x/crypto/argon2"
 )
 
-// Auth constants.
-const (
-	ArgonTime    = 1
-	ArgonMemory  = 64 * 1024
-	ArgonThreads = 4
-	ArgonKeyLen  = 32
-)
-
-// ArgonSettings is used to extract the basic hash settings from a string.
-type ArgonSettings struct {
-	Version int
-	Time    uint32
-	Memory  uint32
-	Threads uint8
-	KeyLen  uint32
-}
-
 // Ensure service implements interface.
 var _ gofman.AuthService = (*AuthService)(nil)
 
 // AuthService represents a service for managing authentication.
-type AuthService struct{}
+type AuthService struct {
+	// Hasher is used to hash and verify passwords. Defaults to an
+	// Argon2Hasher.
+	Hasher gofman.PasswordHasher
+
+	// Length of generated salts and tokens in bytes. Tokens shorter than
+	// MinTokenLen fail Session.Validate.
+	SaltLen  int
+	TokenLen int
+
+	// Key used by VerifyPasswordDummy. Created on first use with the
+	// current hasher so the dummy verification costs as much as a real one.
+	dummyOnce sync.Once
+	dummyKey  string
+	dummyErr  error
+}
 
-// NewAuthService returns a new instance of AuthService.
+// NewAuthService returns a new instance of AuthService using the default
+// Argon2 parameters.
 func NewAuthService() *AuthService {
-	return &AuthService{}
+	return &AuthService{
+		Hasher:   &Argon2Hasher{config: DefaultArgonConfig()},
+		SaltLen:  ArgonSaltLen,
+		TokenLen: TokenLen,
+	}
+}
+
+// NewAuthServiceWithConfig returns a new instance of AuthService using the
+// given Argon2 parameters.
+// Returns EINVALID if the parameters are invalid.
+func NewAuthServiceWithConfig(config ArgonConfig) (*AuthService, error) {
+	hasher, err := NewArgon2Hasher(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthService{
+		Hasher:   hasher,
+		SaltLen:  config.SaltLen,
+		TokenLen: config.TokenLen,
+	}, nil
 }
 
 // GenerateRandomBytes is a helper function that is used by NewToken,
 // NewPassword and NewSalt. It returns securely generated random bytes.
 func GenerateRandomBytes(n int) ([]byte, error) {
-	if n < -1 {
+	if n < 0 {
 		return nil, gofman.NewError(gofman.EINTERNAL, "Length must be a positive int.")
 	}
 
@@ -67,7 +83,7 @@ func DecodeBase64String(s string) ([]byte, error) {
 
 // NewToken generates a new token that can be used as a session-key.
 func (s *AuthService) NewToken() (string, error) {
-	if b, err := GenerateRandomBytes(32); err != nil {
+	if b, err := GenerateRandomBytes(s.TokenLen); err != nil {
 		return "", err
 	} else {
 		return EncodeToBase64String(b), nil
@@ -87,92 +103,73 @@ func (s *AuthService) NewPassword() (string, error) {
 // NewSalt generates a secure salt that can be used in combination with the
 // HashPassword function.
 func (s *AuthService) NewSalt() (string, error) {
-	if b, err := GenerateRandomBytes(16); err != nil {
+	if b, err := GenerateRandomBytes(s.SaltLen); err != nil {
 		return "", err
 	} else {
 		return EncodeToBase64String(b), nil
 	}
 }
 
-// HashPassword takes a password and a salt and returns an argon2 key that
-// can be saved in a database.
+// HashPassword takes a password and a salt and returns a key that can be
+// saved in a database.
 func (s *AuthService) HashPassword(password string, salt string) (string, error) {
-	if password == "" {
-		return "", gofman.NewError(gofman.EINVALID, "Password required.")
-	}
-
-	if salt == "" {
-		return "", gofman.NewError(gofman.EINVALID, "Salt required.")
-	}
-
-	hash := argon2.IDKey(
-		[]byte(password), []byte(salt),
-		ArgonTime, ArgonMemory, ArgonThreads, ArgonKeyLen,
-	)
-
-	b64Salt := EncodeToBase64String([]byte(salt))
-	b64Hash := EncodeToBase64String(hash)
-
-	key := fmt.Sprintf(
-		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
-		argon2.Version, ArgonMemory, ArgonTime, ArgonThreads, b64Salt, b64Hash,
-	)
-
-	return key, nil
+	return s.Hasher.HashPassword(password, salt)
 }
 
-// VerifyPassword takes a password and an argon2 key and compares both. It will
-// return an error if they are not equal.
+// VerifyPassword takes a password and a key and compares both. It will
+// return an error if they are not equal. Bcrypt keys are always verified with
+// bcrypt so databases migrated from bcrypt keep working.
 func (s *AuthService) VerifyPassword(password string, key string) error {
-	if password == "" {
-		return gofman.NewError(gofman.EINVALID, "Password required.")
+	if _, ok := s.Hasher.(*BcryptHasher); !ok && IsBcryptKey(key) {
+		return (&BcryptHasher{}).VerifyPassword(password, key)
 	}
 
-	if key == "" {
-		return gofman.NewError(gofman.EINVALID, "Argon2 key required.")
-	}
+	return s.Hasher.VerifyPassword(password, key)
+}
 
-	decodedKey := strings.Split(key, "$")
-	if len(decodedKey) != 6 {
-		return gofman.NewError(gofman.EINVALID, "Decoded key wrong length.")
+// NeedsRehash returns true if the key was hashed with settings that differ
+// from the current settings of the hasher or, if the hasher is not bcrypt,
+// the key is a bcrypt key. Hashers that do not support rehashing never report
+// outdated keys.
+func (s *AuthService) NeedsRehash(key string) (bool, error) {
+	if _, ok := s.Hasher.(*BcryptHasher); !ok && IsBcryptKey(key) {
+		return true, nil
 	}
 
-	p := ArgonSettings{}
-
-	if _, err := fmt.Sscanf(decodedKey[2], "v=%d", &p.Version); err != nil {
-		return err
+	if h, ok := s.Hasher.(interface {
+		NeedsRehash(key string) (bool, error)
+	}); ok {
+		return h.NeedsRehash(key)
 	}
 
-	if p.Version != argon2.Version {
-		return gofman.NewError(gofman.EINVALID, "Argon version mismatch.")
-	}
+	return false, nil
+}
 
-	if _, err := fmt.Sscanf(decodedKey[3], "m=%d,t=%d,p=%d",
-		&p.Memory, &p.Time, &p.Threads,
-	); err != nil {
-		return err
+// VerifyUserPassword verifies the password of a user found during login. If
+// the user is nil, VerifyPasswordDummy is run instead so unknown usernames
+// take as long to reject as wrong passwords.
+func (s *AuthService) VerifyUserPassword(user *gofman.User, password string) error {
+	if user == nil {
+		return s.VerifyPasswordDummy(password)
 	}
 
-	salt, err := DecodeBase64String(decodedKey[4])
-	if err != nil {
-		return err
-	}
+	return s.VerifyPassword(password, user.Password)
+}
 
-	hash, err := DecodeBase64String(decodedKey[5])
-	if err != nil {
-		return err
-	}
+// VerifyPasswordDummy runs a full password verification against a fixed
+// dummy key and always returns an error. It is meant for logins with an
+// unknown username, so they do not reveal which usernames exist by being
+// faster than logins with a wrong password.
+func (s *AuthService) VerifyPasswordDummy(password string) error {
+	s.dummyOnce.Do(func() {
+		s.dummyKey, s.dummyErr = s.Hasher.HashPassword("gofman-dummy-password", "gofman-dummy-salt")
+	})
 
-	p.KeyLen = uint32(len(hash))
+	if s.dummyErr != nil {
+		return s.dummyErr
+	}
 
-	control := argon2.IDKey(
-		[]byte(password), []byte(salt),
-		p.Time, p.Memory, p.Threads, p.KeyLen,
-	)
+	s.VerifyPassword(password, s.dummyKey)
 
-	if subtle.ConstantTimeCompare(hash, control) == 1 {
-		return nil
-	} else {
-		return gofman.NewError(gofman.EINVALID, "Hash not equal password.")
-	}
+	return gofman.NewError(gofman.EINVALID, "Hash not equal password.")
 }
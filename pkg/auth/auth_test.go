@@ -2,23 +2,40 @@ package auth_test
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
 )
 
 func TestGenerateRandomBytes(t *testing.T) {
-	t.Run("InvalidInt", func(t *testing.T) {
-		if _, err := auth.GenerateRandomBytes(-10); err == nil {
-			t.Fatal("Expected to fail.")
-		}
-	})
+	for _, tt := range []struct {
+		name string
+		n    int
+		code string
+	}{
+		{name: "Negative", n: -10, code: gofman.EINTERNAL},
+		{name: "MinusOne", n: -1, code: gofman.EINTERNAL},
+		{name: "Zero", n: 0},
+		{name: "One", n: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := auth.GenerateRandomBytes(tt.n)
+			if tt.code != "" {
+				if gofman.ErrorCode(err) != tt.code {
+					t.Fatalf("Unexpected error: %#v", err)
+				}
+				return
+			}
 
-	t.Run("ValidInt", func(t *testing.T) {
-		if _, err := auth.GenerateRandomBytes(1); err != nil {
-			t.Fatal("Did not expect to fail.")
-		}
-	})
+			if err != nil {
+				t.Fatal(err)
+			} else if len(b) != tt.n {
+				t.Fatalf("Unexpected length: %d", len(b))
+			}
+		})
+	}
 }
 
 func TestEncodeToBase64String(t *testing.T) {
@@ -111,6 +128,57 @@ func TestNewSalt(t *testing.T) {
 	})
 }
 
+func TestNewAuthServiceWithConfig(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for name, fn := range map[string]func(*auth.ArgonConfig){
+			"Time":     func(c *auth.ArgonConfig) { c.Time = 0 },
+			"Memory":   func(c *auth.ArgonConfig) { c.Memory = 0 },
+			"Threads":  func(c *auth.ArgonConfig) { c.Threads = 0 },
+			"KeyLen":   func(c *auth.ArgonConfig) { c.KeyLen = 0 },
+			"SaltLen":  func(c *auth.ArgonConfig) { c.SaltLen = 0 },
+			"TokenLen": func(c *auth.ArgonConfig) { c.TokenLen = auth.MinTokenLen - 1 },
+		} {
+			config := auth.DefaultArgonConfig()
+			fn(&config)
+
+			if _, err := auth.NewAuthServiceWithConfig(config); gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Unexpected error for %s: %#v", name, err)
+			}
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		s, err := auth.NewAuthServiceWithConfig(auth.ArgonConfig{Time: 2, Memory: 1024, Threads: 1, KeyLen: 16, SaltLen: 32, TokenLen: 32})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := s.HashPassword("password", "salt")
+		if err != nil {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(key, "$argon2id$v=19$m=1024,t=2,p=1$") {
+			t.Fatalf("Unexpected key: %s", key)
+		}
+
+		if err := s.VerifyPassword("password", key); err != nil {
+			t.Fatal(err)
+		}
+
+		// Keys hashed with the default parameters still verify.
+		if err := s.VerifyPassword("password", "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$OWwmnKFemKE2ILjM60j1so1oRXDFJYqvOiYlZTByvuU"); err != nil {
+			t.Fatal(err)
+		}
+
+		if salt, err := s.NewSalt(); err != nil {
+			t.Fatal(err)
+		} else if b, err := auth.DecodeBase64String(salt); err != nil {
+			t.Fatal(err)
+		} else if len(b) != 32 {
+			t.Fatalf("Unexpected salt length: %d", len(b))
+		}
+	})
+}
+
 func TestHashPassword(t *testing.T) {
 	s := auth.NewAuthService()
 
@@ -196,3 +264,175 @@ func TestVerifyPassword(t *testing.T) {
 		})
 	})
 }
+
+func TestNeedsRehash(t *testing.T) {
+	// password:salt, hashed with the default parameters.
+	key := "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$OWwmnKFemKE2ILjM60j1so1oRXDFJYqvOiYlZTByvuU"
+
+	t.Run("Current", func(t *testing.T) {
+		if ok, err := auth.NewAuthService().NeedsRehash(key); err != nil {
+			t.Fatal(err)
+		} else if ok {
+			t.Fatal("Expected no rehash.")
+		}
+	})
+
+	t.Run("Outdated", func(t *testing.T) {
+		config := auth.DefaultArgonConfig()
+		config.Time = 3
+
+		s, err := auth.NewAuthServiceWithConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := s.NeedsRehash(key); err != nil {
+			t.Fatal(err)
+		} else if !ok {
+			t.Fatal("Expected rehash.")
+		}
+
+		if rehashed, err := s.HashPassword("password", "salt"); err != nil {
+			t.Fatal(err)
+		} else if ok, err := s.NeedsRehash(rehashed); err != nil {
+			t.Fatal(err)
+		} else if ok {
+			t.Fatal("Expected no rehash after rehashing.")
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		s := auth.NewAuthService()
+
+		for _, key := range []string{
+			"",
+			"$argon2id$v=19",
+			"$argon2id$v=19$m=x,t=1,p=4$c2FsdA$c2FsdA",
+			"$argon2id$v=19$m=65536,t=1,p=4$!$c2FsdA",
+		} {
+			if _, err := s.NeedsRehash(key); gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Unexpected error for %q: %#v", key, err)
+			}
+		}
+	})
+}
+
+// plainHasher is a trivial hasher used to test that AuthService delegates to
+// its Hasher.
+type plainHasher struct{}
+
+func (plainHasher) HashPassword(password string, salt string) (string, error) {
+	return salt + ":" + password, nil
+}
+
+func (plainHasher) VerifyPassword(password string, key string) error {
+	if !strings.HasSuffix(key, ":"+password) {
+		return gofman.NewError(gofman.EINVALID, "Hash not equal password.")
+	}
+	return nil
+}
+
+func TestAuthService_Hasher(t *testing.T) {
+	s := auth.NewAuthService()
+	s.Hasher = plainHasher{}
+
+	if key, err := s.HashPassword("password", "salt"); err != nil {
+		t.Fatal(err)
+	} else if key != "salt:password" {
+		t.Fatalf("Unexpected key: %s", key)
+	}
+
+	if err := s.VerifyPassword("password", "salt:password"); err != nil {
+		t.Fatal(err)
+	} else if err := s.VerifyPassword("password1", "salt:password"); err == nil {
+		t.Fatal("Expected error.")
+	}
+
+	// Hashers without NeedsRehash never require a rehash.
+	if ok, err := s.NeedsRehash("salt:password"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("Expected no rehash.")
+	}
+}
+
+func TestAuthService_Lengths(t *testing.T) {
+	config := auth.DefaultArgonConfig()
+	config.SaltLen = 24
+	config.TokenLen = auth.MinTokenLen
+
+	s, err := auth.NewAuthServiceWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if salt, err := s.NewSalt(); err != nil {
+		t.Fatal(err)
+	} else if b, err := auth.DecodeBase64String(salt); err != nil {
+		t.Fatal(err)
+	} else if len(b) != 24 {
+		t.Fatalf("Unexpected salt length: %d", len(b))
+	}
+
+	// The shortest allowed token must still be a valid session token.
+	token, err := s.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := &gofman.Session{UserID: "1", Token: token, ExpiresAt: 1}
+	if err := session.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// countingHasher counts the calls to VerifyPassword.
+type countingHasher struct {
+	plainHasher
+	verified int
+}
+
+func (h *countingHasher) VerifyPassword(password string, key string) error {
+	h.verified++
+	return h.plainHasher.VerifyPassword(password, key)
+}
+
+func TestAuthService_VerifyUserPassword(t *testing.T) {
+	h := &countingHasher{}
+
+	s := auth.NewAuthService()
+	s.Hasher = h
+
+	user := &gofman.User{Username: "jane", Password: "salt:password"}
+
+	t.Run("KnownUser", func(t *testing.T) {
+		if err := s.VerifyUserPassword(user, "password"); err != nil {
+			t.Fatal(err)
+		} else if h.verified != 1 {
+			t.Fatalf("Expected one verification, got %d", h.verified)
+		}
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		if err := s.VerifyUserPassword(nil, "password"); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if h.verified != 2 {
+			t.Fatalf("Expected dummy verification, got %d", h.verified)
+		}
+	})
+
+	t.Run("DummyPassword", func(t *testing.T) {
+		// The dummy key must never authenticate, not even its own password.
+		if err := s.VerifyPasswordDummy("gofman-dummy-password"); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestAuthService_VerifyPasswordDummy_Argon2(t *testing.T) {
+	s := auth.NewAuthService()
+
+	if err := s.VerifyPasswordDummy("password"); gofman.ErrorCode(err) != gofman.EINVALID {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt constants.
+const (
+	BcryptCost = bcrypt.DefaultCost
+
+	// Bcrypt only uses the first 72 bytes of a password.
+	BcryptMaxPasswordLen = 72
+)
+
+// Ensure service implements interface.
+var _ gofman.PasswordHasher = (*BcryptHasher)(nil)
+
+// BcryptHasher represents a password hasher using bcrypt. It is mainly meant
+// for databases migrated from systems that stored bcrypt hashes.
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher returns a new instance of BcryptHasher.
+// Returns EINVALID if the cost is out of range.
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, gofman.NewError(gofman.EINVALID, "Bcrypt cost must be between %d and %d.", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	return &BcryptHasher{cost: cost}, nil
+}
+
+// HashPassword takes a password and returns a bcrypt key that can be saved
+// in a database. The salt is ignored as bcrypt generates and embeds its own.
+func (h *BcryptHasher) HashPassword(password string, salt string) (string, error) {
+	if password == "" {
+		return "", gofman.NewError(gofman.EINVALID, "Password required.")
+	}
+
+	if len(password) > BcryptMaxPasswordLen {
+		return "", gofman.NewError(gofman.EINVALID, "Password must not be longer than %d bytes.", BcryptMaxPasswordLen)
+	}
+
+	key, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+// VerifyPassword takes a password and a bcrypt key and compares both. It will
+// return an error if they are not equal.
+func (h *BcryptHasher) VerifyPassword(password string, key string) error {
+	if password == "" {
+		return gofman.NewError(gofman.EINVALID, "Password required.")
+	}
+
+	if !IsBcryptKey(key) {
+		return gofman.NewError(gofman.EINVALID, "Bcrypt key required.")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(key), []byte(password)); err != nil {
+		return gofman.NewError(gofman.EINVALID, "Hash not equal password.")
+	}
+
+	return nil
+}
+
+// NeedsRehash returns true if the bcrypt key was hashed with a different
+// cost or is not a bcrypt key at all.
+func (h *BcryptHasher) NeedsRehash(key string) (bool, error) {
+	if !IsBcryptKey(key) {
+		return true, nil
+	}
+
+	cost, err := bcrypt.Cost([]byte(key))
+	if err != nil {
+		return false, gofman.NewError(gofman.EINVALID, "Invalid bcrypt key.")
+	}
+
+	return cost != h.cost, nil
+}
+
+// IsBcryptKey returns true if the key has the prefix of a bcrypt hash.
+func IsBcryptKey(key string) bool {
+	return strings.HasPrefix(key, "$2a$") ||
+		strings.HasPrefix(key, "$2b$") ||
+		strings.HasPrefix(key, "$2y$")
+}
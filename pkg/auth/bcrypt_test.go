@@ -0,0 +1,90 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// password, cost 4
+const bcryptKey = "$2a$04$BBJnkl3Ft9/sH7LAbMkpv.q2xojk.GdyoG7p5T6178CYpGD0P3Pzq"
+
+func TestBcryptHasher(t *testing.T) {
+	h, err := auth.NewBcryptHasher(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("InvalidCost", func(t *testing.T) {
+		if _, err := auth.NewBcryptHasher(100); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("KnownKey", func(t *testing.T) {
+		if err := h.VerifyPassword("password", bcryptKey); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		if err := h.VerifyPassword("password1", bcryptKey); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("NotBcrypt", func(t *testing.T) {
+		if err := h.VerifyPassword("password", "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$c2FsdA"); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("HashPassword", func(t *testing.T) {
+		key, err := h.HashPassword("password", "ignored")
+		if err != nil {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(key, "$2a$04$") {
+			t.Fatalf("Unexpected key: %s", key)
+		} else if err := h.VerifyPassword("password", key); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := h.HashPassword(strings.Repeat("a", 73), ""); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("NeedsRehash", func(t *testing.T) {
+		if ok, err := h.NeedsRehash(bcryptKey); err != nil {
+			t.Fatal(err)
+		} else if ok {
+			t.Fatal("Expected no rehash.")
+		}
+
+		if h, err := auth.NewBcryptHasher(5); err != nil {
+			t.Fatal(err)
+		} else if ok, err := h.NeedsRehash(bcryptKey); err != nil {
+			t.Fatal(err)
+		} else if !ok {
+			t.Fatal("Expected rehash.")
+		}
+	})
+}
+
+func TestAuthService_MixedKeys(t *testing.T) {
+	s := auth.NewAuthService()
+
+	if err := s.VerifyPassword("password", bcryptKey); err != nil {
+		t.Fatal(err)
+	} else if err := s.VerifyPassword("password1", bcryptKey); err == nil {
+		t.Fatal("Expected error.")
+	}
+
+	if ok, err := s.NeedsRehash(bcryptKey); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("Expected bcrypt key to need a rehash.")
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"math/big"
+	"strings"
+)
+
+// Passphrase constants.
+const (
+	// Number of words used by NewPassphrase if no number is given.
+	DefaultPassphraseWords = 4
+
+	// Minimum number of words used by NewPassphrase. The shortest words of
+	// the list have three letters, so three words joined by hyphens are
+	// always longer than gofman.MinPasswordLen.
+	MinPassphraseWords = 3
+)
+
+// wordlist is the EFF short wordlist, one word per line. See
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases.
+//
+//go:embed wordlist.txt
+var wordlist string
+
+// words holds the parsed wordlist.
+var words = strings.Fields(wordlist)
+
+// NewPassphrase generates a memorable password by joining randomly picked
+// words with hyphens. It is meant for temporary passwords that have to be
+// read out loud. Uses DefaultPassphraseWords if n is not positive and at
+// least MinPassphraseWords.
+func (s *AuthService) NewPassphrase(n int) (string, error) {
+	if n <= 0 {
+		n = DefaultPassphraseWords
+	} else if n < MinPassphraseWords {
+		n = MinPassphraseWords
+	}
+
+	max := big.NewInt(int64(len(words)))
+	picked := make([]string, n)
+
+	for i := range picked {
+		j, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		picked[i] = words[j.Int64()]
+	}
+
+	return strings.Join(picked, "-"), nil
+}
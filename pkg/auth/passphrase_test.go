@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestNewPassphrase(t *testing.T) {
+	s := auth.NewAuthService()
+
+	for _, tt := range []struct {
+		name  string
+		n     int
+		words int
+	}{
+		{name: "Default", n: 0, words: auth.DefaultPassphraseWords},
+		{name: "Negative", n: -1, words: auth.DefaultPassphraseWords},
+		{name: "One", n: 1, words: auth.MinPassphraseWords},
+		{name: "Six", n: 6, words: 6},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			passphrase, err := s.NewPassphrase(tt.n)
+			if err != nil {
+				t.Fatal(err)
+			} else if n := len(strings.Split(passphrase, "-")); n != tt.words {
+				t.Fatalf("Unexpected number of words: %d", n)
+			}
+
+			user := &gofman.User{Username: "jane", Password: passphrase}
+			if err := user.Validate(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("Random", func(t *testing.T) {
+		seen := make(map[string]bool)
+
+		for i := 0; i < 20; i++ {
+			passphrase, err := s.NewPassphrase(0)
+			if err != nil {
+				t.Fatal(err)
+			} else if seen[passphrase] {
+				t.Fatalf("Duplicate passphrase: %s", passphrase)
+			}
+
+			seen[passphrase] = true
+		}
+	})
+}
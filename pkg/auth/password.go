@@ -0,0 +1,92 @@
+package auth
+
+import (
+	_ "embed"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// commonPasswords is a small list of the most common passwords, one per line.
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// blocklist holds the parsed common passwords.
+var blocklist = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, password := range strings.Fields(commonPasswords) {
+		m[password] = true
+	}
+	return m
+}()
+
+// PasswordPolicy represents the rules a new password has to follow. A zero
+// value policy accepts every password.
+type PasswordPolicy struct {
+	// Minimum number of characters.
+	MinLength int
+
+	// Minimum number of character classes out of lowercase letters,
+	// uppercase letters, digits and symbols.
+	MinClasses int
+
+	// If set, common passwords are rejected regardless of case.
+	Blocklist bool
+}
+
+// DefaultPasswordPolicy returns the policy used by CheckPasswordStrength.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:  10,
+		MinClasses: 2,
+		Blocklist:  true,
+	}
+}
+
+// CheckPasswordStrength returns an error if the password does not follow the
+// default password policy.
+func CheckPasswordStrength(password string) error {
+	return DefaultPasswordPolicy().Check(password)
+}
+
+// Check returns an EINVALID error if the password does not follow the
+// policy.
+func (p PasswordPolicy) Check(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return gofman.NewError(gofman.EINVALID, "Password must have at least %d characters.", p.MinLength)
+	}
+
+	if passwordClasses(password) < p.MinClasses {
+		return gofman.NewError(gofman.EINVALID, "Password must contain at least %d of lowercase letters, uppercase letters, digits and symbols.", p.MinClasses)
+	}
+
+	if p.Blocklist && blocklist[strings.ToLower(password)] {
+		return gofman.NewError(gofman.EINVALID, "Password is too common.")
+	}
+
+	return nil
+}
+
+// passwordClasses returns the number of character classes used in the
+// password.
+func passwordClasses(password string) int {
+	var lower, upper, digit, symbol int
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,49 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestCheckPasswordStrength(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		password string
+		ok       bool
+	}{
+		{name: "TooShort", password: "1234567"},
+		{name: "OneClass", password: "abcdefghijkl"},
+		{name: "Common", password: "Password123"},
+		{name: "CommonUpperCase", password: "PASSWORD123"},
+		{name: "Passphrase", password: "apple-bamboo-cactus-dawn", ok: true},
+		{name: "Mixed", password: "Tr0ub4dor&3", ok: true},
+		{name: "Unicode", password: "überlänge-Wörter", ok: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.CheckPasswordStrength(tt.password)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			} else if !tt.ok && gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Unexpected error: %#v", err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	t.Run("Relaxed", func(t *testing.T) {
+		if err := (auth.PasswordPolicy{}).Check("password"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("BlocklistOnly", func(t *testing.T) {
+		if err := (auth.PasswordPolicy{Blocklist: true}).Check("letmein"); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if gofman.ErrorMessage(err) != "Password is too common." {
+			t.Fatalf("Unexpected message: %s", gofman.ErrorMessage(err))
+		}
+	})
+}
@@ -40,12 +40,12 @@ func (t *Actor) Validate() error {
 // the given filter.
 func CanFindActor(ctx context.Context, filter ActorFilter) bool {
   id := UserIDFromContext(ctx)
-  return id != "" && filter.UserID == &id
+  return id != "" && filter.UserID != nil && *filter.UserID == id
 }
 
 // CanUpdateActor returns true if the current user can update the actor.
 func CanUpdateActor(ctx context.Context, actor *Actor) bool {
-  if user := UserFromContext(ctx); user != nil && user.IsDemo {
+  if CanUpload(ctx) == false {
     return false
   } else {
     id := UserIDFromContext(ctx)
@@ -62,6 +62,9 @@ type ActorService interface {
   CreateActor(ctx context.Context, actor *Actor) error
   UpdateActor(ctx context.Context, id string, update ActorUpdate) (*Actor, error)
   RemoveActor(ctx context.Context, id string) error
+  RestoreActor(ctx context.Context, id string) error
+  EnsureActorByName(ctx context.Context, name string) (*Actor, error)
+  Exists(ctx context.Context, id string) (bool, error)
 }
 
 // ActorFilter represents a filter passed to FindActors().
@@ -69,6 +72,23 @@ type ActorFilter struct {
   ID     *string `json:"id"`
   UserID *string `json:"users_id"`
 
+  // Only actors whose name contains NameLike, ignoring ASCII case.
+  NameLike *string `json:"name_like"`
+
+  // Only actors created within the range, as unix timestamps. Both bounds
+  // are inclusive and optional.
+  CreatedAfter  *int64 `json:"created_after"`
+  CreatedBefore *int64 `json:"created_before"`
+
+  // Sort column and direction. SortBy must be one of name, created_at or
+  // updated_at. Defaults to created_at.
+  SortBy   string `json:"sort_by"`
+  SortDesc bool   `json:"sort_desc"`
+
+  // Also find removed actors, for example to restore them.
+  IncludeRemoved bool `json:"include_removed"`
+
+  // Paging. Limit defaults to DefaultLimit and is capped at MaxLimit.
   Offset int `json:"offset"`
   Limit  int `json:"limit"`
 }
@@ -1,11 +1,22 @@
 package gofman
 
+// PasswordHasher represents a service for hashing and verifying passwords.
+type PasswordHasher interface {
+	HashPassword(password string, salt string) (string, error)
+	VerifyPassword(password string, hash string) error
+}
+
 // AuthService represents a service for managing authentication. It should be
 // used for creating, hasing and comparing passwords and tokens.
 type AuthService interface {
+	PasswordHasher
+
 	NewToken() (string, error)
 	NewPassword() (string, error)
 	NewSalt() (string, error)
-	HashPassword(password string, salt string) (string, error)
-	VerifyPassword(password string, hash string) error
+
+	// VerifyUserPassword verifies the password of the user found during
+	// login. It runs a dummy verification if user is nil so unknown
+	// usernames cannot be told apart by timing.
+	VerifyUserPassword(user *User, password string) error
 }
@@ -1,33 +1,103 @@
 package gofman
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 // Application error codes.
 const (
-	ECONFLICT       = "conflict"
-	EINTERNAL       = "internal"
-	EINVALID        = "invalid"
-	ENOTFOUND       = "not_found"
-	ENOTIMPLEMENTED = "not_implemented"
-	EUNAUTHORIZED   = "unauthorized"
+	ECONFLICT        = "conflict"
+	EFORBIDDEN       = "forbidden"
+	EINTERNAL        = "internal"
+	EINVALID         = "invalid"
+	ENOTFOUND        = "not_found"
+	ENOTIMPLEMENTED  = "not_implemented"
+	ETIMEOUT         = "timeout"
+	ETOOMANYREQUESTS = "too_many_requests"
+	EUNAUTHORIZED    = "unauthorized"
 )
 
+// errorStatusCodes maps application error codes to HTTP status codes.
+var errorStatusCodes = map[string]int{
+	ECONFLICT:        http.StatusConflict,
+	EINVALID:         http.StatusUnprocessableEntity,
+	ENOTFOUND:        http.StatusNotFound,
+	ENOTIMPLEMENTED:  http.StatusNotImplemented,
+	EUNAUTHORIZED:    http.StatusUnauthorized,
+	EFORBIDDEN:       http.StatusForbidden,
+	ETOOMANYREQUESTS: http.StatusTooManyRequests,
+	ETIMEOUT:         http.StatusServiceUnavailable,
+	EINTERNAL:        http.StatusInternalServerError,
+}
+
 // Error represents an application-specific error.
 // Any non-application error (disk error, ram error, etc.) will be reported as
 // internal error, only logged and not exposed to the end-user.
 type Error struct {
 	Code    string
 	Message string
+
+	// Underlying cause. It is only meant for logging and is never exposed to
+	// the end-user.
+	Err error
 }
 
 // Error implements the error interface.
 func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("gofman error: code=%s message=%s err=%v", e.Code, e.Message, e.Err)
+	}
+
 	return fmt.Sprintf("gofman error: code=%s message=%s", e.Code, e.Message)
 }
 
+// Unwrap returns the underlying cause, if any, so errors.Is and errors.As
+// can see through the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorJSON represents the JSON structure of an Error.
+type errorJSON struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(errorJSON{Code: e.Code, Message: e.Message})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var v errorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	e.Code, e.Message = v.Code, v.Message
+
+	return nil
+}
+
+// ErrorFromJSON returns the Error encoded in data. Returns an internal error if
+// data is not a valid JSON encoded Error.
+func ErrorFromJSON(data []byte) error {
+	var e Error
+	if err := json.Unmarshal(data, &e); err != nil {
+		return NewError(EINTERNAL, "Invalid error JSON: %v", err)
+	}
+
+	if e.Code == "" {
+		return NewError(EINTERNAL, "Error code required.")
+	}
+
+	return &e
+}
+
 // ErrorCode returns the application error code.
 func ErrorCode(err error) string {
 	var e *Error
@@ -52,6 +122,16 @@ func ErrorMessage(err error) string {
 	}
 }
 
+// ErrorStatusCode returns the HTTP status code of the application error code.
+// Unknown codes are reported as internal server error.
+func ErrorStatusCode(code string) int {
+	if status, ok := errorStatusCodes[code]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
 // NewError is a helper function to return an Error with a given code and formatted message.
 func NewError(code string, format string, args ...interface{}) *Error {
 	return &Error{
@@ -59,3 +139,11 @@ func NewError(code string, format string, args ...interface{}) *Error {
 		Message: fmt.Sprintf(format, args...),
 	}
 }
+
+// NewWrappedError is a helper function to return an Error with a given code
+// and formatted message that wraps err.
+func NewWrappedError(code string, err error, format string, args ...interface{}) *Error {
+	e := NewError(code, format, args...)
+	e.Err = err
+	return e
+}
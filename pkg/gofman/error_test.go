@@ -0,0 +1,104 @@
+package gofman_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestError_JSON(t *testing.T) {
+	for _, code := range []string{
+		gofman.ECONFLICT,
+		gofman.EINTERNAL,
+		gofman.EINVALID,
+		gofman.ENOTFOUND,
+		gofman.ENOTIMPLEMENTED,
+		gofman.EUNAUTHORIZED,
+		gofman.EFORBIDDEN,
+		gofman.ETOOMANYREQUESTS,
+		gofman.ETIMEOUT,
+	} {
+		t.Run(code, func(t *testing.T) {
+			buf, err := json.Marshal(gofman.NewError(code, "Message %d.", 1))
+			if err != nil {
+				t.Fatal(err)
+			} else if got, want := string(buf), `{"code":"`+code+`","message":"Message 1."}`; got != want {
+				t.Fatalf("Unexpected JSON: %s", got)
+			}
+
+			err = gofman.ErrorFromJSON(buf)
+			if gofman.ErrorCode(err) != code {
+				t.Fatalf("Unexpected code: %q", gofman.ErrorCode(err))
+			} else if gofman.ErrorMessage(err) != "Message 1." {
+				t.Fatalf("Unexpected message: %q", gofman.ErrorMessage(err))
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, data := range []string{`not json`, `{}`} {
+			if err := gofman.ErrorFromJSON([]byte(data)); gofman.ErrorCode(err) != gofman.EINTERNAL {
+				t.Fatalf("Unexpected error for %q: %#v", data, err)
+			}
+		}
+	})
+}
+
+func TestError_Unwrap(t *testing.T) {
+	err := gofman.NewWrappedError(gofman.EINTERNAL, sql.ErrNoRows, "Could not find %s.", "row")
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatal("Expected error to wrap sql.ErrNoRows")
+	} else if gofman.ErrorCode(err) != gofman.EINTERNAL {
+		t.Fatalf("Unexpected code: %q", gofman.ErrorCode(err))
+	} else if gofman.ErrorMessage(err) != "Could not find row." {
+		t.Fatalf("Unexpected message: %q", gofman.ErrorMessage(err))
+	}
+
+	// The cause must not leak into the JSON sent to clients.
+	if buf, err := json.Marshal(err); err != nil {
+		t.Fatal(err)
+	} else if got, want := string(buf), `{"code":"internal","message":"Could not find row."}`; got != want {
+		t.Fatalf("Unexpected JSON: %s", got)
+	}
+
+	// Errors wrapping an Error keep its code.
+	wrapped := fmt.Errorf("open: %w", err)
+	if gofman.ErrorCode(wrapped) != gofman.EINTERNAL || !errors.Is(wrapped, sql.ErrNoRows) {
+		t.Fatalf("Unexpected error: %#v", wrapped)
+	}
+
+	if gofman.NewError(gofman.EINVALID, "Message.").Unwrap() != nil {
+		t.Fatal("Expected no cause")
+	}
+}
+
+func TestErrorStatusCode(t *testing.T) {
+	for _, tt := range []struct {
+		code   string
+		status int
+	}{
+		{gofman.ECONFLICT, http.StatusConflict},
+		{gofman.EINVALID, http.StatusUnprocessableEntity},
+		{gofman.ENOTFOUND, http.StatusNotFound},
+		{gofman.EUNAUTHORIZED, http.StatusUnauthorized},
+		{gofman.EFORBIDDEN, http.StatusForbidden},
+		{gofman.ETOOMANYREQUESTS, http.StatusTooManyRequests},
+		{gofman.ETIMEOUT, http.StatusServiceUnavailable},
+		{gofman.ENOTIMPLEMENTED, http.StatusNotImplemented},
+		{gofman.EINTERNAL, http.StatusInternalServerError},
+		{"", http.StatusInternalServerError},
+		{"unknown", http.StatusInternalServerError},
+	} {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := gofman.ErrorStatusCode(tt.code); got != tt.status {
+				t.Fatalf("Unexpected status: %d", got)
+			}
+		})
+	}
+}
@@ -2,19 +2,142 @@ package gofman
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"mime"
+	"path/filepath"
+	"strings"
 )
 
+// Checksum algorithms.
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA1   = "sha1"
+	ChecksumMD5    = "md5"
+
+	DefaultChecksumAlgo = ChecksumSHA256
+)
+
+// checksumLens maps the checksum algorithms to the length of their hex
+// encoded checksums.
+var checksumLens = map[string]int{
+	ChecksumSHA256: 64,
+	ChecksumSHA1:   40,
+	ChecksumMD5:    32,
+}
+
+// IsValidChecksumAlgo returns true if the checksum algorithm is known.
+func IsValidChecksumAlgo(algo string) bool {
+	_, ok := checksumLens[algo]
+	return ok
+}
+
+// NewChecksumHash returns a new hash computing checksums with the given
+// algorithm. Returns EINVALID if the algorithm is unknown.
+func NewChecksumHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumSHA1:
+		return sha1.New(), nil
+	case ChecksumMD5:
+		return md5.New(), nil
+	default:
+		return nil, NewError(EINVALID, "Unknown checksum algorithm %q.", algo)
+	}
+}
+
+// DefaultAllowedTypes lists the media types accepted by DefaultFileConfig.
+var DefaultAllowedTypes = []string{
+	"image/*",
+	"video/*",
+	"audio/*",
+	"text/plain",
+	"text/markdown",
+	"text/csv",
+	"application/pdf",
+	"application/rtf",
+	"application/epub+zip",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"application/vnd.oasis.opendocument.text",
+	"application/vnd.oasis.opendocument.spreadsheet",
+	"application/vnd.oasis.opendocument.presentation",
+}
+
+// FileConfig represents the settings files are validated against.
+type FileConfig struct {
+	// Media types files may have. An entry like "image/*" allows all
+	// subtypes and "*/*" allows every type, as does an empty list.
+	AllowedTypes []string
+}
+
+// DefaultFileConfig returns a config allowing common document, image, audio
+// and video types.
+func DefaultFileConfig() FileConfig {
+	return FileConfig{AllowedTypes: append([]string(nil), DefaultAllowedTypes...)}
+}
+
+// IsAllowedType returns true if files may have the given media type.
+// Parameters like the charset are ignored.
+func (c FileConfig) IsAllowedType(typ string) bool {
+	if len(c.AllowedTypes) == 0 {
+		return true
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(typ); err == nil {
+		typ = mediaType
+	} else {
+		return false
+	}
+
+	for _, allowed := range c.AllowedTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+
+		if allowed == "*/*" || allowed == typ {
+			return true
+		}
+
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(typ, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateType returns EINVALID if the type of the file is not allowed by
+// the config.
+func (b *File) ValidateType(config FileConfig) error {
+	if !config.IsAllowedType(b.Type) {
+		return NewError(EINVALID, "Type %q is not allowed.", b.Type)
+	}
+
+	return nil
+}
+
 // File represents a file in the system.
 type File struct {
-	ID        string `json:"id"`
-	UserID    string `json:"users_id"`
-	Name      string `json:"name"`
-	Type      string `json:"type"`
-	Path      string `json:"path"`
-	Checksum  string `json:"checksum"`
-	CreatedAt int64  `json:"created_at"`
-	UpdatedAt int64  `json:"updated_at"`
-	RemovedAt int64  `json:"removed_at"`
+	ID           string  `json:"id"`
+	UserID       string  `json:"users_id"`
+	FolderID     *string `json:"folder_id"`
+	Name         string  `json:"name"`
+	Type         string  `json:"type"`
+	Path         string  `json:"path"`
+	Checksum     string  `json:"checksum"`
+	ChecksumAlgo string  `json:"checksum_algo"`
+	Description  string  `json:"description"`
+	Size         int64   `json:"size"`
+	CreatedAt    int64   `json:"created_at"`
+	UpdatedAt    int64   `json:"updated_at"`
+	RemovedAt    int64   `json:"removed_at"`
 }
 
 // Validate returns an error if the file contains invalid fields.
@@ -23,6 +146,10 @@ func (b *File) Validate() error {
 		return NewError(EINVALID, "User ID required.")
 	}
 
+	if b.FolderID != nil && *b.FolderID == "" {
+		return NewError(EINVALID, "Folder ID must not be empty.")
+	}
+
 	if b.Name == "" {
 		return NewError(EINVALID, "Name required.")
 	}
@@ -34,10 +161,39 @@ func (b *File) Validate() error {
 		return NewError(EINVALID, "Path required.")
 	}
 
+	// Paths must not be able to escape the directory they appear to be in,
+	// so only absolute paths without any ".." or "." components are allowed.
+	if !filepath.IsAbs(b.Path) {
+		return NewError(EINVALID, "Path must be absolute.")
+	}
+
+	if filepath.Clean(b.Path) != b.Path {
+		return NewError(EINVALID, "Path must be clean.")
+	}
+
 	if b.Checksum == "" {
 		return NewError(EINVALID, "Checksum required.")
 	}
 
+	algo := b.ChecksumAlgo
+	if algo == "" {
+		algo = DefaultChecksumAlgo
+	}
+
+	if !IsValidChecksumAlgo(algo) {
+		return NewError(EINVALID, "Checksum algorithm %q is not supported.", b.ChecksumAlgo)
+	}
+
+	if _, err := hex.DecodeString(b.Checksum); err != nil || len(b.Checksum) != checksumLens[algo] {
+		return NewError(EINVALID, "Checksum must be a %d character hex encoded %s hash.", checksumLens[algo], algo)
+	}
+
+	// Empty files and files stored before sizes were tracked have a size of
+	// zero.
+	if b.Size < 0 {
+		return NewError(EINVALID, "Size must not be negative.")
+	}
+
 	return nil
 }
 
@@ -45,12 +201,25 @@ func (b *File) Validate() error {
 // the given filter.
 func CanFindFile(ctx context.Context, filter FileFilter) bool {
 	id := UserIDFromContext(ctx)
-	return id != "" && filter.UserID == &id
+	return id != "" && filter.UserID != nil && *filter.UserID == id
+}
+
+// CanGroupFiles returns true if the current user can group the files of the
+// given user.
+func CanGroupFiles(ctx context.Context, filter FileGroupFilter) bool {
+	id := UserIDFromContext(ctx)
+	return id != "" && filter.UserID == id
+}
+
+// CanReconcileFiles returns true if the current user can compare the files
+// on disk with the files in the database.
+func CanReconcileFiles(ctx context.Context) bool {
+	return CanManageUsers(ctx)
 }
 
 // CanUpdateFile returns true if the current user can update the file.
 func CanUpdateFile(ctx context.Context, file *File) bool {
-	if user := UserFromContext(ctx); user != nil && user.IsDemo {
+	if CanUpload(ctx) == false {
 		return false
 	} else {
 		id := UserIDFromContext(ctx)
@@ -58,31 +227,123 @@ func CanUpdateFile(ctx context.Context, file *File) bool {
 	}
 }
 
+// CanRecomputeChecksum returns true if the current user can recompute the
+// checksum of the file.
+func CanRecomputeChecksum(ctx context.Context, file *File) bool {
+	if CanManageUsers(ctx) {
+		return true
+	}
+
+	return CanUpdateFile(ctx, file)
+}
+
 // FileService represents a service for managing files. The functions
 // should return ENOTFOUND if the file could not be found and EUNAUTHORIZED
 // if the user is not authorized to run the transaction.
 type FileService interface {
 	FindFileByID(ctx context.Context, id string) (*File, error)
+	FindFileByChecksum(ctx context.Context, userID, checksum string) (*File, error)
 	FindFiles(ctx context.Context, filter FileFilter) ([]*File, int, error)
+	SearchFiles(ctx context.Context, query string, filter FileFilter) ([]*File, int, error)
 	CreateFile(ctx context.Context, file *File) error
+	CreateFiles(ctx context.Context, files []*File) error
 	UpdateFile(ctx context.Context, id string, update FileUpdate) (*File, error)
 	RemoveFile(ctx context.Context, id string) error
+	RestoreFile(ctx context.Context, id string) error
+	GroupFilesByTag(ctx context.Context, filter FileGroupFilter) ([]*TagFiles, error)
+	GroupFilesByActor(ctx context.Context, filter FileGroupFilter) ([]*ActorFiles, error)
+	Reconcile(ctx context.Context, opts FileReconcileOptions) (*FileReconcileReport, error)
+	RecomputeChecksum(ctx context.Context, id string) (*File, error)
+	Exists(ctx context.Context, id string) (bool, error)
 }
 
 // FileFilter represents a filter passed to FindFiles().
 type FileFilter struct {
-	ID     *string `json:"id"`
-	UserID *string `json:"users_id"`
-	Type   *string `json:"type"`
+	ID       *string `json:"id"`
+	UserID   *string `json:"users_id"`
+	FolderID *string `json:"folder_id"`
+	Type     *string `json:"type"`
+	Checksum *string `json:"checksum"`
+
+	// Only files whose name contains NameLike, ignoring ASCII case.
+	NameLike *string `json:"name_like"`
+
+	// Only files of at least MinSize bytes.
+	MinSize *int64 `json:"min_size"`
 
+	// Only files created within the range, as unix timestamps. Both bounds
+	// are inclusive and optional.
+	CreatedAfter  *int64 `json:"created_after"`
+	CreatedBefore *int64 `json:"created_before"`
+
+	// Sort column and direction. SortBy must be one of name, type, size,
+	// created_at or updated_at. Defaults to created_at.
+	SortBy   string `json:"sort_by"`
+	SortDesc bool   `json:"sort_desc"`
+
+	// Also find removed files, for example to restore them.
+	IncludeRemoved bool `json:"include_removed"`
+
+	// Paging. Limit defaults to DefaultLimit and is capped at MaxLimit.
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 }
 
 // FileUpdate represents a set of fields to be updated via UpdateFile().
+// A nil field is left unchanged, a field pointing at an empty string is set
+// to empty. When decoded from JSON a missing key or null is nil while an
+// explicit "" is a pointer to an empty string. A FolderID pointing at an
+// empty string moves the file out of its folder.
 type FileUpdate struct {
-	Name     *string `json:"name"`
-	Type     *string `json:"type"`
-	Path     *string `json:"path"`
-	Checksum *string `json:"checksum"`
+	FolderID     *string `json:"folder_id"`
+	Name         *string `json:"name"`
+	Type         *string `json:"type"`
+	Path         *string `json:"path"`
+	Checksum     *string `json:"checksum"`
+	ChecksumAlgo *string `json:"checksum_algo"`
+	Description  *string `json:"description"`
+	Size         *int64  `json:"size"`
+}
+
+// FileGroupFilter represents a filter passed to GroupFilesByTag() and
+// GroupFilesByActor().
+type FileGroupFilter struct {
+	UserID       string `json:"users_id"`
+	IncludeEmpty bool   `json:"include_empty"`
+}
+
+// TagFiles represents a tag together with the files it is attached to.
+type TagFiles struct {
+	Tag   *Tag    `json:"tag"`
+	Files []*File `json:"files"`
+}
+
+// ActorFiles represents an actor together with the files it is attached to.
+type ActorFiles struct {
+	Actor *Actor  `json:"actor"`
+	Files []*File `json:"files"`
+}
+
+// FileReconcileOptions represents the options passed to Reconcile().
+type FileReconcileOptions struct {
+	UserID string `json:"users_id"`
+	Root   string `json:"root"`
+
+	// Remove rows whose file does not exist on disk anymore.
+	RemoveOrphaned bool `json:"remove_orphaned"`
+
+	// Create rows for files on disk that are not tracked yet.
+	ImportUntracked bool `json:"import_untracked"`
+}
+
+// FileReconcileReport represents the result of Reconcile().
+type FileReconcileReport struct {
+	// Rows of the user below the root whose file does not exist on disk.
+	Orphaned []*File `json:"orphaned"`
+
+	// Files on disk below the root that are not tracked by any row.
+	Untracked []*File `json:"untracked"`
+
+	Removed  int `json:"removed"`
+	Imported int `json:"imported"`
 }
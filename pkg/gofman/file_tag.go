@@ -0,0 +1,15 @@
+package gofman
+
+import (
+	"context"
+)
+
+// FileTagService represents a service for attaching tags to files. The
+// functions should return ENOTFOUND if the file or tag could not be found and
+// EUNAUTHORIZED if the user is not authorized to run the transaction.
+type FileTagService interface {
+	AddTag(ctx context.Context, fileID, tagID string) error
+	RemoveTag(ctx context.Context, fileID, tagID string) error
+	TagsForFile(ctx context.Context, fileID string) ([]*Tag, error)
+	FilesForTag(ctx context.Context, tagID string) ([]*File, error)
+}
@@ -0,0 +1,104 @@
+package gofman_test
+
+import (
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestFileConfig_IsAllowedType(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		allowed []string
+		typ     string
+		want    bool
+	}{
+		{name: "Exact", allowed: gofman.DefaultAllowedTypes, typ: "application/pdf", want: true},
+		{name: "Wildcard", allowed: gofman.DefaultAllowedTypes, typ: "image/webp", want: true},
+		{name: "Parameters", allowed: gofman.DefaultAllowedTypes, typ: "text/plain; charset=utf-8", want: true},
+		{name: "CaseInsensitive", allowed: gofman.DefaultAllowedTypes, typ: "Image/JPEG", want: true},
+		{name: "Rejected", allowed: gofman.DefaultAllowedTypes, typ: "application/x-msdownload", want: false},
+		{name: "OctetStream", allowed: gofman.DefaultAllowedTypes, typ: "application/octet-stream", want: false},
+		{name: "Garbage", allowed: gofman.DefaultAllowedTypes, typ: "not a type", want: false},
+		{name: "PrefixOnly", allowed: []string{"image/*"}, typ: "imagery/png", want: false},
+		{name: "AllowAll", allowed: []string{"*/*"}, typ: "application/x-msdownload", want: true},
+		{name: "Empty", allowed: nil, typ: "application/x-msdownload", want: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := gofman.FileConfig{AllowedTypes: tt.allowed}
+			if got := config.IsAllowedType(tt.typ); got != tt.want {
+				t.Fatalf("Unexpected result for %q: %v", tt.typ, got)
+			}
+		})
+	}
+}
+
+func TestFile_ValidateType(t *testing.T) {
+	file := &gofman.File{Type: "application/x-msdownload"}
+
+	if err := file.ValidateType(gofman.DefaultFileConfig()); gofman.ErrorCode(err) != gofman.EINVALID {
+		t.Fatalf("Unexpected error: %#v", err)
+	} else if msg := gofman.ErrorMessage(err); msg != `Type "application/x-msdownload" is not allowed.` {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestFile_Validate_Path(t *testing.T) {
+	for _, tt := range []struct {
+		path string
+		ok   bool
+	}{
+		{path: "/data/photos/a.jpg", ok: true},
+		{path: "/data/photos/..a.jpg", ok: true},
+		{path: "../../etc/passwd", ok: false},
+		{path: "/data/../../etc/passwd", ok: false},
+		{path: "/data/./a.jpg", ok: false},
+		{path: "/data//a.jpg", ok: false},
+		{path: "photos/a.jpg", ok: false},
+		{path: "a.jpg", ok: false},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			file := &gofman.File{UserID: "1", Name: "a.jpg", Type: "image/jpeg", Path: tt.path, Checksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}
+
+			if err := file.Validate(); tt.ok && err != nil {
+				t.Fatalf("Unexpected error: %#v", err)
+			} else if !tt.ok && gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Expected EINVALID, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestFile_Validate_Checksum(t *testing.T) {
+	const (
+		sha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		sha1   = "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+		md5    = "d41d8cd98f00b204e9800998ecf8427e"
+	)
+
+	for _, tt := range []struct {
+		name     string
+		algo     string
+		checksum string
+		ok       bool
+	}{
+		{name: "Default", algo: "", checksum: sha256, ok: true},
+		{name: "SHA256", algo: gofman.ChecksumSHA256, checksum: sha256, ok: true},
+		{name: "SHA1", algo: gofman.ChecksumSHA1, checksum: sha1, ok: true},
+		{name: "MD5", algo: gofman.ChecksumMD5, checksum: md5, ok: true},
+		{name: "WrongLength", algo: gofman.ChecksumSHA256, checksum: md5, ok: false},
+		{name: "DefaultWrongLength", algo: "", checksum: sha1, ok: false},
+		{name: "NotHex", algo: gofman.ChecksumMD5, checksum: "zz1d8cd98f00b204e9800998ecf8427e", ok: false},
+		{name: "UnknownAlgo", algo: "crc32", checksum: "00000000", ok: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &gofman.File{UserID: "1", Name: "a.jpg", Type: "image/jpeg", Path: "/data/a.jpg", Checksum: tt.checksum, ChecksumAlgo: tt.algo}
+
+			if err := file.Validate(); tt.ok && err != nil {
+				t.Fatalf("Unexpected error: %#v", err)
+			} else if !tt.ok && gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Expected EINVALID, got %#v", err)
+			}
+		})
+	}
+}
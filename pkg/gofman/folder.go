@@ -0,0 +1,96 @@
+package gofman
+
+import (
+	"context"
+)
+
+// Folder constants.
+const (
+	MaxFolderNameLen = 255
+)
+
+// Folder represents a folder in the system. Folders without a parent are
+// top-level folders of their user.
+type Folder struct {
+	ID        string  `json:"id"`
+	UserID    string  `json:"users_id"`
+	Name      string  `json:"name"`
+	ParentID  *string `json:"parent_id"`
+	CreatedAt int64   `json:"created_at"`
+	UpdatedAt int64   `json:"updated_at"`
+	RemovedAt int64   `json:"removed_at"`
+}
+
+// Validate returns an error if the folder contains invalid fields.
+func (f *Folder) Validate() error {
+	if f.UserID == "" {
+		return NewError(EINVALID, "User ID required.")
+	}
+
+	if f.Name == "" {
+		return NewError(EINVALID, "Name required.")
+	}
+
+	if len(f.Name) > MaxFolderNameLen {
+		return NewError(EINVALID, "Name must be less than %d characters.", MaxFolderNameLen)
+	}
+
+	if f.ParentID != nil && *f.ParentID == "" {
+		return NewError(EINVALID, "Parent ID must not be empty.")
+	}
+
+	if f.ParentID != nil && f.ID != "" && *f.ParentID == f.ID {
+		return NewError(EINVALID, "Folder cannot be its own parent.")
+	}
+
+	return nil
+}
+
+// CanFindFolder returns true if the current user can list folders with the
+// given filter.
+func CanFindFolder(ctx context.Context, filter FolderFilter) bool {
+	id := UserIDFromContext(ctx)
+	return id != "" && filter.UserID != nil && *filter.UserID == id
+}
+
+// CanUpdateFolder returns true if the current user can update the folder.
+func CanUpdateFolder(ctx context.Context, folder *Folder) bool {
+	if CanUpload(ctx) == false {
+		return false
+	} else {
+		id := UserIDFromContext(ctx)
+		return id != "" && folder.UserID == id
+	}
+}
+
+// FolderService represents a service for managing folders. The functions
+// should return ENOTFOUND if the folder or its parent could not be found and
+// EUNAUTHORIZED if the user is not authorized to run the transaction.
+type FolderService interface {
+	FindFolderByID(ctx context.Context, id string) (*Folder, error)
+	FindFolders(ctx context.Context, filter FolderFilter) ([]*Folder, int, error)
+	CreateFolder(ctx context.Context, folder *Folder) error
+	UpdateFolder(ctx context.Context, id string, update FolderUpdate) (*Folder, error)
+	RemoveFolder(ctx context.Context, id string) error
+}
+
+// FolderFilter represents a filter passed to FindFolders().
+type FolderFilter struct {
+	ID       *string `json:"id"`
+	UserID   *string `json:"users_id"`
+	ParentID *string `json:"parent_id"`
+
+	// Only top-level folders. Ignored if ParentID is set.
+	Root bool `json:"root"`
+
+	// Paging. Limit defaults to DefaultLimit and is capped at MaxLimit.
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// FolderUpdate represents a set of fields to be updated via UpdateFolder().
+// A ParentID pointing at an empty string moves the folder to the top level.
+type FolderUpdate struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
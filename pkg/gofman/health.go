@@ -0,0 +1,12 @@
+package gofman
+
+import (
+	"context"
+)
+
+// HealthService represents a service for checking whether the dependencies
+// of the application are reachable.
+type HealthService interface {
+	// Ping returns an error if the database cannot be queried.
+	Ping(ctx context.Context) error
+}
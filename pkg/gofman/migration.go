@@ -0,0 +1,23 @@
+package gofman
+
+import (
+	"context"
+)
+
+// MigrationStatus represents the state of the database schema. Pending counts
+// both migration files and added columns that have not been applied yet.
+type MigrationStatus struct {
+	Version string `json:"version"`
+	Latest  string `json:"latest"`
+	Pending int    `json:"pending"`
+}
+
+// IsMigrated returns true if no migrations are pending.
+func (s *MigrationStatus) IsMigrated() bool {
+	return s.Pending == 0
+}
+
+// MigrationService represents a service for inspecting schema migrations.
+type MigrationService interface {
+	MigrationStatus(ctx context.Context) (*MigrationStatus, error)
+}
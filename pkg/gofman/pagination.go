@@ -0,0 +1,85 @@
+package gofman
+
+// Paging constants. Filters without a limit return DefaultLimit results and
+// larger limits are capped at MaxLimit.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 1000
+)
+
+// Pagination represents the position of a page within a list of results. It
+// is computed from the offset and limit of a filter and the total hits
+// returned by the FindX functions.
+type Pagination struct {
+	Offset     int  `json:"offset"`
+	Limit      int  `json:"limit"`
+	Total      int  `json:"total"`
+	Page       int  `json:"page"`
+	TotalPages int  `json:"total_pages"`
+	HasNext    bool `json:"has_next"`
+	HasPrev    bool `json:"has_prev"`
+}
+
+// NewPagination returns the pagination for the given offset, limit and total
+// hits. Pages start at 1. A limit of zero means all results fit on a single
+// page and an empty result has zero pages.
+func NewPagination(offset, limit, total int) Pagination {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit < 0 {
+		limit = 0
+	}
+
+	if total < 0 {
+		total = 0
+	}
+
+	p := Pagination{
+		Offset: offset,
+		Limit:  limit,
+		Total:  total,
+		Page:   1,
+	}
+
+	if limit == 0 {
+		if total > 0 {
+			p.TotalPages = 1
+		}
+
+		p.HasPrev = offset > 0
+		return p
+	}
+
+	p.Page = offset/limit + 1
+	p.TotalPages = (total + limit - 1) / limit
+	p.HasNext = offset+limit < total
+	p.HasPrev = offset > 0
+
+	return p
+}
+
+// NextOffset returns the offset of the next page.
+func (p Pagination) NextOffset() int {
+	return p.Offset + p.Limit
+}
+
+// PrevOffset returns the offset of the previous page. It never drops below
+// zero.
+func (p Pagination) PrevOffset() int {
+	if p.Offset < p.Limit {
+		return 0
+	}
+
+	return p.Offset - p.Limit
+}
+
+// LastOffset returns the offset of the last page.
+func (p Pagination) LastOffset() int {
+	if p.TotalPages == 0 {
+		return 0
+	}
+
+	return (p.TotalPages - 1) * p.Limit
+}
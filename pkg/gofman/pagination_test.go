@@ -0,0 +1,75 @@
+package gofman_test
+
+import (
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestNewPagination(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		offset, limit int
+		total         int
+		want          gofman.Pagination
+	}{
+		{
+			name: "Empty", offset: 0, limit: 10, total: 0,
+			want: gofman.Pagination{Limit: 10, Page: 1},
+		},
+		{
+			name: "SinglePage", offset: 0, limit: 10, total: 3,
+			want: gofman.Pagination{Limit: 10, Total: 3, Page: 1, TotalPages: 1},
+		},
+		{
+			name: "ExactMultiple", offset: 10, limit: 10, total: 20,
+			want: gofman.Pagination{Offset: 10, Limit: 10, Total: 20, Page: 2, TotalPages: 2, HasPrev: true},
+		},
+		{
+			name: "ExactMultipleFirstPage", offset: 0, limit: 10, total: 20,
+			want: gofman.Pagination{Limit: 10, Total: 20, Page: 1, TotalPages: 2, HasNext: true},
+		},
+		{
+			name: "PartialLastPage", offset: 20, limit: 10, total: 25,
+			want: gofman.Pagination{Offset: 20, Limit: 10, Total: 25, Page: 3, TotalPages: 3, HasPrev: true},
+		},
+		{
+			name: "MiddlePage", offset: 10, limit: 10, total: 25,
+			want: gofman.Pagination{Offset: 10, Limit: 10, Total: 25, Page: 2, TotalPages: 3, HasNext: true, HasPrev: true},
+		},
+		{
+			name: "ZeroLimit", offset: 0, limit: 0, total: 25,
+			want: gofman.Pagination{Total: 25, Page: 1, TotalPages: 1},
+		},
+		{
+			name: "ZeroLimitEmpty", offset: 0, limit: 0, total: 0,
+			want: gofman.Pagination{Page: 1},
+		},
+		{
+			name: "Negative", offset: -5, limit: -1, total: -1,
+			want: gofman.Pagination{Page: 1},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gofman.NewPagination(tt.offset, tt.limit, tt.total); got != tt.want {
+				t.Fatalf("Unexpected pagination: %+v", got)
+			}
+		})
+	}
+}
+
+func TestPagination_Offsets(t *testing.T) {
+	p := gofman.NewPagination(5, 10, 25)
+
+	if got := p.NextOffset(); got != 15 {
+		t.Fatalf("Unexpected next offset: %d", got)
+	} else if got := p.PrevOffset(); got != 0 {
+		t.Fatalf("Unexpected previous offset: %d", got)
+	} else if got := p.LastOffset(); got != 20 {
+		t.Fatalf("Unexpected last offset: %d", got)
+	}
+
+	if got := gofman.NewPagination(0, 10, 0).LastOffset(); got != 0 {
+		t.Fatalf("Unexpected last offset: %d", got)
+	}
+}
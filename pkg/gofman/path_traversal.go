@@ -5,4 +5,7 @@ package gofman
 type PathTraversalService interface {
 	Expand(path string) (string, error)
 	GetFilesInPath(root string) ([]*File, error)
+	GetFile(path string) (*File, error)
+	GetFileWithChecksumAlgo(path, algo string) (*File, error)
+	Resolve(root, path string) (string, error)
 }
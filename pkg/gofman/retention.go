@@ -0,0 +1,28 @@
+package gofman
+
+import (
+	"context"
+)
+
+// RetentionPolicy represents the maximum age in seconds of rows per table.
+// Sessions age from their expiry, not their creation. A max age of zero
+// disables pruning for that table and keeps rows forever.
+type RetentionPolicy struct {
+	SessionMaxAge int64 `json:"session_max_age"`
+}
+
+// IsEnabled returns true if at least one table has a max age configured.
+func (p RetentionPolicy) IsEnabled() bool {
+	return p.SessionMaxAge > 0
+}
+
+// RetentionService represents a service for pruning rows that are older than
+// the configured retention policy.
+type RetentionService interface {
+	Prune(ctx context.Context, policy RetentionPolicy) (*RetentionResult, error)
+}
+
+// RetentionResult represents the number of pruned rows per table.
+type RetentionResult struct {
+	Sessions int `json:"sessions"`
+}
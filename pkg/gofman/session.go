@@ -7,14 +7,36 @@ import (
 // Session constants.
 const (
 	MinTokenLen = 32
+
+	MaxUserAgentLen = 512
+
+	DefaultSessionLimit = 20
+	MaxSessionLimit     = 100
+
+	// Lifetime of a session in seconds if no other lifetime is configured.
+	DefaultSessionTTL = 7 * 24 * 60 * 60
 )
 
 // Session represents an active user session. These are linked to a user.
 type Session struct {
 	ID        string `json:"id"`
 	UserID    string `json:"users_id"`
-	Token     string `json:"token"`
+	Token     string `json:"token,omitempty"`
 	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+
+	// Client the session was created from. Both are optional and only meant
+	// to help users tell their sessions apart.
+	UserAgent string `json:"user_agent"`
+	IP        string `json:"ip"`
+
+	// Lifetime in seconds used by CreateSession if no expiry is set. Zero
+	// uses the default lifetime of the service.
+	TTL int64 `json:"-"`
+
+	// Hash of the token as stored in the database. The plaintext token is
+	// never stored and is only known right after the session was created.
+	TokenHash string `json:"-"`
 }
 
 // Validate returns an error if any fields are invalid in the session.
@@ -31,9 +53,37 @@ func (s *Session) Validate() error {
 		return NewError(EINVALID, "Token must have at least %d characters.", MinTokenLen)
 	}
 
+	if s.ExpiresAt <= 0 {
+		return NewError(EINVALID, "Expiry required.")
+	}
+
+	if len(s.UserAgent) > MaxUserAgentLen {
+		return NewError(EINVALID, "User agent must not exceed %d characters.", MaxUserAgentLen)
+	}
+
 	return nil
 }
 
+// IsExpired returns true if the session expired at the given unix time.
+func (s *Session) IsExpired(now int64) bool {
+	return s.ExpiresAt <= now
+}
+
+// Redacted returns a copy of the session without the token. It should be used
+// whenever a session is sent to a client.
+func (s *Session) Redacted() *Session {
+	other := *s
+	other.Token = ""
+	return &other
+}
+
+// CanFindSession returns true if the current user can find the sessions
+// matching the filter.
+func CanFindSession(ctx context.Context, filter SessionFilter) bool {
+	id := UserIDFromContext(ctx)
+	return id != "" && filter.UserID != nil && *filter.UserID == id
+}
+
 // CanDeleteSession returns true if the current user can remove the session.
 func CanDeleteSession(ctx context.Context, session *Session) bool {
 	if id := UserIDFromContext(ctx); id != "" && session.UserID == id {
@@ -49,8 +99,11 @@ func CanDeleteSession(ctx context.Context, session *Session) bool {
 type SessionService interface {
 	FindSessionForToken(ctx context.Context, id string, token string) (*Session, error)
 	FindSessions(ctx context.Context, filter SessionFilter) ([]*Session, int, error)
+	FindSessionsForUser(ctx context.Context, userID string) ([]*Session, error)
 	CreateSession(ctx context.Context, session *Session) error
 	DeleteSession(ctx context.Context, id string) error
+	DeleteSessionsForUser(ctx context.Context, userID string) (int, error)
+	PurgeExpired(ctx context.Context) (int, error)
 }
 
 // SessionFilter represents a filter accepted by FindSessions().
@@ -59,6 +112,11 @@ type SessionFilter struct {
 	UserID *string `json:"users_id"`
 	Token  *string `json:"token"`
 
+	// Order newest sessions first.
+	SortDesc bool `json:"sort_desc"`
+
+	// Paging. Limit defaults to DefaultSessionLimit and is capped at
+	// MaxSessionLimit.
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 }
@@ -9,4 +9,5 @@ import (
 // need to be added to the routes
 type SetupService interface {
 	ShouldRunSetup(ctx context.Context) (bool, error)
+	RunSetup(ctx context.Context, user *User) error
 }
@@ -0,0 +1,22 @@
+package gofman
+
+import (
+	"context"
+)
+
+// Stats represents counters of the whole application used for monitoring.
+// Removed users and files are not counted.
+type Stats struct {
+	Users int
+	Files int
+
+	// Connections of the database pool.
+	OpenConnections  int
+	InUseConnections int
+	IdleConnections  int
+}
+
+// StatsService represents a service for collecting monitoring counters.
+type StatsService interface {
+	Stats(ctx context.Context) (*Stats, error)
+}
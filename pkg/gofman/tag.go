@@ -40,12 +40,12 @@ func (t *Tag) Validate() error {
 // the given filter.
 func CanFindTag(ctx context.Context, filter TagFilter) bool {
 	id := UserIDFromContext(ctx)
-	return id != "" && filter.UserID == &id
+	return id != "" && filter.UserID != nil && *filter.UserID == id
 }
 
 // CanUpdateTag returns true if the current user can update the tag.
 func CanUpdateTag(ctx context.Context, tag *Tag) bool {
-	if user := UserFromContext(ctx); user != nil && user.IsDemo {
+	if CanUpload(ctx) == false {
 		return false
 	} else {
 		id := UserIDFromContext(ctx)
@@ -62,6 +62,9 @@ type TagService interface {
 	CreateTag(ctx context.Context, tag *Tag) error
 	UpdateTag(ctx context.Context, id string, update TagUpdate) (*Tag, error)
 	RemoveTag(ctx context.Context, id string) error
+	RestoreTag(ctx context.Context, id string) error
+	EnsureTagByName(ctx context.Context, name string) (*Tag, error)
+	Exists(ctx context.Context, id string) (bool, error)
 }
 
 // TagFilter represents a filter passed to FindTags().
@@ -69,6 +72,23 @@ type TagFilter struct {
 	ID     *string `json:"id"`
 	UserID *string `json:"users_id"`
 
+	// Only tags whose name contains NameLike, ignoring ASCII case.
+	NameLike *string `json:"name_like"`
+
+	// Only tags created within the range, as unix timestamps. Both bounds
+	// are inclusive and optional.
+	CreatedAfter  *int64 `json:"created_after"`
+	CreatedBefore *int64 `json:"created_before"`
+
+	// Sort column and direction. SortBy must be one of name, created_at or
+	// updated_at. Defaults to created_at.
+	SortBy   string `json:"sort_by"`
+	SortDesc bool   `json:"sort_desc"`
+
+	// Also find removed tags, for example to restore them.
+	IncludeRemoved bool `json:"include_removed"`
+
+	// Paging. Limit defaults to DefaultLimit and is capped at MaxLimit.
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 }
@@ -0,0 +1,35 @@
+package gofman
+
+import (
+	"context"
+)
+
+// CanEmptyTrash returns true if the current user can permanently remove their
+// removed content.
+func CanEmptyTrash(ctx context.Context) bool {
+	if user := UserFromContext(ctx); user != nil && user.IsDemo {
+		return false
+	} else {
+		return UserIDFromContext(ctx) != ""
+	}
+}
+
+// TrashService represents a service for permanently removing the removed
+// content of the current user and, for admins, old removed content of all
+// users.
+type TrashService interface {
+	EmptyTrash(ctx context.Context, dryRun bool) (*TrashResult, error)
+	PurgeRemoved(ctx context.Context, olderThan int64, dryRun bool) (*TrashResult, error)
+}
+
+// TrashResult represents the number of permanently removed rows per type.
+type TrashResult struct {
+	Files   int `json:"files"`
+	Folders int `json:"folders"`
+	Actors  int `json:"actors"`
+	Tags    int `json:"tags"`
+
+	// Number of uploaded files deleted from disk. Only files below the
+	// storage root are deleted, files imported from elsewhere are kept.
+	DiskFiles int `json:"disk_files"`
+}
@@ -2,6 +2,8 @@ package gofman
 
 import (
 	"context"
+	"regexp"
+	"strings"
 )
 
 // User constants.
@@ -10,11 +12,33 @@ const (
 	MinPasswordLen = 7
 )
 
+// usernameRegexp matches the characters allowed in lowercased usernames.
+var usernameRegexp = regexp.MustCompile(`^[a-z0-9_.-]+$`)
+
+// User roles. Admins manage users and the system, users manage their own
+// content and readonly users can only look at their content.
+const (
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+	RoleReadOnly = "readonly"
+)
+
+// IsValidRole returns true if role is a known role.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleUser, RoleReadOnly:
+		return true
+	default:
+		return false
+	}
+}
+
 // User represents a user in the system.
 type User struct {
 	ID        string `json:"id"`
 	Username  string `json:"username"`
-	Password  string `json:"password"`
+	Password  string `json:"password,omitempty"`
+	Role      string `json:"role"`
 	IsAdmin   bool   `json:"is_admin"`
 	IsDemo    bool   `json:"is_demo"`
 	CreatedAt int64  `json:"created_at"`
@@ -32,6 +56,13 @@ func (u *User) Validate() error {
 		return NewError(EINVALID, "Username must be less than %d characters.", MaxUsernameLen)
 	}
 
+	// Usernames are stored lowercase and end up in URLs, so only a small set
+	// of characters is allowed. A leading dot could be mistaken for a
+	// relative path.
+	if username := strings.ToLower(u.Username); !usernameRegexp.MatchString(username) || strings.HasPrefix(username, ".") {
+		return NewError(EINVALID, "Username may only contain ASCII letters, digits, underscores, dots and hyphens and must not start with a dot.")
+	}
+
 	if u.Password == "" {
 		return NewError(EINVALID, "Password required.")
 	}
@@ -40,40 +71,83 @@ func (u *User) Validate() error {
 		return NewError(EINVALID, "Password must have at least %d characters.", MinPasswordLen)
 	}
 
+	// Users without a role are regular users, see role.
+	if u.Role != "" && !IsValidRole(u.Role) {
+		return NewError(EINVALID, "Invalid role %q.", u.Role)
+	}
+
 	return nil
 }
 
+// SetRole sets the role of the user and keeps IsAdmin in sync. IsAdmin only
+// remains for clients that do not know about roles yet.
+func (u *User) SetRole(role string) {
+	u.Role = role
+	u.IsAdmin = role == RoleAdmin
+}
+
+// role returns the role of the user. Users without a role, like the ones
+// built in memory from IsAdmin alone, are admins or regular users.
+func (u *User) role() string {
+	if u.Role != "" {
+		return u.Role
+	} else if u.IsAdmin {
+		return RoleAdmin
+	} else {
+		return RoleUser
+	}
+}
+
+// Redacted returns a copy of the user without the password hash. It should be
+// used whenever a user is sent to a client.
+func (u *User) Redacted() *User {
+	other := *u
+	other.Password = ""
+	return &other
+}
+
+// CanUpload returns true if the current user can create and change content.
+func CanUpload(ctx context.Context) bool {
+	if user := UserFromContext(ctx); user == nil || user.IsDemo {
+		return false
+	} else {
+		return user.role() != RoleReadOnly
+	}
+}
+
+// CanManageUsers returns true if the current user can create, find and
+// update other users.
+func CanManageUsers(ctx context.Context) bool {
+	if user := UserFromContext(ctx); user != nil {
+		return user.role() == RoleAdmin
+	} else {
+		return false
+	}
+}
+
 // CanFindUser returns true if the current user can list users with
 // the given filter.
 func CanFindUser(ctx context.Context, filter UserFilter) bool {
-	if id := UserIDFromContext(ctx); filter.ID == &id {
+	if id := UserIDFromContext(ctx); id != "" && filter.ID != nil && *filter.ID == id {
 		return true
-	} else if user := UserFromContext(ctx); user != nil {
-		return user.IsAdmin
 	} else {
-		return false
+		return CanManageUsers(ctx)
 	}
 }
 
 // CanCreateUser returns true if the current user can create a new user.
 func CanCreateUser(ctx context.Context) bool {
-	if user := UserFromContext(ctx); user != nil {
-		return user.IsAdmin
-	} else {
-		return false
-	}
+	return CanManageUsers(ctx)
 }
 
 // CanUpdateUser returns true if the current user can update the user.
 func CanUpdateUser(ctx context.Context, user *User) bool {
-	if user := UserFromContext(ctx); user != nil && user.IsDemo {
+	if current := UserFromContext(ctx); current == nil || current.IsDemo {
 		return false
-	} else if id := UserIDFromContext(ctx); user.ID == id {
+	} else if current.ID != "" && current.ID == user.ID {
 		return true
-	} else if user := UserFromContext(ctx); user != nil {
-		return user.IsAdmin
 	} else {
-		return false
+		return CanManageUsers(ctx)
 	}
 }
 
@@ -87,6 +161,8 @@ type UserService interface {
 	CreateUser(ctx context.Context, user *User) error
 	UpdateUser(ctx context.Context, id string, update UserUpdate) (*User, error)
 	RemoveUser(ctx context.Context, id string) error
+	RestoreUser(ctx context.Context, id string) error
+	RemoveUserWithContent(ctx context.Context, id string, dryRun bool) (*UserContent, error)
 }
 
 // UserFilter represents a filter passed to FindUsers().
@@ -94,6 +170,23 @@ type UserFilter struct {
 	ID       *string `json:"id"`
 	Username *string `json:"username"`
 
+	// Only users whose username contains NameLike, ignoring ASCII case.
+	NameLike *string `json:"name_like"`
+
+	// Only users created within the range, as unix timestamps. Both bounds
+	// are inclusive and optional.
+	CreatedAfter  *int64 `json:"created_after"`
+	CreatedBefore *int64 `json:"created_before"`
+
+	// Sort column and direction. SortBy must be one of username, created_at
+	// or updated_at. Defaults to created_at.
+	SortBy   string `json:"sort_by"`
+	SortDesc bool   `json:"sort_desc"`
+
+	// Also find removed users, for example to restore them.
+	IncludeRemoved bool `json:"include_removed"`
+
+	// Paging. Limit defaults to DefaultLimit and is capped at MaxLimit.
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 }
@@ -102,5 +195,21 @@ type UserFilter struct {
 type UserUpdate struct {
 	Username *string `json:"username"`
 	Password *string `json:"password"`
-	IsAdmin  *bool   `json:"is_admin"`
+	Role     *string `json:"role"`
+
+	// Deprecated: use Role. Setting it to true or false sets the role to
+	// RoleAdmin or RoleUser.
+	IsAdmin *bool `json:"is_admin"`
+
+	// Delete all sessions of the user, logging them out everywhere. Sessions
+	// are always deleted if the role changes.
+	RevokeSessions bool `json:"revoke_sessions"`
+}
+
+// UserContent represents the number of rows owned by a user.
+type UserContent struct {
+	Files    int `json:"files"`
+	Actors   int `json:"actors"`
+	Tags     int `json:"tags"`
+	Sessions int `json:"sessions"`
 }
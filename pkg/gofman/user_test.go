@@ -0,0 +1,126 @@
+package gofman_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestUser_Validate_Role(t *testing.T) {
+	for _, role := range []string{"", gofman.RoleAdmin, gofman.RoleUser, gofman.RoleReadOnly} {
+		user := &gofman.User{Username: "jane", Password: "password", Role: role}
+		if err := user.Validate(); err != nil {
+			t.Fatalf("Unexpected error for role %q: %#v", role, err)
+		}
+	}
+
+	user := &gofman.User{Username: "jane", Password: "password", Role: "root"}
+	if err := user.Validate(); gofman.ErrorCode(err) != gofman.EINVALID {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
+
+func TestUser_Validate_Username(t *testing.T) {
+	for _, tt := range []struct {
+		username string
+		ok       bool
+	}{
+		{username: "jane.doe-1_2", ok: true},
+		{username: "Jane", ok: true},
+		{username: "jane doe", ok: false},
+		{username: "jane/doe", ok: false},
+		{username: "jane\x00", ok: false},
+		{username: "jane😀", ok: false},
+		{username: ".jane", ok: false},
+		{username: "..", ok: false},
+	} {
+		t.Run(tt.username, func(t *testing.T) {
+			user := &gofman.User{Username: tt.username, Password: "password"}
+
+			if err := user.Validate(); tt.ok && err != nil {
+				t.Fatalf("Unexpected error: %#v", err)
+			} else if !tt.ok && gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Expected EINVALID, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestCanUpload(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		user *gofman.User
+		want bool
+	}{
+		{name: "Anonymous", user: nil, want: false},
+		{name: "Admin", user: &gofman.User{ID: "1", Role: gofman.RoleAdmin}, want: true},
+		{name: "User", user: &gofman.User{ID: "1", Role: gofman.RoleUser}, want: true},
+		{name: "ReadOnly", user: &gofman.User{ID: "1", Role: gofman.RoleReadOnly}, want: false},
+		{name: "Demo", user: &gofman.User{ID: "1", Role: gofman.RoleUser, IsDemo: true}, want: false},
+		{name: "NoRole", user: &gofman.User{ID: "1"}, want: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.user != nil {
+				ctx = gofman.NewContextWithUser(ctx, tt.user)
+			}
+
+			if got := gofman.CanUpload(ctx); got != tt.want {
+				t.Fatalf("Unexpected result: %v", got)
+			}
+		})
+	}
+}
+
+func TestCanManageUsers(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		user *gofman.User
+		want bool
+	}{
+		{name: "Anonymous", user: nil, want: false},
+		{name: "Admin", user: &gofman.User{ID: "1", Role: gofman.RoleAdmin}, want: true},
+		{name: "User", user: &gofman.User{ID: "1", Role: gofman.RoleUser}, want: false},
+		{name: "ReadOnly", user: &gofman.User{ID: "1", Role: gofman.RoleReadOnly}, want: false},
+		{name: "IsAdminShim", user: &gofman.User{ID: "1", IsAdmin: true}, want: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.user != nil {
+				ctx = gofman.NewContextWithUser(ctx, tt.user)
+			}
+
+			if got := gofman.CanManageUsers(ctx); got != tt.want {
+				t.Fatalf("Unexpected result: %v", got)
+			}
+		})
+	}
+}
+
+func TestCanUpdateUser(t *testing.T) {
+	jane := &gofman.User{ID: "1", Role: gofman.RoleUser}
+
+	for _, tt := range []struct {
+		name string
+		user *gofman.User
+		want bool
+	}{
+		{name: "Anonymous", user: nil, want: false},
+		{name: "Admin", user: &gofman.User{ID: "2", Role: gofman.RoleAdmin}, want: true},
+		{name: "Self", user: &gofman.User{ID: "1", Role: gofman.RoleUser}, want: true},
+		{name: "OtherUser", user: &gofman.User{ID: "2", Role: gofman.RoleUser}, want: false},
+		{name: "Demo", user: &gofman.User{ID: "1", Role: gofman.RoleUser, IsDemo: true}, want: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.user != nil {
+				ctx = gofman.NewContextWithUser(ctx, tt.user)
+			}
+
+			if got := gofman.CanUpdateUser(ctx, jane); got != tt.want {
+				t.Fatalf("Unexpected result: %v", got)
+			}
+		})
+	}
+}
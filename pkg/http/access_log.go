@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// accessLogEntryKey is the context key of the access log entry of a request.
+type accessLogEntryKey struct{}
+
+// accessLogEntry collects the details of a request that are only known to
+// handlers further down the chain.
+type accessLogEntry struct {
+	userID string
+}
+
+// accessLog is middleware for logging every request once it was served. It
+// does nothing unless the AccessLog logger of the server is set.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := s.AccessLog
+		if logger == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		entry := &accessLogEntry{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogEntryKey{}, entry))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		logger.Printf("Request: method=%s path=%q status=%d duration=%s bytes=%d request_id=%q user_id=%q",
+			r.Method, r.URL.Path, rec.Status(), time.Since(start), rec.n,
+			gofman.RequestIDFromContext(r.Context()), entry.userID)
+	})
+}
+
+// setAccessLogUser records the authenticated user of the request in its
+// access log entry. Requests are authenticated below the access log
+// middleware, so the user cannot be read from its context.
+func setAccessLogUser(ctx context.Context, userID string) {
+	if entry, ok := ctx.Value(accessLogEntryKey{}).(*accessLogEntry); ok {
+		entry.userID = userID
+	}
+}
+
+// statusRecorder wraps an http.ResponseWriter to record the status code and
+// the number of bytes written.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+	n      int64
+}
+
+// WriteHeader records the status code and writes it to the underlying
+// writer.
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the written bytes. Writing without a status code implies 200.
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Status returns the recorded status code. Responses without a body or
+// status code are reported as 200 like by net/http.
+func (w *statusRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
@@ -0,0 +1,60 @@
+package http_test
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_AccessLog(t *testing.T) {
+	t.Run("NotFound", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		s := gofmanhttp.NewServer()
+		s.AccessLog = log.New(&buf, "", 0)
+		s.TrustedProxies = []string{"192.0.2.1"}
+
+		r := httptest.NewRequest("GET", "/missing", nil)
+		r.Header.Set(gofmanhttp.RequestIDHeader, "abc")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		line := buf.String()
+		if !strings.Contains(line, `method=GET path="/missing" status=404 `) {
+			t.Fatalf("Unexpected log line: %s", line)
+		} else if !strings.Contains(line, ` bytes=`+strconv.Itoa(w.Body.Len())+` request_id="abc" user_id=""`) {
+			t.Fatalf("Unexpected log line: %s", line)
+		}
+	})
+
+	t.Run("User", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		s := newAuthServer()
+		s.AccessLog = log.New(&buf, "", 0)
+
+		if w := serveAuth(s, "GET", "/account", ""); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		if line := buf.String(); !strings.Contains(line, `status=200 `) || !strings.Contains(line, `user_id="2"`) {
+			t.Fatalf("Unexpected log line: %s", line)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		gofmanhttp.NewServer().ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
@@ -1,10 +1,121 @@
 package http
 
 import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
 	"github.com/gorilla/mux"
 )
 
 // registerActorRoutes is a helper function for registering all actor routes.
 func (s *Server) registerActorRoutes(r *mux.Router) {
-	// TODO
+	r.HandleFunc("/actors", s.handleActorIndex).Methods("GET")
+	r.HandleFunc("/actors", s.handleActorCreate).Methods("POST")
+	r.HandleFunc("/actors/{id}", s.handleActorView).Methods("GET")
+	r.HandleFunc("/actors/{id}", s.handleActorUpdate).Methods("PATCH")
+	r.HandleFunc("/actors/{id}", s.handleActorDelete).Methods("DELETE")
+}
+
+// handleActorIndex lists the actors of the current user. The list can be
+// narrowed down with the name_like, sort_by, sort_desc, offset and limit
+// query parameters.
+func (s *Server) handleActorIndex(w http.ResponseWriter, r *http.Request) {
+	userID := gofman.UserIDFromContext(r.Context())
+	filter := gofman.ActorFilter{UserID: &userID}
+
+	q := r.URL.Query()
+
+	if v := q.Get("name_like"); v != "" {
+		filter.NameLike = &v
+	}
+
+	filter.SortBy = q.Get("sort_by")
+
+	if v := q.Get("sort_desc"); v != "" {
+		var err error
+		if filter.SortDesc, err = strconv.ParseBool(v); err != nil {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "Invalid sort_desc parameter."))
+			return
+		}
+	}
+
+	var err error
+	if filter.Offset, filter.Limit, err = parsePaging(r); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	actors, n, err := s.ActorService.FindActors(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if actors == nil {
+		actors = []*gofman.Actor{}
+	}
+
+	writeList(w, r, actors, filter.Offset, effectiveLimit(filter.Limit), n)
+}
+
+// handleActorView displays a single actor.
+func (s *Server) handleActorView(w http.ResponseWriter, r *http.Request) {
+	actor, err := s.ActorService.FindActorByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, actor)
+}
+
+// handleActorCreate creates an actor from a JSON encoded Actor. The actor
+// always belongs to the current user, IDs and timestamps of the body are
+// ignored.
+func (s *Server) handleActorCreate(w http.ResponseWriter, r *http.Request) {
+	var body gofman.Actor
+	if err := decodeJSON(w, r, &body); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	actor := &gofman.Actor{
+		UserID: gofman.UserIDFromContext(r.Context()),
+		Name:   body.Name,
+	}
+
+	if err := s.ActorService.CreateActor(r.Context(), actor); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	created(w, r, "/actors/"+actor.ID, actor)
+}
+
+// handleActorUpdate updates an actor from a JSON encoded ActorUpdate.
+func (s *Server) handleActorUpdate(w http.ResponseWriter, r *http.Request) {
+	var update gofman.ActorUpdate
+	if err := decodeJSON(w, r, &update); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	actor, err := s.ActorService.UpdateActor(r.Context(), mux.Vars(r)["id"], update)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, actor)
+}
+
+// handleActorDelete moves an actor to the trash.
+func (s *Server) handleActorDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.ActorService.RemoveActor(r.Context(), mux.Vars(r)["id"]); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
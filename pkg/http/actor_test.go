@@ -0,0 +1,193 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_ActorIndex(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.ActorFilter
+		s := newAuthServer()
+		s.ActorService = &ActorService{
+			FindActorsFn: func(ctx context.Context, filter gofman.ActorFilter) ([]*gofman.Actor, int, error) {
+				got = filter
+				return []*gofman.Actor{{ID: "1", UserID: "2", Name: "jane"}}, 11, nil
+			},
+		}
+
+		w := serveAuth(s, "GET", "/actors?name_like=ja&sort_by=name&sort_desc=true&offset=5&limit=5", "")
+
+		var actors []*gofman.Actor
+		resp := gofmanhttp.ListResponse{Data: &actors}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.UserID == nil || *got.UserID != "2" {
+			t.Fatalf("Unexpected user ID: %#v", got.UserID)
+		} else if got.NameLike == nil || *got.NameLike != "ja" || got.SortBy != "name" || !got.SortDesc {
+			t.Fatalf("Unexpected filter: %#v", got)
+		} else if got.Offset != 5 || got.Limit != 5 {
+			t.Fatalf("Unexpected paging: %d, %d", got.Offset, got.Limit)
+		} else if len(actors) != 1 || actors[0].ID != "1" {
+			t.Fatalf("Unexpected actors: %#v", actors)
+		} else if resp.Total != 11 || resp.Limit != 5 || resp.Offset != 5 {
+			t.Fatalf("Unexpected envelope: %#v", resp)
+		} else if w.Header().Get("Link") == "" {
+			t.Fatal("Expected Link header")
+		}
+	})
+
+	t.Run("ErrInvalidLimit", func(t *testing.T) {
+		s := newAuthServer()
+
+		if w := serveAuth(s, "GET", "/actors?limit=x", ""); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_ActorView(t *testing.T) {
+	s := newAuthServer()
+	s.ActorService = &ActorService{
+		FindActorByIDFn: func(ctx context.Context, id string) (*gofman.Actor, error) {
+			if id != "1" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "Actor not found.")
+			}
+
+			return &gofman.Actor{ID: "1", UserID: "2", Name: "jane"}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := serveAuth(s, "GET", "/actors/1", "")
+
+		var actor gofman.Actor
+		if err := json.NewDecoder(w.Body).Decode(&actor); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if actor.ID != "1" || actor.Name != "jane" {
+			t.Fatalf("Unexpected actor: %#v", actor)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		w := serveAuth(s, "GET", "/actors/2", "")
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.Error.Code != gofman.ENOTFOUND || resp.Error.Message != "Actor not found." {
+			t.Fatalf("Unexpected error: %#v", resp.Error)
+		}
+	})
+}
+
+func TestServer_ActorCreate(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.Actor
+		s := newAuthServer()
+		s.ActorService = &ActorService{
+			CreateActorFn: func(ctx context.Context, actor *gofman.Actor) error {
+				got = *actor
+				actor.ID = "1"
+				return nil
+			},
+		}
+
+		w := serveAuth(s, "POST", "/actors", `{"name":"jane","users_id":"3","id":"4"}`)
+
+		var actor gofman.Actor
+		if err := json.NewDecoder(w.Body).Decode(&actor); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.UserID != "2" || got.Name != "jane" || got.ID != "" {
+			t.Fatalf("Unexpected actor: %#v", got)
+		} else if v := w.Header().Get("Location"); v != "/actors/1" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		} else if actor.ID != "1" {
+			t.Fatalf("Unexpected actor: %#v", actor)
+		}
+	})
+
+	t.Run("ErrInvalidJSON", func(t *testing.T) {
+		s := newAuthServer()
+
+		if w := serveAuth(s, "POST", "/actors", `{`); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_ActorUpdate(t *testing.T) {
+	var got gofman.ActorUpdate
+	s := newAuthServer()
+	s.ActorService = &ActorService{
+		UpdateActorFn: func(ctx context.Context, id string, update gofman.ActorUpdate) (*gofman.Actor, error) {
+			if id != "1" {
+				return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to update this actor.")
+			}
+
+			got = update
+			return &gofman.Actor{ID: id, UserID: "2", Name: *update.Name}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := serveAuth(s, "PATCH", "/actors/1", `{"name":"john"}`)
+
+		var actor gofman.Actor
+		if err := json.NewDecoder(w.Body).Decode(&actor); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.Name == nil || *got.Name != "john" {
+			t.Fatalf("Unexpected update: %#v", got)
+		} else if actor.Name != "john" {
+			t.Fatalf("Unexpected actor: %#v", actor)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if w := serveAuth(s, "PATCH", "/actors/2", `{"name":"john"}`); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_ActorDelete(t *testing.T) {
+	var removed []string
+	s := newAuthServer()
+	s.ActorService = &ActorService{
+		RemoveActorFn: func(ctx context.Context, id string) error {
+			removed = append(removed, id)
+			return nil
+		},
+	}
+
+	if w := serveAuth(s, "DELETE", "/actors/1", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if len(removed) != 1 || removed[0] != "1" {
+		t.Fatalf("Unexpected removed actors: %v", removed)
+	}
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// assetsModTime is the modification time of the assets. Embedded files carry
+// no modification time, the assets are replaced only with the binary, so the
+// time the binary started is used instead.
+var assetsModTime = time.Now().UTC().Truncate(time.Second)
+
+// compressAssets returns the gzip compressed content of all files in fsys
+// keyed by their path. Files that do not get smaller are left out.
+func compressAssets(fsys fs.FS) (map[string][]byte, error) {
+	compressed := make(map[string][]byte)
+
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		raw, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+
+		zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+
+		if _, err := zw.Write(raw); err != nil {
+			return err
+		} else if err := zw.Close(); err != nil {
+			return err
+		}
+
+		if buf.Len() < len(raw) {
+			compressed["/"+path] = buf.Bytes()
+		}
+
+		return nil
+	})
+
+	return compressed, err
+}
+
+// hashAssets returns the hex encoded SHA-256 hash of the content of all files
+// in fsys keyed by their path. The hashes are used as strong ETags.
+func hashAssets(fsys fs.FS) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		raw, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(raw)
+		hashes["/"+path] = hex.EncodeToString(sum[:])
+
+		return nil
+	})
+
+	return hashes, err
+}
+
+// assetETag returns the strong ETag of an asset with the given hash. The gzip
+// encoded variant gets its own ETag, as its bytes differ from the original.
+func assetETag(hash string, gzip bool) string {
+	if gzip {
+		return `"` + hash + `-gzip"`
+	}
+
+	return `"` + hash + `"`
+}
+
+// acceptsGzip returns true if the Accept-Encoding header of the request allows
+// a gzip encoded response.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.SplitN(v, ";", 2)
+		if coding := strings.TrimSpace(parts[0]); coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		if len(parts) == 1 {
+			return true
+		}
+
+		q := strings.TrimSpace(parts[1])
+		if !strings.HasPrefix(q, "q=") {
+			return true
+		}
+
+		if f, err := strconv.ParseFloat(strings.TrimPrefix(q, "q="), 64); err == nil && f > 0 {
+			return true
+		}
+	}
+
+	return false
+}
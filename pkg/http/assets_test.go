@@ -0,0 +1,163 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestServer_HandleAssets(t *testing.T) {
+	css := strings.Repeat("body { margin: 0; }\n", 100)
+
+	fsys := fstest.MapFS{
+		"css/main.css": {Data: []byte(css)},
+		"css/tiny.css": {Data: []byte("a{}")},
+	}
+
+	compressed, err := compressAssets(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := hashAssets(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer()
+	h := s.handleAssets(http.FS(fsys), compressed, hashes)
+
+	t.Run("Gzip", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/css/main.css", nil)
+		r.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Content-Encoding"); v != "gzip" {
+			t.Fatalf("Unexpected Content-Encoding header: %q", v)
+		} else if v := w.Header().Get("Vary"); v != "Accept-Encoding" {
+			t.Fatalf("Unexpected Vary header: %q", v)
+		} else if v := w.Header().Get("Content-Type"); !strings.HasPrefix(v, "text/css") {
+			t.Fatalf("Unexpected Content-Type header: %q", v)
+		}
+
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, err := ioutil.ReadAll(zr); err != nil {
+			t.Fatal(err)
+		} else if string(body) != css {
+			t.Fatal("Unexpected decompressed body.")
+		}
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		for _, enc := range []string{"", "gzip;q=0"} {
+			r := httptest.NewRequest("GET", "/css/main.css", nil)
+			r.Header.Set("Accept-Encoding", enc)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Unexpected status: %d", w.Code)
+			} else if v := w.Header().Get("Content-Encoding"); v != "" {
+				t.Fatalf("Unexpected Content-Encoding header: %q", v)
+			} else if v := w.Header().Get("Vary"); v != "Accept-Encoding" {
+				t.Fatalf("Unexpected Vary header: %q", v)
+			} else if !bytes.Equal(w.Body.Bytes(), []byte(css)) {
+				t.Fatal("Unexpected body.")
+			}
+		}
+	})
+
+	t.Run("Incompressible", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/css/tiny.css", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		if v := w.Header().Get("Content-Encoding"); v != "" {
+			t.Fatalf("Unexpected Content-Encoding header: %q", v)
+		} else if w.Body.String() != "a{}" {
+			t.Fatalf("Unexpected body: %q", w.Body.String())
+		}
+	})
+
+	t.Run("IfNoneMatch", func(t *testing.T) {
+		for _, enc := range []string{"", "gzip"} {
+			r := httptest.NewRequest("GET", "/css/main.css", nil)
+			r.Header.Set("Accept-Encoding", enc)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			etag := w.Header().Get("ETag")
+			if w.Code != http.StatusOK {
+				t.Fatalf("Unexpected status: %d", w.Code)
+			} else if etag == "" || strings.HasPrefix(etag, "W/") {
+				t.Fatalf("Unexpected ETag header: %q", etag)
+			} else if v := w.Header().Get("Last-Modified"); v == "" {
+				t.Fatal("Expected Last-Modified header")
+			}
+
+			r = httptest.NewRequest("GET", "/css/main.css", nil)
+			r.Header.Set("Accept-Encoding", enc)
+			r.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotModified {
+				t.Fatalf("Unexpected status: %d", w.Code)
+			} else if w.Body.Len() != 0 {
+				t.Fatalf("Unexpected body: %q", w.Body.String())
+			}
+		}
+	})
+
+	t.Run("IfModifiedSince", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/css/tiny.css", nil)
+		r.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/css/main.css", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		// The ETag of the gzip variant must not match the original content.
+		r = httptest.NewRequest("GET", "/css/main.css", nil)
+		r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		w = httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if w.Body.String() != css {
+			t.Fatal("Unexpected body.")
+		}
+	})
+}
@@ -32,7 +32,11 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := s.UserService.FindUserByID(r.Context(), session.UserID)
+		// The user of the session is not set yet, so the lookup is made on
+		// behalf of the user the session belongs to.
+		ctx := gofman.NewContextWithUser(r.Context(), &gofman.User{ID: session.UserID})
+
+		user, err := s.UserService.FindUserByID(ctx, session.UserID)
 		if err != nil || user == nil {
 			next.ServeHTTP(w, r)
 			return
@@ -41,6 +45,8 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 		r = r.WithContext(gofman.NewContextWithSession(r.Context(), session))
 		r = r.WithContext(gofman.NewContextWithUser(r.Context(), user))
 
+		setAccessLogUser(r.Context(), user.ID)
+
 		next.ServeHTTP(w, r)
 	})
 }
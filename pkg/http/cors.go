@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS constants.
+const (
+	// Duration browsers may cache the result of a preflight request.
+	CORSMaxAge = 10 * time.Minute
+)
+
+// corsAllowedHeaders are the request headers clients of other origins may
+// send. Cookies are not listed, they are allowed by the credentials flag.
+var corsAllowedHeaders = []string{
+	"Content-Type",
+	CSRFHeader,
+	RequestIDHeader,
+}
+
+// corsExposedHeaders are the response headers scripts of other origins may
+// read.
+var corsExposedHeaders = []string{
+	"Link",
+	"Location",
+	"Retry-After",
+	RequestIDHeader,
+}
+
+// cors is middleware for allowing scripts of the configured origins to call
+// the API with the cookies of the user. Requests from all other origins get
+// no CORS headers, so browsers do not expose the response to them.
+// Preflight requests are answered by handleOptions, which must be wrapped as
+// well.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(s.CORSOrigins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The response depends on the origin, caches must not share it
+		// between origins.
+		w.Header().Add("Vary", "Origin")
+
+		if !s.isAllowedOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			methods := append(s.allowedMethods(r), http.MethodOptions)
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(CORSMaxAge/time.Second)))
+		} else {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAllowedOrigin returns true if the origin is part of the configured
+// origins. Wildcards are not supported, browsers reject them for requests
+// with credentials anyway.
+func (s *Server) isAllowedOrigin(origin string) bool {
+	for _, v := range s.CORSOrigins {
+		if strings.EqualFold(strings.TrimSuffix(v, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
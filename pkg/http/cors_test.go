@@ -0,0 +1,126 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestServer_CORS(t *testing.T) {
+	s := newAuthServer()
+	s.CORSOrigins = []string{"https://app.example.com"}
+	s.ActorService = &ActorService{
+		FindActorsFn: func(ctx context.Context, filter gofman.ActorFilter) ([]*gofman.Actor, int, error) {
+			return nil, 0, nil
+		},
+	}
+
+	t.Run("Allowed", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/actors", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://app.example.com" {
+			t.Fatalf("Unexpected Access-Control-Allow-Origin header: %q", v)
+		} else if v := w.Header().Get("Access-Control-Allow-Credentials"); v != "true" {
+			t.Fatalf("Unexpected Access-Control-Allow-Credentials header: %q", v)
+		} else if v := w.Header().Get("Access-Control-Expose-Headers"); v == "" {
+			t.Fatal("Expected Access-Control-Expose-Headers header")
+		} else if v := w.Header().Get("Vary"); v != "Origin" {
+			t.Fatalf("Unexpected Vary header: %q", v)
+		}
+	})
+
+	t.Run("AllowedUnauthenticated", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/actors", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		// Responses of the middleware, like the redirect to the login, must
+		// be readable by the frontend too.
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://app.example.com" {
+			t.Fatalf("Unexpected Access-Control-Allow-Origin header: %q", v)
+		}
+	})
+
+	t.Run("Disallowed", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/actors", nil)
+		r.Header.Set("Origin", "https://evil.example.com")
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+			t.Fatalf("Unexpected Access-Control-Allow-Origin header: %q", v)
+		} else if v := w.Header().Get("Access-Control-Allow-Credentials"); v != "" {
+			t.Fatalf("Unexpected Access-Control-Allow-Credentials header: %q", v)
+		}
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		r := httptest.NewRequest("OPTIONS", "/actors/1", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set("Access-Control-Request-Method", "PATCH")
+		r.Header.Set("Access-Control-Request-Headers", "content-type, x-csrf-token")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://app.example.com" {
+			t.Fatalf("Unexpected Access-Control-Allow-Origin header: %q", v)
+		} else if v := w.Header().Get("Access-Control-Allow-Methods"); v != "GET, HEAD, PATCH, DELETE, OPTIONS" {
+			t.Fatalf("Unexpected Access-Control-Allow-Methods header: %q", v)
+		} else if v := w.Header().Get("Access-Control-Allow-Headers"); v != "Content-Type, X-CSRF-Token, X-Request-Id" {
+			t.Fatalf("Unexpected Access-Control-Allow-Headers header: %q", v)
+		} else if v := w.Header().Get("Access-Control-Max-Age"); v != "600" {
+			t.Fatalf("Unexpected Access-Control-Max-Age header: %q", v)
+		}
+	})
+
+	t.Run("PreflightDisallowed", func(t *testing.T) {
+		r := httptest.NewRequest("OPTIONS", "/actors/1", nil)
+		r.Header.Set("Origin", "https://evil.example.com")
+		r.Header.Set("Access-Control-Request-Method", "PATCH")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+			t.Fatalf("Unexpected Access-Control-Allow-Origin header: %q", v)
+		} else if v := w.Header().Get("Access-Control-Allow-Methods"); v != "" {
+			t.Fatalf("Unexpected Access-Control-Allow-Methods header: %q", v)
+		}
+	})
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		s := newAuthServer()
+
+		r := httptest.NewRequest("OPTIONS", "/actors/1", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set("Access-Control-Request-Method", "PATCH")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+			t.Fatalf("Unexpected Access-Control-Allow-Origin header: %q", v)
+		} else if v := w.Header().Get("Vary"); v != "" {
+			t.Fatalf("Unexpected Vary header: %q", v)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/gorilla/mux"
+)
+
+// CSRF constants. Clients send the value of the CSRF cookie back in the
+// CSRF header with every request that changes data.
+const (
+	CSRFCookie = "CSRF"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// registerCSRFRoutes is a helper function for registering all CSRF routes.
+func (s *Server) registerCSRFRoutes(r *mux.Router) {
+	r.HandleFunc("/csrf", s.handleCSRF).Methods("GET")
+}
+
+// CSRFResponse represents the JSON structure returned by GET /csrf.
+type CSRFResponse struct {
+	Token string `json:"token"`
+}
+
+// handleCSRF returns the CSRF token of the client and sets the CSRF cookie
+// if it is missing.
+func (s *Server) handleCSRF(w http.ResponseWriter, r *http.Request) {
+	token, err := s.csrfToken(w, r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &CSRFResponse{Token: token})
+}
+
+// csrf is middleware for protecting cookie authenticated requests against
+// cross-site request forgery. Requests with unsafe methods must send the
+// value of the CSRF cookie in the CSRF header. Other sites can neither read
+// the cookie nor set the header, so they cannot forge such requests.
+func (s *Server) csrf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(CSRFCookie)
+		if err != nil || cookie.Value == "" {
+			Error(w, r, gofman.NewError(gofman.EFORBIDDEN, "Missing CSRF token."))
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.Header.Get(CSRFHeader))) != 1 {
+			Error(w, r, gofman.NewError(gofman.EFORBIDDEN, "Invalid CSRF token."))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfToken returns the CSRF token of the request. A new token is generated
+// and set as cookie if the request does not have one yet.
+func (s *Server) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(CSRFCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not generate CSRF token.")
+	}
+
+	token := hex.EncodeToString(b)
+
+	// The cookie is readable by scripts of the site on purpose, they send it
+	// back in the CSRF header.
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   s.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return token, nil
+}
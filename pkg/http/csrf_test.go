@@ -0,0 +1,150 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_CSRF(t *testing.T) {
+	s := newAuthServer()
+	s.TagService = &TagService{
+		CreateTagFn: func(ctx context.Context, tag *gofman.Tag) error {
+			tag.ID = "1"
+			return nil
+		},
+	}
+
+	// serve sends a POST /tags request with the session cookies and the given
+	// CSRF cookie and header. Empty values are not sent.
+	serve := func(cookie, header string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/tags", strings.NewReader(`{"name":"work"}`))
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: cookie})
+		}
+		if header != "" {
+			r.Header.Set(gofmanhttp.CSRFHeader, header)
+		}
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// expectForbidden checks for a forbidden error with the given message.
+	expectForbidden := func(t *testing.T, w *httptest.ResponseRecorder, message string) {
+		t.Helper()
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.Error.Code != gofman.EFORBIDDEN || resp.Error.Message != message {
+			t.Fatalf("Unexpected error: %#v", resp.Error)
+		}
+	}
+
+	t.Run("ErrMissing", func(t *testing.T) {
+		expectForbidden(t, serve("", ""), "Missing CSRF token.")
+	})
+
+	t.Run("ErrMissingCookie", func(t *testing.T) {
+		expectForbidden(t, serve("", "csrf"), "Missing CSRF token.")
+	})
+
+	t.Run("ErrMissingHeader", func(t *testing.T) {
+		expectForbidden(t, serve("csrf", ""), "Invalid CSRF token.")
+	})
+
+	t.Run("ErrMismatch", func(t *testing.T) {
+		expectForbidden(t, serve("csrf", "other"), "Invalid CSRF token.")
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if w := serve("csrf", "csrf"); w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("SafeMethod", func(t *testing.T) {
+		s.TagService.(*TagService).FindTagsFn = func(ctx context.Context, filter gofman.TagFilter) ([]*gofman.Tag, int, error) {
+			return nil, 0, nil
+		}
+
+		r := httptest.NewRequest("GET", "/tags", nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_CSRFToken(t *testing.T) {
+	s := newAuthServer()
+
+	t.Run("Existing", func(t *testing.T) {
+		w := serveAuth(s, "GET", "/csrf", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		// serveAuth already sends a CSRF cookie, so it is returned as is.
+		var resp gofmanhttp.CSRFResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		} else if resp.Token != "csrf" {
+			t.Fatalf("Unexpected token: %q", resp.Token)
+		}
+	})
+
+	t.Run("Generate", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/csrf", nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		var resp gofmanhttp.CSRFResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		} else if len(resp.Token) != 64 {
+			t.Fatalf("Unexpected token: %q", resp.Token)
+		}
+
+		var cookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == gofmanhttp.CSRFCookie {
+				cookie = c
+			}
+		}
+
+		if cookie == nil {
+			t.Fatal("Expected CSRF cookie")
+		} else if cookie.Value != resp.Token || cookie.HttpOnly {
+			t.Fatalf("Unexpected cookie: %#v", cookie)
+		}
+	})
+
+}
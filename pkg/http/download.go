@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/gorilla/mux"
+)
+
+// handleFileDownload streams a file of the current user from disk. Range
+// requests and conditional GETs are supported. The file is always sent as
+// attachment, so uploaded HTML cannot run in the context of the site.
+func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
+	file, err := s.FileService.FindFileByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Lookups are already limited to the files of the current user, this
+	// only guards against services that are not.
+	if file.UserID != gofman.UserIDFromContext(r.Context()) {
+		Error(w, r, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to download this file."))
+		return
+	}
+
+	path, err := s.PathTraversalService.Resolve(s.StorageRoot, file.Path)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		Error(w, r, gofman.NewError(gofman.ENOTFOUND, "File not found on disk."))
+		return
+	} else if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if file.Type != "" {
+		w.Header().Set("Content-Type", file.Type)
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	s.serveContent(w, r, file.Name, info.ModTime(), f)
+}
+
+// serveContent streams the content to the client using http.ServeContent so
+// Range requests and conditional GETs are supported. The content is throttled
+// to the configured download rate of the server.
+func (s *Server) serveContent(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+	http.ServeContent(w, r, name, modtime, throttle(r.Context(), content, s.DownloadRate))
+}
+
+// throttle wraps the given io.ReadSeeker so it can not be read faster than
+// rate bytes per second. A rate of zero or less disables throttling. Reads
+// stop waiting and fail once ctx is done.
+func throttle(ctx context.Context, rs io.ReadSeeker, rate int64) io.ReadSeeker {
+	if rate <= 0 {
+		return rs
+	}
+
+	return &throttledReadSeeker{ctx: ctx, rs: rs, rate: rate}
+}
+
+// throttledReadSeeker represents an io.ReadSeeker with a bandwidth limit.
+type throttledReadSeeker struct {
+	ctx  context.Context
+	rs   io.ReadSeeker
+	rate int64
+
+	start time.Time
+	n     int64
+}
+
+// Read reads at most rate bytes and waits until the average throughput since
+// the first read is back under the configured rate. Waiting is cut short with
+// the context error when the context is done.
+func (t *throttledReadSeeker) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	if int64(len(p)) > t.rate {
+		p = p[:t.rate]
+	}
+
+	n, err := t.rs.Read(p)
+	t.n += int64(n)
+
+	if wait := t.expected() - time.Since(t.start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+
+	return n, err
+}
+
+// expected returns how long reading all bytes so far should take at the
+// configured rate. Whole seconds and the remainder are computed separately,
+// so large downloads do not overflow.
+func (t *throttledReadSeeker) expected() time.Duration {
+	return time.Duration(t.n/t.rate)*time.Second + time.Duration(t.n%t.rate)*time.Second/time.Duration(t.rate)
+}
+
+// Seek implements the io.Seeker interface. Seeking does not reset the rate
+// limit.
+func (t *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return t.rs.Seek(offset, whence)
+}
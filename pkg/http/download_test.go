@@ -0,0 +1,89 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_ServeContent(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 20)
+
+	t.Run("Unthrottled", func(t *testing.T) {
+		if rs := bytes.NewReader(content); throttle(context.Background(), rs, 0) != rs {
+			t.Fatal("Expected reader to be returned unchanged.")
+		}
+	})
+
+	t.Run("Throttled", func(t *testing.T) {
+		s := NewServer()
+		s.DownloadRate = 1000
+
+		r := httptest.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		s.serveContent(w, r, "file.txt", time.Time{}, bytes.NewReader(content))
+
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Fatalf("Expected download to take at least 200ms, took %s", elapsed)
+		} else if !bytes.Equal(w.Body.Bytes(), content) {
+			t.Fatal("Unexpected body.")
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		s := NewServer()
+		s.DownloadRate = 10
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		s.serveContent(w, r, "file.txt", time.Time{}, bytes.NewReader(content))
+
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("Expected download to stop with the request, took %s", elapsed)
+		} else if w.Body.Len() >= len(content) {
+			t.Fatalf("Unexpected body length: %d", w.Body.Len())
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		s := NewServer()
+		s.DownloadRate = 1000
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Range", "bytes=10-19")
+		w := httptest.NewRecorder()
+
+		s.serveContent(w, r, "file.txt", time.Time{}, bytes.NewReader(content))
+
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		} else if v := resp.Header.Get("Content-Range"); v != "bytes 10-19/200" {
+			t.Fatalf("Unexpected Content-Range header: %q", v)
+		} else if string(body) != "0123456789" {
+			t.Fatalf("Unexpected body: %q", body)
+		}
+	})
+}
+
+func TestThrottledReadSeeker_Expected(t *testing.T) {
+	// 10 GiB at 1 MiB/s would overflow when multiplying bytes by a second.
+	rs := &throttledReadSeeker{rate: 1 << 20, n: 10<<30 + 1<<19}
+
+	if got, want := rs.expected(), 10240*time.Second+500*time.Millisecond; got != want {
+		t.Fatalf("Unexpected duration: %s, want %s", got, want)
+	}
+}
@@ -1,10 +1,172 @@
 package http
 
 import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
 	"github.com/gorilla/mux"
 )
 
 // registerFileRoutes is a helper function for registering all file routes.
 func (s *Server) registerFileRoutes(r *mux.Router) {
-	// TODO
+	r.HandleFunc("/files", s.handleFileIndex).Methods("GET")
+	r.HandleFunc("/files", s.handleFileCreate).Methods("POST")
+	r.HandleFunc("/files/{id}", s.handleFileView).Methods("GET")
+	r.HandleFunc("/files/{id}", s.handleFileUpdate).Methods("PATCH")
+	r.HandleFunc("/files/{id}", s.handleFileDelete).Methods("DELETE")
+	r.HandleFunc("/files/{id}/recompute-checksum", s.handleFileRecomputeChecksum).Methods("POST")
+}
+
+// registerUploadRoutes is a helper function for registering all routes that
+// receive large bodies. They are subject to the upload timeout instead of the
+// API timeout.
+func (s *Server) registerUploadRoutes(r *mux.Router) {
+	r.HandleFunc("/files/upload", s.handleFileUpload).Methods("POST")
+}
+
+// registerDownloadRoutes is a helper function for registering all routes that
+// send large bodies. They are not subject to a timeout, as slow clients and
+// the download rate may keep them busy for long.
+func (s *Server) registerDownloadRoutes(r *mux.Router) {
+	r.HandleFunc("/files/{id}/download", s.handleFileDownload).Methods("GET")
+}
+
+// handleFileIndex lists the files of the current user. The list can be
+// narrowed down with the folder_id, type, name_like, min_size, sort_by,
+// sort_desc, offset and limit query parameters.
+func (s *Server) handleFileIndex(w http.ResponseWriter, r *http.Request) {
+	userID := gofman.UserIDFromContext(r.Context())
+	filter := gofman.FileFilter{UserID: &userID}
+
+	q := r.URL.Query()
+
+	if v := q.Get("folder_id"); v != "" {
+		filter.FolderID = &v
+	}
+
+	if v := q.Get("type"); v != "" {
+		filter.Type = &v
+	}
+
+	if v := q.Get("name_like"); v != "" {
+		filter.NameLike = &v
+	}
+
+	if v := q.Get("min_size"); v != "" {
+		minSize, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "Invalid min_size parameter."))
+			return
+		}
+
+		filter.MinSize = &minSize
+	}
+
+	filter.SortBy = q.Get("sort_by")
+
+	if v := q.Get("sort_desc"); v != "" {
+		var err error
+		if filter.SortDesc, err = strconv.ParseBool(v); err != nil {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "Invalid sort_desc parameter."))
+			return
+		}
+	}
+
+	var err error
+	if filter.Offset, filter.Limit, err = parsePaging(r); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	files, n, err := s.FileService.FindFiles(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if files == nil {
+		files = []*gofman.File{}
+	}
+
+	writeList(w, r, files, filter.Offset, effectiveLimit(filter.Limit), n)
+}
+
+// handleFileView displays a single file.
+func (s *Server) handleFileView(w http.ResponseWriter, r *http.Request) {
+	file, err := s.FileService.FindFileByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, file)
+}
+
+// handleFileCreate creates a file from a JSON encoded File. The file always
+// belongs to the current user, IDs and timestamps of the body are ignored.
+func (s *Server) handleFileCreate(w http.ResponseWriter, r *http.Request) {
+	var body gofman.File
+	if err := decodeJSON(w, r, &body); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	file := &gofman.File{
+		UserID:       gofman.UserIDFromContext(r.Context()),
+		FolderID:     body.FolderID,
+		Name:         body.Name,
+		Type:         body.Type,
+		Path:         body.Path,
+		Checksum:     body.Checksum,
+		ChecksumAlgo: body.ChecksumAlgo,
+		Description:  body.Description,
+		Size:         body.Size,
+	}
+
+	if err := s.FileService.CreateFile(r.Context(), file); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	created(w, r, "/files/"+file.ID, file)
+}
+
+// handleFileUpdate updates a file from a JSON encoded FileUpdate.
+func (s *Server) handleFileUpdate(w http.ResponseWriter, r *http.Request) {
+	var update gofman.FileUpdate
+	if err := decodeJSON(w, r, &update); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	file, err := s.FileService.UpdateFile(r.Context(), mux.Vars(r)["id"], update)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, file)
+}
+
+// handleFileDelete moves a file to the trash. The file on disk is kept.
+func (s *Server) handleFileDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.FileService.RemoveFile(r.Context(), mux.Vars(r)["id"]); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFileRecomputeChecksum reads a file from disk again and stores its new
+// checksum. This acknowledges changes made to the file outside of gofman.
+func (s *Server) handleFileRecomputeChecksum(w http.ResponseWriter, r *http.Request) {
+	file, err := s.FileService.RecomputeChecksum(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, file)
 }
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/gorilla/mux"
+)
+
+// registerFileTagRoutes is a helper function for registering all routes that
+// attach tags to files.
+func (s *Server) registerFileTagRoutes(r *mux.Router) {
+	r.HandleFunc("/files/{id}/tags", s.handleFileTagIndex).Methods("GET")
+	r.HandleFunc("/files/{id}/tags/{tag_id}", s.handleFileTagCreate).Methods("PUT")
+	r.HandleFunc("/files/{id}/tags/{tag_id}", s.handleFileTagDelete).Methods("DELETE")
+	r.HandleFunc("/tags/{id}/files", s.handleTagFileIndex).Methods("GET")
+}
+
+// handleFileTagIndex lists the tags attached to a file, oldest first. All
+// tags of the file fit on a single page.
+func (s *Server) handleFileTagIndex(w http.ResponseWriter, r *http.Request) {
+	tags, err := s.FileTagService.TagsForFile(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if tags == nil {
+		tags = []*gofman.Tag{}
+	}
+
+	writeJSON(w, http.StatusOK, &ListResponse{Data: tags, Total: len(tags)})
+}
+
+// handleFileTagCreate attaches a tag to a file. Attaching a tag twice
+// responds with a conflict.
+func (s *Server) handleFileTagCreate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := s.FileTagService.AddTag(r.Context(), vars["id"], vars["tag_id"]); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFileTagDelete detaches a tag from a file.
+func (s *Server) handleFileTagDelete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := s.FileTagService.RemoveTag(r.Context(), vars["id"], vars["tag_id"]); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTagFileIndex lists the files a tag is attached to, oldest first. All
+// files of the tag fit on a single page.
+func (s *Server) handleTagFileIndex(w http.ResponseWriter, r *http.Request) {
+	files, err := s.FileTagService.FilesForTag(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if files == nil {
+		files = []*gofman.File{}
+	}
+
+	writeJSON(w, http.StatusOK, &ListResponse{Data: files, Total: len(files)})
+}
@@ -0,0 +1,139 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_FileTagIndex(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		s := newAuthServer()
+		s.FileTagService = &FileTagService{
+			TagsForFileFn: func(ctx context.Context, fileID string) ([]*gofman.Tag, error) {
+				if fileID != "1" {
+					return nil, gofman.NewError(gofman.ENOTFOUND, "File not found.")
+				}
+
+				return []*gofman.Tag{{ID: "3", UserID: "2", Name: "summer"}}, nil
+			},
+		}
+
+		w := serveAuth(s, "GET", "/files/1/tags", "")
+
+		var tags []*gofman.Tag
+		resp := gofmanhttp.ListResponse{Data: &tags}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if len(tags) != 1 || tags[0].ID != "3" || resp.Total != 1 {
+			t.Fatalf("Unexpected tags: %#v", tags)
+		}
+
+		if w := serveAuth(s, "GET", "/files/2/tags", ""); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		s := newAuthServer()
+		s.FileTagService = &FileTagService{
+			TagsForFileFn: func(ctx context.Context, fileID string) ([]*gofman.Tag, error) {
+				return nil, nil
+			},
+		}
+
+		w := serveAuth(s, "GET", "/files/1/tags", "")
+
+		var resp map[string]json.RawMessage
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		} else if string(resp["data"]) != "[]" {
+			t.Fatalf("Unexpected data: %s", resp["data"])
+		}
+	})
+}
+
+func TestServer_FileTagCreate(t *testing.T) {
+	var fileID, tagID string
+	s := newAuthServer()
+	s.FileTagService = &FileTagService{
+		AddTagFn: func(ctx context.Context, f, t string) error {
+			if f == fileID && t == tagID {
+				return gofman.NewError(gofman.ECONFLICT, "Tag is already attached to the file.")
+			}
+
+			fileID, tagID = f, t
+			return nil
+		},
+	}
+
+	if w := serveAuth(s, "PUT", "/files/1/tags/3", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if fileID != "1" || tagID != "3" {
+		t.Fatalf("Unexpected IDs: %q, %q", fileID, tagID)
+	}
+
+	if w := serveAuth(s, "PUT", "/files/1/tags/3", ""); w.Code != http.StatusConflict {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
+
+func TestServer_FileTagDelete(t *testing.T) {
+	s := newAuthServer()
+	s.FileTagService = &FileTagService{
+		RemoveTagFn: func(ctx context.Context, fileID, tagID string) error {
+			if fileID != "1" || tagID != "3" {
+				return gofman.NewError(gofman.ENOTFOUND, "Tag is not attached to the file.")
+			}
+
+			return nil
+		},
+	}
+
+	if w := serveAuth(s, "DELETE", "/files/1/tags/3", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	if w := serveAuth(s, "DELETE", "/files/1/tags/4", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
+
+func TestServer_TagFileIndex(t *testing.T) {
+	s := newAuthServer()
+	s.FileTagService = &FileTagService{
+		FilesForTagFn: func(ctx context.Context, tagID string) ([]*gofman.File, error) {
+			if tagID != "3" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "Tag not found.")
+			}
+
+			return []*gofman.File{{ID: "1", UserID: "2", Name: "beach.jpg"}}, nil
+		},
+	}
+
+	w := serveAuth(s, "GET", "/tags/3/files", "")
+
+	var files []*gofman.File
+	resp := gofmanhttp.ListResponse{Data: &files}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if len(files) != 1 || files[0].ID != "1" || resp.Total != 1 {
+		t.Fatalf("Unexpected files: %#v", files)
+	}
+
+	if w := serveAuth(s, "GET", "/tags/4/files", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
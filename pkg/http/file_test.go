@@ -0,0 +1,263 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_FileRecomputeChecksum(t *testing.T) {
+	s := gofmanhttp.NewServer()
+	s.SessionService = &SessionService{
+		FindSessionForTokenFn: func(ctx context.Context, id string, token string) (*gofman.Session, error) {
+			return &gofman.Session{ID: id, UserID: "2", Token: token}, nil
+		},
+	}
+	s.UserService = &UserService{
+		FindUserByIDFn: func(ctx context.Context, id string) (*gofman.User, error) {
+			return &gofman.User{ID: id, Username: "jane"}, nil
+		},
+	}
+	s.FileService = &FileService{
+		RecomputeChecksumFn: func(ctx context.Context, id string) (*gofman.File, error) {
+			if id != "3" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "File not found on disk.")
+			}
+
+			return &gofman.File{ID: id, UserID: "2", Checksum: "abc"}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/files/3/recompute-checksum", nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: "csrf"})
+		r.Header.Set(gofmanhttp.CSRFHeader, "csrf")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		var file gofman.File
+		if err := json.NewDecoder(w.Body).Decode(&file); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if file.ID != "3" || file.Checksum != "abc" {
+			t.Fatalf("Unexpected file: %#v", file)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/files/4/recompute-checksum", nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: "csrf"})
+		r.Header.Set(gofmanhttp.CSRFHeader, "csrf")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_FileIndex(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.FileFilter
+		s := newAuthServer()
+		s.FileService = &FileService{
+			FindFilesFn: func(ctx context.Context, filter gofman.FileFilter) ([]*gofman.File, int, error) {
+				got = filter
+				return []*gofman.File{{ID: "1", UserID: "2", Name: "a.txt"}}, 1, nil
+			},
+		}
+
+		w := serveAuth(s, "GET", "/files?folder_id=3&type=text/plain&min_size=10&users_id=4", "")
+
+		var files []*gofman.File
+		resp := gofmanhttp.ListResponse{Data: &files}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.UserID == nil || *got.UserID != "2" {
+			t.Fatalf("Unexpected user ID: %#v", got.UserID)
+		} else if got.FolderID == nil || *got.FolderID != "3" || got.Type == nil || *got.Type != "text/plain" {
+			t.Fatalf("Unexpected filter: %#v", got)
+		} else if got.MinSize == nil || *got.MinSize != 10 {
+			t.Fatalf("Unexpected min size: %#v", got.MinSize)
+		} else if len(files) != 1 || files[0].ID != "1" {
+			t.Fatalf("Unexpected files: %#v", files)
+		} else if resp.Total != 1 || resp.Limit != gofman.DefaultLimit || resp.Offset != 0 {
+			t.Fatalf("Unexpected envelope: %#v", resp)
+		}
+	})
+
+	t.Run("ErrInvalidMinSize", func(t *testing.T) {
+		if w := serveAuth(newAuthServer(), "GET", "/files?min_size=x", ""); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_FileView(t *testing.T) {
+	s := newAuthServer()
+	s.FileService = &FileService{
+		FindFileByIDFn: func(ctx context.Context, id string) (*gofman.File, error) {
+			if id != "1" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "File not found.")
+			}
+
+			return &gofman.File{ID: id, UserID: "2", Name: "a.txt"}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := serveAuth(s, "GET", "/files/1", "")
+
+		var file gofman.File
+		if err := json.NewDecoder(w.Body).Decode(&file); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if file.ID != "1" || file.Name != "a.txt" {
+			t.Fatalf("Unexpected file: %#v", file)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if w := serveAuth(s, "GET", "/files/2", ""); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_FileCreate(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.File
+		s := newAuthServer()
+		s.FileService = &FileService{
+			CreateFileFn: func(ctx context.Context, file *gofman.File) error {
+				got = *file
+				file.ID = "1"
+				return nil
+			},
+		}
+
+		w := serveAuth(s, "POST", "/files", `{"id":"5","users_id":"3","name":"a.txt","type":"text/plain","path":"/a.txt","size":4}`)
+
+		var file gofman.File
+		if err := json.NewDecoder(w.Body).Decode(&file); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.ID != "" || got.UserID != "2" {
+			t.Fatalf("Unexpected owner: %#v", got)
+		} else if got.Name != "a.txt" || got.Type != "text/plain" || got.Path != "/a.txt" || got.Size != 4 {
+			t.Fatalf("Unexpected file: %#v", got)
+		} else if v := w.Header().Get("Location"); v != "/files/1" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		} else if file.ID != "1" {
+			t.Fatalf("Unexpected file: %#v", file)
+		}
+	})
+
+	t.Run("ErrInvalid", func(t *testing.T) {
+		s := newAuthServer()
+		s.FileService = &FileService{
+			CreateFileFn: func(ctx context.Context, file *gofman.File) error {
+				return gofman.NewError(gofman.EINVALID, "Name required.")
+			},
+		}
+
+		w := serveAuth(s, "POST", "/files", `{}`)
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.Error.Message != "Name required." {
+			t.Fatalf("Unexpected error: %#v", resp.Error)
+		}
+	})
+}
+
+func TestServer_FileUpdate(t *testing.T) {
+	var got gofman.FileUpdate
+	s := newAuthServer()
+	s.FileService = &FileService{
+		UpdateFileFn: func(ctx context.Context, id string, update gofman.FileUpdate) (*gofman.File, error) {
+			got = update
+			return &gofman.File{ID: id, UserID: "2", Name: *update.Name}, nil
+		},
+	}
+
+	w := serveAuth(s, "PATCH", "/files/1", `{"name":"b.txt","folder_id":""}`)
+
+	var file gofman.File
+	if err := json.NewDecoder(w.Body).Decode(&file); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if got.Name == nil || *got.Name != "b.txt" || got.FolderID == nil || *got.FolderID != "" || got.Type != nil {
+		t.Fatalf("Unexpected update: %#v", got)
+	} else if file.ID != "1" || file.Name != "b.txt" {
+		t.Fatalf("Unexpected file: %#v", file)
+	}
+}
+
+func TestServer_FileDelete(t *testing.T) {
+	s := newAuthServer()
+	s.FileService = &FileService{
+		RemoveFileFn: func(ctx context.Context, id string) error {
+			if id != "1" {
+				return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to remove this file.")
+			}
+
+			return nil
+		},
+	}
+
+	if w := serveAuth(s, "DELETE", "/files/1", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	if w := serveAuth(s, "DELETE", "/files/2", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
+
+func TestServer_FileDownload(t *testing.T) {
+	s := newAuthServer()
+	s.FileService = &FileService{
+		FindFileByIDFn: func(ctx context.Context, id string) (*gofman.File, error) {
+			return &gofman.File{ID: id, UserID: "3", Name: "hello.txt", Path: "/tmp/hello.txt"}, nil
+		},
+	}
+
+	// Files of other users are rejected even if the service returns them.
+	if w := serveAuth(s, "GET", "/files/1/download", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
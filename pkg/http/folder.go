@@ -0,0 +1,121 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/gorilla/mux"
+)
+
+// registerFolderRoutes is a helper function for registering all folder
+// routes.
+func (s *Server) registerFolderRoutes(r *mux.Router) {
+	r.HandleFunc("/folders", s.handleFolderIndex).Methods("GET")
+	r.HandleFunc("/folders", s.handleFolderCreate).Methods("POST")
+	r.HandleFunc("/folders/{id}", s.handleFolderView).Methods("GET")
+	r.HandleFunc("/folders/{id}", s.handleFolderUpdate).Methods("PATCH")
+	r.HandleFunc("/folders/{id}", s.handleFolderDelete).Methods("DELETE")
+}
+
+// handleFolderIndex lists the folders of the current user. The list can be
+// narrowed down with the parent_id, root, offset and limit query parameters.
+func (s *Server) handleFolderIndex(w http.ResponseWriter, r *http.Request) {
+	userID := gofman.UserIDFromContext(r.Context())
+	filter := gofman.FolderFilter{UserID: &userID}
+
+	q := r.URL.Query()
+
+	if v := q.Get("parent_id"); v != "" {
+		filter.ParentID = &v
+	}
+
+	if v := q.Get("root"); v != "" {
+		var err error
+		if filter.Root, err = strconv.ParseBool(v); err != nil {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "Invalid root parameter."))
+			return
+		}
+	}
+
+	var err error
+	if filter.Offset, filter.Limit, err = parsePaging(r); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	folders, n, err := s.FolderService.FindFolders(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if folders == nil {
+		folders = []*gofman.Folder{}
+	}
+
+	writeList(w, r, folders, filter.Offset, effectiveLimit(filter.Limit), n)
+}
+
+// handleFolderView displays a single folder.
+func (s *Server) handleFolderView(w http.ResponseWriter, r *http.Request) {
+	folder, err := s.FolderService.FindFolderByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folder)
+}
+
+// handleFolderCreate creates a folder from a JSON encoded Folder. The folder
+// always belongs to the current user, IDs and timestamps of the body are
+// ignored.
+func (s *Server) handleFolderCreate(w http.ResponseWriter, r *http.Request) {
+	var body gofman.Folder
+	if err := decodeJSON(w, r, &body); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	folder := &gofman.Folder{
+		UserID:   gofman.UserIDFromContext(r.Context()),
+		Name:     body.Name,
+		ParentID: body.ParentID,
+	}
+
+	if err := s.FolderService.CreateFolder(r.Context(), folder); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	created(w, r, "/folders/"+folder.ID, folder)
+}
+
+// handleFolderUpdate renames or moves a folder from a JSON encoded
+// FolderUpdate.
+func (s *Server) handleFolderUpdate(w http.ResponseWriter, r *http.Request) {
+	var update gofman.FolderUpdate
+	if err := decodeJSON(w, r, &update); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	folder, err := s.FolderService.UpdateFolder(r.Context(), mux.Vars(r)["id"], update)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folder)
+}
+
+// handleFolderDelete moves a folder to the trash.
+func (s *Server) handleFolderDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.FolderService.RemoveFolder(r.Context(), mux.Vars(r)["id"]); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,210 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_FolderIndex(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.FolderFilter
+		s := newAuthServer()
+		s.FolderService = &FolderService{
+			FindFoldersFn: func(ctx context.Context, filter gofman.FolderFilter) ([]*gofman.Folder, int, error) {
+				got = filter
+				return []*gofman.Folder{{ID: "1", UserID: "2", Name: "Holidays"}}, 11, nil
+			},
+		}
+
+		w := serveAuth(s, "GET", "/folders?parent_id=3&offset=5&limit=5", "")
+
+		var folders []*gofman.Folder
+		resp := gofmanhttp.ListResponse{Data: &folders}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.UserID == nil || *got.UserID != "2" {
+			t.Fatalf("Unexpected user ID: %#v", got.UserID)
+		} else if got.ParentID == nil || *got.ParentID != "3" || got.Root {
+			t.Fatalf("Unexpected filter: %#v", got)
+		} else if got.Offset != 5 || got.Limit != 5 {
+			t.Fatalf("Unexpected paging: %d, %d", got.Offset, got.Limit)
+		} else if len(folders) != 1 || folders[0].ID != "1" {
+			t.Fatalf("Unexpected folders: %#v", folders)
+		} else if resp.Total != 11 || resp.Limit != 5 || resp.Offset != 5 {
+			t.Fatalf("Unexpected envelope: %#v", resp)
+		}
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		var got gofman.FolderFilter
+		s := newAuthServer()
+		s.FolderService = &FolderService{
+			FindFoldersFn: func(ctx context.Context, filter gofman.FolderFilter) ([]*gofman.Folder, int, error) {
+				got = filter
+				return nil, 0, nil
+			},
+		}
+
+		if w := serveAuth(s, "GET", "/folders?root=true", ""); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if !got.Root || got.ParentID != nil {
+			t.Fatalf("Unexpected filter: %#v", got)
+		}
+	})
+
+	t.Run("ErrInvalidRoot", func(t *testing.T) {
+		s := newAuthServer()
+
+		if w := serveAuth(s, "GET", "/folders?root=x", ""); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_FolderView(t *testing.T) {
+	s := newAuthServer()
+	s.FolderService = &FolderService{
+		FindFolderByIDFn: func(ctx context.Context, id string) (*gofman.Folder, error) {
+			if id != "1" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "Folder not found.")
+			}
+
+			return &gofman.Folder{ID: "1", UserID: "2", Name: "Holidays"}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := serveAuth(s, "GET", "/folders/1", "")
+
+		var folder gofman.Folder
+		if err := json.NewDecoder(w.Body).Decode(&folder); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if folder.ID != "1" || folder.Name != "Holidays" {
+			t.Fatalf("Unexpected folder: %#v", folder)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if w := serveAuth(s, "GET", "/folders/2", ""); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_FolderCreate(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.Folder
+		s := newAuthServer()
+		s.FolderService = &FolderService{
+			CreateFolderFn: func(ctx context.Context, folder *gofman.Folder) error {
+				got = *folder
+				folder.ID = "1"
+				return nil
+			},
+		}
+
+		w := serveAuth(s, "POST", "/folders", `{"name":"Holidays","parent_id":"5","users_id":"3","id":"4"}`)
+
+		var folder gofman.Folder
+		if err := json.NewDecoder(w.Body).Decode(&folder); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.UserID != "2" || got.Name != "Holidays" || got.ID != "" {
+			t.Fatalf("Unexpected folder: %#v", got)
+		} else if got.ParentID == nil || *got.ParentID != "5" {
+			t.Fatalf("Unexpected parent ID: %#v", got.ParentID)
+		} else if v := w.Header().Get("Location"); v != "/folders/1" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		} else if folder.ID != "1" {
+			t.Fatalf("Unexpected folder: %#v", folder)
+		}
+	})
+
+	t.Run("ErrParentNotFound", func(t *testing.T) {
+		s := newAuthServer()
+		s.FolderService = &FolderService{
+			CreateFolderFn: func(ctx context.Context, folder *gofman.Folder) error {
+				return gofman.NewError(gofman.ENOTFOUND, "Parent folder not found.")
+			},
+		}
+
+		if w := serveAuth(s, "POST", "/folders", `{"name":"Holidays","parent_id":"5"}`); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_FolderUpdate(t *testing.T) {
+	var got gofman.FolderUpdate
+	s := newAuthServer()
+	s.FolderService = &FolderService{
+		UpdateFolderFn: func(ctx context.Context, id string, update gofman.FolderUpdate) (*gofman.Folder, error) {
+			if id != "1" {
+				return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to update this folder.")
+			}
+
+			got = update
+			return &gofman.Folder{ID: id, UserID: "2", Name: *update.Name}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := serveAuth(s, "PATCH", "/folders/1", `{"name":"Summer"}`)
+
+		var folder gofman.Folder
+		if err := json.NewDecoder(w.Body).Decode(&folder); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.Name == nil || *got.Name != "Summer" || got.ParentID != nil {
+			t.Fatalf("Unexpected update: %#v", got)
+		} else if folder.Name != "Summer" {
+			t.Fatalf("Unexpected folder: %#v", folder)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if w := serveAuth(s, "PATCH", "/folders/2", `{"name":"Summer"}`); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_FolderDelete(t *testing.T) {
+	s := newAuthServer()
+	s.FolderService = &FolderService{
+		RemoveFolderFn: func(ctx context.Context, id string) error {
+			if id != "1" {
+				return gofman.NewError(gofman.ECONFLICT, "Folder is not empty.")
+			}
+
+			return nil
+		},
+	}
+
+	if w := serveAuth(s, "DELETE", "/folders/1", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	if w := serveAuth(s, "DELETE", "/folders/2", ""); w.Code != http.StatusConflict {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
@@ -12,6 +12,8 @@ import (
 func (s *Server) registerDebugRoutes(r *mux.Router) {
 	r.HandleFunc("/version", s.handleVersion).Methods("GET")
 	r.HandleFunc("/commit", s.handleCommit).Methods("GET")
+	r.HandleFunc("/migrations", s.handleMigrations).Methods("GET")
+	r.HandleFunc("/metrics", s.handleMetrics).Methods("GET")
 }
 
 // handleVersion displays the deployed version.
@@ -25,3 +27,25 @@ func (s *Server) handleCommit(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(gofman.Commit))
 }
+
+// handleMigrations displays the current schema migration status. It responds
+// with 503 if any migrations are still pending.
+func (s *Server) handleMigrations(w http.ResponseWriter, r *http.Request) {
+	if s.MigrationService == nil {
+		Error(w, r, gofman.NewError(gofman.ENOTIMPLEMENTED, "Migration status is not available."))
+		return
+	}
+
+	status, err := s.MigrationService.MigrationStatus(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if !status.IsMigrated() {
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
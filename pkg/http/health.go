@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Health statuses.
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// registerHealthRoutes is a helper function for registering the routes used
+// by load balancers and orchestrators. They never require a session.
+func (s *Server) registerHealthRoutes(r *mux.Router) {
+	r.HandleFunc("/healthz", s.handleHealthz).Methods("GET")
+	r.HandleFunc("/readyz", s.handleReadyz).Methods("GET")
+}
+
+// HealthResponse represents the JSON structure returned by GET /healthz and
+// GET /readyz.
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// handleHealthz reports that the process is up. It does not check any
+// dependencies.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &HealthResponse{Status: HealthStatusOK})
+}
+
+// handleReadyz reports whether the server can handle requests. It responds
+// with 503 if the database cannot be queried or no health service is set.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.HealthService == nil {
+		writeJSON(w, http.StatusServiceUnavailable, &HealthResponse{Status: HealthStatusUnavailable})
+		return
+	}
+
+	if err := s.HealthService.Ping(r.Context()); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, &HealthResponse{Status: HealthStatusUnavailable})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &HealthResponse{Status: HealthStatusOK})
+}
@@ -0,0 +1,79 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_Healthz(t *testing.T) {
+	s := gofmanhttp.NewServer()
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	var resp gofmanhttp.HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if resp.Status != gofmanhttp.HealthStatusOK {
+		t.Fatalf("Unexpected status: %q", resp.Status)
+	}
+}
+
+func TestServer_Readyz(t *testing.T) {
+	var pingErr error
+
+	s := gofmanhttp.NewServer()
+	s.HealthService = &HealthService{
+		PingFn: func(ctx context.Context) error {
+			return pingErr
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("ErrUnavailable", func(t *testing.T) {
+		pingErr = errors.New("sql: database is closed")
+		defer func() { pingErr = nil }()
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		var resp gofmanhttp.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.Status != gofmanhttp.HealthStatusUnavailable {
+			t.Fatalf("Unexpected status: %q", resp.Status)
+		}
+	})
+	t.Run("ErrNoHealthService", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
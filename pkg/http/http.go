@@ -1,12 +1,16 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"embed"
 	"fmt"
 	"io/fs"
+	"log"
+	"mime"
 	"net"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -19,7 +23,9 @@ var assetsFS embed.FS
 
 // HTTP constants.
 const (
-	ShutdownTimeout = 1 * time.Second
+	DefaultShutdownTimeout = 1 * time.Second
+
+	DefaultAPITimeout = 30 * time.Second
 )
 
 // Server represents an HTTP server.
@@ -28,16 +34,89 @@ type Server struct {
 	server *http.Server
 	router *mux.Router
 
+	// Prometheus registry and request metrics of the server.
+	metrics *metrics
+
+	// Cancels background jobs of the server on close.
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	// Bind address & port for the server's listener.
 	Address string
 	Port    int
 
+	// Certificate and key files for serving HTTPS. Alternatively, a self
+	// signed certificate is generated on open for local development. Plain
+	// HTTP is served if neither is set.
+	CertFile   string
+	KeyFile    string
+	SelfSigned bool
+
+	// Maximum bytes per second per download. Zero disables throttling.
+	DownloadRate int64
+
+	// Directory uploaded files are stored in, one subdirectory per user.
+	// Uploads are disabled if empty.
+	StorageRoot string
+
+	// Maximum size of an uploaded file in bytes.
+	MaxUploadSize int64
+
+	// Maximum duration of API and upload requests. Zero disables the timeout.
+	// Downloads are not limited, see DownloadRate.
+	APITimeout    time.Duration
+	UploadTimeout time.Duration
+
+	// Maximum duration Close waits for in-flight requests, like uploads,
+	// before their connections are closed.
+	ShutdownTimeout time.Duration
+
+	// Format of generated request IDs. Defaults to a random token.
+	RequestIDFormat string
+
+	// Addresses or CIDR ranges of reverse proxies whose inbound request IDs
+	// and X-Forwarded-For headers are accepted. If empty, no client is
+	// trusted.
+	TrustedProxies []string
+
+	// Origins whose scripts may call the API with the cookies of the user,
+	// e.g. "https://app.example.com". If empty, no CORS headers are sent.
+	CORSOrigins []string
+
+	// Lifetime of new sessions, depending on whether the user asked to be
+	// remembered.
+	SessionTTL  time.Duration
+	RememberTTL time.Duration
+
+	// Mark cookies as Secure, so browsers only send them over HTTPS. Only
+	// disable it for local development over plain HTTP.
+	SecureCookies bool
+
+	// Throttles failed logins per username. Swept while the server is open.
+	LoginLimiter *LoginLimiter
+
+	// Limits the requests per user or remote IP on the API and upload
+	// routes. Disabled until its rate is set. Swept while the server is open.
+	RateLimiter *RateLimiter
+
+	// Logger for one line per served request. Nil disables access logging.
+	AccessLog *log.Logger
+
+	// Instrument requests and expose Prometheus metrics at /debug/metrics.
+	Metrics bool
+
 	// Servics used by the various HTTP routes.
 	ActorService         gofman.ActorService
 	FileService          gofman.FileService
+	FileTagService       gofman.FileTagService
+	FolderService        gofman.FolderService
+	HealthService        gofman.HealthService
+	StatsService         gofman.StatsService
+	MigrationService     gofman.MigrationService
 	SessionService       gofman.SessionService
 	SetupService         gofman.SetupService
 	TagService           gofman.TagService
+	TrashService         gofman.TrashService
 	UserService          gofman.UserService
 	AuthService          gofman.AuthService
 	PathTraversalService gofman.PathTraversalService
@@ -48,17 +127,41 @@ func NewServer() *Server {
 	s := &Server{
 		server: &http.Server{},
 		router: mux.NewRouter(),
+
+		MaxUploadSize: DefaultMaxUploadSize,
+
+		APITimeout:      DefaultAPITimeout,
+		ShutdownTimeout: DefaultShutdownTimeout,
+
+		SessionTTL:  DefaultSessionTTL,
+		RememberTTL: DefaultRememberTTL,
+
+		SecureCookies: true,
+
+		LoginLimiter: NewLoginLimiter(DefaultLoginMaxAttempts, DefaultLoginWindow),
+		RateLimiter:  NewRateLimiter(0, DefaultRateLimitBurst),
 	}
 
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	s.metrics = s.newMetrics()
+
 	s.router.Use(s.handlePanic)
 
-	s.server.Handler = http.HandlerFunc(s.router.ServeHTTP)
+	s.server.Handler = s.requestID(s.accessLog(s.instrument(http.HandlerFunc(s.router.ServeHTTP))))
 
 	s.router.NotFoundHandler = http.HandlerFunc(s.handleNotFound)
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(s.handleMethodNotAllowed)
+
+	s.router.Methods(http.MethodOptions).Handler(s.cors(http.HandlerFunc(s.handleOptions)))
+	s.router.Methods(http.MethodHead).HandlerFunc(s.handleHead)
 
 	if assetsHTTPFS, err := fs.Sub(assetsFS, "assets"); err == nil {
-		s.router.PathPrefix("/assets/").
-			Handler(http.StripPrefix("/assets/", s.handleAssets(http.FS(assetsHTTPFS))))
+		compressed, _ := compressAssets(assetsHTTPFS)
+		hashes, _ := hashAssets(assetsHTTPFS)
+
+		s.router.PathPrefix("/assets/").Methods(http.MethodGet).
+			Handler(http.StripPrefix("/assets/", s.handleAssets(http.FS(assetsHTTPFS), compressed, hashes)))
 	}
 
 	{
@@ -69,6 +172,13 @@ func NewServer() *Server {
 
 	{
 		r := s.router.PathPrefix("/").Subrouter()
+
+		s.registerHealthRoutes(r)
+	}
+
+	{
+		r := s.router.PathPrefix("/").Subrouter()
+		r.Use(s.cors)
 		r.Use(s.authenticate)
 
 		s.registerSetupRoutes(r)
@@ -76,19 +186,65 @@ func NewServer() *Server {
 
 	{
 		r := s.router.PathPrefix("/").Subrouter()
+		r.Use(s.cors)
+		r.Use(s.authenticate)
+		r.Use(s.timeout(&s.APITimeout))
+
+		s.registerLoginRoutes(r)
+	}
+
+	{
+		r := s.router.PathPrefix("/").Subrouter()
+		r.Use(s.cors)
+		r.Use(s.authenticate)
+		r.Use(s.requireAuth)
+		r.Use(s.rateLimit(s.RateLimiter))
+		r.Use(s.csrf)
+		r.Use(s.timeout(&s.UploadTimeout))
+
+		s.registerUploadRoutes(r)
+	}
+
+	{
+		r := s.router.PathPrefix("/").Subrouter()
+		r.Use(s.cors)
+		r.Use(s.authenticate)
+		r.Use(s.requireAuth)
+		r.Use(s.rateLimit(s.RateLimiter))
+		r.Use(s.csrf)
+
+		s.registerDownloadRoutes(r)
+	}
+
+	{
+		r := s.router.PathPrefix("/").Subrouter()
+		r.Use(s.cors)
 		r.Use(s.authenticate)
 		r.Use(s.requireAuth)
+		r.Use(s.rateLimit(s.RateLimiter))
+		r.Use(s.csrf)
+		r.Use(s.timeout(&s.APITimeout))
 
 		s.registerActorRoutes(r)
+		s.registerCSRFRoutes(r)
 		s.registerFileRoutes(r)
+		s.registerFileTagRoutes(r)
+		s.registerFolderRoutes(r)
 		s.registerSessionRoutes(r)
 		s.registerTagRoutes(r)
+		s.registerTrashRoutes(r)
 		s.registerUserRoutes(r)
 	}
 
 	return s
 }
 
+// ServeHTTP implements the http.Handler interface by delegating to the
+// underlying server handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.server.Handler.ServeHTTP(w, r)
+}
+
 // URL returns the local base URL of the running server.
 func (s *Server) URL() string {
 	return fmt.Sprintf("%s:%d", s.Address, s.Port)
@@ -96,29 +252,58 @@ func (s *Server) URL() string {
 
 // Open begins listening on the bind address.
 func (s *Server) Open() (err error) {
+	if s.TLS() {
+		if s.server.TLSConfig, err = s.tlsConfig(); err != nil {
+			return err
+		}
+	}
+
 	if s.ln, err = net.Listen("tcp", s.URL()); err != nil {
 		return err
 	}
 
-	go s.server.Serve(s.ln)
+	if s.TLS() {
+		// The certificate is part of the TLS config already.
+		go s.server.ServeTLS(s.ln, "", "")
+	} else {
+		go s.server.Serve(s.ln)
+	}
+
+	if s.LoginLimiter != nil {
+		go s.LoginLimiter.run(s.ctx)
+	}
+
+	if s.RateLimiter != nil {
+		go s.RateLimiter.run(s.ctx)
+	}
 
 	return nil
 }
 
-// Close gracefully shuts down the server.
+// Close gracefully shuts down the server. It waits for in-flight requests to
+// finish and forcefully closes all remaining connections once the shutdown
+// timeout is exceeded.
 func (s *Server) Close() error {
-	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	s.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
 	defer cancel()
-	return s.server.Shutdown(ctx)
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		return err
+	}
+
+	return nil
 }
 
-// handlePanic is middleware for catching panics.
+// handlePanic is middleware for catching panics. The panic is logged and the
+// client only receives a generic internal error.
 func (s *Server) handlePanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "500")
+				Error(w, r, gofman.NewWrappedError(gofman.EINTERNAL, fmt.Errorf("panic: %v", err), "Internal error."))
 			}
 		}()
 
@@ -126,12 +311,94 @@ func (s *Server) handlePanic(next http.Handler) http.Handler {
 	})
 }
 
+// timeout returns middleware that cancels the context of requests taking
+// longer than the given duration. The response is not buffered, handlers are
+// expected to stop once the context is done. A timeout error is written if
+// they did not write a response by then. The duration is read on every
+// request so it can be changed after the server was created. A duration of
+// zero disables the timeout.
+func (s *Server) timeout(d *time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if *d <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), *d)
+			defer cancel()
+
+			r = r.WithContext(ctx)
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 && ctx.Err() == context.DeadlineExceeded {
+				Error(w, r, gofman.NewError(gofman.ETIMEOUT, "Request timed out."))
+			}
+		})
+	}
+}
+
+// allowedMethods returns all methods that are registered for the path of the
+// given request.
+func (s *Server) allowedMethods(r *http.Request) []string {
+	var methods []string
+
+	for _, method := range []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	} {
+		req := r.Clone(r.Context())
+		req.Method = method
+
+		var match mux.RouteMatch
+		if s.router.Match(req, &match) && match.MatchErr == nil {
+			methods = append(methods, method)
+
+			if method == http.MethodGet {
+				methods = append(methods, http.MethodHead)
+			}
+		}
+	}
+
+	return methods
+}
+
+// handleOptions answers OPTIONS requests for every route by advertising the
+// allowed methods in the Allow header.
+func (s *Server) handleOptions(w http.ResponseWriter, r *http.Request) {
+	methods := s.allowedMethods(r)
+	if len(methods) == 0 {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	methods = append(methods, http.MethodOptions)
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHead answers HEAD requests by dispatching them to the matching GET
+// route. The response body is discarded by the underlying http server.
+func (s *Server) handleHead(w http.ResponseWriter, r *http.Request) {
+	req := r.Clone(r.Context())
+	req.Method = http.MethodGet
+
+	s.router.ServeHTTP(w, req)
+}
+
 // handleAssets handles request to publicly accessible assets. It checks if the
 // asset exists and if that is the case it will return it. If the asset is a
 // directory or it does not exist our default not found handler will be called.
-func (s *Server) handleAssets(root http.FileSystem) http.Handler {
-	fs := http.FileServer(root)
-
+// Assets with a precompressed variant are served gzip encoded to clients that
+// accept it. Assets are sent with an ETag and a Last-Modified header, so
+// clients revalidate them with a conditional GET and get 304 if unchanged.
+func (s *Server) handleAssets(root http.FileSystem, compressed map[string][]byte, hashes map[string]string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		if !strings.HasPrefix(path, "/") {
@@ -144,37 +411,63 @@ func (s *Server) handleAssets(root http.FileSystem) http.Handler {
 			s.handleNotFound(w, r)
 			return
 		}
+		defer file.Close()
 
 		stats, err := file.Stat()
 		if err != nil {
-			file.Close()
 			s.handleNotFound(w, r)
 			return
 		}
 
 		if stats.IsDir() {
-			file.Close()
 			s.handleNotFound(w, r)
 			return
 		}
 
-		if err == nil {
-			file.Close()
+		modtime := stats.ModTime()
+		if modtime.IsZero() {
+			modtime = assetsModTime
+		}
+
+		// Clients may keep the assets but have to revalidate them, a new
+		// build can change them without changing their URL.
+		w.Header().Set("Cache-Control", "no-cache")
+
+		hash, hashed := hashes[path]
+
+		if gz, ok := compressed[path]; ok {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if acceptsGzip(r) {
+				if typ := mime.TypeByExtension(filepath.Ext(path)); typ != "" {
+					w.Header().Set("Content-Type", typ)
+				}
+
+				if hashed {
+					w.Header().Set("ETag", assetETag(hash, true))
+				}
+
+				w.Header().Set("Content-Encoding", "gzip")
+				http.ServeContent(w, r, path, modtime, bytes.NewReader(gz))
+				return
+			}
+		}
+
+		if hashed {
+			w.Header().Set("ETag", assetETag(hash, false))
 		}
 
-		fs.ServeHTTP(w, r)
+		http.ServeContent(w, r, path, modtime, file)
 	})
 }
 
 // handleNotFound handles requests to routes that don't exist.
 func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(w, "404")
+	Error(w, r, gofman.NewError(gofman.ENOTFOUND, "Not found."))
 }
 
 // handleMethodNotAllowed handles requests to routes that did not implement
 // the requested method.
 func (s *Server) handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(w, "404")
+	Error(w, r, gofman.NewError(gofman.ENOTFOUND, "Not found."))
 }
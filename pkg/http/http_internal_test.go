@@ -0,0 +1,175 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestServer_RemoteIP(t *testing.T) {
+	s := NewServer()
+	s.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1"}
+
+	for _, tt := range []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		ip           string
+	}{
+		{name: "Direct", remoteAddr: "198.51.100.1:1234", ip: "198.51.100.1"},
+		{name: "UntrustedClient", remoteAddr: "198.51.100.1:1234", forwardedFor: []string{"203.0.113.1"}, ip: "198.51.100.1"},
+		{name: "TrustedProxy", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"203.0.113.1"}, ip: "203.0.113.1"},
+		{name: "ProxyChain", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"203.0.113.1, 192.0.2.1"}, ip: "203.0.113.1"},
+		{name: "SpoofedHop", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.9", "203.0.113.1"}, ip: "203.0.113.1"},
+		{name: "OnlyProxies", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"10.0.0.1"}, ip: "10.0.0.1"},
+		{name: "InvalidHop", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"unknown"}, ip: "10.1.2.3"},
+		{name: "NoHeader", remoteAddr: "10.1.2.3:1234", ip: "10.1.2.3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				r.Header.Add(ForwardedForHeader, v)
+			}
+
+			if ip := s.remoteIP(r); ip != tt.ip {
+				t.Fatalf("Unexpected IP: %q", ip)
+			}
+		})
+	}
+
+	t.Run("NoTrustedProxies", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "10.1.2.3:1234"
+		r.Header.Set(ForwardedForHeader, "203.0.113.1")
+
+		if ip := NewServer().remoteIP(r); ip != "10.1.2.3" {
+			t.Fatalf("Unexpected IP: %q", ip)
+		}
+	})
+}
+
+func TestServer_Close(t *testing.T) {
+	started := make(chan struct{})
+
+	s := NewServer()
+	s.Address = "127.0.0.1"
+	s.router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + s.ln.Addr().String() + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		errs <- err
+	}()
+
+	<-started
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The in-flight request must have finished once Close returns.
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("Expected in-flight request to finish, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected in-flight request to finish before Close returned.")
+	}
+}
+
+func TestServer_Close_Timeout(t *testing.T) {
+	started := make(chan struct{})
+
+	s := NewServer()
+	s.Address = "127.0.0.1"
+	s.ShutdownTimeout = 50 * time.Millisecond
+	s.router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+
+		select {
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})
+
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + s.ln.Addr().String() + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		errs <- err
+	}()
+
+	<-started
+
+	start := time.Now()
+
+	if err := s.Close(); err == nil {
+		t.Fatal("Expected shutdown timeout error")
+	} else if d := time.Since(start); d >= time.Second {
+		t.Fatalf("Expected Close to return after the shutdown timeout, took %s", d)
+	}
+
+	// The connection of the request was closed before it finished.
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("Expected in-flight request to be cut off")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected in-flight request to be cut off after Close returned.")
+	}
+}
+
+func TestServer_HandlePanic(t *testing.T) {
+	// The panic is logged, keep it out of the test output.
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	s := NewServer()
+	s.router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("secret")
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if v := w.Header().Get("Content-Type"); v != "application/json" {
+		t.Fatalf("Unexpected Content-Type header: %q", v)
+	} else if resp.Error.Code != gofman.EINTERNAL || resp.Error.Message != "Internal error." {
+		t.Fatalf("Unexpected error: %#v", resp.Error)
+	}
+}
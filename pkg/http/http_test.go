@@ -0,0 +1,347 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+	"github.com/dhenkes/gofman/pkg/path_traversal"
+)
+
+func TestServer_Options(t *testing.T) {
+	ts := httptest.NewServer(gofmanhttp.NewServer())
+	defer ts.Close()
+
+	t.Run("ExistingRoute", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodOptions, ts.URL+"/debug/version", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		} else if v := resp.Header.Get("Allow"); v != "GET, HEAD, OPTIONS" {
+			t.Fatalf("Unexpected Allow header: %q", v)
+		}
+	})
+
+	t.Run("MissingRoute", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodOptions, ts.URL+"/debug/missing", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestServer_NotFound(t *testing.T) {
+	s := gofmanhttp.NewServer()
+
+	// Unknown methods of existing routes are reported like unknown routes.
+	for _, r := range []*http.Request{
+		httptest.NewRequest("GET", "/missing", nil),
+		httptest.NewRequest("PUT", "/debug/version", nil),
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Content-Type"); v != "application/json" {
+			t.Fatalf("Unexpected Content-Type header: %q", v)
+		} else if resp.Error.Code != gofman.ENOTFOUND || resp.Error.Message != "Not found." {
+			t.Fatalf("Unexpected error: %#v", resp.Error)
+		}
+	}
+}
+
+func TestServer_Head(t *testing.T) {
+	gofman.Version = "1.0.0"
+
+	ts := httptest.NewServer(gofmanhttp.NewServer())
+	defer ts.Close()
+
+	resp, err := http.Head(ts.URL + "/debug/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", resp.StatusCode)
+	} else if v := resp.Header.Get("Content-Type"); v != "text/plain" {
+		t.Fatalf("Unexpected Content-Type header: %q", v)
+	} else if len(body) != 0 {
+		t.Fatalf("Expected empty body, got %q", body)
+	}
+}
+
+func TestServer_Migrations(t *testing.T) {
+	var status gofman.MigrationStatus
+
+	s := gofmanhttp.NewServer()
+	s.MigrationService = &MigrationService{
+		MigrationStatusFn: func(ctx context.Context) (*gofman.MigrationStatus, error) {
+			return &status, nil
+		},
+	}
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	t.Run("Pending", func(t *testing.T) {
+		status = gofman.MigrationStatus{Latest: "00000001", Pending: 2}
+
+		resp, err := http.Get(ts.URL + "/debug/migrations")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		var got gofman.MigrationStatus
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		} else if got != status {
+			t.Fatalf("Unexpected migration status: %#v", got)
+		}
+	})
+
+	t.Run("Migrated", func(t *testing.T) {
+		status = gofman.MigrationStatus{Version: "00000001", Latest: "00000001"}
+
+		resp, err := http.Get(ts.URL + "/debug/migrations")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		var got gofman.MigrationStatus
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		} else if got != status {
+			t.Fatalf("Unexpected migration status: %#v", got)
+		}
+	})
+
+	t.Run("ErrInternal", func(t *testing.T) {
+		s.MigrationService.(*MigrationService).MigrationStatusFn = func(ctx context.Context) (*gofman.MigrationStatus, error) {
+			return nil, errors.New("database is locked")
+		}
+
+		resp, err := http.Get(ts.URL + "/debug/migrations")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		var got gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		} else if got.Error.Code != gofman.EINTERNAL {
+			t.Fatalf("Unexpected error code: %q", got.Error.Code)
+		}
+	})
+
+	t.Run("ErrNoService", func(t *testing.T) {
+		ts := httptest.NewServer(gofmanhttp.NewServer())
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/debug/migrations")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotImplemented {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestServer_Timeout(t *testing.T) {
+	// wait blocks until the deadline of the request is exceeded.
+	wait := func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("no deadline")
+		}
+
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	s := newAuthServer()
+	s.APITimeout = 10 * time.Millisecond
+	s.UploadTimeout = 10 * time.Millisecond
+	s.StorageRoot = t.TempDir()
+	s.PathTraversalService = path_traversal.NewPathTraversalService()
+	s.UserService.(*UserService).FindUserByUsernameFn = func(ctx context.Context, username string) (*gofman.User, error) {
+		return nil, wait(ctx)
+	}
+	s.TagService = &TagService{
+		FindTagsFn: func(ctx context.Context, filter gofman.TagFilter) ([]*gofman.Tag, int, error) {
+			return nil, 0, wait(ctx)
+		},
+	}
+	s.FileService = &FileService{
+		CreateFileFn: func(ctx context.Context, file *gofman.File) error {
+			return wait(ctx)
+		},
+		FindFileByIDFn: func(ctx context.Context, id string) (*gofman.File, error) {
+			if _, ok := ctx.Deadline(); ok {
+				return nil, errors.New("unexpected deadline")
+			}
+
+			return nil, gofman.NewError(gofman.ENOTFOUND, "File not found.")
+		},
+	}
+
+	// expectTimeout checks that the timeout is reported as JSON error.
+	expectTimeout := func(t *testing.T, w *httptest.ResponseRecorder) {
+		t.Helper()
+
+		var resp gofmanhttp.ErrorResponse
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Unexpected status: %d %s", w.Code, w.Body)
+		} else if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		} else if resp.Error.Code != gofman.ETIMEOUT {
+			t.Fatalf("Unexpected error code: %q", resp.Error.Code)
+		}
+	}
+
+	t.Run("Login", func(t *testing.T) {
+		expectTimeout(t, serveAuth(s, "POST", "/login", `{"username":"jane","password":"password"}`))
+	})
+
+	t.Run("API", func(t *testing.T) {
+		expectTimeout(t, serveAuth(s, "GET", "/tags", ""))
+	})
+
+	t.Run("Upload", func(t *testing.T) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+
+		if fw, err := mw.CreateFormFile("file", "hello.txt"); err != nil {
+			t.Fatal(err)
+		} else if _, err := fw.Write([]byte("hello world")); err != nil {
+			t.Fatal(err)
+		} else if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("POST", "/files/upload", &buf)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: "csrf"})
+		r.Header.Set(gofmanhttp.CSRFHeader, "csrf")
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		expectTimeout(t, w)
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		// Downloads are not subject to a timeout.
+		if w := serveAuth(s, "GET", "/files/1/download", ""); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		s.APITimeout = 0
+		defer func() { s.APITimeout = 10 * time.Millisecond }()
+
+		if w := serveAuth(s, "GET", "/tags", ""); w.Code != http.StatusInternalServerError {
+			t.Fatalf("Unexpected status: %d %s", w.Code, w.Body)
+		}
+	})
+}
+
+// newAuthServer returns a server authenticating every request with a session
+// of the user "2".
+func newAuthServer() *gofmanhttp.Server {
+	s := gofmanhttp.NewServer()
+	s.SessionService = &SessionService{
+		FindSessionForTokenFn: func(ctx context.Context, id string, token string) (*gofman.Session, error) {
+			return &gofman.Session{ID: id, UserID: "2", Token: token}, nil
+		},
+	}
+	s.UserService = &UserService{
+		FindUserByIDFn: func(ctx context.Context, id string) (*gofman.User, error) {
+			return &gofman.User{ID: id, Username: "jane"}, nil
+		},
+	}
+
+	return s
+}
+
+// serveAuth runs the request against the server with the session cookies
+// accepted by newAuthServer and a valid CSRF token.
+func serveAuth(s *gofmanhttp.Server, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+	r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+	r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: "csrf"})
+	r.Header.Set(gofmanhttp.CSRFHeader, "csrf")
+	w := httptest.NewRecorder()
+
+	s.ServeHTTP(w, r)
+
+	return w
+}
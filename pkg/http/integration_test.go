@@ -0,0 +1,904 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+	"github.com/dhenkes/gofman/pkg/path_traversal"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestIntegration_Auth(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		user := h.MustCreateUser(t, "jane", "password")
+		cookies := h.MustLogin(t, "jane", "password")
+
+		w := h.Do(httptest.NewRequest("GET", "/account", nil), cookies...)
+
+		var resp gofmanhttp.AccountResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.User.ID != user.ID || resp.Session.ID != cookies[0].Value {
+			t.Fatalf("Unexpected account: %#v", resp)
+		}
+
+		h.MustLogout(t, cookies)
+
+		if w := h.Do(httptest.NewRequest("GET", "/account", nil), cookies...); w.Code != http.StatusFound {
+			t.Fatalf("Unexpected status after logout: %d", w.Code)
+		}
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		h.MustCreateUser(t, "jane", "password")
+
+		if w := h.Do(httptest.NewRequest("GET", "/account", nil)); w.Code != http.StatusFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Location"); v != "/login" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		}
+
+		invalid := []*http.Cookie{
+			{Name: "Session", Value: "00000000-0000-0000-0000-000000000000"},
+			{Name: "Token", Value: "invalid"},
+		}
+
+		if w := h.Do(httptest.NewRequest("GET", "/account", nil), invalid...); w.Code != http.StatusFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestIntegration_TagUpdate(t *testing.T) {
+	h := MustOpenHarness(t)
+	defer h.MustClose(t)
+
+	h.MustCreateUser(t, "jane", "password")
+	cookies := h.MustLogin(t, "jane", "password")
+
+	w := h.Do(httptest.NewRequest("POST", "/tags", strings.NewReader(`{"name":"summer"}`)), cookies...)
+
+	var tag gofman.Tag
+	if err := json.NewDecoder(w.Body).Decode(&tag); err != nil {
+		t.Fatal(err)
+	} else if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	if w := h.Do(httptest.NewRequest("PATCH", "/tags/"+tag.ID, strings.NewReader(`{"name":"winter"}`)), cookies...); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	// The new name must have been written to the database, not only to the
+	// returned tag.
+	w = h.Do(httptest.NewRequest("GET", "/tags/"+tag.ID, nil), cookies...)
+
+	var other gofman.Tag
+	if err := json.NewDecoder(w.Body).Decode(&other); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if other.Name != "winter" || other.UpdatedAt < tag.UpdatedAt {
+		t.Fatalf("Unexpected tag: %#v", other)
+	}
+
+	// Tags of other users are not found, so they cannot be updated.
+	h.MustCreateUser(t, "john", "password")
+	john := h.MustLogin(t, "john", "password")
+
+	w = h.Do(httptest.NewRequest("PATCH", "/tags/"+tag.ID, strings.NewReader(`{"name":"spring"}`)), john...)
+
+	var resp gofmanhttp.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if resp.Error.Code != gofman.ENOTFOUND {
+		t.Fatalf("Unexpected error: %#v", resp.Error)
+	}
+}
+
+func TestIntegration_Users(t *testing.T) {
+	h := MustOpenHarness(t)
+	defer h.MustClose(t)
+
+	user := h.MustCreateUser(t, "jane", "password")
+	cookies := h.MustLogin(t, "jane", "password")
+
+	// Only admins may list all users.
+	if w := h.Do(httptest.NewRequest("GET", "/users", nil), cookies...); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	// Users can view themselves without their password hash.
+	w := h.Do(httptest.NewRequest("GET", "/users/"+user.ID, nil), cookies...)
+
+	var other gofman.User
+	if err := json.NewDecoder(w.Body).Decode(&other); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if other.ID != user.ID || other.Username != "jane" || other.Password != "" {
+		t.Fatalf("Unexpected user: %#v", other)
+	}
+
+	// Only admins may create users.
+	if w := h.Do(httptest.NewRequest("POST", "/users", strings.NewReader(`{"username":"john","password":"password"}`)), cookies...); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
+
+func TestIntegration_Sessions(t *testing.T) {
+	h := MustOpenHarness(t)
+	defer h.MustClose(t)
+
+	h.MustCreateUser(t, "jane", "password")
+	laptop := h.MustLogin(t, "jane", "password")
+	phone := h.MustLogin(t, "jane", "password")
+
+	w := h.Do(httptest.NewRequest("GET", "/sessions", nil), laptop...)
+
+	var sessions []*gofman.Session
+	resp := gofmanhttp.SessionsResponse{ListResponse: gofmanhttp.ListResponse{Data: &sessions}}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if len(sessions) != 2 || resp.Total != 2 || resp.CurrentID != laptop[0].Value {
+		t.Fatalf("Unexpected sessions: %#v", resp)
+	}
+
+	for _, session := range sessions {
+		if session.Token != "" {
+			t.Fatal("Expected token to be redacted.")
+		}
+	}
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		h.MustCreateUser(t, "john", "password")
+		john := h.MustLogin(t, "john", "password")
+
+		if w := h.Do(httptest.NewRequest("DELETE", "/sessions/"+phone[0].Value, nil), john...); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		if w := h.Do(httptest.NewRequest("GET", "/account", nil), phone...); w.Code != http.StatusOK {
+			t.Fatalf("Expected session to be kept: %d", w.Code)
+		}
+	})
+
+	t.Run("Other", func(t *testing.T) {
+		w := h.Do(httptest.NewRequest("DELETE", "/sessions/"+phone[0].Value, nil), laptop...)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if len(w.Result().Cookies()) != 0 {
+			t.Fatal("Expected cookies to be kept.")
+		}
+
+		if w := h.Do(httptest.NewRequest("GET", "/account", nil), phone...); w.Code != http.StatusFound {
+			t.Fatalf("Expected session to be revoked: %d", w.Code)
+		}
+	})
+
+	t.Run("Current", func(t *testing.T) {
+		w := h.Do(httptest.NewRequest("DELETE", "/sessions/"+laptop[0].Value, nil), laptop...)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 2 {
+			t.Fatalf("Unexpected cookies: %v", cookies)
+		}
+
+		for _, cookie := range cookies {
+			if cookie.MaxAge >= 0 || cookie.Value != "" {
+				t.Fatalf("Expected cookie to be cleared: %v", cookie)
+			}
+		}
+	})
+}
+
+func TestIntegration_ListTotal(t *testing.T) {
+	h := MustOpenHarness(t)
+	defer h.MustClose(t)
+
+	h.MustCreateUser(t, "jane", "password")
+	cookies := h.MustLogin(t, "jane", "password")
+
+	for _, name := range []string{"spring", "summer", "autumn"} {
+		if w := h.Do(httptest.NewRequest("POST", "/tags", strings.NewReader(`{"name":"`+name+`"}`)), cookies...); w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	}
+
+	w := h.Do(httptest.NewRequest("GET", "/tags?limit=2&sort_by=name", nil), cookies...)
+
+	var tags []*gofman.Tag
+	resp := gofmanhttp.ListResponse{Data: &tags}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The total counts all hits, not only the ones on the page.
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if len(tags) != 2 || tags[0].Name != "autumn" || tags[1].Name != "spring" {
+		t.Fatalf("Unexpected tags: %#v", tags)
+	} else if resp.Total != 3 || resp.Limit != 2 || resp.Offset != 0 {
+		t.Fatalf("Unexpected envelope: %#v", resp)
+	}
+}
+
+func TestIntegration_FileUpload(t *testing.T) {
+	h := MustOpenHarness(t)
+	defer h.MustClose(t)
+
+	h.StorageRoot = t.TempDir()
+
+	user := h.MustCreateUser(t, "jane", "password")
+	cookies := h.MustLogin(t, "jane", "password")
+
+	// upload sends the content as multipart body with a description field.
+	upload := func(name, content string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+
+		if err := mw.WriteField("description", "Greeting"); err != nil {
+			t.Fatal(err)
+		}
+
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		} else if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("POST", "/files/upload", &buf)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+
+		return h.Do(r, cookies...)
+	}
+
+	// expectNoFiles checks that nothing was left in the directory of the user.
+	expectNoFiles := func(t *testing.T) {
+		t.Helper()
+
+		entries, err := ioutil.ReadDir(filepath.Join(h.StorageRoot, user.ID))
+		if err != nil {
+			t.Fatal(err)
+		} else if len(entries) != 0 {
+			t.Fatalf("Unexpected files: %d", len(entries))
+		}
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := upload("hello.txt", "hello world")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d %s", w.Code, w.Body)
+		}
+
+		var body gofman.File
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := h.FileService.FindFileByID(gofman.NewContextWithUser(context.Background(), user), body.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if file.UserID != user.ID || file.Name != "hello.txt" || file.Description != "Greeting" {
+			t.Fatalf("Unexpected file: %#v", file)
+		} else if file.Type != "text/plain" || file.Size != 11 {
+			t.Fatalf("Unexpected type or size: %#v", file)
+		} else if file.ChecksumAlgo != gofman.ChecksumSHA256 || file.Checksum != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+			t.Fatalf("Unexpected checksum: %#v", file)
+		} else if filepath.Dir(file.Path) != filepath.Join(h.StorageRoot, user.ID) {
+			t.Fatalf("Unexpected path: %q", file.Path)
+		}
+
+		if b, err := ioutil.ReadFile(file.Path); err != nil {
+			t.Fatal(err)
+		} else if string(b) != "hello world" {
+			t.Fatalf("Unexpected content: %q", b)
+		}
+
+		if err := os.Remove(file.Path); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrTooLarge", func(t *testing.T) {
+		h.MaxUploadSize = 4
+		defer func() { h.MaxUploadSize = gofmanhttp.DefaultMaxUploadSize }()
+
+		if w := upload("hello.txt", "hello world"); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		expectNoFiles(t)
+	})
+
+	t.Run("ErrTypeNotAllowed", func(t *testing.T) {
+		// The file is written before the type is validated, it must be
+		// removed again.
+		if w := upload("data", "\x00\x01\x02"); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		expectNoFiles(t)
+	})
+
+	t.Run("ErrNoFile", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/files/upload", strings.NewReader("--x--\r\n"))
+		r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+
+		if w := h.Do(r, cookies...); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("ErrReadOnly", func(t *testing.T) {
+		readOnly := &gofman.User{Username: "john", Password: "password", Role: gofman.RoleReadOnly}
+		if err := h.UserService.CreateUser(adminContext(), readOnly); err != nil {
+			t.Fatal(err)
+		}
+
+		cookies = h.MustLogin(t, "john", "password")
+
+		if w := upload("hello.txt", "hello world"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		// Nothing is written, not even the directory of the user.
+		if _, err := os.Stat(filepath.Join(h.StorageRoot, readOnly.ID)); !os.IsNotExist(err) {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestIntegration_FileDownload(t *testing.T) {
+	h := MustOpenHarness(t)
+	defer h.MustClose(t)
+
+	h.StorageRoot = t.TempDir()
+
+	jane := h.MustCreateUser(t, "jane", "password")
+	h.MustCreateUser(t, "john", "password")
+	cookies := h.MustLogin(t, "jane", "password")
+
+	// create writes the content to disk and creates a file for Jane.
+	create := func(t *testing.T, name, content string) *gofman.File {
+		t.Helper()
+
+		path := filepath.Join(h.StorageRoot, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := h.PathTraversalService.GetFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.UserID = jane.ID
+		if err := h.FileService.CreateFile(gofman.NewContextWithUser(context.Background(), jane), file); err != nil {
+			t.Fatal(err)
+		}
+
+		return file
+	}
+
+	t.Run("Range", func(t *testing.T) {
+		file := create(t, "hello.txt", "hello world")
+
+		r := httptest.NewRequest("GET", "/files/"+file.ID+"/download", nil)
+		r.Header.Set("Range", "bytes=6-10")
+		w := h.Do(r, cookies...)
+
+		if w.Code != http.StatusPartialContent {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Content-Range"); v != "bytes 6-10/11" {
+			t.Fatalf("Unexpected Content-Range header: %q", v)
+		} else if v := w.Header().Get("Content-Type"); v != "text/plain" {
+			t.Fatalf("Unexpected Content-Type header: %q", v)
+		} else if v := w.Header().Get("Content-Disposition"); v != "attachment; filename=hello.txt" {
+			t.Fatalf("Unexpected Content-Disposition header: %q", v)
+		} else if w.Body.String() != "world" {
+			t.Fatalf("Unexpected body: %q", w.Body)
+		}
+	})
+
+	t.Run("ErrOtherUser", func(t *testing.T) {
+		file := create(t, "other.txt", "hello world")
+
+		w := h.Do(httptest.NewRequest("GET", "/files/"+file.ID+"/download", nil), h.MustLogin(t, "john", "password")...)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("ErrRemoved", func(t *testing.T) {
+		file := create(t, "removed.txt", "hello world")
+
+		if w := h.Do(httptest.NewRequest("DELETE", "/files/"+file.ID, nil), cookies...); w.Code != http.StatusNoContent {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		if w := h.Do(httptest.NewRequest("GET", "/files/"+file.ID+"/download", nil), cookies...); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("ErrMissingOnDisk", func(t *testing.T) {
+		file := create(t, "missing.txt", "hello world")
+
+		if err := os.Remove(file.Path); err != nil {
+			t.Fatal(err)
+		}
+
+		if w := h.Do(httptest.NewRequest("GET", "/files/"+file.ID+"/download", nil), cookies...); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestIntegration_TrashEmpty(t *testing.T) {
+	h := MustOpenHarness(t)
+	defer h.MustClose(t)
+
+	h.StorageRoot = t.TempDir()
+	h.DB.StorageRoot = h.StorageRoot
+
+	jane := h.MustCreateUser(t, "jane", "password")
+	cookies := h.MustLogin(t, "jane", "password")
+
+	// remove writes the content to disk, creates a file for Jane and moves it
+	// to the trash.
+	remove := func(t *testing.T, dir, name string) *gofman.File {
+		t.Helper()
+
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("hello world"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := h.PathTraversalService.GetFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.UserID = jane.ID
+		if err := h.FileService.CreateFile(gofman.NewContextWithUser(context.Background(), jane), file); err != nil {
+			t.Fatal(err)
+		}
+
+		if w := h.Do(httptest.NewRequest("DELETE", "/files/"+file.ID, nil), cookies...); w.Code != http.StatusNoContent {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		return file
+	}
+
+	// empty empties the trash and returns the decoded result.
+	empty := func(t *testing.T, target string) *gofman.TrashResult {
+		t.Helper()
+
+		w := h.Do(httptest.NewRequest("POST", target, nil), cookies...)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d %s", w.Code, w.Body)
+		}
+
+		var result gofman.TrashResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+
+		return &result
+	}
+
+	uploaded := remove(t, h.StorageRoot, "uploaded.txt")
+	tracked := remove(t, t.TempDir(), "tracked.txt")
+
+	t.Run("DryRun", func(t *testing.T) {
+		if result := empty(t, "/trash/empty?dry_run=true"); result.Files != 2 || result.DiskFiles != 1 {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+
+		if _, err := os.Stat(uploaded.Path); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if result := empty(t, "/trash/empty"); result.Files != 2 || result.DiskFiles != 1 {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+
+		// Only the file below the storage root is deleted from disk.
+		if _, err := os.Stat(uploaded.Path); !os.IsNotExist(err) {
+			t.Fatalf("Unexpected error: %v", err)
+		} else if _, err := os.Stat(tracked.Path); err != nil {
+			t.Fatal(err)
+		}
+
+		if result := empty(t, "/trash/empty"); result.Files != 0 || result.DiskFiles != 0 {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+	})
+}
+
+func TestIntegration_Login(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		user := h.MustCreateUser(t, "jane", "password")
+
+		w := h.Do(httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"Jane","password":"password"}`)))
+
+		var resp gofmanhttp.AccountResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.User.ID != user.ID || resp.User.Password != "" || resp.Session.Token != "" {
+			t.Fatalf("Unexpected account: %#v", resp)
+		}
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 2 || cookies[0].Name != "Session" || cookies[0].Value != resp.Session.ID || cookies[1].Name != "Token" {
+			t.Fatalf("Unexpected cookies: %v", cookies)
+		}
+
+		if w := h.Do(httptest.NewRequest("GET", "/account", nil), cookies...); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status with session cookies: %d", w.Code)
+		}
+	})
+
+	t.Run("ErrInvalidCredentials", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		h.MustCreateUser(t, "jane", "password")
+
+		for _, body := range []string{
+			`{"username":"jane","password":"wrong"}`,
+			`{"username":"john","password":"password"}`,
+		} {
+			w := h.Do(httptest.NewRequest("POST", "/login", strings.NewReader(body)))
+
+			var resp gofmanhttp.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			// Unknown usernames must not be distinguishable from wrong
+			// passwords.
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("Unexpected status: %d", w.Code)
+			} else if resp.Error.Message != "Invalid username or password." {
+				t.Fatalf("Unexpected error: %#v", resp.Error)
+			} else if len(w.Result().Cookies()) != 0 {
+				t.Fatal("Expected no cookies.")
+			}
+		}
+	})
+
+	t.Run("ErrTooManyAttempts", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		h.MustCreateUser(t, "jane", "password")
+		h.LoginLimiter = gofmanhttp.NewLoginLimiter(1, time.Minute)
+
+		h.Do(httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"jane","password":"wrong"}`)))
+
+		// Even the right password is rejected until the window passed.
+		w := h.Do(httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"jane","password":"password"}`)))
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.Error.Message != "Too many login attempts. Please try again later." {
+			t.Fatalf("Unexpected error: %#v", resp.Error)
+		}
+	})
+}
+
+func TestIntegration_Logout(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		h.MustCreateUser(t, "jane", "password")
+
+		w := h.Do(httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"jane","password":"password"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected login status: %d", w.Code)
+		}
+
+		cookies := w.Result().Cookies()
+
+		w = h.Do(httptest.NewRequest("POST", "/logout", nil), cookies...)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		cleared := w.Result().Cookies()
+		if len(cleared) != 2 {
+			t.Fatalf("Unexpected cookies: %v", cleared)
+		}
+
+		for _, cookie := range cleared {
+			if cookie.MaxAge >= 0 || cookie.Value != "" {
+				t.Fatalf("Expected cookie to be cleared: %v", cookie)
+			}
+		}
+
+		if _, err := h.SessionService.FindSessionForToken(context.Background(), cookies[0].Value, cookies[1].Value); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Expected session to be deleted: %#v", err)
+		}
+	})
+
+	t.Run("NoSession", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		if w := h.Do(httptest.NewRequest("POST", "/logout", nil)); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if len(w.Result().Cookies()) != 2 {
+			t.Fatal("Expected cookies to be cleared.")
+		}
+	})
+}
+
+func TestIntegration_Setup(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		w := h.Do(httptest.NewRequest("GET", "/setup", nil))
+
+		var resp gofmanhttp.SetupResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if !resp.SetupRequired {
+			t.Fatal("Expected setup to be required.")
+		}
+
+		w = h.Do(httptest.NewRequest("POST", "/setup", strings.NewReader(`{"username":"admin","password":"password"}`)))
+
+		var user gofman.User
+		if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if user.Username != "admin" || !user.IsAdmin || user.Role != gofman.RoleAdmin || user.Password != "" {
+			t.Fatalf("Unexpected user: %#v", user)
+		}
+
+		// The admin can log in and manage users.
+		cookies := h.MustLogin(t, "admin", "password")
+		if w := h.Do(httptest.NewRequest("GET", "/users", nil), cookies...); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+
+	t.Run("Done", func(t *testing.T) {
+		h := MustOpenHarness(t)
+		defer h.MustClose(t)
+
+		h.MustCreateUser(t, "jane", "password")
+
+		if w := h.Do(httptest.NewRequest("GET", "/setup", nil)); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		if w := h.Do(httptest.NewRequest("POST", "/setup", strings.NewReader(`{"username":"admin","password":"password"}`))); w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		if _, err := h.UserService.FindUserByUsername(adminContext(), "admin"); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Expected no admin to be created: %#v", err)
+		}
+	})
+}
+
+func TestIntegration_Readyz(t *testing.T) {
+	h := MustOpenHarness(t)
+
+	if w := h.Do(httptest.NewRequest("GET", "/readyz", nil)); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	h.MustClose(t)
+
+	if w := h.Do(httptest.NewRequest("GET", "/readyz", nil)); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+
+	// The process itself is still up.
+	if w := h.Do(httptest.NewRequest("GET", "/healthz", nil)); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	}
+}
+
+// Harness represents a server wired to a real database and the real services
+// so tests can exercise complete flows.
+type Harness struct {
+	*gofmanhttp.Server
+
+	DB *sqlite.DB
+}
+
+// MustOpenHarness returns a new server backed by a database in a temporary
+// directory. Fatal on error.
+func MustOpenHarness(tb testing.TB) *Harness {
+	tb.Helper()
+
+	db := sqlite.NewDB()
+	db.DSN = filepath.Join(tb.TempDir(), "db")
+	db.AuthService = auth.NewAuthService()
+	db.PathTraversalService = path_traversal.NewPathTraversalService()
+
+	if err := db.Open(); err != nil {
+		tb.Fatal(err)
+	}
+
+	s := gofmanhttp.NewServer()
+	s.ActorService = sqlite.NewActorService(db)
+	s.FileService = sqlite.NewFileService(db)
+	s.FileTagService = sqlite.NewFileTagService(db)
+	s.FolderService = sqlite.NewFolderService(db)
+	s.HealthService = sqlite.NewHealthService(db)
+	s.MigrationService = sqlite.NewMigrationService(db)
+	s.SessionService = sqlite.NewSessionService(db)
+	s.SetupService = sqlite.NewSetupService(db)
+	s.TagService = sqlite.NewTagService(db)
+	s.TrashService = sqlite.NewTrashService(db)
+	s.UserService = sqlite.NewUserService(db)
+	s.AuthService = db.AuthService
+	s.PathTraversalService = db.PathTraversalService
+
+	return &Harness{Server: s, DB: db}
+}
+
+// MustClose closes the database of the harness. Fatal on error.
+func (h *Harness) MustClose(tb testing.TB) {
+	tb.Helper()
+
+	if err := h.DB.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// MustCreateUser creates a user with the given credentials. Fatal on error.
+func (h *Harness) MustCreateUser(tb testing.TB, username, password string) *gofman.User {
+	tb.Helper()
+
+	user := &gofman.User{Username: username, Password: password}
+	if err := h.UserService.CreateUser(adminContext(), user); err != nil {
+		tb.Fatal(err)
+	}
+
+	return user
+}
+
+// MustLogin verifies the credentials and creates a new session the same way
+// a login does. Returns the Session, Token and CSRF cookies. Fatal on error.
+func (h *Harness) MustLogin(tb testing.TB, username, password string) []*http.Cookie {
+	tb.Helper()
+
+	user, err := h.UserService.FindUserByUsername(adminContext(), username)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if err := h.AuthService.VerifyUserPassword(user, password); err != nil {
+		tb.Fatal(err)
+	}
+
+	token, err := h.AuthService.NewToken()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	session := &gofman.Session{UserID: user.ID, Token: token}
+	if err := h.SessionService.CreateSession(gofman.NewContextWithUser(context.Background(), user), session); err != nil {
+		tb.Fatal(err)
+	}
+
+	return []*http.Cookie{
+		{Name: "Session", Value: session.ID},
+		{Name: "Token", Value: session.Token},
+		{Name: gofmanhttp.CSRFCookie, Value: "csrf"},
+	}
+}
+
+// MustLogout deletes the session of the given cookies. Fatal on error.
+func (h *Harness) MustLogout(tb testing.TB, cookies []*http.Cookie) {
+	tb.Helper()
+
+	session, err := h.SessionService.FindSessionForToken(context.Background(), cookies[0].Value, cookies[1].Value)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	ctx := gofman.NewContextWithUser(context.Background(), &gofman.User{ID: session.UserID})
+
+	if err := h.SessionService.DeleteSession(ctx, session.ID); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// Do serves the request with the given cookies and returns the recorded
+// response. The value of a CSRF cookie is also sent in the CSRF header.
+func (h *Harness) Do(r *http.Request, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+
+		if cookie.Name == gofmanhttp.CSRFCookie {
+			r.Header.Set(gofmanhttp.CSRFHeader, cookie.Value)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// adminContext returns a context with an admin as the current user.
+func adminContext() context.Context {
+	return gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// MaxJSONBodySize is the maximum size of JSON request bodies in bytes.
+const MaxJSONBodySize = 1 << 20
+
+// decodeJSON decodes the JSON request body into dst. The body must hold a
+// single JSON value of at most MaxJSONBodySize bytes without fields unknown
+// to dst. Decode failures are returned as EINVALID with a message that can be
+// shown to the client.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxJSONBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return jsonDecodeError(err)
+	}
+
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if err != nil && isBodyTooLarge(err) {
+			return jsonDecodeError(err)
+		}
+
+		return gofman.NewError(gofman.EINVALID, "Request body must only contain a single JSON value.")
+	}
+
+	return nil
+}
+
+// jsonDecodeError translates an error of the JSON decoder into EINVALID.
+func jsonDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Invalid JSON body at offset %d.", syntaxErr.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Invalid JSON body, unexpected end of body.")
+	case errors.Is(err, io.EOF):
+		return gofman.NewError(gofman.EINVALID, "Request body must not be empty.")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Invalid value for field %q, expected %s.", typeErr.Field, typeErr.Type)
+	case errors.As(err, &typeErr):
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Invalid JSON body, expected %s.", typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Unknown field %s.", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	case isBodyTooLarge(err):
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Request body must not exceed %d bytes.", MaxJSONBodySize)
+	default:
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Invalid JSON body.")
+	}
+}
+
+// isBodyTooLarge returns true if reading the body failed because it exceeded
+// the limit of http.MaxBytesReader. The reader does not return a distinct
+// error type before Go 1.19, so the message is compared.
+func isBodyTooLarge(err error) bool {
+	return err != nil && err.Error() == "http: request body too large"
+}
+
+// writeJSON writes the data as JSON with the given status code.
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// created writes a 201 Created response with the Location header pointing at
+// the newly created resource and the resource itself as JSON body. A relative
+// location is resolved against the URL of the request.
+func created(w http.ResponseWriter, r *http.Request, location string, body interface{}) {
+	if u, err := url.Parse(location); err == nil {
+		location = r.URL.ResolveReference(u).String()
+	}
+
+	w.Header().Set("Location", location)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+// ListResponse represents the JSON structure of list responses. Data holds
+// the requested page and Total the number of hits of the whole list, so
+// clients can page through it.
+type ListResponse struct {
+	Data   interface{} `json:"data"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// writeList writes a page of a list wrapped in a ListResponse. The limit must
+// be the one applied by the service, see effectiveLimit. The Link header
+// points at the neighbouring pages.
+func writeList(w http.ResponseWriter, r *http.Request, data interface{}, offset, limit, total int) {
+	setLinkHeader(w, r, gofman.NewPagination(offset, limit, total))
+
+	writeJSON(w, http.StatusOK, &ListResponse{
+		Data:   data,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// ErrorResponse represents the JSON structure of an error response.
+type ErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+
+		// ID of the failed request, so clients can refer to it when
+		// reporting errors.
+		RequestID string `json:"request_id,omitempty"`
+	} `json:"error"`
+}
+
+// Error writes the application error as JSON with the status code matching
+// its error code. Internal errors are logged together with the request ID and
+// their details are not exposed to the client. Internal errors of requests
+// that ran out of time are reported as timeout instead.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	code, message := gofman.ErrorCode(err), gofman.ErrorMessage(err)
+	requestID := gofman.RequestIDFromContext(r.Context())
+
+	if code == gofman.EINTERNAL && r.Context().Err() == context.DeadlineExceeded {
+		code, message = gofman.ETIMEOUT, "Request timed out."
+	}
+
+	if code == gofman.EINTERNAL {
+		log.Printf("http error: %s %s (request %s): %s", r.Method, r.URL.Path, requestID, err)
+	}
+
+	var resp ErrorResponse
+	resp.Error.Code = code
+	resp.Error.Message = message
+	resp.Error.RequestID = requestID
+
+	writeJSON(w, gofman.ErrorStatusCode(code), &resp)
+}
+
+// notImplemented is a placeholder handler for routes that are planned but
+// not built yet.
+func notImplemented(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, gofman.NewError(gofman.ENOTIMPLEMENTED, "Not implemented."))
+}
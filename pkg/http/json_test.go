@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestCreated(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/tags", nil)
+		w := httptest.NewRecorder()
+
+		created(w, r, "/tags/1", &gofman.Tag{ID: "1", Name: "tag"})
+
+		var tag gofman.Tag
+		if err := json.NewDecoder(w.Body).Decode(&tag); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Location"); v != "/tags/1" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		} else if v := w.Header().Get("Content-Type"); v != "application/json" {
+			t.Fatalf("Unexpected Content-Type header: %q", v)
+		} else if tag.ID != "1" || tag.Name != "tag" {
+			t.Fatalf("Unexpected body: %#v", tag)
+		}
+	})
+
+	t.Run("RelativeLocation", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/files/upload?folder_id=2", nil)
+		w := httptest.NewRecorder()
+
+		created(w, r, "1", &gofman.File{ID: "1"})
+
+		if v := w.Header().Get("Location"); v != "/files/1" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		}
+	})
+}
+
+func TestNotImplemented(t *testing.T) {
+	s := NewServer()
+	s.router.HandleFunc("/stub", notImplemented).Methods("GET")
+
+	r := httptest.NewRequest("GET", "/stub", nil)
+	w := httptest.NewRecorder()
+
+	s.ServeHTTP(w, r)
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if resp.Error.Code != gofman.ENOTIMPLEMENTED {
+		t.Fatalf("Unexpected error code: %q", resp.Error.Code)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	decode := func(body string) (*gofman.TagUpdate, error) {
+		var update gofman.TagUpdate
+
+		r := httptest.NewRequest("PATCH", "/tags/1", strings.NewReader(body))
+		if err := decodeJSON(httptest.NewRecorder(), r, &update); err != nil {
+			return nil, err
+		}
+
+		return &update, nil
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		if update, err := decode(`{"name":"tag"}`); err != nil {
+			t.Fatal(err)
+		} else if update.Name == nil || *update.Name != "tag" {
+			t.Fatalf("Unexpected update: %#v", update)
+		}
+	})
+
+	for _, tt := range []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{"ErrMalformed", `{"name":}`, "Invalid JSON body at offset 9."},
+		{"ErrTruncated", `{"name":"tag"`, "Invalid JSON body, unexpected end of body."},
+		{"ErrEmpty", ``, "Request body must not be empty."},
+		{"ErrType", `{"name":1}`, `Invalid value for field "name", expected string.`},
+		{"ErrUnknownField", `{"title":"tag"}`, `Unknown field "title".`},
+		{"ErrMultipleValues", `{"name":"tag"}{}`, "Request body must only contain a single JSON value."},
+		{"ErrTooLarge", `{"name":"` + strings.Repeat("a", MaxJSONBodySize) + `"}`, "Request body must not exceed 1048576 bytes."},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decode(tt.body)
+			if gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Unexpected error: %#v", err)
+			} else if msg := gofman.ErrorMessage(err); msg != tt.message {
+				t.Fatalf("Unexpected message: %q", msg)
+			}
+		})
+	}
+}
+
+func TestWriteList(t *testing.T) {
+	r := httptest.NewRequest("GET", "/tags?offset=2&limit=2", nil)
+	w := httptest.NewRecorder()
+
+	writeList(w, r, []*gofman.Tag{{ID: "3"}, {ID: "4"}}, 2, 2, 5)
+
+	var resp map[string]json.RawMessage
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if len(resp) != 4 {
+		t.Fatalf("Unexpected envelope: %s", w.Body)
+	} else if string(resp["total"]) != "5" || string(resp["limit"]) != "2" || string(resp["offset"]) != "2" {
+		t.Fatalf("Unexpected envelope: %v", resp)
+	} else if w.Header().Get("Link") == "" {
+		t.Fatal("Expected Link header")
+	}
+
+	var tags []*gofman.Tag
+	if err := json.Unmarshal(resp["data"], &tags); err != nil {
+		t.Fatal(err)
+	} else if len(tags) != 2 || tags[0].ID != "3" || tags[1].ID != "4" {
+		t.Fatalf("Unexpected data: %s", resp["data"])
+	}
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/gorilla/mux"
+)
+
+// registerLoginRoutes is a helper function for registering the login and
+// logout routes. Both work with and without a session.
+func (s *Server) registerLoginRoutes(r *mux.Router) {
+	r.HandleFunc("/login", s.handleLogin).Methods("POST")
+	r.HandleFunc("/logout", s.handleLogout).Methods("POST")
+}
+
+// LoginRequest represents the JSON structure accepted by POST /login.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// Keep the session for RememberTTL instead of SessionTTL and let its
+	// cookies outlive the browser.
+	Remember bool `json:"remember"`
+}
+
+// handleLogin verifies the credentials of a user and creates a new session.
+// The Session and Token cookies of the session are set and the user and
+// session are returned like by GET /account. Unknown usernames and wrong
+// passwords return the same error, so usernames cannot be enumerated.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Usernames are stored lowercase.
+	req.Username = strings.ToLower(req.Username)
+
+	ip := s.remoteIP(r)
+
+	if s.LoginLimiter != nil {
+		if err := s.LoginLimiter.Allow(req.Username, ip); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	// Nobody is logged in yet, so the user is looked up on behalf of an
+	// admin.
+	admin := gofman.NewContextWithUser(r.Context(), &gofman.User{IsAdmin: true})
+
+	user, err := s.UserService.FindUserByUsername(admin, req.Username)
+	if err != nil && gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		Error(w, r, err)
+		return
+	} else if err != nil {
+		user = nil
+	}
+
+	if err := s.AuthService.VerifyUserPassword(user, req.Password); err != nil || user == nil {
+		if s.LoginLimiter != nil {
+			s.LoginLimiter.Fail(req.Username, ip)
+		}
+
+		Error(w, r, gofman.NewError(gofman.EUNAUTHORIZED, "Invalid username or password."))
+		return
+	}
+
+	token, err := s.AuthService.NewToken()
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	session := s.newSession(r, user.ID, token, req.Remember)
+
+	if err := s.SessionService.CreateSession(gofman.NewContextWithUser(r.Context(), user), session); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if s.LoginLimiter != nil {
+		s.LoginLimiter.Reset(req.Username, ip)
+	}
+
+	s.setSessionCookies(w, session, req.Remember, time.Now().Unix())
+
+	writeJSON(w, http.StatusOK, &AccountResponse{
+		User:    user.Redacted(),
+		Session: session.Redacted(),
+	})
+}
+
+// handleLogout deletes the current session and clears its cookies. Requests
+// without a valid session only clear the cookies, so logging out never fails
+// because the session already expired or was revoked.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if session := gofman.SessionFromContext(r.Context()); session != nil {
+		if err := s.SessionService.DeleteSession(r.Context(), session.ID); err != nil && gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	s.clearSessionCookies(w)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Login limiter constants.
+const (
+	DefaultLoginMaxAttempts = 5
+	DefaultLoginWindow      = 15 * time.Minute
+)
+
+// LoginLimiter throttles password guessing by counting failed logins per
+// username within a sliding window. Once MaxAttempts failures fall within
+// the window, further logins are rejected until the oldest failure leaves
+// the window. It is safe for concurrent use.
+type LoginLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+
+	// Number of failed logins allowed within the window. Zero disables the
+	// limiter.
+	MaxAttempts int
+	Window      time.Duration
+
+	// Count failures per username and remote IP instead of per username
+	// only. This keeps one client from locking out a user everywhere, at the
+	// cost of letting distributed guessing through.
+	ByIP bool
+
+	// Returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewLoginLimiter returns a new instance of LoginLimiter.
+func NewLoginLimiter(maxAttempts int, window time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		failures: make(map[string][]time.Time),
+
+		MaxAttempts: maxAttempts,
+		Window:      window,
+		Now:         time.Now,
+	}
+}
+
+// Allow returns EUNAUTHORIZED if the username has too many recent failed
+// logins. It must be called before the password is verified.
+func (l *LoginLimiter) Allow(username, ip string) error {
+	if l.MaxAttempts <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := l.key(username, ip)
+
+	if len(l.recent(key)) >= l.MaxAttempts {
+		return gofman.NewError(gofman.EUNAUTHORIZED, "Too many login attempts. Please try again later.")
+	}
+
+	return nil
+}
+
+// Fail records a failed login of the username.
+func (l *LoginLimiter) Fail(username, ip string) {
+	if l.MaxAttempts <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := l.key(username, ip)
+
+	// Only the newest failures matter, older ones are dropped so a single
+	// username cannot grow without bounds.
+	failures := append(l.recent(key), l.Now())
+	if len(failures) > l.MaxAttempts {
+		failures = failures[len(failures)-l.MaxAttempts:]
+	}
+
+	l.failures[key] = failures
+}
+
+// Reset forgets all failed logins of the username. It is called after a
+// successful login.
+func (l *LoginLimiter) Reset(username, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, l.key(username, ip))
+}
+
+// Sweep removes usernames without failures in the current window and
+// returns the number of removed usernames.
+func (l *LoginLimiter) Sweep() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var n int
+
+	for key := range l.failures {
+		if len(l.recent(key)) == 0 {
+			delete(l.failures, key)
+			n++
+		}
+	}
+
+	return n
+}
+
+// run sweeps the limiter once per window until the context is cancelled.
+func (l *LoginLimiter) run(ctx context.Context) {
+	if l.Window <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(l.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.Sweep()
+		}
+	}
+}
+
+// recent returns the failures of the key within the window and drops all
+// older ones. The lock must be held.
+func (l *LoginLimiter) recent(key string) []time.Time {
+	failures := l.failures[key]
+	since := l.Now().Add(-l.Window)
+
+	i := 0
+	for i < len(failures) && !failures[i].After(since) {
+		i++
+	}
+
+	if i > 0 {
+		failures = failures[i:]
+
+		if len(failures) == 0 {
+			delete(l.failures, key)
+		} else {
+			l.failures[key] = failures
+		}
+	}
+
+	return failures
+}
+
+// key returns the map key of a username. Usernames are stored lowercase, so
+// the key is too.
+func (l *LoginLimiter) key(username, ip string) string {
+	username = strings.ToLower(username)
+
+	if l.ByIP {
+		return username + "\x00" + ip
+	}
+
+	return username
+}
@@ -0,0 +1,146 @@
+package http_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+
+	newLimiter := func() *gofmanhttp.LoginLimiter {
+		l := gofmanhttp.NewLoginLimiter(3, time.Minute)
+		l.Now = func() time.Time { return now }
+		return l
+	}
+
+	t.Run("Limit", func(t *testing.T) {
+		l := newLimiter()
+
+		for i := 0; i < 3; i++ {
+			if err := l.Allow("jane", "1.2.3.4"); err != nil {
+				t.Fatalf("Unexpected error on attempt %d: %#v", i, err)
+			}
+
+			l.Fail("jane", "1.2.3.4")
+		}
+
+		if err := l.Allow("JANE", "1.2.3.4"); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if err := l.Allow("john", "1.2.3.4"); err != nil {
+			t.Fatalf("Unexpected error for other username: %#v", err)
+		}
+	})
+
+	t.Run("SlidingWindow", func(t *testing.T) {
+		l := newLimiter()
+		start := now
+		defer func() { now = start }()
+
+		for i := 0; i < 3; i++ {
+			l.Fail("jane", "")
+			now = now.Add(20 * time.Second)
+		}
+
+		// The first failure is exactly one window old now.
+		if err := l.Allow("jane", ""); err != nil {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		l.Fail("jane", "")
+
+		if err := l.Allow("jane", ""); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		l := newLimiter()
+
+		for i := 0; i < 3; i++ {
+			l.Fail("jane", "")
+		}
+
+		l.Reset("jane", "")
+
+		if err := l.Allow("jane", ""); err != nil {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ByIP", func(t *testing.T) {
+		l := newLimiter()
+		l.ByIP = true
+
+		for i := 0; i < 3; i++ {
+			l.Fail("jane", "1.2.3.4")
+		}
+
+		if err := l.Allow("jane", "1.2.3.4"); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if err := l.Allow("jane", "5.6.7.8"); err != nil {
+			t.Fatalf("Unexpected error for other IP: %#v", err)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		l := newLimiter()
+		l.MaxAttempts = 0
+
+		for i := 0; i < 10; i++ {
+			l.Fail("jane", "")
+		}
+
+		if err := l.Allow("jane", ""); err != nil {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		l := newLimiter()
+		start := now
+		defer func() { now = start }()
+
+		l.Fail("jane", "")
+		l.Fail("john", "")
+
+		now = now.Add(30 * time.Second)
+		l.Fail("john", "")
+
+		now = now.Add(45 * time.Second)
+
+		if n := l.Sweep(); n != 1 {
+			t.Fatalf("Unexpected swept usernames: %d", n)
+		} else if n := l.Sweep(); n != 0 {
+			t.Fatalf("Unexpected swept usernames: %d", n)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		l := newLimiter()
+		l.MaxAttempts = 1000
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 50; j++ {
+					l.Allow("jane", "")
+					l.Fail("jane", "")
+				}
+			}()
+		}
+		wg.Wait()
+
+		l.MaxAttempts = 500
+		if err := l.Allow("jane", ""); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics constants.
+const (
+	// Maximum duration of collecting the stats on a scrape.
+	MetricsStatsTimeout = 5 * time.Second
+)
+
+// metrics holds the Prometheus registry of the server and the collectors
+// filled by the instrument middleware. Every server has its own registry, so
+// multiple servers can exist in the same process.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// newMetrics returns the metrics of the server with the Go runtime, process
+// and stats collectors registered.
+func (s *Server) newMetrics() *metrics {
+	labels := []string{"method", "route", "status"}
+
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gofman_http_requests_total",
+			Help: "Number of served HTTP requests.",
+		}, labels),
+
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gofman_http_request_duration_seconds",
+			Help:    "Duration of served HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		newStatsCollector(s),
+	)
+
+	return m
+}
+
+// handleMetrics exposes the metrics in the Prometheus text format. The route
+// does not exist unless metrics are enabled.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.Metrics {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// instrument is middleware for counting requests and measuring their
+// duration by route and status. It does nothing unless metrics are enabled.
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Metrics {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		route := s.routeName(r)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.Status())
+
+		s.metrics.requests.WithLabelValues(r.Method, route, status).Inc()
+		s.metrics.duration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeName returns the path template of the route matching the request, so
+// IDs do not create a time series each. HEAD requests are reported as the GET
+// route they are dispatched to. Requests without a route are reported as
+// "other".
+func (s *Server) routeName(r *http.Request) string {
+	req := r
+	if r.Method == http.MethodHead {
+		req = r.Clone(r.Context())
+		req.Method = http.MethodGet
+	}
+
+	var match mux.RouteMatch
+	if !s.router.Match(req, &match) || match.Route == nil {
+		return "other"
+	}
+
+	tpl, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return "other"
+	}
+
+	return tpl
+}
+
+// statsCollector collects the counters of the stats service on every scrape.
+type statsCollector struct {
+	s *Server
+
+	users            *prometheus.Desc
+	files            *prometheus.Desc
+	openConnections  *prometheus.Desc
+	inUseConnections *prometheus.Desc
+	idleConnections  *prometheus.Desc
+}
+
+// newStatsCollector returns a new instance of statsCollector.
+func newStatsCollector(s *Server) *statsCollector {
+	return &statsCollector{
+		s: s,
+
+		users:            prometheus.NewDesc("gofman_users", "Number of users.", nil, nil),
+		files:            prometheus.NewDesc("gofman_files", "Number of files, excluding the trash.", nil, nil),
+		openConnections:  prometheus.NewDesc("gofman_db_open_connections", "Number of open database connections.", nil, nil),
+		inUseConnections: prometheus.NewDesc("gofman_db_in_use_connections", "Number of database connections in use.", nil, nil),
+		idleConnections:  prometheus.NewDesc("gofman_db_idle_connections", "Number of idle database connections.", nil, nil),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.users
+	ch <- c.files
+	ch <- c.openConnections
+	ch <- c.inUseConnections
+	ch <- c.idleConnections
+}
+
+// Collect implements the prometheus.Collector interface. Nothing is
+// collected if the stats cannot be read, so the other metrics are still
+// scraped.
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.s.StatsService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), MetricsStatsTimeout)
+	defer cancel()
+
+	stats, err := c.s.StatsService.Stats(ctx)
+	if err != nil {
+		log.Printf("http metrics: cannot collect stats: %s", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.users, prometheus.GaugeValue, float64(stats.Users))
+	ch <- prometheus.MustNewConstMetric(c.files, prometheus.GaugeValue, float64(stats.Files))
+	ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUseConnections, prometheus.GaugeValue, float64(stats.InUseConnections))
+	ch <- prometheus.MustNewConstMetric(c.idleConnections, prometheus.GaugeValue, float64(stats.IdleConnections))
+}
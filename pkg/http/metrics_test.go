@@ -0,0 +1,104 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	// scrape returns the metrics exposed by the server.
+	scrape := func(t *testing.T, s *gofmanhttp.Server) string {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+
+		return w.Body.String()
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+		s.Metrics = true
+		s.StatsService = &StatsService{
+			StatsFn: func(ctx context.Context) (*gofman.Stats, error) {
+				return &gofman.Stats{Users: 2, Files: 3, OpenConnections: 1, IdleConnections: 1}, nil
+			},
+		}
+
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+		body := scrape(t, s)
+
+		for _, want := range []string{
+			`gofman_http_requests_total{method="GET",route="/healthz",status="200"} 1`,
+			`gofman_http_request_duration_seconds_count{method="GET",route="/healthz",status="200"} 1`,
+			"gofman_users 2",
+			"gofman_files 3",
+			"gofman_db_open_connections 1",
+			"go_goroutines",
+		} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("Expected %q in metrics:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("RouteTemplate", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+		s.Metrics = true
+
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/files/123", nil))
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/does/not/exist", nil))
+
+		body := scrape(t, s)
+
+		if want := `route="/files/{id}"`; !strings.Contains(body, want) {
+			t.Fatalf("Expected %q in metrics:\n%s", want, body)
+		} else if strings.Contains(body, "/files/123") || strings.Contains(body, "/does/not/exist") {
+			t.Fatalf("Expected request paths to be collapsed:\n%s", body)
+		}
+	})
+
+	t.Run("ErrStats", func(t *testing.T) {
+		// The error is logged, keep it out of the test output.
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		s := gofmanhttp.NewServer()
+		s.Metrics = true
+		s.StatsService = &StatsService{
+			StatsFn: func(ctx context.Context) (*gofman.Stats, error) {
+				return nil, errors.New("sql: database is closed")
+			},
+		}
+
+		if body := scrape(t, s); strings.Contains(body, "gofman_users") {
+			t.Fatalf("Unexpected stats in metrics:\n%s", body)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics", nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,348 @@
+package http_test
+
+import (
+	"context"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// ActorService represents a fake implementation of gofman.ActorService.
+type ActorService struct {
+	FindActorByIDFn     func(ctx context.Context, id string) (*gofman.Actor, error)
+	FindActorsFn        func(ctx context.Context, filter gofman.ActorFilter) ([]*gofman.Actor, int, error)
+	CreateActorFn       func(ctx context.Context, actor *gofman.Actor) error
+	UpdateActorFn       func(ctx context.Context, id string, update gofman.ActorUpdate) (*gofman.Actor, error)
+	RemoveActorFn       func(ctx context.Context, id string) error
+	RestoreActorFn      func(ctx context.Context, id string) error
+	EnsureActorByNameFn func(ctx context.Context, name string) (*gofman.Actor, error)
+	ExistsFn            func(ctx context.Context, id string) (bool, error)
+}
+
+func (s *ActorService) FindActorByID(ctx context.Context, id string) (*gofman.Actor, error) {
+	return s.FindActorByIDFn(ctx, id)
+}
+
+func (s *ActorService) FindActors(ctx context.Context, filter gofman.ActorFilter) ([]*gofman.Actor, int, error) {
+	return s.FindActorsFn(ctx, filter)
+}
+
+func (s *ActorService) CreateActor(ctx context.Context, actor *gofman.Actor) error {
+	return s.CreateActorFn(ctx, actor)
+}
+
+func (s *ActorService) UpdateActor(ctx context.Context, id string, update gofman.ActorUpdate) (*gofman.Actor, error) {
+	return s.UpdateActorFn(ctx, id, update)
+}
+
+func (s *ActorService) RemoveActor(ctx context.Context, id string) error {
+	return s.RemoveActorFn(ctx, id)
+}
+
+func (s *ActorService) RestoreActor(ctx context.Context, id string) error {
+	return s.RestoreActorFn(ctx, id)
+}
+
+func (s *ActorService) EnsureActorByName(ctx context.Context, name string) (*gofman.Actor, error) {
+	return s.EnsureActorByNameFn(ctx, name)
+}
+
+func (s *ActorService) Exists(ctx context.Context, id string) (bool, error) {
+	return s.ExistsFn(ctx, id)
+}
+
+// FileService represents a fake implementation of gofman.FileService.
+type FileService struct {
+	FindFileByIDFn       func(ctx context.Context, id string) (*gofman.File, error)
+	FindFileByChecksumFn func(ctx context.Context, userID, checksum string) (*gofman.File, error)
+	FindFilesFn          func(ctx context.Context, filter gofman.FileFilter) ([]*gofman.File, int, error)
+	SearchFilesFn        func(ctx context.Context, query string, filter gofman.FileFilter) ([]*gofman.File, int, error)
+	CreateFileFn         func(ctx context.Context, file *gofman.File) error
+	CreateFilesFn        func(ctx context.Context, files []*gofman.File) error
+	UpdateFileFn         func(ctx context.Context, id string, update gofman.FileUpdate) (*gofman.File, error)
+	RemoveFileFn         func(ctx context.Context, id string) error
+	RestoreFileFn        func(ctx context.Context, id string) error
+	GroupFilesByTagFn    func(ctx context.Context, filter gofman.FileGroupFilter) ([]*gofman.TagFiles, error)
+	GroupFilesByActorFn  func(ctx context.Context, filter gofman.FileGroupFilter) ([]*gofman.ActorFiles, error)
+	ReconcileFn          func(ctx context.Context, opts gofman.FileReconcileOptions) (*gofman.FileReconcileReport, error)
+	RecomputeChecksumFn  func(ctx context.Context, id string) (*gofman.File, error)
+	ExistsFn             func(ctx context.Context, id string) (bool, error)
+}
+
+func (s *FileService) FindFileByID(ctx context.Context, id string) (*gofman.File, error) {
+	return s.FindFileByIDFn(ctx, id)
+}
+
+func (s *FileService) FindFileByChecksum(ctx context.Context, userID, checksum string) (*gofman.File, error) {
+	return s.FindFileByChecksumFn(ctx, userID, checksum)
+}
+
+func (s *FileService) FindFiles(ctx context.Context, filter gofman.FileFilter) ([]*gofman.File, int, error) {
+	return s.FindFilesFn(ctx, filter)
+}
+
+func (s *FileService) SearchFiles(ctx context.Context, query string, filter gofman.FileFilter) ([]*gofman.File, int, error) {
+	return s.SearchFilesFn(ctx, query, filter)
+}
+
+func (s *FileService) CreateFile(ctx context.Context, file *gofman.File) error {
+	return s.CreateFileFn(ctx, file)
+}
+
+func (s *FileService) CreateFiles(ctx context.Context, files []*gofman.File) error {
+	return s.CreateFilesFn(ctx, files)
+}
+
+func (s *FileService) UpdateFile(ctx context.Context, id string, update gofman.FileUpdate) (*gofman.File, error) {
+	return s.UpdateFileFn(ctx, id, update)
+}
+
+func (s *FileService) RemoveFile(ctx context.Context, id string) error {
+	return s.RemoveFileFn(ctx, id)
+}
+
+func (s *FileService) RestoreFile(ctx context.Context, id string) error {
+	return s.RestoreFileFn(ctx, id)
+}
+
+func (s *FileService) GroupFilesByTag(ctx context.Context, filter gofman.FileGroupFilter) ([]*gofman.TagFiles, error) {
+	return s.GroupFilesByTagFn(ctx, filter)
+}
+
+func (s *FileService) GroupFilesByActor(ctx context.Context, filter gofman.FileGroupFilter) ([]*gofman.ActorFiles, error) {
+	return s.GroupFilesByActorFn(ctx, filter)
+}
+
+func (s *FileService) Reconcile(ctx context.Context, opts gofman.FileReconcileOptions) (*gofman.FileReconcileReport, error) {
+	return s.ReconcileFn(ctx, opts)
+}
+
+func (s *FileService) RecomputeChecksum(ctx context.Context, id string) (*gofman.File, error) {
+	return s.RecomputeChecksumFn(ctx, id)
+}
+
+func (s *FileService) Exists(ctx context.Context, id string) (bool, error) {
+	return s.ExistsFn(ctx, id)
+}
+
+// FileTagService represents a fake implementation of gofman.FileTagService.
+type FileTagService struct {
+	AddTagFn      func(ctx context.Context, fileID, tagID string) error
+	RemoveTagFn   func(ctx context.Context, fileID, tagID string) error
+	TagsForFileFn func(ctx context.Context, fileID string) ([]*gofman.Tag, error)
+	FilesForTagFn func(ctx context.Context, tagID string) ([]*gofman.File, error)
+}
+
+func (s *FileTagService) AddTag(ctx context.Context, fileID, tagID string) error {
+	return s.AddTagFn(ctx, fileID, tagID)
+}
+
+func (s *FileTagService) RemoveTag(ctx context.Context, fileID, tagID string) error {
+	return s.RemoveTagFn(ctx, fileID, tagID)
+}
+
+func (s *FileTagService) TagsForFile(ctx context.Context, fileID string) ([]*gofman.Tag, error) {
+	return s.TagsForFileFn(ctx, fileID)
+}
+
+func (s *FileTagService) FilesForTag(ctx context.Context, tagID string) ([]*gofman.File, error) {
+	return s.FilesForTagFn(ctx, tagID)
+}
+
+// FolderService represents a fake implementation of gofman.FolderService.
+type FolderService struct {
+	FindFolderByIDFn func(ctx context.Context, id string) (*gofman.Folder, error)
+	FindFoldersFn    func(ctx context.Context, filter gofman.FolderFilter) ([]*gofman.Folder, int, error)
+	CreateFolderFn   func(ctx context.Context, folder *gofman.Folder) error
+	UpdateFolderFn   func(ctx context.Context, id string, update gofman.FolderUpdate) (*gofman.Folder, error)
+	RemoveFolderFn   func(ctx context.Context, id string) error
+}
+
+func (s *FolderService) FindFolderByID(ctx context.Context, id string) (*gofman.Folder, error) {
+	return s.FindFolderByIDFn(ctx, id)
+}
+
+func (s *FolderService) FindFolders(ctx context.Context, filter gofman.FolderFilter) ([]*gofman.Folder, int, error) {
+	return s.FindFoldersFn(ctx, filter)
+}
+
+func (s *FolderService) CreateFolder(ctx context.Context, folder *gofman.Folder) error {
+	return s.CreateFolderFn(ctx, folder)
+}
+
+func (s *FolderService) UpdateFolder(ctx context.Context, id string, update gofman.FolderUpdate) (*gofman.Folder, error) {
+	return s.UpdateFolderFn(ctx, id, update)
+}
+
+func (s *FolderService) RemoveFolder(ctx context.Context, id string) error {
+	return s.RemoveFolderFn(ctx, id)
+}
+
+// HealthService represents a fake implementation of gofman.HealthService.
+type HealthService struct {
+	PingFn func(ctx context.Context) error
+}
+
+func (s *HealthService) Ping(ctx context.Context) error {
+	return s.PingFn(ctx)
+}
+
+// MigrationService represents a fake implementation of
+// gofman.MigrationService.
+type MigrationService struct {
+	MigrationStatusFn func(ctx context.Context) (*gofman.MigrationStatus, error)
+}
+
+func (s *MigrationService) MigrationStatus(ctx context.Context) (*gofman.MigrationStatus, error) {
+	return s.MigrationStatusFn(ctx)
+}
+
+// SessionService represents a fake implementation of gofman.SessionService.
+type SessionService struct {
+	FindSessionForTokenFn   func(ctx context.Context, id string, token string) (*gofman.Session, error)
+	FindSessionsFn          func(ctx context.Context, filter gofman.SessionFilter) ([]*gofman.Session, int, error)
+	FindSessionsForUserFn   func(ctx context.Context, userID string) ([]*gofman.Session, error)
+	CreateSessionFn         func(ctx context.Context, session *gofman.Session) error
+	DeleteSessionFn         func(ctx context.Context, id string) error
+	DeleteSessionsForUserFn func(ctx context.Context, userID string) (int, error)
+	PurgeExpiredFn          func(ctx context.Context) (int, error)
+}
+
+func (s *SessionService) FindSessionForToken(ctx context.Context, id string, token string) (*gofman.Session, error) {
+	return s.FindSessionForTokenFn(ctx, id, token)
+}
+
+func (s *SessionService) FindSessions(ctx context.Context, filter gofman.SessionFilter) ([]*gofman.Session, int, error) {
+	return s.FindSessionsFn(ctx, filter)
+}
+
+func (s *SessionService) FindSessionsForUser(ctx context.Context, userID string) ([]*gofman.Session, error) {
+	return s.FindSessionsForUserFn(ctx, userID)
+}
+
+func (s *SessionService) CreateSession(ctx context.Context, session *gofman.Session) error {
+	return s.CreateSessionFn(ctx, session)
+}
+
+func (s *SessionService) DeleteSession(ctx context.Context, id string) error {
+	return s.DeleteSessionFn(ctx, id)
+}
+
+func (s *SessionService) DeleteSessionsForUser(ctx context.Context, userID string) (int, error) {
+	return s.DeleteSessionsForUserFn(ctx, userID)
+}
+
+func (s *SessionService) PurgeExpired(ctx context.Context) (int, error) {
+	return s.PurgeExpiredFn(ctx)
+}
+
+// StatsService represents a fake implementation of gofman.StatsService.
+type StatsService struct {
+	StatsFn func(ctx context.Context) (*gofman.Stats, error)
+}
+
+func (s *StatsService) Stats(ctx context.Context) (*gofman.Stats, error) {
+	return s.StatsFn(ctx)
+}
+
+// TagService represents a fake implementation of gofman.TagService.
+type TagService struct {
+	FindTagByIDFn     func(ctx context.Context, id string) (*gofman.Tag, error)
+	FindTagsFn        func(ctx context.Context, filter gofman.TagFilter) ([]*gofman.Tag, int, error)
+	CreateTagFn       func(ctx context.Context, tag *gofman.Tag) error
+	UpdateTagFn       func(ctx context.Context, id string, update gofman.TagUpdate) (*gofman.Tag, error)
+	RemoveTagFn       func(ctx context.Context, id string) error
+	RestoreTagFn      func(ctx context.Context, id string) error
+	EnsureTagByNameFn func(ctx context.Context, name string) (*gofman.Tag, error)
+	ExistsFn          func(ctx context.Context, id string) (bool, error)
+}
+
+func (s *TagService) FindTagByID(ctx context.Context, id string) (*gofman.Tag, error) {
+	return s.FindTagByIDFn(ctx, id)
+}
+
+func (s *TagService) FindTags(ctx context.Context, filter gofman.TagFilter) ([]*gofman.Tag, int, error) {
+	return s.FindTagsFn(ctx, filter)
+}
+
+func (s *TagService) CreateTag(ctx context.Context, tag *gofman.Tag) error {
+	return s.CreateTagFn(ctx, tag)
+}
+
+func (s *TagService) UpdateTag(ctx context.Context, id string, update gofman.TagUpdate) (*gofman.Tag, error) {
+	return s.UpdateTagFn(ctx, id, update)
+}
+
+func (s *TagService) RemoveTag(ctx context.Context, id string) error {
+	return s.RemoveTagFn(ctx, id)
+}
+
+func (s *TagService) RestoreTag(ctx context.Context, id string) error {
+	return s.RestoreTagFn(ctx, id)
+}
+
+func (s *TagService) EnsureTagByName(ctx context.Context, name string) (*gofman.Tag, error) {
+	return s.EnsureTagByNameFn(ctx, name)
+}
+
+func (s *TagService) Exists(ctx context.Context, id string) (bool, error) {
+	return s.ExistsFn(ctx, id)
+}
+
+// TrashService represents a fake implementation of gofman.TrashService.
+type TrashService struct {
+	EmptyTrashFn   func(ctx context.Context, dryRun bool) (*gofman.TrashResult, error)
+	PurgeRemovedFn func(ctx context.Context, olderThan int64, dryRun bool) (*gofman.TrashResult, error)
+}
+
+func (s *TrashService) EmptyTrash(ctx context.Context, dryRun bool) (*gofman.TrashResult, error) {
+	return s.EmptyTrashFn(ctx, dryRun)
+}
+
+func (s *TrashService) PurgeRemoved(ctx context.Context, olderThan int64, dryRun bool) (*gofman.TrashResult, error) {
+	return s.PurgeRemovedFn(ctx, olderThan, dryRun)
+}
+
+// UserService represents a fake implementation of gofman.UserService.
+type UserService struct {
+	FindUserByIDFn       func(ctx context.Context, id string) (*gofman.User, error)
+	FindUserByUsernameFn func(ctx context.Context, username string) (*gofman.User, error)
+	FindUsersFn          func(ctx context.Context, filter gofman.UserFilter) ([]*gofman.User, int, error)
+	CreateUserFn         func(ctx context.Context, user *gofman.User) error
+	UpdateUserFn         func(ctx context.Context, id string, update gofman.UserUpdate) (*gofman.User, error)
+	RemoveUserFn         func(ctx context.Context, id string) error
+	RestoreUserFn        func(ctx context.Context, id string) error
+
+	RemoveUserWithContentFn func(ctx context.Context, id string, dryRun bool) (*gofman.UserContent, error)
+}
+
+func (s *UserService) FindUserByID(ctx context.Context, id string) (*gofman.User, error) {
+	return s.FindUserByIDFn(ctx, id)
+}
+
+func (s *UserService) FindUserByUsername(ctx context.Context, username string) (*gofman.User, error) {
+	return s.FindUserByUsernameFn(ctx, username)
+}
+
+func (s *UserService) FindUsers(ctx context.Context, filter gofman.UserFilter) ([]*gofman.User, int, error) {
+	return s.FindUsersFn(ctx, filter)
+}
+
+func (s *UserService) CreateUser(ctx context.Context, user *gofman.User) error {
+	return s.CreateUserFn(ctx, user)
+}
+
+func (s *UserService) UpdateUser(ctx context.Context, id string, update gofman.UserUpdate) (*gofman.User, error) {
+	return s.UpdateUserFn(ctx, id, update)
+}
+
+func (s *UserService) RemoveUser(ctx context.Context, id string) error {
+	return s.RemoveUserFn(ctx, id)
+}
+
+func (s *UserService) RestoreUser(ctx context.Context, id string) error {
+	return s.RestoreUserFn(ctx, id)
+}
+
+func (s *UserService) RemoveUserWithContent(ctx context.Context, id string, dryRun bool) (*gofman.UserContent, error) {
+	return s.RemoveUserWithContentFn(ctx, id, dryRun)
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// setLinkHeader writes the Link header with the first, previous, next and
+// last page of a list. The links keep the query of the request and only
+// replace the offset and limit.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, p gofman.Pagination) {
+	if p.Limit == 0 {
+		return
+	}
+
+	var links []string
+
+	link := func(offset int, rel string) {
+		u := *r.URL
+		q := u.Query()
+		q.Set("offset", strconv.Itoa(offset))
+		q.Set("limit", strconv.Itoa(p.Limit))
+		u.RawQuery = q.Encode()
+
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel))
+	}
+
+	link(0, "first")
+
+	if p.HasPrev {
+		link(p.PrevOffset(), "prev")
+	}
+
+	if p.HasNext {
+		link(p.NextOffset(), "next")
+	}
+
+	link(p.LastOffset(), "last")
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// parsePaging reads the offset and limit query parameters of a list request.
+// Missing parameters are returned as zero.
+func parsePaging(r *http.Request) (offset, limit int, err error) {
+	q := r.URL.Query()
+
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil {
+			return 0, 0, gofman.NewError(gofman.EINVALID, "Invalid offset parameter.")
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return 0, 0, gofman.NewError(gofman.EINVALID, "Invalid limit parameter.")
+		}
+	}
+
+	return offset, limit, nil
+}
+
+// effectiveLimit returns the limit the services apply to the given limit, so
+// the pagination of a response matches the returned page.
+func effectiveLimit(limit int) int {
+	if limit <= 0 {
+		return gofman.DefaultLimit
+	} else if limit > gofman.MaxLimit {
+		return gofman.MaxLimit
+	}
+
+	return limit
+}
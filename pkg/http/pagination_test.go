@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestSetLinkHeader(t *testing.T) {
+	t.Run("MiddlePage", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/tags?offset=10&limit=10&q=a", nil)
+
+		setLinkHeader(w, r, gofman.NewPagination(10, 10, 25))
+
+		want := `</tags?limit=10&offset=0&q=a>; rel="first", ` +
+			`</tags?limit=10&offset=0&q=a>; rel="prev", ` +
+			`</tags?limit=10&offset=20&q=a>; rel="next", ` +
+			`</tags?limit=10&offset=20&q=a>; rel="last"`
+
+		if got := w.Header().Get("Link"); got != want {
+			t.Fatalf("Unexpected Link header: %s", got)
+		}
+	})
+
+	t.Run("ZeroLimit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setLinkHeader(w, httptest.NewRequest("GET", "/tags", nil), gofman.NewPagination(0, 0, 25))
+
+		if got := w.Header().Get("Link"); got != "" {
+			t.Fatalf("Unexpected Link header: %s", got)
+		}
+	})
+}
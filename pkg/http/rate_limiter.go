@@ -0,0 +1,168 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/gorilla/mux"
+)
+
+// Rate limiter constants.
+const (
+	DefaultRateLimitBurst = 20
+
+	RateLimitSweepInterval = 1 * time.Minute
+)
+
+// RateLimiter limits the requests of each client with a token bucket. Every
+// client may send Burst requests at once and gets Rate new requests per
+// second after that. It is safe for concurrent use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+
+	// Requests per second and maximum requests at once per client. A rate
+	// of zero disables the limiter. A burst below one is treated as one.
+	Rate  float64
+	Burst int
+
+	// Returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// rateBucket represents the token bucket of a single client.
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a new instance of RateLimiter.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*rateBucket),
+
+		Rate:  rate,
+		Burst: burst,
+		Now:   time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the key. If the bucket is empty,
+// false is returned together with the time until the next token is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if l.Rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: l.burst(), last: now}
+		l.buckets[key] = b
+	}
+
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return true, 0
+}
+
+// Sweep removes the buckets that refilled completely and returns the number
+// of removed buckets. A new bucket is full as well, so nothing is lost.
+func (l *RateLimiter) Sweep() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var n int
+
+	now := l.Now()
+
+	for key, b := range l.buckets {
+		if l.refill(b, now); b.tokens >= l.burst() {
+			delete(l.buckets, key)
+			n++
+		}
+	}
+
+	return n
+}
+
+// run sweeps the limiter periodically until the context is cancelled.
+func (l *RateLimiter) run(ctx context.Context) {
+	ticker := time.NewTicker(RateLimitSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.Sweep()
+		}
+	}
+}
+
+// refill adds the tokens earned since the last refill to the bucket. The lock
+// must be held.
+func (l *RateLimiter) refill(b *rateBucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * l.Rate
+		b.last = now
+	}
+
+	if burst := l.burst(); b.tokens > burst {
+		b.tokens = burst
+	}
+}
+
+// burst returns the size of a bucket.
+func (l *RateLimiter) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+
+	return float64(l.Burst)
+}
+
+// rateLimit returns middleware that limits the requests of each client with
+// the given limiter. Clients are identified by their user ID or by their
+// remote IP if they are not logged in, so it must be mounted after
+// authenticate. A nil limiter disables the middleware.
+func (s *Server) rateLimit(l *RateLimiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ip:" + s.remoteIP(r)
+			if userID := gofman.UserIDFromContext(r.Context()); userID != "" {
+				key = "user:" + userID
+			}
+
+			if ok, wait := l.Allow(key); !ok {
+				// Retry-After only supports whole seconds, round up so the
+				// client does not retry too early.
+				w.Header().Set("Retry-After", strconv.FormatInt(int64((wait+time.Second-1)/time.Second), 10))
+				Error(w, r, gofman.NewError(gofman.ETOOMANYREQUESTS, "Too many requests. Please try again later."))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
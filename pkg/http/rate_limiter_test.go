@@ -0,0 +1,168 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+
+	newLimiter := func() *gofmanhttp.RateLimiter {
+		l := gofmanhttp.NewRateLimiter(2, 3)
+		l.Now = func() time.Time { return now }
+		return l
+	}
+
+	t.Run("Burst", func(t *testing.T) {
+		l := newLimiter()
+
+		for i := 0; i < 3; i++ {
+			if ok, _ := l.Allow("jane"); !ok {
+				t.Fatalf("Unexpected limit on request %d", i)
+			}
+		}
+
+		if ok, wait := l.Allow("jane"); ok {
+			t.Fatal("Expected limit")
+		} else if wait != 500*time.Millisecond {
+			t.Fatalf("Unexpected wait: %s", wait)
+		} else if ok, _ := l.Allow("john"); !ok {
+			t.Fatal("Unexpected limit for other key")
+		}
+	})
+
+	t.Run("Refill", func(t *testing.T) {
+		l := newLimiter()
+		start := now
+		defer func() { now = start }()
+
+		for i := 0; i < 3; i++ {
+			l.Allow("jane")
+		}
+
+		now = now.Add(500 * time.Millisecond)
+
+		if ok, _ := l.Allow("jane"); !ok {
+			t.Fatal("Unexpected limit")
+		} else if ok, _ := l.Allow("jane"); ok {
+			t.Fatal("Expected limit")
+		}
+
+		// The bucket never holds more than the burst.
+		now = now.Add(time.Hour)
+
+		for i := 0; i < 3; i++ {
+			if ok, _ := l.Allow("jane"); !ok {
+				t.Fatalf("Unexpected limit on request %d", i)
+			}
+		}
+
+		if ok, _ := l.Allow("jane"); ok {
+			t.Fatal("Expected limit")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		l := newLimiter()
+		l.Rate = 0
+
+		for i := 0; i < 10; i++ {
+			if ok, _ := l.Allow("jane"); !ok {
+				t.Fatalf("Unexpected limit on request %d", i)
+			}
+		}
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		l := newLimiter()
+		start := now
+		defer func() { now = start }()
+
+		l.Allow("jane")
+		l.Allow("jane")
+		l.Allow("jane")
+		l.Allow("john")
+
+		now = now.Add(time.Second)
+
+		// Jane earned two of her three tokens back, John is full again.
+		if n := l.Sweep(); n != 1 {
+			t.Fatalf("Unexpected number of swept buckets: %d", n)
+		}
+
+		now = now.Add(time.Second)
+
+		if n := l.Sweep(); n != 1 {
+			t.Fatalf("Unexpected number of swept buckets: %d", n)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		l := gofmanhttp.NewRateLimiter(0.001, 50)
+
+		var mu sync.Mutex
+		var allowed int
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if ok, _ := l.Allow("jane"); ok {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+
+				l.Sweep()
+			}()
+		}
+		wg.Wait()
+
+		if allowed != 50 {
+			t.Fatalf("Unexpected number of allowed requests: %d", allowed)
+		}
+	})
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	s := newAuthServer()
+	s.RateLimiter.Rate = 0.5
+	s.RateLimiter.Burst = 2
+	s.ActorService = &ActorService{
+		FindActorsFn: func(ctx context.Context, filter gofman.ActorFilter) ([]*gofman.Actor, int, error) {
+			return nil, 0, nil
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := serveAuth(s, "GET", "/actors", ""); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status on request %d: %d", i, w.Code)
+		}
+	}
+
+	w := serveAuth(s, "GET", "/actors", "")
+
+	var resp gofmanhttp.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if resp.Error.Code != gofman.ETOOMANYREQUESTS {
+		t.Fatalf("Unexpected error: %#v", resp.Error)
+	} else if v, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || v < 1 || v > 2 {
+		t.Fatalf("Unexpected Retry-After header: %q", w.Header().Get("Retry-After"))
+	}
+}
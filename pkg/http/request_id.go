@@ -0,0 +1,142 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/google/uuid"
+)
+
+// Request ID formats.
+const (
+	RequestIDFormatToken = "token"
+	RequestIDFormatUUID  = "uuid"
+)
+
+// RequestIDHeader is the header used to receive and return request IDs.
+const RequestIDHeader = "X-Request-Id"
+
+// ForwardedForHeader is the header trusted proxies pass the client address in.
+const ForwardedForHeader = "X-Forwarded-For"
+
+// maxRequestIDLength is the maximum length of an inbound request ID.
+const maxRequestIDLength = 128
+
+// requestID is middleware for attaching a request ID to the context. An
+// inbound ID is reused if the client is trusted, otherwise a new one is
+// generated. The ID is returned in the response header.
+func (s *Server) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+
+		if !validRequestID(id) || !s.isTrustedProxy(r) {
+			id = s.newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		r = r.WithContext(gofman.NewContextWithRequestID(r.Context(), id))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newRequestID returns a new request ID in the configured format.
+func (s *Server) newRequestID() string {
+	if s.RequestIDFormat == RequestIDFormatUUID {
+		return uuid.NewString()
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return uuid.NewString()
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// isTrustedProxy returns true if forwarded headers of the client, like the
+// inbound request ID and X-Forwarded-For, are accepted. No client is trusted
+// if no proxies are configured.
+func (s *Server) isTrustedProxy(r *http.Request) bool {
+	return s.isTrustedIP(net.ParseIP(remoteHost(r)))
+}
+
+// isTrustedIP returns true if the address is one of the trusted proxies or
+// within one of their CIDR ranges.
+func (s *Server) isTrustedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range s.TrustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if v := net.ParseIP(proxy); v != nil && v.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteIP returns the IP address of the client without the port. Requests of
+// trusted proxies are attributed to the last address in X-Forwarded-For that
+// is not a trusted proxy itself, as clients can prepend arbitrary addresses.
+func (s *Server) remoteIP(r *http.Request) string {
+	ip := remoteHost(r)
+	if !s.isTrustedProxy(r) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values(ForwardedForHeader), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+
+		v := net.ParseIP(hop)
+		if v == nil {
+			break
+		}
+
+		ip = hop
+
+		if !s.isTrustedIP(v) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// remoteHost returns the address of the connected client without the port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// validRequestID returns true if id is short and only contains printable
+// ASCII characters so it can safely be written to logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
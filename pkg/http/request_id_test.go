@@ -0,0 +1,106 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+	"github.com/google/uuid"
+)
+
+func TestServer_RequestID(t *testing.T) {
+	t.Run("Generated", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+		s.RequestIDFormat = gofmanhttp.RequestIDFormatUUID
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/debug/version", nil))
+
+		if _, err := uuid.Parse(w.Header().Get(gofmanhttp.RequestIDHeader)); err != nil {
+			t.Fatalf("Expected UUID request ID, got %q", w.Header().Get(gofmanhttp.RequestIDHeader))
+		}
+	})
+
+	t.Run("TrustedProxy", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+		s.TrustedProxies = []string{"10.0.0.0/8"}
+
+		r := httptest.NewRequest("GET", "/debug/version", nil)
+		r.RemoteAddr = "10.1.2.3:1234"
+		r.Header.Set(gofmanhttp.RequestIDHeader, "abc")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if v := w.Header().Get(gofmanhttp.RequestIDHeader); v != "abc" {
+			t.Fatalf("Unexpected request ID: %q", v)
+		}
+	})
+
+	t.Run("UntrustedClient", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+		s.TrustedProxies = []string{"10.0.0.0/8"}
+
+		r := httptest.NewRequest("GET", "/debug/version", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set(gofmanhttp.RequestIDHeader, "abc")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if v := w.Header().Get(gofmanhttp.RequestIDHeader); v == "abc" || len(v) != 32 {
+			t.Fatalf("Unexpected request ID: %q", v)
+		}
+	})
+
+	t.Run("NoTrustedProxies", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+
+		r := httptest.NewRequest("GET", "/debug/version", nil)
+		r.RemoteAddr = "10.1.2.3:1234"
+		r.Header.Set(gofmanhttp.RequestIDHeader, "abc")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if v := w.Header().Get(gofmanhttp.RequestIDHeader); v == "abc" || len(v) != 32 {
+			t.Fatalf("Unexpected request ID: %q", v)
+		}
+	})
+
+	t.Run("InvalidHeader", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+		s.TrustedProxies = []string{"192.0.2.1"}
+
+		r := httptest.NewRequest("GET", "/debug/version", nil)
+		r.Header.Set(gofmanhttp.RequestIDHeader, "abc\tdef")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if v := w.Header().Get(gofmanhttp.RequestIDHeader); v == "abc\tdef" || v == "" {
+			t.Fatalf("Unexpected request ID: %q", v)
+		}
+	})
+
+	t.Run("ErrorResponse", func(t *testing.T) {
+		s := gofmanhttp.NewServer()
+		s.TrustedProxies = []string{"192.0.2.1"}
+
+		r := httptest.NewRequest("GET", "/missing", nil)
+		r.Header.Set(gofmanhttp.RequestIDHeader, "abc")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Error.RequestID != "abc" {
+			t.Fatalf("Unexpected request ID: %q", resp.Error.RequestID)
+		}
+	})
+}
@@ -1,11 +1,144 @@
 package http
 
 import (
+	"net/http"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
 	"github.com/gorilla/mux"
 )
 
+// Session constants.
+const (
+	DefaultSessionTTL  = 24 * time.Hour
+	DefaultRememberTTL = 30 * 24 * time.Hour
+)
+
 // registerSessionRoutes is a helper function for registering all session
 // routes.
 func (s *Server) registerSessionRoutes(r *mux.Router) {
-	// TODO
+	r.HandleFunc("/sessions", s.handleSessionIndex).Methods("GET")
+	r.HandleFunc("/sessions/{id}", s.handleSessionDelete).Methods("DELETE")
+}
+
+// SessionsResponse represents the JSON structure returned by GET /sessions.
+// All sessions of the user fit on a single page.
+type SessionsResponse struct {
+	ListResponse
+
+	// ID of the session the request was made with.
+	CurrentID string `json:"current_id"`
+}
+
+// handleSessionIndex lists the live sessions of the current user, newest
+// first. Tokens are never included.
+func (s *Server) handleSessionIndex(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.SessionService.FindSessionsForUser(r.Context(), gofman.UserIDFromContext(r.Context()))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	redacted := make([]*gofman.Session, len(sessions))
+	for i, session := range sessions {
+		redacted[i] = session.Redacted()
+	}
+
+	resp := &SessionsResponse{
+		ListResponse: ListResponse{
+			Data:  redacted,
+			Total: len(redacted),
+			Limit: gofman.MaxSessionLimit,
+		},
+	}
+
+	if session := gofman.SessionFromContext(r.Context()); session != nil {
+		resp.CurrentID = session.ID
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSessionDelete revokes a session of the current user, logging out the
+// device it belongs to. Revoking the current session also clears the session
+// cookies.
+func (s *Server) handleSessionDelete(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := s.SessionService.DeleteSession(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if session := gofman.SessionFromContext(r.Context()); session != nil && session.ID == id {
+		s.clearSessionCookies(w)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionTTL returns the lifetime of a new session. Sessions of users who
+// ask to be remembered live for RememberTTL, all others for SessionTTL.
+func (s *Server) sessionTTL(remember bool) time.Duration {
+	if remember {
+		return s.RememberTTL
+	}
+
+	return s.SessionTTL
+}
+
+// newSession returns a new session of the user for the given request. The
+// lifetime depends on remember and the client is recorded so users can tell
+// their sessions apart. Overly long user agents are truncated.
+func (s *Server) newSession(r *http.Request, userID, token string, remember bool) *gofman.Session {
+	userAgent := r.UserAgent()
+	if len(userAgent) > gofman.MaxUserAgentLen {
+		userAgent = userAgent[:gofman.MaxUserAgentLen]
+	}
+
+	return &gofman.Session{
+		UserID:    userID,
+		Token:     token,
+		TTL:       int64(s.sessionTTL(remember) / time.Second),
+		UserAgent: userAgent,
+		IP:        s.remoteIP(r),
+	}
+}
+
+// setSessionCookies writes the Session and Token cookies of a new session.
+// Remembered sessions get cookies that expire together with the session.
+// All other cookies have no Max-Age, so the browser drops them when it is
+// closed.
+func (s *Server) setSessionCookies(w http.ResponseWriter, session *gofman.Session, remember bool, now int64) {
+	var maxAge int
+	if remember {
+		maxAge = int(session.ExpiresAt - now)
+	}
+
+	for _, cookie := range []*http.Cookie{
+		{Name: "Session", Value: session.ID},
+		{Name: "Token", Value: session.Token},
+	} {
+		cookie.Path = "/"
+		cookie.MaxAge = maxAge
+		cookie.HttpOnly = true
+		cookie.Secure = s.SecureCookies
+		cookie.SameSite = http.SameSiteLaxMode
+
+		http.SetCookie(w, cookie)
+	}
+}
+
+// clearSessionCookies expires the Session and Token cookies.
+func (s *Server) clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{"Session", "Token"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   s.SecureCookies,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
 }
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestServer_SetSessionCookies(t *testing.T) {
+	s := NewServer()
+
+	for _, tt := range []struct {
+		name     string
+		remember bool
+		maxAge   int
+	}{
+		{name: "BrowserSession", remember: false, maxAge: 0},
+		{name: "Remember", remember: true, maxAge: int(DefaultRememberTTL / time.Second)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var now int64 = 1000000000
+
+			session := &gofman.Session{
+				ID:        "1",
+				Token:     "token",
+				ExpiresAt: now + int64(s.sessionTTL(tt.remember)/time.Second),
+			}
+
+			w := httptest.NewRecorder()
+			s.setSessionCookies(w, session, tt.remember, now)
+
+			cookies := w.Result().Cookies()
+			if len(cookies) != 2 {
+				t.Fatalf("Unexpected cookies: %d", len(cookies))
+			}
+
+			for _, cookie := range cookies {
+				if cookie.MaxAge != tt.maxAge {
+					t.Fatalf("Unexpected Max-Age of %s: %d", cookie.Name, cookie.MaxAge)
+				} else if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+					t.Fatalf("Unexpected attributes of %s: %#v", cookie.Name, cookie)
+				}
+			}
+
+			for _, v := range w.Header()["Set-Cookie"] {
+				if !strings.Contains(v, "; Path=/") || !strings.HasSuffix(v, "; HttpOnly; Secure; SameSite=Lax") {
+					t.Fatalf("Unexpected Set-Cookie header: %q", v)
+				}
+			}
+
+			if cookies[0].Name != "Session" || cookies[0].Value != "1" {
+				t.Fatalf("Unexpected session cookie: %#v", cookies[0])
+			} else if cookies[1].Name != "Token" || cookies[1].Value != "token" {
+				t.Fatalf("Unexpected token cookie: %#v", cookies[1])
+			}
+		})
+	}
+}
+
+func TestServer_NewSession(t *testing.T) {
+	s := NewServer()
+
+	r := httptest.NewRequest("POST", "/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", strings.Repeat("a", gofman.MaxUserAgentLen+1))
+
+	session := s.newSession(r, "1", "token", true)
+
+	if session.UserID != "1" || session.Token != "token" {
+		t.Fatalf("Unexpected session: %#v", session)
+	} else if session.TTL != int64(DefaultRememberTTL/time.Second) {
+		t.Fatalf("Unexpected TTL: %d", session.TTL)
+	} else if session.IP != "192.0.2.1" {
+		t.Fatalf("Unexpected IP: %q", session.IP)
+	} else if len(session.UserAgent) != gofman.MaxUserAgentLen {
+		t.Fatalf("Unexpected user agent length: %d", len(session.UserAgent))
+	}
+
+	if session := s.newSession(r, "1", "token", false); session.TTL != int64(DefaultSessionTTL/time.Second) {
+		t.Fatalf("Unexpected TTL: %d", session.TTL)
+	}
+}
+
+func TestServer_SetSessionCookies_Insecure(t *testing.T) {
+	s := NewServer()
+	s.SecureCookies = false
+
+	w := httptest.NewRecorder()
+	s.setSessionCookies(w, &gofman.Session{ID: "1", Token: "token"}, false, 0)
+
+	for _, v := range w.Header()["Set-Cookie"] {
+		if strings.Contains(v, "Secure") || !strings.HasSuffix(v, "; HttpOnly; SameSite=Lax") {
+			t.Fatalf("Unexpected Set-Cookie header: %q", v)
+		}
+	}
+}
+
+func TestServer_ClearSessionCookies(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewServer().clearSessionCookies(w)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "Session" || cookies[1].Name != "Token" {
+		t.Fatalf("Unexpected cookies: %v", cookies)
+	}
+
+	for _, cookie := range cookies {
+		if cookie.MaxAge != -1 || cookie.Path != "/" || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+			t.Fatalf("Unexpected cookie: %v", cookie)
+		}
+	}
+}
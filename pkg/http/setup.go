@@ -1,10 +1,75 @@
 package http
 
 import (
+	"net/http"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
 	"github.com/gorilla/mux"
 )
 
 // registerSetupRoutes is a helper function for registering all setup routes.
 func (s *Server) registerSetupRoutes(r *mux.Router) {
-	// TODO
+	r.HandleFunc("/setup", s.handleSetupView).Methods("GET")
+	r.HandleFunc("/setup", s.handleSetupCreate).Methods("POST")
+}
+
+// SetupRequest represents the JSON structure accepted by POST /setup.
+type SetupRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SetupResponse represents the JSON structure returned by GET /setup.
+type SetupResponse struct {
+	SetupRequired bool `json:"setup_required"`
+}
+
+// checkSetup writes a not found response and returns false once the setup
+// was run, so the setup routes disappear as soon as the first user exists.
+func (s *Server) checkSetup(w http.ResponseWriter, r *http.Request) bool {
+	ok, err := s.SetupService.ShouldRunSetup(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return false
+	} else if !ok {
+		s.handleNotFound(w, r)
+		return false
+	}
+
+	return true
+}
+
+// handleSetupView reports that the setup still has to be run.
+func (s *Server) handleSetupView(w http.ResponseWriter, r *http.Request) {
+	if !s.checkSetup(w, r) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &SetupResponse{SetupRequired: true})
+}
+
+// handleSetupCreate creates the first user as an admin. The setup service
+// rejects the request if another request ran the setup in the meantime.
+func (s *Server) handleSetupCreate(w http.ResponseWriter, r *http.Request) {
+	if !s.checkSetup(w, r) {
+		return
+	}
+
+	var req SetupRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	user := &gofman.User{
+		Username: req.Username,
+		Password: req.Password,
+	}
+
+	if err := s.SetupService.RunSetup(r.Context(), user); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	created(w, r, "/users/"+user.ID, user.Redacted())
 }
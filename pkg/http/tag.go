@@ -1,10 +1,121 @@
 package http
 
 import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
 	"github.com/gorilla/mux"
 )
 
 // registerTagRoutes is a helper function for registering all tag routes.
 func (s *Server) registerTagRoutes(r *mux.Router) {
-	// TODO
+	r.HandleFunc("/tags", s.handleTagIndex).Methods("GET")
+	r.HandleFunc("/tags", s.handleTagCreate).Methods("POST")
+	r.HandleFunc("/tags/{id}", s.handleTagView).Methods("GET")
+	r.HandleFunc("/tags/{id}", s.handleTagUpdate).Methods("PATCH")
+	r.HandleFunc("/tags/{id}", s.handleTagDelete).Methods("DELETE")
+}
+
+// handleTagIndex lists the tags of the current user. The list can be
+// narrowed down with the name_like, sort_by, sort_desc, offset and limit
+// query parameters.
+func (s *Server) handleTagIndex(w http.ResponseWriter, r *http.Request) {
+	userID := gofman.UserIDFromContext(r.Context())
+	filter := gofman.TagFilter{UserID: &userID}
+
+	q := r.URL.Query()
+
+	if v := q.Get("name_like"); v != "" {
+		filter.NameLike = &v
+	}
+
+	filter.SortBy = q.Get("sort_by")
+
+	if v := q.Get("sort_desc"); v != "" {
+		var err error
+		if filter.SortDesc, err = strconv.ParseBool(v); err != nil {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "Invalid sort_desc parameter."))
+			return
+		}
+	}
+
+	var err error
+	if filter.Offset, filter.Limit, err = parsePaging(r); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	tags, n, err := s.TagService.FindTags(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if tags == nil {
+		tags = []*gofman.Tag{}
+	}
+
+	writeList(w, r, tags, filter.Offset, effectiveLimit(filter.Limit), n)
+}
+
+// handleTagView displays a single tag.
+func (s *Server) handleTagView(w http.ResponseWriter, r *http.Request) {
+	tag, err := s.TagService.FindTagByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+// handleTagCreate creates an tag from a JSON encoded Tag. The tag
+// always belongs to the current user, IDs and timestamps of the body are
+// ignored.
+func (s *Server) handleTagCreate(w http.ResponseWriter, r *http.Request) {
+	var body gofman.Tag
+	if err := decodeJSON(w, r, &body); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	tag := &gofman.Tag{
+		UserID: gofman.UserIDFromContext(r.Context()),
+		Name:   body.Name,
+	}
+
+	if err := s.TagService.CreateTag(r.Context(), tag); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	created(w, r, "/tags/"+tag.ID, tag)
+}
+
+// handleTagUpdate updates an tag from a JSON encoded TagUpdate.
+func (s *Server) handleTagUpdate(w http.ResponseWriter, r *http.Request) {
+	var update gofman.TagUpdate
+	if err := decodeJSON(w, r, &update); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	tag, err := s.TagService.UpdateTag(r.Context(), mux.Vars(r)["id"], update)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+// handleTagDelete moves an tag to the trash.
+func (s *Server) handleTagDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.TagService.RemoveTag(r.Context(), mux.Vars(r)["id"]); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
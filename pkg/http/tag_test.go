@@ -0,0 +1,193 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_TagIndex(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.TagFilter
+		s := newAuthServer()
+		s.TagService = &TagService{
+			FindTagsFn: func(ctx context.Context, filter gofman.TagFilter) ([]*gofman.Tag, int, error) {
+				got = filter
+				return []*gofman.Tag{{ID: "1", UserID: "2", Name: "summer"}}, 11, nil
+			},
+		}
+
+		w := serveAuth(s, "GET", "/tags?name_like=su&sort_by=name&sort_desc=true&offset=5&limit=5", "")
+
+		var tags []*gofman.Tag
+		resp := gofmanhttp.ListResponse{Data: &tags}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.UserID == nil || *got.UserID != "2" {
+			t.Fatalf("Unexpected user ID: %#v", got.UserID)
+		} else if got.NameLike == nil || *got.NameLike != "su" || got.SortBy != "name" || !got.SortDesc {
+			t.Fatalf("Unexpected filter: %#v", got)
+		} else if got.Offset != 5 || got.Limit != 5 {
+			t.Fatalf("Unexpected paging: %d, %d", got.Offset, got.Limit)
+		} else if len(tags) != 1 || tags[0].ID != "1" {
+			t.Fatalf("Unexpected tags: %#v", tags)
+		} else if resp.Total != 11 || resp.Limit != 5 || resp.Offset != 5 {
+			t.Fatalf("Unexpected envelope: %#v", resp)
+		} else if w.Header().Get("Link") == "" {
+			t.Fatal("Expected Link header")
+		}
+	})
+
+	t.Run("ErrInvalidLimit", func(t *testing.T) {
+		s := newAuthServer()
+
+		if w := serveAuth(s, "GET", "/tags?limit=x", ""); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_TagView(t *testing.T) {
+	s := newAuthServer()
+	s.TagService = &TagService{
+		FindTagByIDFn: func(ctx context.Context, id string) (*gofman.Tag, error) {
+			if id != "1" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "Tag not found.")
+			}
+
+			return &gofman.Tag{ID: "1", UserID: "2", Name: "summer"}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := serveAuth(s, "GET", "/tags/1", "")
+
+		var tag gofman.Tag
+		if err := json.NewDecoder(w.Body).Decode(&tag); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if tag.ID != "1" || tag.Name != "summer" {
+			t.Fatalf("Unexpected tag: %#v", tag)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		w := serveAuth(s, "GET", "/tags/2", "")
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.Error.Code != gofman.ENOTFOUND || resp.Error.Message != "Tag not found." {
+			t.Fatalf("Unexpected error: %#v", resp.Error)
+		}
+	})
+}
+
+func TestServer_TagCreate(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var got gofman.Tag
+		s := newAuthServer()
+		s.TagService = &TagService{
+			CreateTagFn: func(ctx context.Context, tag *gofman.Tag) error {
+				got = *tag
+				tag.ID = "1"
+				return nil
+			},
+		}
+
+		w := serveAuth(s, "POST", "/tags", `{"name":"summer","users_id":"3","id":"4"}`)
+
+		var tag gofman.Tag
+		if err := json.NewDecoder(w.Body).Decode(&tag); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.UserID != "2" || got.Name != "summer" || got.ID != "" {
+			t.Fatalf("Unexpected tag: %#v", got)
+		} else if v := w.Header().Get("Location"); v != "/tags/1" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		} else if tag.ID != "1" {
+			t.Fatalf("Unexpected tag: %#v", tag)
+		}
+	})
+
+	t.Run("ErrInvalidJSON", func(t *testing.T) {
+		s := newAuthServer()
+
+		if w := serveAuth(s, "POST", "/tags", `{`); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_TagUpdate(t *testing.T) {
+	var got gofman.TagUpdate
+	s := newAuthServer()
+	s.TagService = &TagService{
+		UpdateTagFn: func(ctx context.Context, id string, update gofman.TagUpdate) (*gofman.Tag, error) {
+			if id != "1" {
+				return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to update this tag.")
+			}
+
+			got = update
+			return &gofman.Tag{ID: id, UserID: "2", Name: *update.Name}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		w := serveAuth(s, "PATCH", "/tags/1", `{"name":"winter"}`)
+
+		var tag gofman.Tag
+		if err := json.NewDecoder(w.Body).Decode(&tag); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.Name == nil || *got.Name != "winter" {
+			t.Fatalf("Unexpected update: %#v", got)
+		} else if tag.Name != "winter" {
+			t.Fatalf("Unexpected tag: %#v", tag)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if w := serveAuth(s, "PATCH", "/tags/2", `{"name":"winter"}`); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_TagDelete(t *testing.T) {
+	var removed []string
+	s := newAuthServer()
+	s.TagService = &TagService{
+		RemoveTagFn: func(ctx context.Context, id string) error {
+			removed = append(removed, id)
+			return nil
+		},
+	}
+
+	if w := serveAuth(s, "DELETE", "/tags/1", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if len(removed) != 1 || removed[0] != "1" {
+		t.Fatalf("Unexpected removed tags: %v", removed)
+	}
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+)
+
+// TLS constants.
+const (
+	SelfSignedValidity = 365 * 24 * time.Hour
+)
+
+// TLS returns true if the server is configured to serve HTTPS.
+func (s *Server) TLS() bool {
+	return s.SelfSigned || s.CertFile != "" || s.KeyFile != ""
+}
+
+// tlsConfig returns the TLS configuration of the server. The certificate is
+// loaded from CertFile and KeyFile or generated if SelfSigned is set.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+
+	switch {
+	case s.CertFile != "" || s.KeyFile != "":
+		if s.CertFile == "" || s.KeyFile == "" {
+			return nil, errors.New("http: cert file and key file must be set together")
+		}
+
+		cert, err = tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	case s.SelfSigned:
+		cert, err = selfSignedCertificate(s.Address, time.Now())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// selfSignedCertificate generates a certificate for localhost and the given
+// host that is valid from now on. Browsers do not trust it, it is only meant
+// for local development.
+func selfSignedCertificate(host string, now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"gofman"}},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(SelfSignedValidity),
+
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !ip.IsLoopback() && !ip.IsUnspecified() {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		}
+	} else if host != "" && host != "localhost" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
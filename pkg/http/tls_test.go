@@ -0,0 +1,137 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServer_OpenTLS(t *testing.T) {
+	// get requests the version over HTTPS and only trusts the given
+	// certificate.
+	get := func(t *testing.T, s *Server, cert tls.Certificate) {
+		t.Helper()
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pool := x509.NewCertPool()
+		pool.AddCert(leaf)
+
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		}
+
+		resp, err := client.Get("https://" + s.ln.Addr().String() + "/debug/version")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.TLS == nil || !resp.TLS.HandshakeComplete {
+			t.Fatal("Expected completed TLS handshake")
+		} else if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		}
+	}
+
+	t.Run("SelfSigned", func(t *testing.T) {
+		s := NewServer()
+		s.Address = "127.0.0.1"
+		s.SelfSigned = true
+
+		if err := s.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		get(t, s, s.server.TLSConfig.Certificates[0])
+	})
+
+	t.Run("CertFile", func(t *testing.T) {
+		cert, err := selfSignedCertificate("127.0.0.1", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir := t.TempDir()
+
+		s := NewServer()
+		s.Address = "127.0.0.1"
+		s.CertFile = filepath.Join(dir, "cert.pem")
+		s.KeyFile = filepath.Join(dir, "key.pem")
+
+		if err := ioutil.WriteFile(s.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+			t.Fatal(err)
+		} else if err := ioutil.WriteFile(s.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		get(t, s, cert)
+	})
+
+	t.Run("ErrMissingKeyFile", func(t *testing.T) {
+		s := NewServer()
+		s.Address = "127.0.0.1"
+		s.CertFile = "cert.pem"
+
+		if err := s.Open(); err == nil {
+			s.Close()
+			t.Fatal("Expected error")
+		}
+	})
+
+	t.Run("ErrCertFileNotFound", func(t *testing.T) {
+		s := NewServer()
+		s.Address = "127.0.0.1"
+		s.CertFile = filepath.Join(t.TempDir(), "cert.pem")
+		s.KeyFile = filepath.Join(t.TempDir(), "key.pem")
+
+		if err := s.Open(); err == nil {
+			s.Close()
+			t.Fatal("Expected error")
+		}
+	})
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+
+	cert, err := selfSignedCertificate("files.example.com", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := leaf.VerifyHostname("files.example.com"); err != nil {
+		t.Fatal(err)
+	} else if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Fatal(err)
+	} else if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	} else if !leaf.NotAfter.Equal(now.Add(SelfSignedValidity)) {
+		t.Fatalf("Unexpected expiry: %s", leaf.NotAfter)
+	}
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/gorilla/mux"
+)
+
+// registerTrashRoutes is a helper function for registering all trash routes.
+func (s *Server) registerTrashRoutes(r *mux.Router) {
+	r.HandleFunc("/trash/empty", s.handleTrashEmpty).Methods("POST")
+}
+
+// handleTrashEmpty permanently removes the removed content of the current
+// user, including uploaded files on disk. Nothing is removed if the dry_run
+// query parameter is set.
+func (s *Server) handleTrashEmpty(w http.ResponseWriter, r *http.Request) {
+	var dryRun bool
+
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "Invalid dry_run parameter."))
+			return
+		}
+	}
+
+	result, err := s.TrashService.EmptyTrash(r.Context(), dryRun)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,60 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_TrashEmpty(t *testing.T) {
+	var dryRuns []bool
+
+	s := gofmanhttp.NewServer()
+	s.SessionService = &SessionService{
+		FindSessionForTokenFn: func(ctx context.Context, id string, token string) (*gofman.Session, error) {
+			return &gofman.Session{ID: id, UserID: "2", Token: token}, nil
+		},
+	}
+	s.UserService = &UserService{
+		FindUserByIDFn: func(ctx context.Context, id string) (*gofman.User, error) {
+			return &gofman.User{ID: id, Username: "jane"}, nil
+		},
+	}
+	s.TrashService = &TrashService{
+		EmptyTrashFn: func(ctx context.Context, dryRun bool) (*gofman.TrashResult, error) {
+			dryRuns = append(dryRuns, dryRun)
+			return &gofman.TrashResult{Files: 1, Folders: 4, Actors: 2, Tags: 3, DiskFiles: 1}, nil
+		},
+	}
+
+	for _, path := range []string{"/trash/empty", "/trash/empty?dry_run=true"} {
+		r := httptest.NewRequest("POST", path, nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: "csrf"})
+		r.Header.Set(gofmanhttp.CSRFHeader, "csrf")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		var result gofman.TrashResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if result != (gofman.TrashResult{Files: 1, Folders: 4, Actors: 2, Tags: 3, DiskFiles: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+	}
+
+	if len(dryRuns) != 2 || dryRuns[0] || !dryRuns[1] {
+		t.Fatalf("Unexpected dry runs: %v", dryRuns)
+	}
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Upload constants.
+const (
+	DefaultMaxUploadSize = 1 << 30
+
+	// maxUploadOverhead is the room for multipart headers and form fields on
+	// top of the maximum file size.
+	maxUploadOverhead = 1 << 20
+
+	// maxUploadFieldSize is the maximum size of a form field besides the file.
+	maxUploadFieldSize = 1 << 16
+)
+
+// handleFileUpload stores the file of a multipart/form-data body below the
+// storage root and creates it for the current user. The file is expected in
+// the "file" field, the optional "folder_id" and "description" fields must
+// precede it. The checksum, size and type are computed while the file is
+// written, so it is read only once.
+func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) {
+	if s.StorageRoot == "" {
+		Error(w, r, gofman.NewError(gofman.ENOTIMPLEMENTED, "Uploads are not configured."))
+		return
+	}
+
+	// Users that cannot create files are rejected before anything is written
+	// to disk.
+	if gofman.CanUpload(r.Context()) == false {
+		Error(w, r, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to upload files."))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadSize+maxUploadOverhead)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		Error(w, r, gofman.NewWrappedError(gofman.EINVALID, err, "Request body must be multipart/form-data."))
+		return
+	}
+
+	file := &gofman.File{UserID: gofman.UserIDFromContext(r.Context())}
+
+	var part *multipart.Part
+	for part == nil {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "File required."))
+			return
+		} else if isBodyTooLarge(err) {
+			Error(w, r, s.uploadError(err))
+			return
+		} else if err != nil {
+			Error(w, r, gofman.NewWrappedError(gofman.EINVALID, err, "Invalid multipart body."))
+			return
+		}
+
+		switch p.FormName() {
+		case "file":
+			part = p
+		case "folder_id":
+			v, err := readUploadField(p)
+			if err != nil {
+				Error(w, r, s.uploadError(err))
+				return
+			}
+
+			file.FolderID = &v
+		case "description":
+			if file.Description, err = readUploadField(p); err != nil {
+				Error(w, r, s.uploadError(err))
+				return
+			}
+		}
+	}
+
+	file.Name = filepath.Base(part.FileName())
+	if file.Name == "." || file.Name == string(filepath.Separator) {
+		Error(w, r, gofman.NewError(gofman.EINVALID, "File name required."))
+		return
+	}
+
+	if err := s.writeUpload(file, part); err != nil {
+		Error(w, r, s.uploadError(err))
+		return
+	}
+
+	if err := s.FileService.CreateFile(r.Context(), file); err != nil {
+		os.Remove(file.Path)
+		Error(w, r, err)
+		return
+	}
+
+	created(w, r, "/files/"+file.ID, file)
+}
+
+// writeUpload writes the content to a new file in the directory of the user
+// below the storage root and sets the path, type, checksum and size of the
+// file. The written file is removed again on error.
+func (s *Server) writeUpload(file *gofman.File, content io.Reader) error {
+	root, err := s.PathTraversalService.Expand(s.StorageRoot)
+	if err != nil {
+		return err
+	}
+
+	// Relative roots are resolved against the working directory, the path
+	// is stored absolute.
+	if root, err = filepath.Abs(root); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(root, file.UserID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	name, err := uploadName(filepath.Ext(file.Name))
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, name)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := copyUpload(file, f, content, s.MaxUploadSize); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	file.Path = path
+
+	return nil
+}
+
+// copyUpload copies at most max bytes of the content to dst and sets the
+// type, checksum and size of the file. The type is derived from the
+// extension and falls back to sniffing the first bytes of the content.
+func copyUpload(file *gofman.File, dst io.Writer, content io.Reader, max int64) error {
+	head := make([]byte, 512)
+
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	head = head[:n]
+
+	hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(dst, hash), io.LimitReader(io.MultiReader(bytes.NewReader(head), content), max+1))
+	if err != nil {
+		return err
+	} else if size > max {
+		return gofman.NewError(gofman.EINVALID, "File must not exceed %d bytes.", max)
+	}
+
+	file.Type = mime.TypeByExtension(filepath.Ext(file.Name))
+	if file.Type == "" {
+		file.Type = http.DetectContentType(head)
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(file.Type); err == nil {
+		file.Type = mediaType
+	}
+
+	file.Checksum = hex.EncodeToString(hash.Sum(nil))
+	file.ChecksumAlgo = gofman.ChecksumSHA256
+	file.Size = size
+
+	return nil
+}
+
+// uploadError translates errors of reading the multipart body into
+// EINVALID. All other errors are returned unchanged.
+func (s *Server) uploadError(err error) error {
+	switch {
+	case isBodyTooLarge(err):
+		return gofman.NewWrappedError(gofman.EINVALID, err, "File must not exceed %d bytes.", s.MaxUploadSize)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Invalid multipart body, unexpected end of body.")
+	default:
+		return err
+	}
+}
+
+// readUploadField returns the value of a form field besides the file.
+func readUploadField(part *multipart.Part) (string, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(part, maxUploadFieldSize+1))
+	if err != nil {
+		return "", err
+	} else if len(b) > maxUploadFieldSize {
+		return "", gofman.NewError(gofman.EINVALID, "Field %q must not exceed %d bytes.", part.FormName(), maxUploadFieldSize)
+	}
+
+	return string(b), nil
+}
+
+// uploadName returns a random file name with the given extension. Uploaded
+// files are not stored under their own name, so names cannot collide and
+// cannot escape the directory of the user.
+func uploadName(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b) + ext, nil
+}
@@ -1,10 +1,143 @@
 package http
 
 import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
 	"github.com/gorilla/mux"
 )
 
 // registerUserRoutes is a helper function for registering all user routes.
 func (s *Server) registerUserRoutes(r *mux.Router) {
-	// TODO
+	r.HandleFunc("/account", s.handleAccount).Methods("GET")
+	r.HandleFunc("/users", s.handleUserIndex).Methods("GET")
+	r.HandleFunc("/users", s.handleUserCreate).Methods("POST")
+	r.HandleFunc("/users/{id}", s.handleUserView).Methods("GET")
+	r.HandleFunc("/users/{id}", s.handleUserUpdate).Methods("PATCH")
+	r.HandleFunc("/users/{id}", s.handleUserDelete).Methods("DELETE")
+}
+
+// AccountResponse represents the JSON structure returned by GET /account.
+type AccountResponse struct {
+	User    *gofman.User    `json:"user"`
+	Session *gofman.Session `json:"session"`
+}
+
+// handleAccount displays the current user and session. Both are already
+// loaded by the authenticate middleware.
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	user := gofman.UserFromContext(r.Context())
+	session := gofman.SessionFromContext(r.Context())
+
+	writeJSON(w, http.StatusOK, &AccountResponse{
+		User:    user.Redacted(),
+		Session: session.Redacted(),
+	})
+}
+
+// handleUserUpdate updates a user from a JSON encoded UserUpdate. Setting
+// revoke_sessions or changing the role logs the user out everywhere.
+func (s *Server) handleUserUpdate(w http.ResponseWriter, r *http.Request) {
+	var update gofman.UserUpdate
+	if err := decodeJSON(w, r, &update); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	user, err := s.UserService.UpdateUser(r.Context(), mux.Vars(r)["id"], update)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user.Redacted())
+}
+
+// handleUserIndex lists all users. Only admins may list users, which the
+// user service enforces. The list can be narrowed down with the name_like,
+// sort_by, sort_desc, offset and limit query parameters.
+func (s *Server) handleUserIndex(w http.ResponseWriter, r *http.Request) {
+	var filter gofman.UserFilter
+
+	q := r.URL.Query()
+
+	if v := q.Get("name_like"); v != "" {
+		filter.NameLike = &v
+	}
+
+	filter.SortBy = q.Get("sort_by")
+
+	if v := q.Get("sort_desc"); v != "" {
+		var err error
+		if filter.SortDesc, err = strconv.ParseBool(v); err != nil {
+			Error(w, r, gofman.NewError(gofman.EINVALID, "Invalid sort_desc parameter."))
+			return
+		}
+	}
+
+	var err error
+	if filter.Offset, filter.Limit, err = parsePaging(r); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	users, n, err := s.UserService.FindUsers(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	redacted := make([]*gofman.User, len(users))
+	for i, user := range users {
+		redacted[i] = user.Redacted()
+	}
+
+	writeList(w, r, redacted, filter.Offset, effectiveLimit(filter.Limit), n)
+}
+
+// handleUserView displays a single user. Users can view themselves, admins
+// can view everyone.
+func (s *Server) handleUserView(w http.ResponseWriter, r *http.Request) {
+	user, err := s.UserService.FindUserByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user.Redacted())
+}
+
+// handleUserCreate creates a user from a JSON encoded User. Only the
+// username, password and role of the body are used. The password is hashed
+// by the user service and never sent back.
+func (s *Server) handleUserCreate(w http.ResponseWriter, r *http.Request) {
+	var body gofman.User
+	if err := decodeJSON(w, r, &body); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	user := &gofman.User{
+		Username: body.Username,
+		Password: body.Password,
+		Role:     body.Role,
+	}
+
+	if err := s.UserService.CreateUser(r.Context(), user); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	created(w, r, "/users/"+user.ID, user.Redacted())
+}
+
+// handleUserDelete removes a user. Their content is kept.
+func (s *Server) handleUserDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.UserService.RemoveUser(r.Context(), mux.Vars(r)["id"]); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
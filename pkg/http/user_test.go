@@ -0,0 +1,235 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	gofmanhttp "github.com/dhenkes/gofman/pkg/http"
+)
+
+func TestServer_Account(t *testing.T) {
+	s := gofmanhttp.NewServer()
+	s.SessionService = &SessionService{
+		FindSessionForTokenFn: func(ctx context.Context, id string, token string) (*gofman.Session, error) {
+			if id != "1" || token != "token" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "Session not found.")
+			}
+
+			return &gofman.Session{ID: "1", UserID: "2", Token: "token"}, nil
+		},
+	}
+	s.UserService = &UserService{
+		FindUserByIDFn: func(ctx context.Context, id string) (*gofman.User, error) {
+			return &gofman.User{ID: id, Username: "jane", Password: "hash", IsAdmin: true}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/account", nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		var resp gofmanhttp.AccountResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.User.ID != "2" || resp.User.Username != "jane" || !resp.User.IsAdmin {
+			t.Fatalf("Unexpected user: %#v", resp.User)
+		} else if resp.User.Password != "" {
+			t.Fatal("Expected password to be redacted.")
+		} else if resp.Session.ID != "1" || resp.Session.Token != "" {
+			t.Fatalf("Unexpected session: %#v", resp.Session)
+		}
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/account", nil))
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if v := w.Header().Get("Location"); v != "/login" {
+			t.Fatalf("Unexpected Location header: %q", v)
+		}
+	})
+}
+
+func TestServer_UserUpdate(t *testing.T) {
+	s := gofmanhttp.NewServer()
+	s.SessionService = &SessionService{
+		FindSessionForTokenFn: func(ctx context.Context, id string, token string) (*gofman.Session, error) {
+			return &gofman.Session{ID: id, UserID: "1", Token: token}, nil
+		},
+	}
+
+	var got gofman.UserUpdate
+	s.UserService = &UserService{
+		FindUserByIDFn: func(ctx context.Context, id string) (*gofman.User, error) {
+			return &gofman.User{ID: id, Username: "jane", IsAdmin: true}, nil
+		},
+		UpdateUserFn: func(ctx context.Context, id string, update gofman.UserUpdate) (*gofman.User, error) {
+			if id != "2" {
+				return nil, gofman.NewError(gofman.ENOTFOUND, "User not found.")
+			}
+
+			got = update
+			return &gofman.User{ID: id, Username: "john", Password: "hash"}, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		r := httptest.NewRequest("PATCH", "/users/2", strings.NewReader(`{"is_admin":false,"revoke_sessions":true}`))
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: "csrf"})
+		r.Header.Set(gofmanhttp.CSRFHeader, "csrf")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		var user gofman.User
+		if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if got.IsAdmin == nil || *got.IsAdmin || !got.RevokeSessions {
+			t.Fatalf("Unexpected update: %#v", got)
+		} else if user.ID != "2" || user.Password != "" {
+			t.Fatalf("Unexpected user: %#v", user)
+		}
+	})
+
+	t.Run("ErrInvalidJSON", func(t *testing.T) {
+		r := httptest.NewRequest("PATCH", "/users/2", strings.NewReader(`{`))
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		r.AddCookie(&http.Cookie{Name: gofmanhttp.CSRFCookie, Value: "csrf"})
+		r.Header.Set(gofmanhttp.CSRFHeader, "csrf")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		}
+	})
+}
+
+func TestServer_UserIndex(t *testing.T) {
+	s := gofmanhttp.NewServer()
+	s.SessionService = &SessionService{
+		FindSessionForTokenFn: func(ctx context.Context, id string, token string) (*gofman.Session, error) {
+			return &gofman.Session{ID: id, UserID: id, Token: token}, nil
+		},
+	}
+	s.UserService = &UserService{
+		FindUserByIDFn: func(ctx context.Context, id string) (*gofman.User, error) {
+			return &gofman.User{ID: id, Username: "jane", IsAdmin: id == "1"}, nil
+		},
+		FindUsersFn: func(ctx context.Context, filter gofman.UserFilter) ([]*gofman.User, int, error) {
+			if !gofman.CanFindUser(ctx, filter) {
+				return nil, 0, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to search using this filter.")
+			}
+
+			return []*gofman.User{{ID: "1", Username: "jane", Password: "hash"}}, 1, nil
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/users", nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "1"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		var users []*gofman.User
+		resp := gofmanhttp.ListResponse{Data: &users}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if len(users) != 1 || users[0].ID != "1" {
+			t.Fatalf("Unexpected users: %#v", users)
+		} else if users[0].Password != "" {
+			t.Fatal("Expected password to be redacted.")
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/users", nil)
+		r.AddCookie(&http.Cookie{Name: "Session", Value: "2"})
+		r.AddCookie(&http.Cookie{Name: "Token", Value: "token"})
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		var resp gofmanhttp.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status: %d", w.Code)
+		} else if resp.Error.Code != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", resp.Error)
+		}
+	})
+}
+
+func TestServer_UserCreate(t *testing.T) {
+	var got gofman.User
+	s := newAuthServer()
+	s.UserService.(*UserService).CreateUserFn = func(ctx context.Context, user *gofman.User) error {
+		got = *user
+		user.ID, user.Password = "3", "hash"
+		return nil
+	}
+
+	w := serveAuth(s, "POST", "/users", `{"id":"5","username":"john","password":"password","role":"readonly","is_demo":true}`)
+
+	var user gofman.User
+	if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if got.ID != "" || got.Username != "john" || got.Password != "password" || got.Role != gofman.RoleReadOnly || got.IsDemo {
+		t.Fatalf("Unexpected user: %#v", got)
+	} else if v := w.Header().Get("Location"); v != "/users/3" {
+		t.Fatalf("Unexpected Location header: %q", v)
+	} else if user.ID != "3" || user.Password != "" {
+		t.Fatalf("Unexpected user: %#v", user)
+	}
+}
+
+func TestServer_UserDelete(t *testing.T) {
+	var removed []string
+	s := newAuthServer()
+	s.UserService.(*UserService).RemoveUserFn = func(ctx context.Context, id string) error {
+		removed = append(removed, id)
+		return nil
+	}
+
+	if w := serveAuth(s, "DELETE", "/users/3", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status: %d", w.Code)
+	} else if len(removed) != 1 || removed[0] != "3" {
+		t.Fatalf("Unexpected removed users: %v", removed)
+	}
+}
@@ -1,7 +1,10 @@
 package path_traversal
 
 import (
+	"encoding/hex"
+	"io"
 	"io/fs"
+	"mime"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -45,6 +48,22 @@ func (s *PathTraversalService) Expand(path string) (string, error) {
 	return fullpath, nil
 }
 
+// Resolve returns the path of a file relative to the working directory.
+// Relative paths are joined with the tilde expanded root, absolute paths are
+// returned unchanged.
+func (s *PathTraversalService) Resolve(root, path string) (string, error) {
+	if root == "" || filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	root, err := s.Expand(root)
+	if err != nil {
+		return path, err
+	}
+
+	return filepath.Join(root, path), nil
+}
+
 // GetFilesInPath returns all files recursively starting from a root path.
 func (s *PathTraversalService) GetFilesInPath(root string) ([]*gofman.File, error) {
 	var files []*gofman.File
@@ -68,3 +87,69 @@ func (s *PathTraversalService) GetFilesInPath(root string) ([]*gofman.File, erro
 
 	return files, err
 }
+
+// GetFile returns the file at the given path with its type, checksum and size
+// set. The checksum is computed with the default algorithm.
+// The type is derived from the file extension and falls back to sniffing the
+// content of the file.
+func (s *PathTraversalService) GetFile(path string) (*gofman.File, error) {
+	return s.GetFileWithChecksumAlgo(path, gofman.DefaultChecksumAlgo)
+}
+
+// GetFileWithChecksumAlgo returns the file at the given path like GetFile,
+// but computes the checksum with the given algorithm.
+func (s *PathTraversalService) GetFileWithChecksumAlgo(path, algo string) (*gofman.File, error) {
+	hash, err := gofman.NewChecksumHash(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 512)
+
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	hash.Write(buf[:n])
+
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+
+	return &gofman.File{
+		Name:         filepath.Base(path),
+		Path:         path,
+		Type:         detectContentType(path, buf[:n]),
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+		ChecksumAlgo: algo,
+		Size:         info.Size(),
+	}, nil
+}
+
+// detectContentType returns the media type of a file based on its extension
+// or, if the extension is unknown, its first bytes.
+func detectContentType(path string, head []byte) string {
+	typ := mime.TypeByExtension(filepath.Ext(path))
+	if typ == "" {
+		typ = DetectContentType(head)
+	}
+
+	if mediatype, _, err := mime.ParseMediaType(typ); err == nil {
+		return mediatype
+	}
+
+	return typ
+}
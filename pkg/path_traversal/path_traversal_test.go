@@ -0,0 +1,63 @@
+package path_traversal_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/path_traversal"
+)
+
+func TestPathTraversalService_Resolve(t *testing.T) {
+	s := path_traversal.NewPathTraversalService()
+	root := filepath.FromSlash("/srv/media")
+
+	for _, tt := range []struct {
+		name string
+		root string
+		path string
+		want string
+	}{
+		{name: "Relative", root: root, path: filepath.FromSlash("a/b.jpg"), want: filepath.FromSlash("/srv/media/a/b.jpg")},
+		{name: "Absolute", root: root, path: filepath.FromSlash("/other/b.jpg"), want: filepath.FromSlash("/other/b.jpg")},
+		{name: "NoRoot", root: "", path: "b.jpg", want: "b.jpg"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := s.Resolve(tt.root, tt.path); err != nil {
+				t.Fatal(err)
+			} else if got != tt.want {
+				t.Fatalf("Unexpected path: %q", got)
+			}
+		})
+	}
+}
+
+func TestPathTraversalService_GetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := ioutil.WriteFile(path, []byte("hello world"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := path_traversal.NewPathTraversalService().GetFile(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if file.Name != "a.txt" || file.Path != path {
+		t.Fatalf("Unexpected file: %#v", file)
+	} else if file.Size != 11 {
+		t.Fatalf("Unexpected size: %d", file.Size)
+	} else if file.Checksum != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+		t.Fatalf("Unexpected checksum: %s", file.Checksum)
+	}
+
+	file, err = path_traversal.NewPathTraversalService().GetFileWithChecksumAlgo(path, gofman.ChecksumSHA1)
+	if err != nil {
+		t.Fatal(err)
+	} else if file.ChecksumAlgo != gofman.ChecksumSHA1 || file.Checksum != "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed" {
+		t.Fatalf("Unexpected checksum: %s %s", file.ChecksumAlgo, file.Checksum)
+	}
+
+	if _, err := path_traversal.NewPathTraversalService().GetFileWithChecksumAlgo(path, "crc32"); gofman.ErrorCode(err) != gofman.EINVALID {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
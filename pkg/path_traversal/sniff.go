@@ -0,0 +1,98 @@
+package path_traversal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+)
+
+// signature represents the magic bytes a media type starts with.
+type signature struct {
+	magic []byte
+	typ   string
+}
+
+// signatures holds the media types that http.DetectContentType does not
+// recognize.
+var signatures = []signature{
+	{magic: []byte("\xff\x0a"), typ: "image/jxl"},
+	{magic: []byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a"), typ: "image/jxl"},
+	{magic: []byte("fLaC"), typ: "audio/flac"},
+}
+
+// brands maps ISO base media file format brands to their media type.
+var brands = map[string]string{
+	"avif": "image/avif",
+	"avis": "image/avif",
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"heim": "image/heic",
+	"heis": "image/heic",
+	"hevc": "image/heic-sequence",
+	"hevx": "image/heic-sequence",
+	"mif1": "image/heif",
+	"msf1": "image/heif-sequence",
+	"qt  ": "video/quicktime",
+	"M4A ": "audio/mp4",
+	"M4V ": "video/x-m4v",
+	"3gp4": "video/3gpp",
+	"3gp5": "video/3gpp",
+	"3g2a": "video/3gpp2",
+}
+
+// DetectContentType returns the media type of data. It recognizes modern image
+// and video formats like WebP, AVIF and HEIC and falls back to
+// http.DetectContentType for everything else.
+func DetectContentType(data []byte) string {
+	if len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return "image/webp"
+	}
+
+	for _, sig := range signatures {
+		if bytes.HasPrefix(data, sig.magic) {
+			return sig.typ
+		}
+	}
+
+	if typ := detectBrand(data); typ != "" {
+		return typ
+	}
+
+	if bytes.HasPrefix(data, []byte("\x1a\x45\xdf\xa3")) && !bytes.Contains(data, []byte("webm")) {
+		return "video/x-matroska"
+	}
+
+	return http.DetectContentType(data)
+}
+
+// detectBrand returns the media type of an ISO base media file based on the
+// major and compatible brands of its ftyp box. Returns an empty string if data
+// is not such a file or none of its brands is known.
+func detectBrand(data []byte) string {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return ""
+	}
+
+	size := int(binary.BigEndian.Uint32(data[:4]))
+	if size < 16 || size%4 != 0 {
+		return ""
+	}
+
+	if size > len(data) {
+		size = len(data)
+	}
+
+	// The major brand is followed by a minor version and a list of
+	// compatible brands.
+	if typ, ok := brands[string(data[8:12])]; ok {
+		return typ
+	}
+
+	for i := 16; i+4 <= size; i += 4 {
+		if typ, ok := brands[string(data[i:i+4])]; ok {
+			return typ
+		}
+	}
+
+	return ""
+}
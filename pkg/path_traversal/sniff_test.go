@@ -0,0 +1,38 @@
+package path_traversal_test
+
+import (
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/path_traversal"
+)
+
+func TestDetectContentType(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+		typ  string
+	}{
+		{name: "WebP", data: "RIFF\x24\x00\x00\x00WEBPVP8 ", typ: "image/webp"},
+		{name: "AVIF", data: "\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf", typ: "image/avif"},
+		{name: "AVIFSequence", data: "\x00\x00\x00\x18ftypavis\x00\x00\x00\x00avismsf1", typ: "image/avif"},
+		{name: "HEIC", data: "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic", typ: "image/heic"},
+		{name: "HEIF", data: "\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic", typ: "image/heif"},
+		{name: "CompatibleBrand", data: "\x00\x00\x00\x18ftypxxxx\x00\x00\x00\x00avifmif1", typ: "image/avif"},
+		{name: "QuickTime", data: "\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00qt  ", typ: "video/quicktime"},
+		{name: "M4A", data: "\x00\x00\x00\x18ftypM4A \x00\x00\x02\x00M4A mp42", typ: "audio/mp4"},
+		{name: "JPEGXL", data: "\xff\x0a\xfa\x7f", typ: "image/jxl"},
+		{name: "JPEGXLContainer", data: "\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a", typ: "image/jxl"},
+		{name: "FLAC", data: "fLaC\x00\x00\x00\x22", typ: "audio/flac"},
+		{name: "Matroska", data: "\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska", typ: "video/x-matroska"},
+		{name: "WebM", data: "\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm", typ: "video/webm"},
+		{name: "JPEG", data: "\xff\xd8\xff\xe0", typ: "image/jpeg"},
+		{name: "Unknown", data: "\x00\x01\x02\x03", typ: "application/octet-stream"},
+		{name: "Empty", data: "", typ: "text/plain; charset=utf-8"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if typ := path_traversal.DetectContentType([]byte(tt.data)); typ != tt.typ {
+				t.Fatalf("Unexpected type: %q", typ)
+			}
+		})
+	}
+}
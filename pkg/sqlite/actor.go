@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"strings"
 
 	"github.com/dhenkes/gofman/pkg/gofman"
@@ -73,6 +74,29 @@ func (s *ActorService) CreateActor(ctx context.Context, actor *gofman.Actor) err
 	return tx.Commit()
 }
 
+// EnsureActorByName retrieves the actor of the current user with the given
+// name or creates it if it does not exist.
+// Returns EUNAUTHORIZED if the current user is not allowed to create actors.
+func (s *ActorService) EnsureActorByName(ctx context.Context, name string) (*gofman.Actor, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	actor, err := ensureActorByName(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return actor, nil
+}
+
 // UpdateActor updates a actor object.
 // Returns EUNAUTHORIZED if current user is not the creator of the actor.
 // Returns ENOTFOUND if actor does not exist.
@@ -115,10 +139,44 @@ func (s *ActorService) RemoveActor(ctx context.Context, id string) error {
 	return tx.Commit()
 }
 
+// RestoreActor resets the removed timestamp of a removed actor.
+// Returns EUNAUTHORIZED if current user is not the creator of the actor.
+// Returns ENOTFOUND if actor does not exist or has not been removed.
+func (s *ActorService) RestoreActor(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := restoreActor(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Exists returns true if the actor exists, has not been removed and belongs
+// to the current user. It does not load the actor.
+func (s *ActorService) Exists(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	return exists(ctx, tx, "actors", id)
+}
+
 // findActorByID is a helper function to fetch a actor by ID.
+// Only actors of the current user are found.
 // Returns ENOTFOUND if actor does not exist.
 func findActorByID(ctx context.Context, tx *Tx, id string) (*gofman.Actor, error) {
-	actors, _, err := findActors(ctx, tx, gofman.ActorFilter{ID: &id, Limit: 1})
+	userID := gofman.UserIDFromContext(ctx)
+
+	actors, _, err := findActors(ctx, tx, gofman.ActorFilter{ID: &id, UserID: &userID, Limit: 1})
 	if err != nil {
 		return nil, err
 	}
@@ -138,6 +196,10 @@ func findActors(ctx context.Context, tx *Tx, filter gofman.ActorFilter) ([]*gofm
 		return nil, 0, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to search using this filter.")
 	}
 
+	if err := checkOffset(filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
 	where, args := []string{"1 = 1"}, []interface{}{}
 
 	if v := filter.ID; v != nil {
@@ -148,7 +210,26 @@ func findActors(ctx context.Context, tx *Tx, filter gofman.ActorFilter) ([]*gofm
 		where, args = append(where, "users_id = ?"), append(args, *v)
 	}
 
-	where = append(where, "removed_at = 0")
+	if v := filter.NameLike; v != nil {
+		where, args = append(where, `name LIKE '%' || ? || '%' ESCAPE '\'`), append(args, escapeLike(*v))
+	}
+
+	if v := filter.CreatedAfter; v != nil {
+		where, args = append(where, "created_at >= ?"), append(args, *v)
+	}
+
+	if v := filter.CreatedBefore; v != nil {
+		where, args = append(where, "created_at <= ?"), append(args, *v)
+	}
+
+	if !filter.IncludeRemoved {
+		where = append(where, "removed_at = 0")
+	}
+
+	orderBy, err := formatOrderBy("actors", filter.SortBy, filter.SortDesc)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
@@ -161,8 +242,8 @@ func findActors(ctx context.Context, tx *Tx, filter gofman.ActorFilter) ([]*gofm
 			COUNT(*) OVER()
 		FROM actors
 		WHERE `+strings.Join(where, " AND ")+`
-		ORDER BY created_at ASC
-		`+formatLimitOffset(filter.Limit, filter.Offset),
+		`+orderBy+`
+		`+formatLimitOffset(clampLimit(filter.Limit, gofman.DefaultLimit, gofman.MaxLimit), filter.Offset),
 		args...,
 	)
 
@@ -196,6 +277,103 @@ func findActors(ctx context.Context, tx *Tx, filter gofman.ActorFilter) ([]*gofm
 	return actors, n, nil
 }
 
+// findActorByName retrieves a actor of the given user by name.
+// Returns ENOTFOUND if actor does not exist.
+func findActorByName(ctx context.Context, tx *Tx, userID string, name string) (*gofman.Actor, error) {
+	var actor gofman.Actor
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			users_id,
+			name,
+			created_at,
+			updated_at,
+			removed_at
+		FROM actors
+		WHERE users_id = ? AND name = ? AND removed_at = 0
+	`,
+		userID,
+		name,
+	).Scan(
+		&actor.ID, &actor.UserID, &actor.Name,
+		&actor.CreatedAt, &actor.UpdatedAt, &actor.RemovedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "Actor not found.")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &actor, nil
+}
+
+// ensureActorByName retrieves the actor of the current user with the given
+// name or creates it if it does not exist. If another transaction created the
+// actor in the meantime the insert is skipped and the actor is looked up again.
+func ensureActorByName(ctx context.Context, tx *Tx, name string) (*gofman.Actor, error) {
+	actor := &gofman.Actor{
+		UserID: gofman.UserIDFromContext(ctx),
+		Name:   name,
+	}
+
+	if err := actor.Validate(); err != nil {
+		return nil, err
+	}
+
+	if gofman.CanUpdateActor(ctx, actor) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to create this actor.")
+	}
+
+	if existing, err := findActorByName(ctx, tx, actor.UserID, name); err == nil {
+		return existing, nil
+	} else if gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		return nil, err
+	}
+
+	if id, err := tx.db.ID(); err != nil {
+		return nil, err
+	} else {
+		actor.ID = id
+	}
+
+	actor.CreatedAt = tx.now
+	actor.UpdatedAt = actor.CreatedAt
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO actors (
+			id,
+			users_id,
+			name,
+			created_at,
+			updated_at,
+			removed_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`,
+		actor.ID,
+		actor.UserID,
+		actor.Name,
+		actor.CreatedAt,
+		actor.UpdatedAt,
+		0,
+	)
+
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return findActorByName(ctx, tx, actor.UserID, name)
+	}
+
+	return actor, nil
+}
+
 // createActor creates a new actor.
 func createActor(ctx context.Context, tx *Tx, actor *gofman.Actor) error {
 	if err := actor.Validate(); err != nil {
@@ -235,7 +413,7 @@ func createActor(ctx context.Context, tx *Tx, actor *gofman.Actor) error {
 	)
 
 	if err != nil {
-		return err
+		return mapSQLiteError(err)
 	}
 
 	return nil
@@ -276,7 +454,7 @@ func updateActor(ctx context.Context, tx *Tx, id string, update gofman.ActorUpda
 	)
 
 	if err != nil {
-		return actor, err
+		return actor, mapSQLiteError(err)
 	}
 
 	return actor, nil
@@ -311,3 +489,40 @@ func removeActor(ctx context.Context, tx *Tx, id string) error {
 
 	return nil
 }
+
+// restoreActor resets the removed timestamp of a removed actor.
+// Returns EUNAUTHORIZED if current user is not the creator of the actor.
+// Returns ENOTFOUND if actor does not exist or has not been removed.
+// Returns ECONFLICT if another actor uses the name by now.
+func restoreActor(ctx context.Context, tx *Tx, id string) error {
+	userID := gofman.UserIDFromContext(ctx)
+
+	actors, _, err := findActors(ctx, tx, gofman.ActorFilter{ID: &id, UserID: &userID, IncludeRemoved: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	if len(actors) == 0 || actors[0].RemovedAt == 0 {
+		return gofman.NewError(gofman.ENOTFOUND, "Removed actor not found.")
+	}
+
+	if gofman.CanUpdateActor(ctx, actors[0]) == false {
+		return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to restore this actor.")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE actors
+		SET removed_at = 0
+		WHERE id = ?
+	`,
+		id,
+	)
+
+	if isUniqueConstraintError(err) {
+		return gofman.NewError(gofman.ECONFLICT, "Name already taken.")
+	} else if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	return nil
+}
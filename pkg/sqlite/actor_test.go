@@ -0,0 +1,246 @@
+package sqlite_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestActorService_EnsureActorByName(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+	user, ctx := MustCreateUser(t, ctx, db, &gofman.User{Username: "jane", Password: "password"})
+
+	s := sqlite.NewActorService(db)
+
+	t.Run("Create", func(t *testing.T) {
+		if actor, err := s.EnsureActorByName(ctx, "new"); err != nil {
+			t.Fatal(err)
+		} else if actor.ID == "" || actor.UserID != user.ID || actor.Name != "new" {
+			t.Fatalf("Unexpected actor: %#v", actor)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		existing := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "existing"})
+
+		if actor, err := s.EnsureActorByName(ctx, "existing"); err != nil {
+			t.Fatal(err)
+		} else if actor.ID != existing.ID {
+			t.Fatalf("Expected existing actor, got %#v", actor)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		// Simulate a concurrent transaction creating the same actor right before
+		// our insert.
+		MustExec(t, db, `
+			CREATE TRIGGER race BEFORE INSERT ON actors WHEN NEW.name = 'race'
+			BEGIN
+				INSERT INTO actors (id, users_id, name, created_at, updated_at, removed_at)
+				VALUES ('other', NEW.users_id, NEW.name, 0, 0, 0);
+			END
+		`)
+
+		if actor, err := s.EnsureActorByName(ctx, "race"); err != nil {
+			t.Fatal(err)
+		} else if actor.ID != "other" {
+			t.Fatalf("Expected concurrently created actor, got %#v", actor)
+		}
+
+		if actor, err := s.EnsureActorByName(ctx, "race"); err != nil {
+			t.Fatal(err)
+		} else if actor.ID != "other" {
+			t.Fatalf("Expected a single actor, got %#v", actor)
+		}
+	})
+}
+
+// MustCreateActor creates an actor in the database. Fatal on error.
+func MustCreateActor(tb testing.TB, ctx context.Context, db *sqlite.DB, actor *gofman.Actor) *gofman.Actor {
+	tb.Helper()
+
+	if err := sqlite.NewActorService(db).CreateActor(ctx, actor); err != nil {
+		tb.Fatal(err)
+	}
+
+	return actor
+}
+
+func TestActorService_Exists(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, ctx2 := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	actor := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "alice"})
+	removed := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "removed"})
+	MustExec(t, db, `UPDATE actors SET removed_at = 1 WHERE id = ?`, removed.ID)
+
+	s := sqlite.NewActorService(db)
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		id   string
+		want bool
+	}{
+		{name: "OK", ctx: ctx, id: actor.ID, want: true},
+		{name: "NotFound", ctx: ctx, id: "00000000-0000-0000-0000-000000000000"},
+		{name: "Removed", ctx: ctx, id: removed.ID},
+		{name: "OtherUser", ctx: ctx2, id: actor.ID},
+		{name: "Unauthenticated", ctx: context.Background(), id: actor.ID},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := s.Exists(tt.ctx, tt.id); err != nil {
+				t.Fatal(err)
+			} else if ok != tt.want {
+				t.Fatalf("Unexpected result: %v", ok)
+			}
+		})
+	}
+}
+
+func TestActorService_FindActors(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	actor0 := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "alice"})
+	actor1 := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "bob"})
+	MustCreateActor(t, otherCtx, db, &gofman.Actor{UserID: other.ID, Name: "carol"})
+
+	s := sqlite.NewActorService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		if actors, n, err := s.FindActors(ctx, gofman.ActorFilter{UserID: &user.ID}); err != nil {
+			t.Fatal(err)
+		} else if n != 2 || len(actors) != 2 {
+			t.Fatalf("Unexpected actors: n=%d len=%d", n, len(actors))
+		} else if actors[0].ID != actor0.ID || actors[1].ID != actor1.ID {
+			t.Fatalf("Unexpected actors: %#v", actors)
+		}
+	})
+
+	t.Run("ByID", func(t *testing.T) {
+		if actor, err := s.FindActorByID(ctx, actor1.ID); err != nil {
+			t.Fatal(err)
+		} else if actor.ID != actor1.ID {
+			t.Fatalf("Unexpected actor: %#v", actor)
+		}
+	})
+
+	t.Run("NameLike", func(t *testing.T) {
+		MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "100%"})
+		MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "1000"})
+		MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "x_y"})
+		MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "xzy"})
+
+		for _, tt := range []struct {
+			like string
+			want []string
+		}{
+			{like: "0%", want: []string{"100%"}},
+			{like: "_", want: []string{"x_y"}},
+			{like: "X", want: []string{"x_y", "xzy"}},
+			{like: "none", want: nil},
+		} {
+			actors, _, err := s.FindActors(ctx, gofman.ActorFilter{UserID: &user.ID, NameLike: &tt.like})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, actor := range actors {
+				names = append(names, actor.Name)
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("Unexpected names for %q: %v", tt.like, names)
+			}
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, _, err := s.FindActors(otherCtx, gofman.ActorFilter{UserID: &user.ID}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if _, _, err := s.FindActors(ctx, gofman.ActorFilter{}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if _, err := s.FindActorByID(otherCtx, actor0.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestActorService_RestoreActor(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	actor := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "jane doe"})
+
+	s := sqlite.NewActorService(db)
+
+	t.Run("ErrNotRemoved", func(t *testing.T) {
+		if err := s.RestoreActor(ctx, actor.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	if err := s.RemoveActor(ctx, actor.ID); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.FindActorByID(ctx, actor.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+
+	t.Run("IncludeRemoved", func(t *testing.T) {
+		if actors, _, err := s.FindActors(ctx, gofman.ActorFilter{UserID: &user.ID, IncludeRemoved: true}); err != nil {
+			t.Fatal(err)
+		} else if len(actors) != 1 || actors[0].RemovedAt == 0 {
+			t.Fatalf("Unexpected actors: %#v", actors)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		// Other users cannot see the actor.
+		if err := s.RestoreActor(otherCtx, actor.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNameTaken", func(t *testing.T) {
+		recreated := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "jane doe"})
+
+		if err := s.RestoreActor(ctx, actor.ID); gofman.ErrorCode(err) != gofman.ECONFLICT {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if err := s.RemoveActor(ctx, recreated.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if err := s.RestoreActor(ctx, actor.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if other, err := s.FindActorByID(ctx, actor.ID); err != nil {
+			t.Fatal(err)
+		} else if other.RemovedAt != 0 {
+			t.Fatalf("Unexpected removed at: %d", other.RemovedAt)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"errors"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/mattn/go-sqlite3"
+)
+
+// mapSQLiteError translates constraint violations into application errors so
+// they are not reported as internal errors. The original error is kept as
+// the cause. All other errors are returned unchanged.
+func mapSQLiteError(err error) error {
+	var e sqlite3.Error
+	if !errors.As(err, &e) || e.Code != sqlite3.ErrConstraint {
+		return err
+	}
+
+	switch e.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return gofman.NewWrappedError(gofman.ECONFLICT, err, "Record already exists.")
+	case sqlite3.ErrConstraintForeignKey:
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Referenced record does not exist.")
+	default:
+		return gofman.NewWrappedError(gofman.EINVALID, err, "Invalid value.")
+	}
+}
+
+// isUniqueConstraintError returns true if err is a violation of a UNIQUE
+// constraint or index.
+func isUniqueConstraintError(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && e.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// isBusyError returns true if err reports that the database is locked by
+// another connection.
+func isBusyError(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && (e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked)
+}
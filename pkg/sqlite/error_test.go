@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestMapSQLiteError(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		code string
+	}{
+		{name: "Unique", err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, code: gofman.ECONFLICT},
+		{name: "PrimaryKey", err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, code: gofman.ECONFLICT},
+		{name: "ForeignKey", err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}, code: gofman.EINVALID},
+		{name: "Check", err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintCheck}, code: gofman.EINVALID},
+		{name: "NotNull", err: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintNotNull}, code: gofman.EINVALID},
+		{name: "Wrapped", err: fmt.Errorf("insert: %w", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}), code: gofman.ECONFLICT},
+		{name: "Busy", err: sqlite3.Error{Code: sqlite3.ErrBusy}, code: gofman.EINTERNAL},
+		{name: "Other", err: errors.New("disk full"), code: gofman.EINTERNAL},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mapSQLiteError(tt.err)
+
+			if code := gofman.ErrorCode(err); code != tt.code {
+				t.Fatalf("Unexpected code: %q", code)
+			} else if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected original error to be kept: %#v", err)
+			}
+		})
+	}
+
+	if err := mapSQLiteError(nil); err != nil {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
+
+func TestIsBusyError(t *testing.T) {
+	if !isBusyError(sqlite3.Error{Code: sqlite3.ErrBusy}) {
+		t.Fatal("Expected SQLITE_BUSY to be a busy error")
+	} else if !isBusyError(fmt.Errorf("begin: %w", sqlite3.Error{Code: sqlite3.ErrLocked})) {
+		t.Fatal("Expected SQLITE_LOCKED to be a busy error")
+	} else if isBusyError(sqlite3.Error{Code: sqlite3.ErrConstraint}) || isBusyError(errors.New("busy")) {
+		t.Fatal("Unexpected busy error")
+	}
+}
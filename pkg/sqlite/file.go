@@ -2,6 +2,9 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/dhenkes/gofman/pkg/gofman"
@@ -38,6 +41,25 @@ func (s *FileService) FindFileByID(ctx context.Context, id string) (*gofman.File
 	return file, nil
 }
 
+// FindFileByChecksum retrieves the oldest file of the user with the checksum.
+// It allows importers to skip files that are already stored.
+// Returns ENOTFOUND if file does not exist.
+func (s *FileService) FindFileByChecksum(ctx context.Context, userID, checksum string) (*gofman.File, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	file, err := findFileByChecksum(ctx, tx, userID, checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
 // FindFiles retrieves file objects and total hits based on a filter.
 // The total hits may differ from the length of the slice if a limit was
 // applied.
@@ -57,6 +79,26 @@ func (s *FileService) FindFiles(ctx context.Context, filter gofman.FileFilter) (
 	return files, total, nil
 }
 
+// SearchFiles retrieves the files whose name contains all words of the
+// query, limited by the filter. Files are ordered by relevance unless the
+// filter sets a sort column. Returns ENOTIMPLEMENTED if SQLite was built
+// without FTS5.
+func (s *FileService) SearchFiles(ctx context.Context, query string, filter gofman.FileFilter) ([]*gofman.File, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer tx.Rollback()
+
+	files, total, err := searchFiles(ctx, tx, query, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return files, total, nil
+}
+
 // CreateFile creates a new file.
 func (s *FileService) CreateFile(ctx context.Context, file *gofman.File) error {
 	tx, err := s.db.BeginTx(ctx, nil)
@@ -73,6 +115,29 @@ func (s *FileService) CreateFile(ctx context.Context, file *gofman.File) error {
 	return tx.Commit()
 }
 
+// CreateFiles creates all files within a single transaction. If any file is
+// invalid none are created and the error names the position of the file.
+func (s *FileService) CreateFiles(ctx context.Context, files []*gofman.File) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for i, file := range files {
+		if err := createFile(ctx, tx, file); err != nil {
+			if gofman.ErrorCode(err) == gofman.EINTERNAL {
+				return err
+			}
+
+			return gofman.NewWrappedError(gofman.ErrorCode(err), err, "File %d: %s", i, gofman.ErrorMessage(err))
+		}
+	}
+
+	return tx.Commit()
+}
+
 // UpdateFile updates a file object.
 // Returns EUNAUTHORIZED if current user is not the creator of the file.
 // Returns ENOTFOUND if file does not exist.
@@ -115,10 +180,59 @@ func (s *FileService) RemoveFile(ctx context.Context, id string) error {
 	return tx.Commit()
 }
 
+// RestoreFile resets the removed timestamp of a removed file.
+// Returns EUNAUTHORIZED if current user is not the creator of the file.
+// Returns ENOTFOUND if file does not exist or has not been removed.
+func (s *FileService) RestoreFile(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := restoreFile(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Exists returns true if the file exists, has not been removed and belongs
+// to the current user. It does not load the file.
+func (s *FileService) Exists(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	return exists(ctx, tx, "files", id)
+}
+
 // findFileByID is a helper function to fetch a file by ID.
+// Only files of the current user are found.
 // Returns ENOTFOUND if file does not exist.
 func findFileByID(ctx context.Context, tx *Tx, id string) (*gofman.File, error) {
-	files, _, err := findFiles(ctx, tx, gofman.FileFilter{ID: &id, Limit: 1})
+	userID := gofman.UserIDFromContext(ctx)
+
+	files, _, err := findFiles(ctx, tx, gofman.FileFilter{ID: &id, UserID: &userID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "File not found.")
+	}
+
+	return files[0], nil
+}
+
+// findFileByChecksum is a helper function to fetch the oldest file of a user
+// by checksum. Returns ENOTFOUND if file does not exist.
+func findFileByChecksum(ctx context.Context, tx *Tx, userID, checksum string) (*gofman.File, error) {
+	files, _, err := findFiles(ctx, tx, gofman.FileFilter{UserID: &userID, Checksum: &checksum, Limit: 1})
 	if err != nil {
 		return nil, err
 	}
@@ -134,11 +248,45 @@ func findFileByID(ctx context.Context, tx *Tx, id string) (*gofman.File, error)
 // The total hits may differ from the length of the slice if a limit was
 // applied.
 func findFiles(ctx context.Context, tx *Tx, filter gofman.FileFilter) ([]*gofman.File, int, error) {
+	return queryFiles(ctx, tx, "", filter)
+}
+
+// searchFiles retrieves the files matching the full-text search query and
+// the filter, see SearchFiles.
+func searchFiles(ctx context.Context, tx *Tx, query string, filter gofman.FileFilter) ([]*gofman.File, int, error) {
+	if !tx.db.search {
+		return nil, 0, gofman.NewError(gofman.ENOTIMPLEMENTED, "Search is not supported by this build.")
+	}
+
+	if query = formatSearchQuery(query); query == "" {
+		return nil, 0, gofman.NewError(gofman.EINVALID, "Query required.")
+	}
+
+	return queryFiles(ctx, tx, query, filter)
+}
+
+// queryFiles retrieves the files matching the filter and, unless empty, the
+// FTS5 query.
+func queryFiles(ctx context.Context, tx *Tx, query string, filter gofman.FileFilter) ([]*gofman.File, int, error) {
 	if gofman.CanFindFile(ctx, filter) == false {
 		return nil, 0, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to search using this filter.")
 	}
 
-	where, args := []string{"1 = 1"}, []interface{}{}
+	if err := checkOffset(filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
+	from, where, args := "files", []string{"1 = 1"}, []interface{}{}
+
+	// The rank is the relevance of the match, lower values are better.
+	if query != "" {
+		from, args = `files JOIN (
+			SELECT files_search.files_id AS search_id, files_fts.rank AS search_rank
+			FROM files_fts
+			JOIN files_search ON files_search.id = files_fts.rowid
+			WHERE files_fts MATCH ?
+		) ON search_id = id`, append(args, query)
+	}
 
 	if v := filter.ID; v != nil {
 		where, args = append(where, "id = ?"), append(args, *v)
@@ -148,28 +296,67 @@ func findFiles(ctx context.Context, tx *Tx, filter gofman.FileFilter) ([]*gofman
 		where, args = append(where, "users_id = ?"), append(args, *v)
 	}
 
+	if v := filter.FolderID; v != nil {
+		where, args = append(where, "folder_id = ?"), append(args, *v)
+	}
+
 	if v := filter.Type; v != nil {
 		where, args = append(where, "type = ?"), append(args, *v)
 	}
 
-	where = append(where, "removed_at = 0")
+	if v := filter.Checksum; v != nil {
+		where, args = append(where, "checksum = ?"), append(args, *v)
+	}
+
+	if v := filter.NameLike; v != nil {
+		where, args = append(where, `name LIKE '%' || ? || '%' ESCAPE '\'`), append(args, escapeLike(*v))
+	}
+
+	if v := filter.MinSize; v != nil {
+		where, args = append(where, "size >= ?"), append(args, *v)
+	}
+
+	if v := filter.CreatedAfter; v != nil {
+		where, args = append(where, "created_at >= ?"), append(args, *v)
+	}
+
+	if v := filter.CreatedBefore; v != nil {
+		where, args = append(where, "created_at <= ?"), append(args, *v)
+	}
+
+	if !filter.IncludeRemoved {
+		where = append(where, "removed_at = 0")
+	}
+
+	orderBy, err := formatOrderBy("files", filter.SortBy, filter.SortDesc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if query != "" && filter.SortBy == "" {
+		orderBy = "ORDER BY search_rank ASC, id ASC"
+	}
 
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
 			id,
 			users_id,
+			folder_id,
 			name,
 			type,
 			path,
 			checksum,
+			checksum_algo,
+			description,
+			size,
 			created_at,
 			updated_at,
 			removed_at,
 			COUNT(*) OVER()
-		FROM files
+		FROM `+from+`
 		WHERE `+strings.Join(where, " AND ")+`
-		ORDER BY created_at ASC
-		`+formatLimitOffset(filter.Limit, filter.Offset),
+		`+orderBy+`
+		`+formatLimitOffset(clampLimit(filter.Limit, gofman.DefaultLimit, gofman.MaxLimit), filter.Offset),
 		args...,
 	)
 
@@ -186,7 +373,7 @@ func findFiles(ctx context.Context, tx *Tx, filter gofman.FileFilter) ([]*gofman
 		var file gofman.File
 
 		if err = rows.Scan(
-			&file.ID, &file.UserID, &file.Name, &file.Type, &file.Path, &file.Checksum,
+			&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.Type, &file.Path, &file.Checksum, &file.ChecksumAlgo, &file.Description, &file.Size,
 			&file.CreatedAt, &file.UpdatedAt, &file.RemovedAt,
 			&n,
 		); err != nil {
@@ -205,14 +392,34 @@ func findFiles(ctx context.Context, tx *Tx, filter gofman.FileFilter) ([]*gofman
 
 // createFile creates a new file.
 func createFile(ctx context.Context, tx *Tx, file *gofman.File) error {
+	if file.ChecksumAlgo == "" {
+		file.ChecksumAlgo = gofman.DefaultChecksumAlgo
+	}
+
 	if err := file.Validate(); err != nil {
 		return err
 	}
 
+	if err := file.ValidateType(tx.db.FileConfig); err != nil {
+		return err
+	}
+
 	if gofman.CanUpdateFile(ctx, file) == false {
 		return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to create this file.")
 	}
 
+	if v := file.FolderID; v != nil {
+		if _, err := findFolderByID(ctx, tx, *v); err != nil {
+			return err
+		}
+	}
+
+	if path, err := tx.db.relativePath(file.Path); err != nil {
+		return err
+	} else {
+		file.Path = path
+	}
+
 	if id, err := tx.db.ID(); err != nil {
 		return err
 	} else {
@@ -226,37 +433,45 @@ func createFile(ctx context.Context, tx *Tx, file *gofman.File) error {
 		INSERT INTO files (
 			id,
 			users_id,
+			folder_id,
 			name,
 			type,
 			path,
 			checksum,
+			checksum_algo,
+			description,
+			size,
 			created_at,
 			updated_at,
 			removed_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		file.ID,
 		file.UserID,
+		file.FolderID,
 		file.Name,
 		file.Type,
 		file.Path,
 		file.Checksum,
+		file.ChecksumAlgo,
+		file.Description,
+		file.Size,
 		file.CreatedAt,
 		file.UpdatedAt,
 		0,
 	)
 
 	if err != nil {
-		return err
+		return mapSQLiteError(err)
 	}
 
-	return nil
+	return indexFile(ctx, tx, file)
 }
 
 // updateFile updates a file object.
-// Returns EUNAUTHORIZED if current user is not the creator of the file.
-// Returns ENOTFOUND if file does not exist.
+// Returns EUNAUTHORIZED if current user cannot upload.
+// Returns ENOTFOUND if file does not exist or belongs to another user.
 func updateFile(ctx context.Context, tx *Tx, id string, update gofman.FileUpdate) (*gofman.File, error) {
 	file, err := findFileByID(ctx, tx, id)
 	if err != nil {
@@ -267,6 +482,21 @@ func updateFile(ctx context.Context, tx *Tx, id string, update gofman.FileUpdate
 		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to update this file.")
 	}
 
+	// Stored paths may be relative to the storage root. They are resolved so
+	// the absolute path is validated and made relative again before saving.
+	if file.Path, err = tx.db.resolvePath(file.Path); err != nil {
+		return file, err
+	}
+
+	if v := update.FolderID; v != nil {
+		if *v == "" {
+			file.FolderID = nil
+		} else {
+			folderID := *v
+			file.FolderID = &folderID
+		}
+	}
+
 	if v := update.Name; v != nil {
 		file.Name = *v
 	}
@@ -283,31 +513,75 @@ func updateFile(ctx context.Context, tx *Tx, id string, update gofman.FileUpdate
 		file.Checksum = *v
 	}
 
+	if v := update.ChecksumAlgo; v != nil {
+		file.ChecksumAlgo = *v
+	}
+
+	if v := update.Description; v != nil {
+		file.Description = *v
+	}
+
+	if v := update.Size; v != nil {
+		file.Size = *v
+	}
+
 	file.UpdatedAt = tx.now
 
 	if err := file.Validate(); err != nil {
 		return file, err
 	}
 
+	// Only changed types are checked, so files stored before their type was
+	// removed from the allowlist can still be updated.
+	if update.Type != nil {
+		if err := file.ValidateType(tx.db.FileConfig); err != nil {
+			return file, err
+		}
+	}
+
+	if v := file.FolderID; v != nil && update.FolderID != nil {
+		if _, err := findFolderByID(ctx, tx, *v); err != nil {
+			return file, err
+		}
+	}
+
+	if file.Path, err = tx.db.relativePath(file.Path); err != nil {
+		return file, err
+	}
+
 	_, err = tx.ExecContext(ctx, `
 		UPDATE files
-		SET name = ?,
+		SET folder_id = ?,
+			name = ?,
 			type = ?,
 			path = ?,
 			checksum = ?,
+			checksum_algo = ?,
+			description = ?,
+			size = ?,
 			updated_at = ?
 		WHERE id = ?
 	`,
+		file.FolderID,
 		file.Name,
 		file.Type,
 		file.Path,
 		file.Checksum,
+		file.ChecksumAlgo,
+		file.Description,
+		file.Size,
 		file.UpdatedAt,
 		id,
 	)
 
 	if err != nil {
-		return file, err
+		return file, mapSQLiteError(err)
+	}
+
+	if update.Name != nil {
+		if err := indexFile(ctx, tx, file); err != nil {
+			return file, err
+		}
 	}
 
 	return file, nil
@@ -340,5 +614,717 @@ func removeFile(ctx context.Context, tx *Tx, id string) error {
 		return err
 	}
 
-	return nil
+	return unindexFiles(ctx, tx, "id = ?", id)
+}
+
+// restoreFile resets the removed timestamp of a removed file.
+// Returns EUNAUTHORIZED if current user is not the creator of the file.
+// Returns ENOTFOUND if file does not exist or has not been removed.
+func restoreFile(ctx context.Context, tx *Tx, id string) error {
+	userID := gofman.UserIDFromContext(ctx)
+
+	files, _, err := findFiles(ctx, tx, gofman.FileFilter{ID: &id, UserID: &userID, IncludeRemoved: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 || files[0].RemovedAt == 0 {
+		return gofman.NewError(gofman.ENOTFOUND, "Removed file not found.")
+	}
+
+	if gofman.CanUpdateFile(ctx, files[0]) == false {
+		return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to restore this file.")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE files
+		SET removed_at = 0
+		WHERE id = ?
+	`,
+		id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return indexFile(ctx, tx, files[0])
+}
+
+// GroupFilesByTag retrieves all tags of a user together with their files.
+// Tags without files are only returned if IncludeEmpty is set.
+// Returns EUNAUTHORIZED if current user is not the given user.
+func (s *FileService) GroupFilesByTag(ctx context.Context, filter gofman.FileGroupFilter) ([]*gofman.TagFiles, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	groups, err := groupFiles(ctx, tx, "tags", "files_tags", "tags_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*gofman.TagFiles, 0, len(groups))
+
+	for _, g := range groups {
+		result = append(result, &gofman.TagFiles{
+			Tag: &gofman.Tag{
+				ID:        g.id,
+				UserID:    g.userID,
+				Name:      g.name,
+				CreatedAt: g.createdAt,
+				UpdatedAt: g.updatedAt,
+				RemovedAt: g.removedAt,
+			},
+			Files: g.files,
+		})
+	}
+
+	return result, nil
+}
+
+// GroupFilesByActor retrieves all actors of a user together with their files.
+// Actors without files are only returned if IncludeEmpty is set.
+// Returns EUNAUTHORIZED if current user is not the given user.
+func (s *FileService) GroupFilesByActor(ctx context.Context, filter gofman.FileGroupFilter) ([]*gofman.ActorFiles, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	groups, err := groupFiles(ctx, tx, "actors", "files_actors", "actors_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*gofman.ActorFiles, 0, len(groups))
+
+	for _, g := range groups {
+		result = append(result, &gofman.ActorFiles{
+			Actor: &gofman.Actor{
+				ID:        g.id,
+				UserID:    g.userID,
+				Name:      g.name,
+				CreatedAt: g.createdAt,
+				UpdatedAt: g.updatedAt,
+				RemovedAt: g.removedAt,
+			},
+			Files: g.files,
+		})
+	}
+
+	return result, nil
+}
+
+// fileGroup represents a single row of a grouping table (tags, actors)
+// together with its files.
+type fileGroup struct {
+	id        string
+	userID    string
+	name      string
+	createdAt int64
+	updatedAt int64
+	removedAt int64
+
+	files []*gofman.File
+}
+
+// groupFiles retrieves the rows of the given table owned by the user and
+// their files in a single joined query. The table and join names are never
+// user input.
+func groupFiles(ctx context.Context, tx *Tx, table, joinTable, joinColumn string, filter gofman.FileGroupFilter) ([]*fileGroup, error) {
+	if gofman.CanGroupFiles(ctx, filter) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to group these files.")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			g.id,
+			g.users_id,
+			g.name,
+			g.created_at,
+			g.updated_at,
+			g.removed_at,
+			COALESCE(f.id, ''),
+			COALESCE(f.users_id, ''),
+			f.folder_id,
+			COALESCE(f.name, ''),
+			COALESCE(f.type, ''),
+			COALESCE(f.path, ''),
+			COALESCE(f.checksum, ''),
+			COALESCE(f.checksum_algo, ''),
+			COALESCE(f.description, ''),
+			COALESCE(f.size, 0),
+			COALESCE(f.created_at, 0),
+			COALESCE(f.updated_at, 0),
+			COALESCE(f.removed_at, 0)
+		FROM `+table+` g
+		LEFT JOIN `+joinTable+` j ON j.`+joinColumn+` = g.id
+		LEFT JOIN files f ON f.id = j.files_id AND f.removed_at = 0
+		WHERE g.users_id = ? AND g.removed_at = 0
+		ORDER BY g.created_at ASC, g.id ASC, f.created_at ASC, f.id ASC
+	`,
+		filter.UserID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var groups []*fileGroup
+
+	for rows.Next() {
+		var g fileGroup
+		var file gofman.File
+
+		if err = rows.Scan(
+			&g.id, &g.userID, &g.name,
+			&g.createdAt, &g.updatedAt, &g.removedAt,
+			&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.Type, &file.Path, &file.Checksum, &file.ChecksumAlgo, &file.Description, &file.Size,
+			&file.CreatedAt, &file.UpdatedAt, &file.RemovedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].id != g.id {
+			groups = append(groups, &g)
+		}
+
+		if file.ID != "" {
+			last := groups[len(groups)-1]
+			last.files = append(last.files, &file)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if filter.IncludeEmpty {
+		return groups, nil
+	}
+
+	result := groups[:0]
+
+	for _, g := range groups {
+		if len(g.files) > 0 {
+			result = append(result, g)
+		}
+	}
+
+	return result, nil
+}
+
+// Reconcile compares the files on disk below the root with the files in the
+// database and reports orphaned rows and untracked files. Depending on the
+// options orphaned rows are removed and untracked files are imported for the
+// given user.
+// Returns EUNAUTHORIZED if current user is not an admin.
+func (s *FileService) Reconcile(ctx context.Context, opts gofman.FileReconcileOptions) (*gofman.FileReconcileReport, error) {
+	// The disk is scanned before the write transaction starts, as SQLite
+	// allows a single writer and uploads must not wait for the scan.
+	scan, err := s.scanFiles(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	report, err := reconcileFiles(ctx, tx, opts, scan)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// fileScan represents the files found on disk below the root of a reconcile.
+type fileScan struct {
+	root   string
+	onDisk []*gofman.File
+
+	// Untracked files with their type, checksum and size set, keyed by path.
+	// Only set if untracked files are imported.
+	hashed map[string]*gofman.File
+}
+
+// scanFiles lists the files on disk below the root of the options and, if
+// they are imported, reads the files that are not tracked yet. The database
+// is only read in a short transaction, files are read outside of it.
+// Returns EUNAUTHORIZED if current user is not an admin.
+func (s *FileService) scanFiles(ctx context.Context, opts gofman.FileReconcileOptions) (*fileScan, error) {
+	if gofman.CanReconcileFiles(ctx) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to reconcile files.")
+	}
+
+	if s.db.PathTraversalService == nil {
+		return nil, gofman.NewError(gofman.EINVALID, "PathTraversalService required.")
+	}
+
+	if opts.Root == "" {
+		return nil, gofman.NewError(gofman.EINVALID, "Root required.")
+	}
+
+	root, err := s.db.PathTraversalService.Expand(opts.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Imported files must have absolute paths.
+	if root, err = filepath.Abs(root); err != nil {
+		return nil, err
+	}
+
+	onDisk, err := s.db.PathTraversalService.GetFilesInPath(root)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &fileScan{root: root, onDisk: onDisk}
+
+	if !opts.ImportUntracked {
+		return scan, nil
+	}
+
+	tracked, err := s.trackedPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scan.hashed = make(map[string]*gofman.File)
+
+	for _, file := range onDisk {
+		path := filepath.Clean(file.Path)
+		if tracked[path] {
+			continue
+		}
+
+		if scan.hashed[path], err = s.db.PathTraversalService.GetFile(file.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	return scan, nil
+}
+
+// trackedPaths returns the cleaned absolute paths of all files in the
+// database.
+func (s *FileService) trackedPaths(ctx context.Context) (map[string]bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	files, err := findAllFiles(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]bool, len(files))
+	for _, file := range files {
+		path, err := s.db.resolvePath(file.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		tracked[filepath.Clean(path)] = true
+	}
+
+	return tracked, nil
+}
+
+// reconcileFiles compares the scanned files on disk with the files in the
+// database.
+// Returns EUNAUTHORIZED if current user is not an admin.
+func reconcileFiles(ctx context.Context, tx *Tx, opts gofman.FileReconcileOptions, scan *fileScan) (*gofman.FileReconcileReport, error) {
+	if gofman.CanReconcileFiles(ctx) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to reconcile files.")
+	}
+
+	root, onDisk := scan.root, scan.onDisk
+
+	user, err := findUserByID(ctx, tx, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := findAllFiles(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var report gofman.FileReconcileReport
+
+	exists := make(map[string]bool, len(onDisk))
+	for _, file := range onDisk {
+		exists[filepath.Clean(file.Path)] = true
+	}
+
+	tracked := make(map[string]bool, len(files))
+	for _, file := range files {
+		path, err := tx.db.resolvePath(file.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Clean(path)
+		tracked[path] = true
+
+		if file.UserID != user.ID || !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			continue
+		}
+
+		if !exists[path] {
+			report.Orphaned = append(report.Orphaned, file)
+		}
+	}
+
+	for _, file := range onDisk {
+		if !tracked[filepath.Clean(file.Path)] {
+			report.Untracked = append(report.Untracked, file)
+		}
+	}
+
+	if opts.RemoveOrphaned {
+		for _, file := range report.Orphaned {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE files
+				SET removed_at = ?
+				WHERE id = ?
+			`,
+				tx.now,
+				file.ID,
+			); err != nil {
+				return nil, err
+			}
+
+			if err := unindexFiles(ctx, tx, "id = ?", file.ID); err != nil {
+				return nil, err
+			}
+
+			file.RemovedAt = tx.now
+			report.Removed++
+		}
+	}
+
+	if opts.ImportUntracked {
+		userCtx := gofman.NewContextWithUser(ctx, user)
+
+		for i, file := range report.Untracked {
+			// Files whose row was removed after the scan were not read and
+			// are left for the next run.
+			imported, ok := scan.hashed[filepath.Clean(file.Path)]
+			if !ok {
+				continue
+			}
+
+			imported.UserID = user.ID
+
+			if err := createFile(userCtx, tx, imported); err != nil {
+				return nil, err
+			}
+
+			report.Untracked[i] = imported
+			report.Imported++
+		}
+	}
+
+	return &report, nil
+}
+
+// RecomputeChecksum reads the file from disk again and updates its checksum.
+// Returns EUNAUTHORIZED if current user is neither the creator of the file nor
+// an admin.
+// Returns ENOTFOUND if file does not exist in the database or on disk, or
+// belongs to another user and current user is not an admin.
+func (s *FileService) RecomputeChecksum(ctx context.Context, id string) (*gofman.File, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	file, err := recomputeChecksum(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// recomputeChecksum reads the file from disk again and updates its checksum.
+// Returns EUNAUTHORIZED if current user is neither the creator of the file nor
+// an admin.
+// Returns ENOTFOUND if file does not exist in the database or on disk, or
+// belongs to another user and current user is not an admin.
+func recomputeChecksum(ctx context.Context, tx *Tx, id string) (*gofman.File, error) {
+	// Only admins look up files of other users, so other users cannot tell
+	// whether an ID exists.
+	find := findFileByID
+	if gofman.CanManageUsers(ctx) {
+		find = lookupFileByID
+	}
+
+	file, err := find(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if gofman.CanRecomputeChecksum(ctx, file) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to update this file.")
+	}
+
+	if tx.db.PathTraversalService == nil {
+		return nil, gofman.NewError(gofman.EINVALID, "PathTraversalService required.")
+	}
+
+	path, err := tx.db.resolvePath(file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The checksum is recomputed with the algorithm it was stored with.
+	disk, err := tx.db.PathTraversalService.GetFileWithChecksumAlgo(path, file.ChecksumAlgo)
+	if os.IsNotExist(err) {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "File not found on disk.")
+	} else if err != nil {
+		return nil, err
+	}
+
+	file.Checksum = disk.Checksum
+	file.Size = disk.Size
+	file.UpdatedAt = tx.now
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE files
+		SET checksum = ?,
+			checksum_algo = ?,
+			size = ?,
+			updated_at = ?
+		WHERE id = ?
+	`,
+		file.Checksum,
+		file.ChecksumAlgo,
+		file.Size,
+		file.UpdatedAt,
+		id,
+	)
+
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+
+	return file, nil
+}
+
+// lookupFileByID retrieves a file that has not been removed by ID regardless
+// of its owner. It must only be used for admins, so other users cannot tell
+// whether an ID exists.
+// Returns ENOTFOUND if file does not exist.
+func lookupFileByID(ctx context.Context, tx *Tx, id string) (*gofman.File, error) {
+	var file gofman.File
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			users_id,
+			folder_id,
+			name,
+			type,
+			path,
+			checksum,
+			checksum_algo,
+			description,
+			size,
+			created_at,
+			updated_at,
+			removed_at
+		FROM files
+		WHERE id = ? AND removed_at = 0
+	`,
+		id,
+	).Scan(
+		&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.Type, &file.Path, &file.Checksum, &file.ChecksumAlgo, &file.Description, &file.Size,
+		&file.CreatedAt, &file.UpdatedAt, &file.RemovedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "File not found.")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// findAllFiles retrieves all files that have not been removed regardless of
+// their owner. It must only be used after authorizing the current user.
+func findAllFiles(ctx context.Context, tx *Tx) ([]*gofman.File, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			users_id,
+			folder_id,
+			name,
+			type,
+			path,
+			checksum,
+			checksum_algo,
+			description,
+			size,
+			created_at,
+			updated_at,
+			removed_at
+		FROM files
+		WHERE removed_at = 0
+		ORDER BY created_at ASC
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var files []*gofman.File
+
+	for rows.Next() {
+		var file gofman.File
+
+		if err = rows.Scan(
+			&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.Type, &file.Path, &file.Checksum, &file.ChecksumAlgo, &file.Description, &file.Size,
+			&file.CreatedAt, &file.UpdatedAt, &file.RemovedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		files = append(files, &file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// resolvePath returns the path of a file stored in the database. Relative
+// paths are resolved against the storage root. Without a storage root paths
+// are returned unchanged.
+func (db *DB) resolvePath(path string) (string, error) {
+	if db.StorageRoot == "" || filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	if db.PathTraversalService == nil {
+		return path, gofman.NewError(gofman.EINVALID, "PathTraversalService required.")
+	}
+
+	return db.PathTraversalService.Resolve(db.StorageRoot, path)
+}
+
+// storageRoot returns the tilde expanded storage root, or an empty string if
+// no storage root is set.
+func (db *DB) storageRoot() (string, error) {
+	if db.StorageRoot == "" {
+		return "", nil
+	}
+
+	if db.PathTraversalService == nil {
+		return "", gofman.NewError(gofman.EINVALID, "PathTraversalService required.")
+	}
+
+	return db.PathTraversalService.Expand(db.StorageRoot)
+}
+
+// relativePath returns the path relative to the storage root if relative
+// paths are enabled and the path is below the root. Otherwise the path is
+// returned unchanged.
+func (db *DB) relativePath(path string) (string, error) {
+	if !db.RelativePaths || db.StorageRoot == "" || !filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	root, err := db.storageRoot()
+	if err != nil {
+		return path, err
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path, nil
+	}
+
+	return rel, nil
+}
+
+// migrateRelativePaths converts the absolute paths of all files below the
+// storage root to paths relative to it.
+func (db *DB) migrateRelativePaths() error {
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(db.ctx, `SELECT id, path FROM files`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	paths := make(map[string]string)
+
+	for rows.Next() {
+		var id, path string
+
+		if err := rows.Scan(&id, &path); err != nil {
+			return err
+		}
+
+		if rel, err := db.relativePath(path); err != nil {
+			return err
+		} else if rel != path {
+			paths[id] = rel
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows.Close()
+
+	for id, path := range paths {
+		if _, err := tx.ExecContext(db.ctx, `UPDATE files SET path = ? WHERE id = ?`, path, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,289 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Ensure service implements interface.
+var _ gofman.FileTagService = (*FileTagService)(nil)
+
+// FileTagService represents a service for attaching tags to files.
+type FileTagService struct {
+	db *DB
+}
+
+// NewFileTagService returns a new instance of FileTagService.
+func NewFileTagService(db *DB) *FileTagService {
+	return &FileTagService{db: db}
+}
+
+// AddTag attaches the tag to the file.
+// Returns ECONFLICT if the tag is already attached to the file.
+// Returns EUNAUTHORIZED if current user cannot update the file or the tag.
+// Returns ENOTFOUND if the file or tag does not exist.
+func (s *FileTagService) AddTag(ctx context.Context, fileID, tagID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := addFileTag(ctx, tx, fileID, tagID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveTag detaches the tag from the file.
+// Returns EUNAUTHORIZED if current user cannot update the file or the tag.
+// Returns ENOTFOUND if the file or tag does not exist or the tag is not
+// attached to the file.
+func (s *FileTagService) RemoveTag(ctx context.Context, fileID, tagID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := removeFileTag(ctx, tx, fileID, tagID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TagsForFile retrieves the tags attached to the file, oldest first.
+// Returns ENOTFOUND if the file does not exist.
+func (s *FileTagService) TagsForFile(ctx context.Context, fileID string) ([]*gofman.Tag, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	tags, err := findTagsForFile(ctx, tx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// FilesForTag retrieves the files the tag is attached to, oldest first.
+// Returns ENOTFOUND if the tag does not exist.
+func (s *FileTagService) FilesForTag(ctx context.Context, tagID string) ([]*gofman.File, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	files, err := findFilesForTag(ctx, tx, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// findFileAndTag retrieves the file and the tag of the current user and
+// checks that the current user may change their associations.
+func findFileAndTag(ctx context.Context, tx *Tx, fileID, tagID string) (*gofman.File, *gofman.Tag, error) {
+	file, err := findFileByID(ctx, tx, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tag, err := findTagByID(ctx, tx, tagID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if gofman.CanUpdateFile(ctx, file) == false || gofman.CanUpdateTag(ctx, tag) == false {
+		return nil, nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to change the tags of this file.")
+	}
+
+	return file, tag, nil
+}
+
+// addFileTag attaches the tag to the file.
+// Returns ECONFLICT if the tag is already attached to the file.
+func addFileTag(ctx context.Context, tx *Tx, fileID, tagID string) error {
+	file, tag, err := findFileAndTag(ctx, tx, fileID, tagID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO files_tags (
+			files_id,
+			tags_id
+		)
+		VALUES (?, ?)
+		ON CONFLICT DO NOTHING
+	`,
+		file.ID,
+		tag.ID,
+	)
+
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return gofman.NewError(gofman.ECONFLICT, "Tag is already attached to this file.")
+	}
+
+	return nil
+}
+
+// removeFileTag detaches the tag from the file.
+// Returns ENOTFOUND if the tag is not attached to the file.
+func removeFileTag(ctx context.Context, tx *Tx, fileID, tagID string) error {
+	file, tag, err := findFileAndTag(ctx, tx, fileID, tagID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM files_tags
+		WHERE files_id = ? AND tags_id = ?
+	`,
+		file.ID,
+		tag.ID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return gofman.NewError(gofman.ENOTFOUND, "Tag is not attached to this file.")
+	}
+
+	return nil
+}
+
+// findTagsForFile retrieves the tags attached to a file of the current user.
+// Removed tags are skipped.
+// Returns ENOTFOUND if the file does not exist.
+func findTagsForFile(ctx context.Context, tx *Tx, fileID string) ([]*gofman.Tag, error) {
+	file, err := findFileByID(ctx, tx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			t.id,
+			t.users_id,
+			t.name,
+			t.created_at,
+			t.updated_at,
+			t.removed_at
+		FROM tags t
+		INNER JOIN files_tags ft ON ft.tags_id = t.id
+		WHERE ft.files_id = ? AND t.removed_at = 0
+		ORDER BY t.created_at ASC, t.id ASC
+	`,
+		file.ID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tags []*gofman.Tag
+
+	for rows.Next() {
+		var tag gofman.Tag
+
+		if err = rows.Scan(
+			&tag.ID, &tag.UserID, &tag.Name,
+			&tag.CreatedAt, &tag.UpdatedAt, &tag.RemovedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, &tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// findFilesForTag retrieves the files a tag of the current user is attached
+// to. Removed files are skipped.
+// Returns ENOTFOUND if the tag does not exist.
+func findFilesForTag(ctx context.Context, tx *Tx, tagID string) ([]*gofman.File, error) {
+	tag, err := findTagByID(ctx, tx, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			f.id,
+			f.users_id,
+			f.folder_id,
+			f.name,
+			f.type,
+			f.path,
+			f.checksum,
+			f.checksum_algo,
+			f.description,
+			f.size,
+			f.created_at,
+			f.updated_at,
+			f.removed_at
+		FROM files f
+		INNER JOIN files_tags ft ON ft.files_id = f.id
+		WHERE ft.tags_id = ? AND f.removed_at = 0
+		ORDER BY f.created_at ASC, f.id ASC
+	`,
+		tag.ID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var files []*gofman.File
+
+	for rows.Next() {
+		var file gofman.File
+
+		if err = rows.Scan(
+			&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.Type, &file.Path, &file.Checksum, &file.ChecksumAlgo, &file.Description, &file.Size,
+			&file.CreatedAt, &file.UpdatedAt, &file.RemovedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		files = append(files, &file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
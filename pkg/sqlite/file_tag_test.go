@@ -0,0 +1,88 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestFileTagService(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg"})
+	tag0 := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "a"})
+	tag1 := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "b"})
+	otherTag := MustCreateTag(t, otherCtx, db, &gofman.Tag{UserID: other.ID, Name: "a"})
+
+	s := sqlite.NewFileTagService(db)
+
+	t.Run("Add", func(t *testing.T) {
+		if err := s.AddTag(ctx, file.ID, tag0.ID); err != nil {
+			t.Fatal(err)
+		} else if err := s.AddTag(ctx, file.ID, tag1.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if tags, err := s.TagsForFile(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		} else if len(tags) != 2 || tags[0].ID != tag0.ID || tags[1].ID != tag1.ID {
+			t.Fatalf("Unexpected tags: %#v", tags)
+		}
+
+		if files, err := s.FilesForTag(ctx, tag0.ID); err != nil {
+			t.Fatal(err)
+		} else if len(files) != 1 || files[0].ID != file.ID {
+			t.Fatalf("Unexpected files: %#v", files)
+		}
+	})
+
+	t.Run("ErrConflict", func(t *testing.T) {
+		if err := s.AddTag(ctx, file.ID, tag0.ID); gofman.ErrorCode(err) != gofman.ECONFLICT {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if err := s.AddTag(ctx, file.ID, otherTag.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if err := s.AddTag(otherCtx, file.ID, otherTag.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if _, err := s.TagsForFile(otherCtx, file.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if _, err := s.FilesForTag(ctx, otherTag.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := s.RemoveTag(ctx, file.ID, tag0.ID); err != nil {
+			t.Fatal(err)
+		} else if err := s.RemoveTag(ctx, file.ID, tag0.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		if tags, err := s.TagsForFile(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		} else if len(tags) != 1 || tags[0].ID != tag1.ID {
+			t.Fatalf("Unexpected tags: %#v", tags)
+		}
+	})
+
+	t.Run("SkipRemovedTags", func(t *testing.T) {
+		if err := sqlite.NewTagService(db).RemoveTag(ctx, tag1.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if tags, err := s.TagsForFile(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		} else if len(tags) != 0 {
+			t.Fatalf("Unexpected tags: %#v", tags)
+		}
+	})
+}
@@ -0,0 +1,1113 @@
+package sqlite_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestFileService_GroupFilesByTag(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+	user, ctx := MustCreateUser(t, ctx, db, &gofman.User{Username: "jane", Password: "password"})
+	_, ctx2 := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	file0 := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg"})
+	file1 := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "b.jpg"})
+	tag0 := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "red"})
+	tag1 := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "blue"})
+	MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "empty"})
+
+	MustExec(t, db, `INSERT INTO files_tags (files_id, tags_id) VALUES (?, ?)`, file0.ID, tag0.ID)
+	MustExec(t, db, `INSERT INTO files_tags (files_id, tags_id) VALUES (?, ?)`, file0.ID, tag1.ID)
+	MustExec(t, db, `INSERT INTO files_tags (files_id, tags_id) VALUES (?, ?)`, file1.ID, tag1.ID)
+
+	s := sqlite.NewFileService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		groups, err := s.GroupFilesByTag(ctx, gofman.FileGroupFilter{UserID: user.ID})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(groups) != 2 {
+			t.Fatalf("Expected two groups, got %d", len(groups))
+		}
+
+		if g := groups[0]; g.Tag.ID != tag0.ID || len(g.Files) != 1 || g.Files[0].ID != file0.ID {
+			t.Fatalf("Unexpected first group: %#v", g)
+		}
+
+		if g := groups[1]; g.Tag.ID != tag1.ID || len(g.Files) != 2 || g.Files[0].ID != file0.ID || g.Files[1].ID != file1.ID {
+			t.Fatalf("Unexpected second group: %#v", g)
+		}
+	})
+
+	t.Run("IncludeEmpty", func(t *testing.T) {
+		groups, err := s.GroupFilesByTag(ctx, gofman.FileGroupFilter{UserID: user.ID, IncludeEmpty: true})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(groups) != 3 {
+			t.Fatalf("Expected three groups, got %d", len(groups))
+		} else if len(groups[2].Files) != 0 {
+			t.Fatal("Expected last group to be empty.")
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, err := s.GroupFilesByTag(ctx2, gofman.FileGroupFilter{UserID: user.ID}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestFileService_GroupFilesByActor(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+	user, ctx := MustCreateUser(t, ctx, db, &gofman.User{Username: "jane", Password: "password"})
+
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg"})
+	actor := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: user.ID, Name: "jane"})
+
+	MustExec(t, db, `INSERT INTO files_actors (files_id, actors_id) VALUES (?, ?)`, file.ID, actor.ID)
+
+	groups, err := sqlite.NewFileService(db).GroupFilesByActor(ctx, gofman.FileGroupFilter{UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(groups) != 1 {
+		t.Fatalf("Expected one group, got %d", len(groups))
+	} else if g := groups[0]; g.Actor.ID != actor.ID || len(g.Files) != 1 || g.Files[0].ID != file.ID {
+		t.Fatalf("Unexpected group: %#v", g)
+	}
+}
+
+func TestFileService_Reconcile(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	root := t.TempDir()
+	tracked := filepath.Join(root, "tracked.txt")
+	untracked := filepath.Join(root, "untracked.txt")
+
+	for _, path := range []string{tracked, untracked} {
+		if err := ioutil.WriteFile(path, []byte("content"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	user, ctx := MustCreateUser(t, ctx, db, &gofman.User{Username: "jane", Password: "password"})
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{ID: "admin", IsAdmin: true})
+
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "tracked.txt", Path: tracked})
+	orphaned := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "missing.txt", Path: filepath.Join(root, "missing.txt")})
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "outside.txt", Path: "/elsewhere/outside.txt"})
+
+	s := sqlite.NewFileService(db)
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, err := s.Reconcile(ctx, gofman.FileReconcileOptions{UserID: user.ID, Root: root}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		report, err := s.Reconcile(admin, gofman.FileReconcileOptions{UserID: user.ID, Root: root})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(report.Orphaned) != 1 || report.Orphaned[0].ID != orphaned.ID {
+			t.Fatalf("Unexpected orphaned files: %#v", report.Orphaned)
+		} else if len(report.Untracked) != 1 || report.Untracked[0].Path != untracked {
+			t.Fatalf("Unexpected untracked files: %#v", report.Untracked)
+		} else if report.Removed != 0 || report.Imported != 0 {
+			t.Fatal("Did not expect any changes.")
+		}
+	})
+
+	t.Run("Fix", func(t *testing.T) {
+		report, err := s.Reconcile(admin, gofman.FileReconcileOptions{
+			UserID:          user.ID,
+			Root:            root,
+			RemoveOrphaned:  true,
+			ImportUntracked: true,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		} else if report.Removed != 1 || report.Imported != 1 {
+			t.Fatalf("Unexpected changes: removed=%d imported=%d", report.Removed, report.Imported)
+		}
+
+		if file := report.Untracked[0]; file.ID == "" || file.UserID != user.ID || file.Type != "text/plain" {
+			t.Fatalf("Unexpected imported file: %#v", file)
+		}
+
+		report, err = s.Reconcile(admin, gofman.FileReconcileOptions{UserID: user.ID, Root: root})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(report.Orphaned) != 0 || len(report.Untracked) != 0 {
+			t.Fatalf("Expected no differences, got %#v", report)
+		}
+	})
+}
+
+func TestFileService_UpdateFile(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg", Description: "Beach"})
+
+	s := sqlite.NewFileService(db)
+
+	decode := func(t *testing.T, data string) gofman.FileUpdate {
+		var update gofman.FileUpdate
+		if err := json.Unmarshal([]byte(data), &update); err != nil {
+			t.Fatal(err)
+		}
+		return update
+	}
+
+	t.Run("Absent", func(t *testing.T) {
+		if updated, err := s.UpdateFile(ctx, file.ID, decode(t, `{"name":"b.jpg"}`)); err != nil {
+			t.Fatal(err)
+		} else if updated.Name != "b.jpg" || updated.Description != "Beach" {
+			t.Fatalf("Unexpected file: %#v", updated)
+		}
+	})
+
+	t.Run("Null", func(t *testing.T) {
+		if updated, err := s.UpdateFile(ctx, file.ID, decode(t, `{"description":null}`)); err != nil {
+			t.Fatal(err)
+		} else if updated.Description != "Beach" {
+			t.Fatalf("Unexpected description: %q", updated.Description)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if updated, err := s.UpdateFile(ctx, file.ID, decode(t, `{"description":""}`)); err != nil {
+			t.Fatal(err)
+		} else if updated.Description != "" {
+			t.Fatalf("Unexpected description: %q", updated.Description)
+		}
+
+		if got := MustQueryString(t, db, `SELECT description FROM files WHERE id = ?`, file.ID); got != "" {
+			t.Fatalf("Unexpected stored description: %q", got)
+		}
+	})
+
+	t.Run("ErrEmptyName", func(t *testing.T) {
+		if _, err := s.UpdateFile(ctx, file.ID, decode(t, `{"name":""}`)); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		if updated, err := s.UpdateFile(ctx, file.ID, decode(t, `{"size":42}`)); err != nil {
+			t.Fatal(err)
+		} else if updated.Size != 42 {
+			t.Fatalf("Unexpected size: %d", updated.Size)
+		} else if got := MustQueryString(t, db, `SELECT size FROM files WHERE id = ?`, file.ID); got != "42" {
+			t.Fatalf("Unexpected stored size: %s", got)
+		}
+	})
+
+	t.Run("ErrNegativeSize", func(t *testing.T) {
+		if _, err := s.UpdateFile(ctx, file.ID, decode(t, `{"size":-1}`)); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		_, other := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+		if _, err := s.UpdateFile(other, file.ID, decode(t, `{"description":""}`)); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestFileService_NoPathTraversalService(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	// The service is only required for reconciling and recomputing checksums.
+	db.PathTraversalService = nil
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg"})
+
+	s := sqlite.NewFileService(db)
+	name := "b.jpg"
+
+	if updated, err := s.UpdateFile(ctx, file.ID, gofman.FileUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	} else if updated.Name != "b.jpg" || updated.Path != "/data/a.jpg" {
+		t.Fatalf("Unexpected file: %#v", updated)
+	}
+
+	if _, err := s.RecomputeChecksum(ctx, file.ID); gofman.ErrorCode(err) != gofman.EINVALID {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+
+	if err := s.RemoveFile(ctx, file.ID); err != nil {
+		t.Fatal(err)
+	} else if result, err := sqlite.NewTrashService(db).EmptyTrash(ctx, false); err != nil {
+		t.Fatal(err)
+	} else if result.Files != 1 || result.DiskFiles != 0 {
+		t.Fatalf("Unexpected result: %#v", result)
+	}
+}
+
+// MustCreateFile creates a file in the database. Missing required fields are
+// filled with defaults. Fatal on error.
+func MustCreateFile(tb testing.TB, ctx context.Context, db *sqlite.DB, file *gofman.File) *gofman.File {
+	tb.Helper()
+
+	if file.Type == "" {
+		file.Type = "image/jpeg"
+	}
+
+	if file.Path == "" {
+		file.Path = "/data/" + file.Name
+	}
+
+	if file.Checksum == "" {
+		file.Checksum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	}
+
+	if err := sqlite.NewFileService(db).CreateFile(ctx, file); err != nil {
+		tb.Fatal(err)
+	}
+
+	return file
+}
+
+func TestFileService_RecomputeChecksum(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, other := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.txt", Path: path})
+
+	s := sqlite.NewFileService(db)
+
+	sum := sha256.Sum256([]byte("modified"))
+	checksum := hex.EncodeToString(sum[:])
+
+	t.Run("OK", func(t *testing.T) {
+		if err := ioutil.WriteFile(path, []byte("modified"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if updated, err := s.RecomputeChecksum(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		} else if updated.Checksum != checksum {
+			t.Fatalf("Unexpected checksum: %s", updated.Checksum)
+		}
+	})
+
+	t.Run("MD5", func(t *testing.T) {
+		md5Path := filepath.Join(t.TempDir(), "b.txt")
+		if err := ioutil.WriteFile(md5Path, []byte("modified"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		md5File := MustCreateFile(t, ctx, db, &gofman.File{
+			UserID:       user.ID,
+			Name:         "b.txt",
+			Path:         md5Path,
+			Checksum:     "d41d8cd98f00b204e9800998ecf8427e",
+			ChecksumAlgo: gofman.ChecksumMD5,
+		})
+
+		sum := md5.Sum([]byte("modified"))
+
+		if updated, err := s.RecomputeChecksum(ctx, md5File.ID); err != nil {
+			t.Fatal(err)
+		} else if updated.Checksum != hex.EncodeToString(sum[:]) || updated.ChecksumAlgo != gofman.ChecksumMD5 {
+			t.Fatalf("Unexpected checksum: %s %s", updated.ChecksumAlgo, updated.Checksum)
+		} else if algo := MustQueryString(t, db, `SELECT checksum_algo FROM files WHERE id = ?`, md5File.ID); algo != gofman.ChecksumMD5 {
+			t.Fatalf("Unexpected stored algorithm: %q", algo)
+		}
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		admin := gofman.NewContextWithUser(context.Background(), &gofman.User{ID: "admin", IsAdmin: true})
+
+		if updated, err := s.RecomputeChecksum(admin, file.ID); err != nil {
+			t.Fatal(err)
+		} else if updated.Checksum != checksum {
+			t.Fatalf("Unexpected checksum: %s", updated.Checksum)
+		}
+	})
+
+	t.Run("ErrOtherUser", func(t *testing.T) {
+		if _, err := s.RecomputeChecksum(other, file.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrMissingOnDisk", func(t *testing.T) {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.RecomputeChecksum(ctx, file.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		// The orphaned row shows the checksum that was stored before.
+		admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+		report, err := s.Reconcile(admin, gofman.FileReconcileOptions{UserID: user.ID, Root: filepath.Dir(path)})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(report.Orphaned) != 1 || report.Orphaned[0].Checksum != checksum {
+			t.Fatalf("Unexpected orphaned files: %#v", report.Orphaned)
+		}
+	})
+}
+
+func TestFileService_RelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "a")
+
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(filepath.Join(root, "x.jpg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("CreateFile", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		db.StorageRoot = root
+		db.RelativePaths = true
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+		file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "x.jpg", Path: filepath.Join(root, "x.jpg")})
+		if file.Path != "x.jpg" {
+			t.Fatalf("Unexpected path: %q", file.Path)
+		}
+
+		// Stored relative paths are validated as absolute paths on update.
+		name := "y.jpg"
+		if other, err := sqlite.NewFileService(db).UpdateFile(ctx, file.ID, gofman.FileUpdate{Name: &name}); err != nil {
+			t.Fatal(err)
+		} else if other.Path != "x.jpg" {
+			t.Fatalf("Unexpected path: %q", other.Path)
+		}
+
+		traversal := filepath.Join(root, "x.jpg") + "/../../x.jpg"
+		if _, err := sqlite.NewFileService(db).UpdateFile(ctx, file.ID, gofman.FileUpdate{Path: &traversal}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		// Move the storage root, the file must still be found on disk.
+		moved := filepath.Join(dir, "b")
+		if err := os.Rename(root, moved); err != nil {
+			t.Fatal(err)
+		}
+
+		defer os.Rename(moved, root)
+
+		db.StorageRoot = moved
+
+		if _, err := sqlite.NewFileService(db).RecomputeChecksum(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		db := MustOpenDB(t)
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+		file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "x.jpg", Path: filepath.Join(root, "x.jpg")})
+		MustCloseDB(t, db)
+
+		reopened := sqlite.NewDB()
+		reopened.DSN = db.DSN
+		reopened.AuthService = db.AuthService
+		reopened.PathTraversalService = db.PathTraversalService
+		reopened.StorageRoot = root
+		reopened.RelativePaths = true
+
+		if err := reopened.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		defer MustCloseDB(t, reopened)
+
+		moved := filepath.Join(dir, "b")
+		if err := os.Rename(root, moved); err != nil {
+			t.Fatal(err)
+		}
+
+		defer os.Rename(moved, root)
+
+		reopened.StorageRoot = moved
+
+		if _, err := sqlite.NewFileService(reopened).RecomputeChecksum(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFileService_Exists(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, ctx2 := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg"})
+	removed := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "removed"})
+	MustExec(t, db, `UPDATE files SET removed_at = 1 WHERE id = ?`, removed.ID)
+
+	s := sqlite.NewFileService(db)
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		id   string
+		want bool
+	}{
+		{name: "OK", ctx: ctx, id: file.ID, want: true},
+		{name: "NotFound", ctx: ctx, id: "00000000-0000-0000-0000-000000000000"},
+		{name: "Removed", ctx: ctx, id: removed.ID},
+		{name: "OtherUser", ctx: ctx2, id: file.ID},
+		{name: "Unauthenticated", ctx: context.Background(), id: file.ID},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := s.Exists(tt.ctx, tt.id); err != nil {
+				t.Fatal(err)
+			} else if ok != tt.want {
+				t.Fatalf("Unexpected result: %v", ok)
+			}
+		})
+	}
+}
+
+func TestFileService_FindFiles(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	file0 := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg", Size: 100})
+	file1 := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "b.jpg", Size: 2000})
+	MustCreateFile(t, otherCtx, db, &gofman.File{UserID: other.ID, Name: "c.jpg"})
+
+	s := sqlite.NewFileService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		if files, n, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID}); err != nil {
+			t.Fatal(err)
+		} else if n != 2 || len(files) != 2 {
+			t.Fatalf("Unexpected files: n=%d len=%d", n, len(files))
+		} else if files[0].ID != file0.ID || files[1].ID != file1.ID {
+			t.Fatalf("Unexpected files: %#v", files)
+		}
+	})
+
+	t.Run("MinSize", func(t *testing.T) {
+		minSize := int64(1000)
+		if files, n, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, MinSize: &minSize}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 || len(files) != 1 || files[0].ID != file1.ID {
+			t.Fatalf("Unexpected files: %#v", files)
+		}
+	})
+
+	t.Run("ChecksumAlgo", func(t *testing.T) {
+		if file0.ChecksumAlgo != gofman.ChecksumSHA256 {
+			t.Fatalf("Unexpected default algorithm: %q", file0.ChecksumAlgo)
+		}
+
+		checksum, algo := "d41d8cd98f00b204e9800998ecf8427e", gofman.ChecksumMD5
+		if _, err := s.UpdateFile(ctx, file0.ID, gofman.FileUpdate{Checksum: &checksum, ChecksumAlgo: &algo}); err != nil {
+			t.Fatal(err)
+		} else if file, err := s.FindFileByID(ctx, file0.ID); err != nil {
+			t.Fatal(err)
+		} else if file.Checksum != checksum || file.ChecksumAlgo != algo {
+			t.Fatalf("Unexpected file: %#v", file)
+		}
+
+		// Changing the algorithm alone leaves a checksum of the wrong length.
+		sha1 := gofman.ChecksumSHA1
+		if _, err := s.UpdateFile(ctx, file0.ID, gofman.FileUpdate{ChecksumAlgo: &sha1}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		if files, _, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, SortBy: "size", SortDesc: true}); err != nil {
+			t.Fatal(err)
+		} else if len(files) != 2 || files[0].ID != file1.ID || files[1].ID != file0.ID {
+			t.Fatalf("Unexpected files: %#v", files)
+		}
+	})
+
+	t.Run("ErrSortBy", func(t *testing.T) {
+		if _, _, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, SortBy: "path; DROP TABLE files"}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ByID", func(t *testing.T) {
+		if file, err := s.FindFileByID(ctx, file1.ID); err != nil {
+			t.Fatal(err)
+		} else if file.ID != file1.ID || file.Size != 2000 {
+			t.Fatalf("Unexpected file: %#v", file)
+		}
+	})
+
+	t.Run("NameLike", func(t *testing.T) {
+		MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "100%"})
+		MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "1000"})
+		MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "x_y"})
+		MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "xzy"})
+
+		for _, tt := range []struct {
+			like string
+			want []string
+		}{
+			{like: "0%", want: []string{"100%"}},
+			{like: "_", want: []string{"x_y"}},
+			{like: "X", want: []string{"x_y", "xzy"}},
+			{like: "none", want: nil},
+		} {
+			files, _, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, NameLike: &tt.like})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, file := range files {
+				names = append(names, file.Name)
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("Unexpected names for %q: %v", tt.like, names)
+			}
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, _, err := s.FindFiles(otherCtx, gofman.FileFilter{UserID: &user.ID}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if _, _, err := s.FindFiles(ctx, gofman.FileFilter{}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if _, err := s.FindFileByID(otherCtx, file0.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestFileService_AllowedTypes(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	s := sqlite.NewFileService(db)
+
+	newFile := func(name, typ string) *gofman.File {
+		return &gofman.File{UserID: user.ID, Name: name, Type: typ, Path: "/data/" + name, Checksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}
+	}
+
+	t.Run("Accepted", func(t *testing.T) {
+		if err := s.CreateFile(ctx, newFile("a.pdf", "application/pdf")); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrRejected", func(t *testing.T) {
+		if err := s.CreateFile(ctx, newFile("a.exe", "application/x-msdownload")); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "b.jpg"})
+		typ := "application/x-msdownload"
+		if _, err := s.UpdateFile(ctx, file.ID, gofman.FileUpdate{Type: &typ}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("AllowAll", func(t *testing.T) {
+		db.FileConfig = gofman.FileConfig{AllowedTypes: []string{"*/*"}}
+		defer func() { db.FileConfig = gofman.DefaultFileConfig() }()
+
+		if err := s.CreateFile(ctx, newFile("b.exe", "application/x-msdownload")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFileService_FindFiles_CreatedRange(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	for i, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		createdAt := int64(100 * (i + 1))
+		db.Now = func() int64 { return createdAt }
+
+		MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: name})
+	}
+
+	s := sqlite.NewFileService(db)
+
+	ts := func(v int64) *int64 { return &v }
+
+	for _, tt := range []struct {
+		name          string
+		after, before *int64
+		want          []string
+	}{
+		{name: "Unbounded", want: []string{"a.jpg", "b.jpg", "c.jpg"}},
+		{name: "After", after: ts(200), want: []string{"b.jpg", "c.jpg"}},
+		{name: "Before", before: ts(200), want: []string{"a.jpg", "b.jpg"}},
+		{name: "Between", after: ts(150), before: ts(250), want: []string{"b.jpg"}},
+		{name: "Empty", after: ts(250), before: ts(150), want: nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			files, n, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, CreatedAfter: tt.after, CreatedBefore: tt.before})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, file := range files {
+				names = append(names, file.Name)
+			}
+
+			if n != len(tt.want) || !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("Unexpected files: %v", names)
+			}
+		})
+	}
+}
+
+func TestFileService_FindFileByChecksum(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	checksum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	a := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg", Checksum: checksum})
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "b.jpg", Checksum: checksum})
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "c.jpg", Checksum: strings.Repeat("0", 64)})
+	MustCreateFile(t, otherCtx, db, &gofman.File{UserID: other.ID, Name: "d.jpg", Checksum: checksum})
+
+	s := sqlite.NewFileService(db)
+
+	t.Run("FindFiles", func(t *testing.T) {
+		files, n, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, Checksum: &checksum})
+		if err != nil {
+			t.Fatal(err)
+		} else if n != 2 || len(files) != 2 {
+			t.Fatalf("Unexpected files: %d", n)
+		} else if files[0].Name != "a.jpg" || files[1].Name != "b.jpg" {
+			t.Fatalf("Unexpected files: %q, %q", files[0].Name, files[1].Name)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		// The oldest file is returned.
+		if file, err := s.FindFileByChecksum(ctx, user.ID, checksum); err != nil {
+			t.Fatal(err)
+		} else if file.ID != a.ID {
+			t.Fatalf("Unexpected file: %q", file.Name)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if _, err := s.FindFileByChecksum(ctx, user.ID, strings.Repeat("1", 64)); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, err := s.FindFileByChecksum(otherCtx, user.ID, checksum); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestFileService_CreateFiles(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	s := sqlite.NewFileService(db)
+
+	checksum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	t.Run("OK", func(t *testing.T) {
+		files := []*gofman.File{
+			{UserID: user.ID, Name: "a.jpg", Type: "image/jpeg", Path: "/data/a.jpg", Checksum: checksum},
+			{UserID: user.ID, Name: "b.jpg", Type: "image/jpeg", Path: "/data/b.jpg", Checksum: checksum},
+		}
+
+		if err := s.CreateFiles(ctx, files); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, file := range files {
+			if file.ID == "" {
+				t.Fatalf("Expected ID for %q", file.Name)
+			} else if _, err := s.FindFileByID(ctx, file.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("ErrInvalid", func(t *testing.T) {
+		files := []*gofman.File{
+			{UserID: user.ID, Name: "c.jpg", Type: "image/jpeg", Path: "/data/c.jpg", Checksum: checksum},
+			{UserID: user.ID, Name: "d.jpg", Type: "image/jpeg", Path: "data/d.jpg", Checksum: checksum},
+			{UserID: user.ID, Name: "e.jpg", Type: "image/jpeg", Path: "/data/e.jpg", Checksum: checksum},
+		}
+
+		if err := s.CreateFiles(ctx, files); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if gofman.ErrorMessage(err) != "File 1: Path must be absolute." {
+			t.Fatalf("Unexpected message: %q", gofman.ErrorMessage(err))
+		}
+
+		// No file of the batch was created.
+		if _, n, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID}); err != nil {
+			t.Fatal(err)
+		} else if n != 2 {
+			t.Fatalf("Unexpected number of files: %d", n)
+		}
+	})
+}
+
+func BenchmarkFileService_CreateFile(b *testing.B) {
+	db := MustOpenDB(b)
+	defer MustCloseDB(b, db)
+
+	user, ctx := MustCreateUser(b, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	s := sqlite.NewFileService(db)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := s.CreateFile(ctx, benchmarkFile(user.ID, i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFileService_CreateFiles(b *testing.B) {
+	db := MustOpenDB(b)
+	defer MustCloseDB(b, db)
+
+	user, ctx := MustCreateUser(b, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	s := sqlite.NewFileService(db)
+
+	files := make([]*gofman.File, b.N)
+	for i := range files {
+		files[i] = benchmarkFile(user.ID, i)
+	}
+
+	b.ResetTimer()
+
+	if err := s.CreateFiles(ctx, files); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// benchmarkFile returns a valid file for the benchmarks.
+func benchmarkFile(userID string, i int) *gofman.File {
+	name := fmt.Sprintf("%d.jpg", i)
+
+	return &gofman.File{
+		UserID:   userID,
+		Name:     name,
+		Type:     "image/jpeg",
+		Path:     "/data/" + name,
+		Checksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+}
+
+func TestFileService_SearchFiles(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	s := sqlite.NewFileService(db)
+
+	if _, _, err := s.SearchFiles(ctx, "holiday", gofman.FileFilter{UserID: &user.ID}); gofman.ErrorCode(err) == gofman.ENOTIMPLEMENTED {
+		t.Skip("SQLite was built without FTS5, run the tests with -tags sqlite_fts5.")
+	}
+
+	beach := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "holiday beach.jpg"})
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "holiday holiday mountains.jpg"})
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "birthday.jpg"})
+	MustCreateFile(t, otherCtx, db, &gofman.File{UserID: other.ID, Name: "holiday.jpg"})
+
+	search := func(t *testing.T, query string, filter gofman.FileFilter) []string {
+		t.Helper()
+
+		files, n, err := s.SearchFiles(ctx, query, filter)
+		if err != nil {
+			t.Fatal(err)
+		} else if n != len(files) {
+			t.Fatalf("Unexpected total: %d", n)
+		}
+
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name)
+		}
+
+		return names
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		// Names mentioning the word more often rank higher.
+		if names := search(t, "holiday", gofman.FileFilter{UserID: &user.ID}); !reflect.DeepEqual(names, []string{"holiday holiday mountains.jpg", "holiday beach.jpg"}) {
+			t.Fatalf("Unexpected files: %v", names)
+		}
+
+		if names := search(t, "HOLI bea", gofman.FileFilter{UserID: &user.ID}); !reflect.DeepEqual(names, []string{"holiday beach.jpg"}) {
+			t.Fatalf("Unexpected files: %v", names)
+		}
+
+		if names := search(t, `"x`, gofman.FileFilter{UserID: &user.ID}); names != nil {
+			t.Fatalf("Unexpected files: %v", names)
+		}
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		if names := search(t, "holiday", gofman.FileFilter{UserID: &user.ID, SortBy: "name"}); !reflect.DeepEqual(names, []string{"holiday beach.jpg", "holiday holiday mountains.jpg"}) {
+			t.Fatalf("Unexpected files: %v", names)
+		}
+	})
+
+	t.Run("Updated", func(t *testing.T) {
+		name := "sunset.jpg"
+		if _, err := s.UpdateFile(ctx, beach.ID, gofman.FileUpdate{Name: &name}); err != nil {
+			t.Fatal(err)
+		}
+
+		if names := search(t, "sunset", gofman.FileFilter{UserID: &user.ID}); !reflect.DeepEqual(names, []string{"sunset.jpg"}) {
+			t.Fatalf("Unexpected files: %v", names)
+		}
+
+		if err := s.RemoveFile(ctx, beach.ID); err != nil {
+			t.Fatal(err)
+		} else if names := search(t, "sunset", gofman.FileFilter{UserID: &user.ID}); names != nil {
+			t.Fatalf("Unexpected files: %v", names)
+		}
+	})
+
+	t.Run("ErrInvalid", func(t *testing.T) {
+		if _, _, err := s.SearchFiles(ctx, " ", gofman.FileFilter{UserID: &user.ID}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, _, err := s.SearchFiles(otherCtx, "holiday", gofman.FileFilter{UserID: &user.ID}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestDB_SearchIndex(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "holiday.jpg"})
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "beach.jpg"})
+
+	conn, err := sql.Open("sqlite3", db.DSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	// count returns the single integer selected by the query.
+	count := func(t *testing.T, query string) int {
+		t.Helper()
+
+		var n int
+		if err := conn.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+
+		return n
+	}
+
+	// reopen opens the database again, which runs all migrations.
+	reopen := func(t *testing.T) {
+		t.Helper()
+
+		other := sqlite.NewDB()
+		other.DSN = db.DSN
+
+		if err := other.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		MustCloseDB(t, other)
+	}
+
+	s := sqlite.NewFileService(db)
+
+	if _, _, err := s.SearchFiles(ctx, "holiday", gofman.FileFilter{UserID: &user.ID}); gofman.ErrorCode(err) == gofman.ENOTIMPLEMENTED {
+		// Builds without FTS5 cannot update the index, so the next build
+		// with FTS5 has to rebuild it.
+		if _, err := conn.Exec(`INSERT INTO migrations (name) VALUES ('migration/fts5/00000000.sql')`); err != nil {
+			t.Fatal(err)
+		}
+
+		reopen(t)
+
+		if n := count(t, `SELECT COUNT(*) FROM migrations WHERE name LIKE 'migration/fts5/%'`); n != 0 {
+			t.Fatalf("Expected search migrations to be forgotten, got %d", n)
+		}
+
+		return
+	}
+
+	t.Run("Removed", func(t *testing.T) {
+		if err := s.RemoveFile(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		} else if n := count(t, `SELECT COUNT(*) FROM files_fts`); n != 1 {
+			t.Fatalf("Unexpected index size: %d", n)
+		}
+
+		if err := s.RestoreFile(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		} else if n := count(t, `SELECT COUNT(*) FROM files_fts WHERE files_fts MATCH 'holiday'`); n != 1 {
+			t.Fatalf("Unexpected matches: %d", n)
+		}
+	})
+
+	t.Run("Purged", func(t *testing.T) {
+		if err := s.RemoveFile(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		// Rows removed without updating the index are deleted on purge.
+		if _, err := conn.Exec(`INSERT INTO files_search (files_id) VALUES (?)`, file.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := sqlite.NewTrashService(db).EmptyTrash(ctx, false); err != nil {
+			t.Fatal(err)
+		} else if n := count(t, `SELECT COUNT(*) FROM files_search`); n != 1 {
+			t.Fatalf("Unexpected index size: %d", n)
+		}
+	})
+
+	t.Run("Reopened", func(t *testing.T) {
+		// The index is only built by the migration, not on every open.
+		if _, err := conn.Exec(`DELETE FROM files_fts`); err != nil {
+			t.Fatal(err)
+		}
+
+		reopen(t)
+
+		if n := count(t, `SELECT COUNT(*) FROM files_fts`); n != 0 {
+			t.Fatalf("Expected index not to be rebuilt, got %d rows", n)
+		}
+	})
+}
+
+func TestFileService_RestoreFile(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg"})
+
+	s := sqlite.NewFileService(db)
+
+	t.Run("ErrNotRemoved", func(t *testing.T) {
+		if err := s.RestoreFile(ctx, file.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	if err := s.RemoveFile(ctx, file.ID); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.FindFileByID(ctx, file.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+
+	t.Run("IncludeRemoved", func(t *testing.T) {
+		if files, _, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, IncludeRemoved: true}); err != nil {
+			t.Fatal(err)
+		} else if len(files) != 1 || files[0].RemovedAt == 0 {
+			t.Fatalf("Unexpected files: %#v", files)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		// Other users cannot see the file.
+		if err := s.RestoreFile(otherCtx, file.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if err := s.RestoreFile(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if other, err := s.FindFileByID(ctx, file.ID); err != nil {
+			t.Fatal(err)
+		} else if other.RemovedAt != 0 {
+			t.Fatalf("Unexpected removed at: %d", other.RemovedAt)
+		}
+	})
+}
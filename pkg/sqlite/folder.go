@@ -0,0 +1,392 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Ensure service implements interface.
+var _ gofman.FolderService = (*FolderService)(nil)
+
+// FolderService represents a service for managing folders.
+type FolderService struct {
+	db *DB
+}
+
+// NewFolderService returns a new instance of FolderService.
+func NewFolderService(db *DB) *FolderService {
+	return &FolderService{db: db}
+}
+
+// FindFolderByID retrieves a folder by ID.
+// Returns ENOTFOUND if folder does not exist.
+func (s *FolderService) FindFolderByID(ctx context.Context, id string) (*gofman.Folder, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	folder, err := findFolderByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// FindFolders retrieves folder objects and total hits based on a filter.
+// The total hits may differ from the length of the slice if a limit was
+// applied.
+func (s *FolderService) FindFolders(ctx context.Context, filter gofman.FolderFilter) ([]*gofman.Folder, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer tx.Rollback()
+
+	folders, total, err := findFolders(ctx, tx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return folders, total, nil
+}
+
+// CreateFolder creates a new folder.
+// Returns ENOTFOUND if the parent folder does not exist.
+func (s *FolderService) CreateFolder(ctx context.Context, folder *gofman.Folder) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := createFolder(ctx, tx, folder); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateFolder updates a folder object.
+// Returns EUNAUTHORIZED if current user is not the creator of the folder.
+// Returns ENOTFOUND if folder or the new parent does not exist.
+// Returns EINVALID if the folder would be moved into one of its subfolders.
+func (s *FolderService) UpdateFolder(ctx context.Context, id string, update gofman.FolderUpdate) (*gofman.Folder, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	folder, err := updateFolder(ctx, tx, id, update)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// RemoveFolder sets the removed timestamp to the current time. This allows
+// us to re-enable removed folder.
+// Returns EUNAUTHORIZED if current user is not the creator of the folder.
+// Returns ENOTFOUND if folder does not exist.
+// Returns ECONFLICT if the folder still contains folders or files.
+func (s *FolderService) RemoveFolder(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := removeFolder(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// findFolderByID is a helper function to fetch a folder by ID.
+// Only folders of the current user are found.
+// Returns ENOTFOUND if folder does not exist.
+func findFolderByID(ctx context.Context, tx *Tx, id string) (*gofman.Folder, error) {
+	userID := gofman.UserIDFromContext(ctx)
+
+	folders, _, err := findFolders(ctx, tx, gofman.FolderFilter{ID: &id, UserID: &userID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(folders) == 0 {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "Folder not found.")
+	}
+
+	return folders[0], nil
+}
+
+// findFolders retrieves folder objects and total hits based on a filter.
+// The total hits may differ from the length of the slice if a limit was
+// applied.
+func findFolders(ctx context.Context, tx *Tx, filter gofman.FolderFilter) ([]*gofman.Folder, int, error) {
+	if gofman.CanFindFolder(ctx, filter) == false {
+		return nil, 0, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to search using this filter.")
+	}
+
+	if err := checkOffset(filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
+	where, args := []string{"1 = 1"}, []interface{}{}
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+
+	if v := filter.UserID; v != nil {
+		where, args = append(where, "users_id = ?"), append(args, *v)
+	}
+
+	if v := filter.ParentID; v != nil {
+		where, args = append(where, "parent_id = ?"), append(args, *v)
+	} else if filter.Root {
+		where = append(where, "parent_id IS NULL")
+	}
+
+	where = append(where, "removed_at = 0")
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			users_id,
+			name,
+			parent_id,
+			created_at,
+			updated_at,
+			removed_at,
+			COUNT(*) OVER()
+		FROM folders
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY created_at ASC, id ASC
+		`+formatLimitOffset(clampLimit(filter.Limit, gofman.DefaultLimit, gofman.MaxLimit), filter.Offset),
+		args...,
+	)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var n int
+	var folders []*gofman.Folder
+
+	for rows.Next() {
+		var folder gofman.Folder
+
+		if err = rows.Scan(
+			&folder.ID, &folder.UserID, &folder.Name, &folder.ParentID,
+			&folder.CreatedAt, &folder.UpdatedAt, &folder.RemovedAt,
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		folders = append(folders, &folder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return folders, n, nil
+}
+
+// checkFolderParent returns ENOTFOUND if the parent of the folder does not
+// exist and EINVALID if the parent is the folder itself or one of its
+// subfolders.
+func checkFolderParent(ctx context.Context, tx *Tx, folder *gofman.Folder) error {
+	for parentID := folder.ParentID; parentID != nil; {
+		if *parentID == folder.ID {
+			return gofman.NewError(gofman.EINVALID, "Folder cannot be moved into itself or one of its subfolders.")
+		}
+
+		parent, err := findFolderByID(ctx, tx, *parentID)
+		if err != nil {
+			return err
+		}
+
+		parentID = parent.ParentID
+	}
+
+	return nil
+}
+
+// createFolder creates a new folder.
+func createFolder(ctx context.Context, tx *Tx, folder *gofman.Folder) error {
+	if err := folder.Validate(); err != nil {
+		return err
+	}
+
+	if gofman.CanUpdateFolder(ctx, folder) == false {
+		return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to create this folder.")
+	}
+
+	if id, err := tx.db.ID(); err != nil {
+		return err
+	} else {
+		folder.ID = id
+	}
+
+	if err := checkFolderParent(ctx, tx, folder); err != nil {
+		return err
+	}
+
+	folder.CreatedAt = tx.now
+	folder.UpdatedAt = folder.CreatedAt
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO folders (
+			id,
+			users_id,
+			name,
+			parent_id,
+			created_at,
+			updated_at,
+			removed_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		folder.ID,
+		folder.UserID,
+		folder.Name,
+		folder.ParentID,
+		folder.CreatedAt,
+		folder.UpdatedAt,
+		0,
+	)
+
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	return nil
+}
+
+// updateFolder updates a folder object.
+// Returns EUNAUTHORIZED if current user is not the creator of the folder.
+// Returns ENOTFOUND if folder or the new parent does not exist.
+func updateFolder(ctx context.Context, tx *Tx, id string, update gofman.FolderUpdate) (*gofman.Folder, error) {
+	folder, err := findFolderByID(ctx, tx, id)
+	if err != nil {
+		return folder, err
+	}
+
+	if gofman.CanUpdateFolder(ctx, folder) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to update this folder.")
+	}
+
+	if v := update.Name; v != nil {
+		folder.Name = *v
+	}
+
+	if v := update.ParentID; v != nil {
+		if *v == "" {
+			folder.ParentID = nil
+		} else {
+			parentID := *v
+			folder.ParentID = &parentID
+		}
+	}
+
+	folder.UpdatedAt = tx.now
+
+	if err := folder.Validate(); err != nil {
+		return folder, err
+	}
+
+	if update.ParentID != nil {
+		if err := checkFolderParent(ctx, tx, folder); err != nil {
+			return folder, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE folders
+		SET name = ?,
+			parent_id = ?,
+			updated_at = ?
+		WHERE id = ?
+	`,
+		folder.Name,
+		folder.ParentID,
+		folder.UpdatedAt,
+		id,
+	)
+
+	if err != nil {
+		return folder, mapSQLiteError(err)
+	}
+
+	return folder, nil
+}
+
+// removeFolder sets the removed timestamp to the current time. This allows
+// us to re-enable removed folder. Only empty folders can be removed so no
+// folder or file is left inside a removed folder.
+// Returns EUNAUTHORIZED if current user is not the creator of the folder.
+// Returns ENOTFOUND if folder does not exist.
+// Returns ECONFLICT if the folder still contains folders or files.
+func removeFolder(ctx context.Context, tx *Tx, id string) error {
+	folder, err := findFolderByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if gofman.CanUpdateFolder(ctx, folder) == false {
+		return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to remove this folder.")
+	}
+
+	var n int
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM folders WHERE parent_id = ? AND removed_at = 0) +
+			(SELECT COUNT(*) FROM files WHERE folder_id = ? AND removed_at = 0)
+	`,
+		id,
+		id,
+	).Scan(&n); err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return gofman.NewError(gofman.ECONFLICT, "Folder is not empty.")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE folders
+		SET removed_at = ?
+		WHERE id = ?
+	`,
+		tx.now,
+		id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
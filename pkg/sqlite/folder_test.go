@@ -0,0 +1,186 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestFolderService_CreateFolder(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+		parent := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "photos"})
+		child := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "2021", ParentID: &parent.ID})
+
+		s := sqlite.NewFolderService(db)
+
+		if other, err := s.FindFolderByID(ctx, child.ID); err != nil {
+			t.Fatal(err)
+		} else if other.ParentID == nil || *other.ParentID != parent.ID {
+			t.Fatalf("Unexpected folder: %#v", other)
+		}
+
+		if folders, n, err := s.FindFolders(ctx, gofman.FolderFilter{UserID: &user.ID, Root: true}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 || folders[0].ID != parent.ID {
+			t.Fatalf("Unexpected folders: %#v", folders)
+		}
+
+		if folders, n, err := s.FindFolders(ctx, gofman.FolderFilter{UserID: &user.ID, ParentID: &parent.ID}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 || folders[0].ID != child.ID {
+			t.Fatalf("Unexpected folders: %#v", folders)
+		}
+	})
+
+	t.Run("ErrParentNotFound", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+		other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+		otherFolder := MustCreateFolder(t, otherCtx, db, &gofman.Folder{UserID: other.ID, Name: "photos"})
+
+		s := sqlite.NewFolderService(db)
+
+		missing := "00000000-0000-0000-0000-000000000000"
+		if err := s.CreateFolder(ctx, &gofman.Folder{UserID: user.ID, Name: "2021", ParentID: &missing}); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if err := s.CreateFolder(ctx, &gofman.Folder{UserID: user.ID, Name: "2021", ParentID: &otherFolder.ID}); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNameRequired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+		if err := sqlite.NewFolderService(db).CreateFolder(ctx, &gofman.Folder{UserID: user.ID}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestFolderService_UpdateFolder(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	a := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "a"})
+	b := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "b", ParentID: &a.ID})
+	c := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "c", ParentID: &b.ID})
+
+	s := sqlite.NewFolderService(db)
+
+	t.Run("ErrCycle", func(t *testing.T) {
+		for _, parentID := range []string{a.ID, c.ID} {
+			if _, err := s.UpdateFolder(ctx, a.ID, gofman.FolderUpdate{ParentID: &parentID}); gofman.ErrorCode(err) != gofman.EINVALID {
+				t.Fatalf("Unexpected error: %#v", err)
+			}
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		if folder, err := s.UpdateFolder(ctx, c.ID, gofman.FolderUpdate{ParentID: &a.ID}); err != nil {
+			t.Fatal(err)
+		} else if folder.ParentID == nil || *folder.ParentID != a.ID {
+			t.Fatalf("Unexpected folder: %#v", folder)
+		}
+
+		root := ""
+		if folder, err := s.UpdateFolder(ctx, b.ID, gofman.FolderUpdate{ParentID: &root}); err != nil {
+			t.Fatal(err)
+		} else if folder.ParentID != nil {
+			t.Fatalf("Unexpected folder: %#v", folder)
+		}
+
+		if folder, err := s.FindFolderByID(ctx, b.ID); err != nil {
+			t.Fatal(err)
+		} else if folder.ParentID != nil {
+			t.Fatalf("Unexpected folder: %#v", folder)
+		}
+	})
+}
+
+func TestFolderService_RemoveFolder(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	parent := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "photos"})
+	child := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "2021", ParentID: &parent.ID})
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg", FolderID: &child.ID})
+
+	s := sqlite.NewFolderService(db)
+
+	if err := s.RemoveFolder(ctx, parent.ID); gofman.ErrorCode(err) != gofman.ECONFLICT {
+		t.Fatalf("Unexpected error: %#v", err)
+	} else if err := s.RemoveFolder(ctx, child.ID); gofman.ErrorCode(err) != gofman.ECONFLICT {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+
+	if err := sqlite.NewFileService(db).RemoveFile(ctx, file.ID); err != nil {
+		t.Fatal(err)
+	} else if err := s.RemoveFolder(ctx, child.ID); err != nil {
+		t.Fatal(err)
+	} else if err := s.RemoveFolder(ctx, parent.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.FindFolderByID(ctx, parent.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
+
+func TestFileService_FolderID(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	folder := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: user.ID, Name: "photos"})
+	file := MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "a.jpg", FolderID: &folder.ID})
+	MustCreateFile(t, ctx, db, &gofman.File{UserID: user.ID, Name: "b.jpg"})
+
+	s := sqlite.NewFileService(db)
+
+	if files, n, err := s.FindFiles(ctx, gofman.FileFilter{UserID: &user.ID, FolderID: &folder.ID}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 || files[0].ID != file.ID || files[0].FolderID == nil || *files[0].FolderID != folder.ID {
+		t.Fatalf("Unexpected files: %#v", files)
+	}
+
+	missing := "00000000-0000-0000-0000-000000000000"
+	if err := s.CreateFile(ctx, &gofman.File{UserID: user.ID, Name: "c.jpg", Type: "image/jpeg", Path: "/data/c.jpg", Checksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", FolderID: &missing}); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+
+	root := ""
+	if other, err := s.UpdateFile(ctx, file.ID, gofman.FileUpdate{FolderID: &root}); err != nil {
+		t.Fatal(err)
+	} else if other.FolderID != nil {
+		t.Fatalf("Unexpected folder: %v", *other.FolderID)
+	}
+}
+
+// MustCreateFolder creates a folder in the database. Fatal on error.
+func MustCreateFolder(tb testing.TB, ctx context.Context, db *sqlite.DB, folder *gofman.Folder) *gofman.Folder {
+	tb.Helper()
+
+	if err := sqlite.NewFolderService(db).CreateFolder(ctx, folder); err != nil {
+		tb.Fatal(err)
+	}
+
+	return folder
+}
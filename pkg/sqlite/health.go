@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Ensure service implements interface.
+var _ gofman.HealthService = (*HealthService)(nil)
+
+// HealthService represents a service for checking the database connection.
+type HealthService struct {
+	db *DB
+}
+
+// NewHealthService returns a new instance of HealthService.
+func NewHealthService(db *DB) *HealthService {
+	return &HealthService{db: db}
+}
+
+// Ping runs a trivial query against the database.
+func (s *HealthService) Ping(ctx context.Context) error {
+	var n int
+	return s.db.db.QueryRowContext(ctx, `SELECT 1`).Scan(&n)
+}
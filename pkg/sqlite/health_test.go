@@ -0,0 +1,23 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestHealthService_Ping(t *testing.T) {
+	db := MustOpenDB(t)
+	s := sqlite.NewHealthService(db)
+
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	MustCloseDB(t, db)
+
+	if err := s.Ping(context.Background()); err == nil {
+		t.Fatal("Expected error for closed database.")
+	}
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// ulidEncoding is the Crockford base32 alphabet used to encode ULIDs. Its
+// characters are in ASCII order, so encoded ULIDs sort like their bytes.
+const ulidEncoding = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// maxULIDTime is the largest timestamp fitting into the 48 bits of a ULID.
+const maxULIDTime = 1<<48 - 1
+
+// defaultIDGenerator is shared by all databases, so IDs are unique and
+// ordered across them.
+var defaultIDGenerator = &ulidGenerator{now: time.Now}
+
+// id is a helper function returning a new ULID.
+func id() (string, error) {
+	return defaultIDGenerator.New()
+}
+
+// ulidGenerator generates ULIDs: 26 characters encoding a millisecond
+// timestamp followed by 80 random bits. IDs generated within the same
+// millisecond, or after the clock went backwards, reuse the last timestamp
+// and increment the random part, so every ID sorts after the previous one.
+type ulidGenerator struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	ms      uint64
+	entropy [10]byte
+}
+
+// New returns a new ULID. It returns an error instead of a duplicate if more
+// IDs are requested within a millisecond than the random part can hold.
+func (g *ulidGenerator) New() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixNano() / int64(time.Millisecond))
+	if ms > maxULIDTime {
+		return "", gofman.NewError(gofman.EINTERNAL, "Could not generate ID, time out of range.")
+	}
+
+	if ms <= g.ms {
+		if !incrementEntropy(&g.entropy) {
+			return "", gofman.NewError(gofman.EINTERNAL, "Could not generate ID, too many IDs within a millisecond.")
+		}
+	} else {
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			return "", gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not generate ID.")
+		}
+
+		g.ms = ms
+	}
+
+	return encodeULID(g.ms, g.entropy), nil
+}
+
+// incrementEntropy adds one to the big-endian random part. It returns false
+// if the random part overflows.
+func incrementEntropy(entropy *[10]byte) bool {
+	for i := len(entropy) - 1; i >= 0; i-- {
+		entropy[i]++
+		if entropy[i] != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodeULID encodes the timestamp and the random part as 26 base32
+// characters, the most significant first.
+func encodeULID(ms uint64, entropy [10]byte) string {
+	// The 128 bits are split into the upper 64 bits, holding the timestamp
+	// and the first two random bytes, and the lower 64 bits.
+	hi := ms<<16 | uint64(entropy[0])<<8 | uint64(entropy[1])
+
+	var lo uint64
+	for _, b := range entropy[2:] {
+		lo = lo<<8 | uint64(b)
+	}
+
+	var buf [26]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = ulidEncoding[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(buf[:])
+}
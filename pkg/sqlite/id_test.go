@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+func TestULIDGenerator_New(t *testing.T) {
+	t.Run("Concurrent", func(t *testing.T) {
+		g := &ulidGenerator{now: time.Now}
+
+		const workers, n = 8, 1000
+
+		var wg sync.WaitGroup
+		results := make([][]string, workers)
+
+		for i := range results {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				for j := 0; j < n; j++ {
+					id, err := g.New()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+
+					results[i] = append(results[i], id)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		seen := make(map[string]bool, workers*n)
+		for _, ids := range results {
+			// Every worker must see increasing IDs.
+			if !sort.StringsAreSorted(ids) {
+				t.Fatal("Expected IDs to be ordered")
+			}
+
+			for _, id := range ids {
+				if len(id) != 26 {
+					t.Fatalf("Unexpected ID: %q", id)
+				} else if seen[id] {
+					t.Fatalf("Duplicate ID: %q", id)
+				}
+
+				seen[id] = true
+			}
+		}
+	})
+
+	t.Run("SameMillisecond", func(t *testing.T) {
+		ts := time.Unix(1000000000, 0)
+		g := &ulidGenerator{now: func() time.Time { return ts }}
+
+		a, err := g.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := g.New()
+		if err != nil {
+			t.Fatal(err)
+		} else if b <= a {
+			t.Fatalf("Expected %q after %q", b, a)
+		} else if a[:10] != b[:10] {
+			t.Fatalf("Expected same timestamp: %q, %q", a, b)
+		}
+
+		// A clock going backwards must not break the order.
+		ts = ts.Add(-time.Second)
+		if c, err := g.New(); err != nil {
+			t.Fatal(err)
+		} else if c <= b {
+			t.Fatalf("Expected %q after %q", c, b)
+		}
+	})
+
+	t.Run("ErrOverflow", func(t *testing.T) {
+		g := &ulidGenerator{now: func() time.Time { return time.Unix(1000000000, 0) }}
+
+		if _, err := g.New(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range g.entropy {
+			g.entropy[i] = 0xff
+		}
+
+		if _, err := g.New(); gofman.ErrorCode(err) != gofman.EINTERNAL {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestEncodeULID(t *testing.T) {
+	if got := encodeULID(0, [10]byte{}); got != "00000000000000000000000000" {
+		t.Fatalf("Unexpected ULID: %q", got)
+	}
+
+	var entropy [10]byte
+	for i := range entropy {
+		entropy[i] = 0xff
+	}
+
+	if got := encodeULID(maxULIDTime, entropy); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("Unexpected ULID: %q", got)
+	}
+
+	// The timestamp takes the first 10 characters.
+	if got := encodeULID(1469918176385, [10]byte{}); got[:10] != "01ARYZ6S41" {
+		t.Fatalf("Unexpected ULID: %q", got)
+	}
+}
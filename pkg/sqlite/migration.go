@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Ensure service implements interface.
+var _ gofman.MigrationService = (*MigrationService)(nil)
+
+// MigrationService represents a service for inspecting schema migrations.
+type MigrationService struct {
+	db *DB
+}
+
+// NewMigrationService returns a new instance of MigrationService.
+func NewMigrationService(db *DB) *MigrationService {
+	return &MigrationService{db: db}
+}
+
+// MigrationStatus returns the last applied migration, the latest available
+// migration and the number of migration files and columns that have not been
+// applied yet.
+func (s *MigrationService) MigrationStatus(ctx context.Context) (*gofman.MigrationStatus, error) {
+	names, err := migrationNames()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		applied[name] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var status gofman.MigrationStatus
+
+	for _, name := range names {
+		status.Latest = migrationVersion(name)
+
+		if applied[name] {
+			status.Version = migrationVersion(name)
+		} else {
+			status.Pending++
+		}
+	}
+
+	for _, c := range migrationColumns {
+		var n int
+
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.name).Scan(&n)
+		if err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			status.Pending++
+		}
+	}
+
+	return &status, nil
+}
+
+// migrationVersion returns the version of a migration file, which is its
+// file name without the extension.
+func migrationVersion(name string) string {
+	return strings.TrimSuffix(path.Base(name), path.Ext(name))
+}
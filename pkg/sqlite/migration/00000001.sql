@@ -0,0 +1,69 @@
+CREATE TEMP TABLE tags_duplicates AS
+SELECT tags.id AS id, (
+  SELECT keep.id
+  FROM tags AS keep
+  WHERE keep.users_id = tags.users_id AND keep.name = tags.name AND keep.removed_at = 0
+  ORDER BY keep.created_at ASC, keep.id ASC
+  LIMIT 1
+) AS keep_id
+FROM tags
+WHERE tags.removed_at = 0;
+
+DELETE FROM tags_duplicates WHERE id = keep_id;
+
+INSERT OR IGNORE INTO files_tags (files_id, tags_id)
+SELECT files_tags.files_id, tags_duplicates.keep_id
+FROM files_tags
+JOIN tags_duplicates ON tags_duplicates.id = files_tags.tags_id;
+
+DELETE FROM files_tags WHERE tags_id IN (SELECT id FROM tags_duplicates);
+
+INSERT OR IGNORE INTO actors_tags (actors_id, tags_id)
+SELECT actors_tags.actors_id, tags_duplicates.keep_id
+FROM actors_tags
+JOIN tags_duplicates ON tags_duplicates.id = actors_tags.tags_id;
+
+DELETE FROM actors_tags WHERE tags_id IN (SELECT id FROM tags_duplicates);
+
+UPDATE tags
+SET removed_at = CAST(strftime('%s', 'now') AS BIGINT)
+WHERE id IN (SELECT id FROM tags_duplicates);
+
+DROP TABLE tags_duplicates;
+
+CREATE TEMP TABLE actors_duplicates AS
+SELECT actors.id AS id, (
+  SELECT keep.id
+  FROM actors AS keep
+  WHERE keep.users_id = actors.users_id AND keep.name = actors.name AND keep.removed_at = 0
+  ORDER BY keep.created_at ASC, keep.id ASC
+  LIMIT 1
+) AS keep_id
+FROM actors
+WHERE actors.removed_at = 0;
+
+DELETE FROM actors_duplicates WHERE id = keep_id;
+
+INSERT OR IGNORE INTO files_actors (files_id, actors_id)
+SELECT files_actors.files_id, actors_duplicates.keep_id
+FROM files_actors
+JOIN actors_duplicates ON actors_duplicates.id = files_actors.actors_id;
+
+DELETE FROM files_actors WHERE actors_id IN (SELECT id FROM actors_duplicates);
+
+INSERT OR IGNORE INTO actors_tags (actors_id, tags_id)
+SELECT actors_duplicates.keep_id, actors_tags.tags_id
+FROM actors_tags
+JOIN actors_duplicates ON actors_duplicates.id = actors_tags.actors_id;
+
+DELETE FROM actors_tags WHERE actors_id IN (SELECT id FROM actors_duplicates);
+
+UPDATE actors
+SET removed_at = CAST(strftime('%s', 'now') AS BIGINT)
+WHERE id IN (SELECT id FROM actors_duplicates);
+
+DROP TABLE actors_duplicates;
+
+CREATE UNIQUE INDEX IF NOT EXISTS tags_users_id_name ON tags (users_id, name) WHERE removed_at = 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS actors_users_id_name ON actors (users_id, name) WHERE removed_at = 0;
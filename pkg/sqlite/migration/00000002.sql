@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS folders (
+  id          UUID PRIMARY KEY,
+  users_id    UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+  parent_id   UUID REFERENCES folders(id) ON DELETE RESTRICT,
+  name        VARCHAR(255) NOT NULL,
+  created_at  BIGINT NOT NULL,
+  updated_at  BIGINT NOT NULL,
+  removed_at  BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS folders_parent_id ON folders (parent_id);
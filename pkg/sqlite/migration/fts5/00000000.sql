@@ -0,0 +1,4 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS files_fts USING fts5(
+  id UNINDEXED,
+  name
+);
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS files_fts;
+
+DROP TABLE IF EXISTS files_search;
+
+CREATE TABLE files_search (
+  id        INTEGER PRIMARY KEY,
+  files_id  UUID NOT NULL UNIQUE
+);
+
+CREATE VIRTUAL TABLE files_fts USING fts5(
+  name
+);
+
+INSERT INTO files_search (files_id) SELECT id FROM files WHERE removed_at = 0;
+
+INSERT INTO files_fts (rowid, name)
+SELECT files_search.id, files.name
+FROM files_search
+JOIN files ON files.id = files_search.files_id;
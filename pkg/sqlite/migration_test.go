@@ -0,0 +1,178 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestMigrationService_MigrationStatus(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+	s := sqlite.NewMigrationService(db)
+
+	t.Run("Migrated", func(t *testing.T) {
+		if status, err := s.MigrationStatus(ctx); err != nil {
+			t.Fatal(err)
+		} else if !status.IsMigrated() {
+			t.Fatalf("Expected no pending migrations, got %d", status.Pending)
+		} else if status.Version != status.Latest {
+			t.Fatalf("Expected version %q, got %q", status.Latest, status.Version)
+		}
+	})
+
+	t.Run("PendingColumn", func(t *testing.T) {
+		MustExec(t, db, `ALTER TABLE sessions RENAME COLUMN ip TO ip_old`)
+
+		if status, err := s.MigrationStatus(ctx); err != nil {
+			t.Fatal(err)
+		} else if status.Pending != 1 {
+			t.Fatalf("Expected 1 pending migration, got %d", status.Pending)
+		} else if status.Version != status.Latest {
+			t.Fatalf("Expected version %q, got %q", status.Latest, status.Version)
+		}
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		conn, err := sql.Open("sqlite3", db.DSN)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer conn.Close()
+
+		if _, err := conn.Exec(`DELETE FROM migrations`); err != nil {
+			t.Fatal(err)
+		}
+
+		if status, err := s.MigrationStatus(ctx); err != nil {
+			t.Fatal(err)
+		} else if status.IsMigrated() {
+			t.Fatal("Expected pending migrations.")
+		} else if status.Version != "" {
+			t.Fatalf("Expected empty version, got %q", status.Version)
+		}
+	})
+
+	t.Run("Remigrated", func(t *testing.T) {
+		other := sqlite.NewDB()
+		other.DSN = db.DSN
+
+		if err := other.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		defer MustCloseDB(t, other)
+
+		if status, err := s.MigrationStatus(ctx); err != nil {
+			t.Fatal(err)
+		} else if !status.IsMigrated() {
+			t.Fatalf("Expected no pending migrations, got %d", status.Pending)
+		}
+	})
+}
+
+func TestDB_Migrate_DuplicateNames(t *testing.T) {
+	db := MustSeedDB(t, `
+		INSERT INTO users (id, username, password, created_at, updated_at) VALUES ('u', 'jane', '', 1, 1);
+
+		INSERT INTO files (id, users_id, name, type, path, checksum, created_at, updated_at) VALUES
+			('f1', 'u', 'a.jpg', 'image/jpeg', '/data/a.jpg', '', 1, 1),
+			('f2', 'u', 'b.jpg', 'image/jpeg', '/data/b.jpg', '', 1, 1);
+
+		INSERT INTO tags (id, users_id, name, created_at, updated_at) VALUES
+			('t1', 'u', 'beach', 1, 1),
+			('t2', 'u', 'beach', 2, 2),
+			('t3', 'u', 'beach', 3, 3);
+
+		INSERT INTO actors (id, users_id, name, created_at, updated_at) VALUES
+			('a1', 'u', 'john', 1, 1),
+			('a2', 'u', 'john', 2, 2);
+
+		INSERT INTO files_tags (files_id, tags_id) VALUES ('f1', 't1'), ('f1', 't2'), ('f2', 't3');
+		INSERT INTO files_actors (files_id, actors_id) VALUES ('f1', 'a2');
+		INSERT INTO actors_tags (actors_id, tags_id) VALUES ('a2', 't2');
+	`)
+
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer MustCloseDB(t, db)
+
+	// Duplicates are merged into the oldest tag or actor and removed.
+	for query, want := range map[string]string{
+		`SELECT group_concat(id) FROM tags WHERE removed_at = 0`:                                            "t1",
+		`SELECT group_concat(id) FROM actors WHERE removed_at = 0`:                                          "a1",
+		`SELECT group_concat(files_id || ':' || tags_id) FROM (SELECT * FROM files_tags ORDER BY files_id)`: "f1:t1,f2:t1",
+		`SELECT group_concat(files_id || ':' || actors_id) FROM files_actors`:                               "f1:a1",
+		`SELECT group_concat(actors_id || ':' || tags_id) FROM actors_tags`:                                 "a1:t1",
+	} {
+		if got := MustQueryString(t, db, query); got != want {
+			t.Fatalf("Unexpected result of %q: %q", query, got)
+		}
+	}
+}
+
+func TestDB_Migrate_UsernameCase(t *testing.T) {
+	db := MustSeedDB(t, `
+		INSERT INTO users (id, username, password, created_at, updated_at) VALUES
+			('u1', 'jane', '', 1, 1),
+			('u2', 'Jane', '', 2, 2),
+			('u3', 'john', '', 3, 3);
+	`)
+
+	// Which user keeps the name is up to the admin, the error names them.
+	if err := db.Open(); err == nil {
+		MustCloseDB(t, db)
+		t.Fatal("Expected error")
+	} else if !strings.Contains(err.Error(), "Rename all but one user of each group before upgrading: Jane, jane.") {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	MustCloseDB(t, db)
+
+	MustExec(t, db, `UPDATE users SET username = 'jane2' WHERE id = 'u2'`)
+
+	renamed := sqlite.NewDB()
+	renamed.DSN = db.DSN
+	renamed.AuthService = auth.NewAuthService()
+
+	if err := renamed.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	MustCloseDB(t, renamed)
+}
+
+// MustSeedDB returns a new, unopened DB in a temporary directory. Its schema
+// is in the state of the first migration and contains the rows of the seed.
+// Opening it runs all later migrations against the seeded rows. Fatal on
+// error.
+func MustSeedDB(tb testing.TB, seed string) *sqlite.DB {
+	tb.Helper()
+
+	db := sqlite.NewDB()
+	db.DSN = filepath.Join(tb.TempDir(), "db")
+	db.AuthService = auth.NewAuthService()
+
+	schema, err := ioutil.ReadFile(filepath.Join("migration", "00000000.sql"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	MustExec(tb, db, string(schema)+`
+		CREATE TABLE migrations (name TEXT PRIMARY KEY);
+		INSERT INTO migrations (name) VALUES ('migration/00000000.sql');
+	`+seed)
+
+	return db
+}
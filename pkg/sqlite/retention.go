@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Retention constants.
+const (
+	RetentionBatchSize = 500
+)
+
+// Ensure service implements interface.
+var _ gofman.RetentionService = (*RetentionService)(nil)
+
+// RetentionService represents a service for pruning old rows.
+type RetentionService struct {
+	db *DB
+}
+
+// NewRetentionService returns a new instance of RetentionService.
+func NewRetentionService(db *DB) *RetentionService {
+	return &RetentionService{db: db}
+}
+
+// Prune deletes all rows that are older than the max age configured in the
+// policy. Sessions age from their expiry, so sessions that are still valid are
+// never pruned. Rows are deleted in batches, each in its own transaction, so
+// the database is never locked for long.
+func (s *RetentionService) Prune(ctx context.Context, policy gofman.RetentionPolicy) (*gofman.RetentionResult, error) {
+	var result gofman.RetentionResult
+
+	if v := policy.SessionMaxAge; v > 0 {
+		n, err := s.pruneTable(ctx, "sessions", "expires_at", s.db.Now()-v)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Sessions = n
+	}
+
+	return &result, nil
+}
+
+// pruneTable deletes rows whose timestamp column is before the given
+// timestamp in batches and returns the total number of deleted rows.
+func (s *RetentionService) pruneTable(ctx context.Context, table, column string, before int64) (int, error) {
+	var total int
+
+	for {
+		n, err := s.pruneBatch(ctx, table, column, before)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+
+		if n < RetentionBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// pruneBatch deletes a single batch of rows whose timestamp column is before
+// the given timestamp.
+func (s *RetentionService) pruneBatch(ctx context.Context, table, column string, before int64) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM `+table+`
+		WHERE id IN (
+			SELECT id
+			FROM `+table+`
+			WHERE `+column+` < ?
+			LIMIT ?
+		)
+	`,
+		before,
+		RetentionBatchSize,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
+}
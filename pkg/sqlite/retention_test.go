@@ -0,0 +1,52 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestRetentionService_Prune(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+	user, ctx := MustCreateUser(t, ctx, db, &gofman.User{Username: "jane", Password: "password"})
+
+	// Both sessions are created at the same time, but only the remembered
+	// one is still valid when pruning.
+	db.Now = func() int64 { return 1000 }
+	old := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: "00000000000000000000000000000000", ExpiresAt: 1500})
+	remembered := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: "11111111111111111111111111111111", ExpiresAt: 100000})
+
+	db.Now = func() int64 { return 5000 }
+
+	s := sqlite.NewRetentionService(db)
+
+	t.Run("Disabled", func(t *testing.T) {
+		if result, err := s.Prune(ctx, gofman.RetentionPolicy{}); err != nil {
+			t.Fatal(err)
+		} else if result.Sessions != 0 {
+			t.Fatalf("Expected no pruned sessions, got %d", result.Sessions)
+		}
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		if result, err := s.Prune(ctx, gofman.RetentionPolicy{SessionMaxAge: 2000}); err != nil {
+			t.Fatal(err)
+		} else if result.Sessions != 1 {
+			t.Fatalf("Expected one pruned session, got %d", result.Sessions)
+		}
+
+		sessions, _, err := sqlite.NewSessionService(db).FindSessions(ctx, gofman.SessionFilter{UserID: &user.ID})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(sessions) != 1 {
+			t.Fatalf("Expected one session, got %d", len(sessions))
+		} else if sessions[0].ID != remembered.ID || sessions[0].ID == old.ID {
+			t.Fatal("Expected remembered session to be kept.")
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// migrateSearch creates the full-text search index of file names from the
+// sqlite/migration/fts5 folder if SQLite was built with FTS5, which requires
+// the sqlite_fts5 build tag. The index is filled once by a migration and kept
+// up to date whenever files are created, renamed, removed or restored.
+//
+// The index is keyed by the files_search table, as FTS5 rows have an integer
+// rowid and the rowid of files may change on VACUUM.
+//
+// Binaries built without FTS5 cannot update the index. They forget the search
+// migrations instead, so the index is rebuilt once by the next binary with
+// FTS5.
+func (db *DB) migrateSearch(ctx context.Context) error {
+	var enabled bool
+
+	if err := db.db.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return err
+	} else if !enabled {
+		_, err := db.db.ExecContext(ctx, `DELETE FROM migrations WHERE name LIKE 'migration/fts5/%'`)
+		return err
+	}
+
+	names, err := fs.Glob(migrationFS, "migration/fts5/*.sql")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := db.migrateFile(ctx, name); err != nil {
+			return gofman.NewWrappedError(gofman.EINTERNAL, err, "Error during migration in %q.", name)
+		}
+	}
+
+	db.search = true
+
+	return nil
+}
+
+// indexFile adds the name of the file to the full-text search index or
+// replaces it.
+func indexFile(ctx context.Context, tx *Tx, file *gofman.File) error {
+	if !tx.db.search {
+		return nil
+	}
+
+	if err := unindexFiles(ctx, tx, "id = ?", file.ID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO files_search (files_id) VALUES (?)`, file.ID)
+	if err != nil {
+		return err
+	}
+
+	rowid, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO files_fts (rowid, name) VALUES (?, ?)`, rowid, file.Name)
+	return err
+}
+
+// unindexFiles removes the files matching the condition from the full-text
+// search index. The condition applies to the files table and is never user
+// input.
+func unindexFiles(ctx context.Context, tx *Tx, cond string, args ...interface{}) error {
+	if !tx.db.search {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM files_fts
+		WHERE rowid IN (
+			SELECT id FROM files_search WHERE files_id IN (
+				SELECT id FROM files WHERE `+cond+`
+			)
+		)
+	`,
+		args...,
+	); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM files_search
+		WHERE files_id IN (
+			SELECT id FROM files WHERE `+cond+`
+		)
+	`,
+		args...,
+	)
+
+	return err
+}
+
+// formatSearchQuery turns the words of a search into an FTS5 query matching
+// names containing all of them as prefixes of words. Every word is quoted,
+// so the FTS5 query syntax cannot be used and never causes syntax errors.
+func formatSearchQuery(query string) string {
+	words := strings.Fields(query)
+
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+	}
+
+	return strings.Join(words, " ")
+}
@@ -2,22 +2,47 @@ package sqlite
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
 	"strings"
+	"time"
 
 	"github.com/dhenkes/gofman/pkg/gofman"
 )
 
+// Session limit strategies.
+const (
+	SessionLimitReject = "reject"
+	SessionLimitEvict  = "evict"
+)
+
 // Ensure service implements interface.
 var _ gofman.SessionService = (*SessionService)(nil)
 
 // SessionService represents a service for managing sessions.
 type SessionService struct {
 	db *DB
+
+	// Maximum number of sessions per user. Zero disables the limit.
+	MaxSessions int
+
+	// Strategy used when a user reaches the maximum number of sessions.
+	// Either rejects the new session or evicts the oldest sessions.
+	// Defaults to SessionLimitReject.
+	MaxSessionsStrategy string
+
+	// Lifetime of new sessions without an expiry or lifetime.
+	TTL time.Duration
 }
 
 // NewSessionService returns a new instance of SessionService.
 func NewSessionService(db *DB) *SessionService {
-	return &SessionService{db: db}
+	return &SessionService{
+		db:  db,
+		TTL: gofman.DefaultSessionTTL * time.Second,
+	}
 }
 
 // FindSessionForToken looks up a session by ID and token.
@@ -57,7 +82,37 @@ func (s *SessionService) FindSessions(ctx context.Context, filter gofman.Session
 	return sessions, total, nil
 }
 
-// CreateSession creates a new session object.
+// FindSessionsForUser retrieves the live sessions of a user, newest first.
+// Tokens are redacted.
+// Returns EUNAUTHORIZED if current user is not the user.
+func (s *SessionService) FindSessionsForUser(ctx context.Context, userID string) ([]*gofman.Session, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	filter := gofman.SessionFilter{UserID: &userID, SortDesc: true, Limit: gofman.MaxSessionLimit}
+
+	if gofman.CanFindSession(ctx, filter) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to find the sessions of this user.")
+	}
+
+	sessions, _, err := findSessions(ctx, tx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i] = sessions[i].Redacted()
+	}
+
+	return sessions, nil
+}
+
+// CreateSession creates a new session object. Sessions without an expiry
+// expire after their own TTL or, if not set, the TTL of the service.
 func (s *SessionService) CreateSession(ctx context.Context, session *gofman.Session) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -66,7 +121,11 @@ func (s *SessionService) CreateSession(ctx context.Context, session *gofman.Sess
 
 	defer tx.Rollback()
 
-	if err = createSession(ctx, tx, session); err != nil {
+	if err = limitSessions(ctx, tx, session.UserID, s.MaxSessions, s.MaxSessionsStrategy); err != nil {
+		return err
+	}
+
+	if err = createSession(ctx, tx, session, s.TTL); err != nil {
 		return err
 	}
 
@@ -91,42 +150,120 @@ func (s *SessionService) DeleteSession(ctx context.Context, id string) error {
 	return tx.Commit()
 }
 
-// findSessionByID looks up a session by ID.
-// Returns ENOTFOUND if session does not exist.
-func findSessionByID(ctx context.Context, tx *Tx, id string) (*gofman.Session, error) {
-	sessions, _, err := findSessions(ctx, tx, gofman.SessionFilter{ID: &id, Limit: 1})
+// DeleteSessionsForUser permanently deletes all sessions of a user and
+// returns the number of deleted sessions.
+// Returns EUNAUTHORIZED if current user is not allowed to update the user.
+// Returns ENOTFOUND if user does not exist.
+func (s *SessionService) DeleteSessionsForUser(ctx context.Context, userID string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
 
+	user, err := findUserByID(ctx, tx, userID)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	if len(sessions) == 0 {
-		return nil, gofman.NewError(gofman.ENOTFOUND, "Session not found.")
+	if gofman.CanUpdateUser(ctx, user) == false {
+		return 0, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to delete the sessions of this user.")
+	}
+
+	n, err := deleteSessionsForUser(ctx, tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// PurgeExpired permanently deletes all expired sessions and returns the
+// number of deleted sessions.
+func (s *SessionService) PurgeExpired(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	n, err := purgeExpiredSessions(ctx, tx)
+	if err != nil {
+		return 0, err
 	}
 
-	return sessions[0], nil
+	return n, tx.Commit()
 }
 
-// findSessionForToken looks up a session by ID, user ID and token.
+// lookupSessionByID retrieves a session by ID, including expired sessions.
+// Callers decide whether an expired session is acceptable, so expired
+// sessions can still be deleted.
 // Returns ENOTFOUND if session does not exist.
-func findSessionForToken(ctx context.Context, tx *Tx, id string, token string) (*gofman.Session, error) {
-	sessions, _, err := findSessions(ctx, tx, gofman.SessionFilter{ID: &id, Token: &token, Limit: 1})
+func lookupSessionByID(ctx context.Context, tx *Tx, id string) (*gofman.Session, error) {
+	var session gofman.Session
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			users_id,
+			token_hash,
+			created_at,
+			expires_at,
+			user_agent,
+			ip
+		FROM sessions
+		WHERE id = ?
+	`,
+		id,
+	).Scan(
+		&session.ID, &session.UserID, &session.TokenHash,
+		&session.CreatedAt, &session.ExpiresAt,
+		&session.UserAgent, &session.IP,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "Session not found.")
+	} else if err != nil {
+		return nil, err
+	}
 
+	return &session, nil
+}
+
+// findSessionForToken looks up a session by ID and compares the hash of the
+// token with the stored hash in constant time.
+// Returns ENOTFOUND if session does not exist, has expired or the token does
+// not match.
+func findSessionForToken(ctx context.Context, tx *Tx, id string, token string) (*gofman.Session, error) {
+	session, err := lookupSessionByID(ctx, tx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(sessions) == 0 {
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(session.TokenHash)) != 1 {
 		return nil, gofman.NewError(gofman.ENOTFOUND, "Session not found.")
 	}
 
-	return sessions[0], nil
+	if session.IsExpired(tx.now) {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "Session not found.")
+	}
+
+	session.Token = token
+
+	return session, nil
 }
 
 // findSessions retrieves session objects and total hits based on a filter.
 // The total hits may differ from the length of the slice if a limit was
-// applied.
+// applied. Expired sessions are skipped. Only the hash of a token is stored,
+// so the token of the returned sessions is empty.
 func findSessions(ctx context.Context, tx *Tx, filter gofman.SessionFilter) ([]*gofman.Session, int, error) {
+	if err := checkOffset(filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
 	where, args := []string{"1 = 1"}, []interface{}{}
 
 	if v := filter.ID; v != nil {
@@ -138,20 +275,32 @@ func findSessions(ctx context.Context, tx *Tx, filter gofman.SessionFilter) ([]*
 	}
 
 	if v := filter.Token; v != nil {
-		where, args = append(where, "token = ?"), append(args, *v)
+		where, args = append(where, "token_hash = ?"), append(args, hashToken(*v))
+	}
+
+	where, args = append(where, "expires_at > ?"), append(args, tx.now)
+
+	order := "ASC"
+	if filter.SortDesc {
+		order = "DESC"
 	}
 
+	limit := clampLimit(filter.Limit, gofman.DefaultSessionLimit, gofman.MaxSessionLimit)
+
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
 			id,
 			users_id,
-			token,
+			token_hash,
 			created_at,
+			expires_at,
+			user_agent,
+			ip,
 			COUNT(*) OVER()
 		FROM sessions
 		WHERE `+strings.Join(where, " AND ")+`
-		ORDER BY created_at ASC
-		`+formatLimitOffset(filter.Limit, filter.Offset),
+		ORDER BY created_at `+order+`, id ASC
+		`+formatLimitOffset(limit, filter.Offset),
 		args...,
 	)
 
@@ -168,8 +317,9 @@ func findSessions(ctx context.Context, tx *Tx, filter gofman.SessionFilter) ([]*
 		var session gofman.Session
 
 		if err = rows.Scan(
-			&session.ID, &session.UserID, &session.Token,
-			&session.CreatedAt,
+			&session.ID, &session.UserID, &session.TokenHash,
+			&session.CreatedAt, &session.ExpiresAt,
+			&session.UserAgent, &session.IP,
 			&n,
 		); err != nil {
 			return nil, 0, err
@@ -185,8 +335,15 @@ func findSessions(ctx context.Context, tx *Tx, filter gofman.SessionFilter) ([]*
 	return sessions, n, nil
 }
 
-// createSession creates a new session object.
-func createSession(ctx context.Context, tx *Tx, session *gofman.Session) error {
+// createSession creates a new session object. If the session has no expiry
+// it expires after its own TTL or, if not set, after ttl.
+func createSession(ctx context.Context, tx *Tx, session *gofman.Session, ttl time.Duration) error {
+	if session.ExpiresAt == 0 && session.TTL > 0 {
+		session.ExpiresAt = tx.now + session.TTL
+	} else if session.ExpiresAt == 0 {
+		session.ExpiresAt = tx.now + int64(ttl/time.Second)
+	}
+
 	if err := session.Validate(); err != nil {
 		return err
 	}
@@ -198,34 +355,82 @@ func createSession(ctx context.Context, tx *Tx, session *gofman.Session) error {
 	}
 
 	session.CreatedAt = tx.now
+	session.TokenHash = hashToken(session.Token)
 
 	_, err := tx.ExecContext(ctx, `
 		INSERT INTO sessions (
 			id,
 			users_id,
 			token,
-			created_at
+			token_hash,
+			created_at,
+			expires_at,
+			user_agent,
+			ip
 		)
-		VALUES (?, ?, ?, ?)
+		VALUES (?, ?, '', ?, ?, ?, ?, ?)
 	`,
 		session.ID,
 		session.UserID,
-		session.Token,
+		session.TokenHash,
 		session.CreatedAt,
+		session.ExpiresAt,
+		session.UserAgent,
+		session.IP,
 	)
 
 	if err != nil {
-		return err
+		return mapSQLiteError(err)
 	}
 
 	return nil
 }
 
+// limitSessions makes room for a new session of the given user. If the user
+// already has max live sessions it either returns ECONFLICT or deletes the oldest
+// sessions, depending on the strategy. A max of zero disables the limit.
+func limitSessions(ctx context.Context, tx *Tx, userID string, max int, strategy string) error {
+	if max <= 0 {
+		return nil
+	}
+
+	var n int
+
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE users_id = ? AND expires_at > ?`, userID, tx.now).Scan(&n); err != nil {
+		return err
+	}
+
+	if n < max {
+		return nil
+	}
+
+	if strategy != SessionLimitEvict {
+		return gofman.NewError(gofman.ECONFLICT, "Maximum number of %d sessions reached.", max)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM sessions
+		WHERE id IN (
+			SELECT id
+			FROM sessions
+			WHERE users_id = ? AND expires_at > ?
+			ORDER BY created_at ASC, id ASC
+			LIMIT ?
+		)
+	`,
+		userID,
+		tx.now,
+		n-max+1,
+	)
+
+	return err
+}
+
 // deleteSession permanently deletes a session object from the system by ID.
 // Returns EUNAUTHORIZED if current user is not the creator of the session.
 // Returns ENOTFOUND if session does not exist.
 func deleteSession(ctx context.Context, tx *Tx, id string) error {
-	session, err := findSessionByID(ctx, tx, id)
+	session, err := lookupSessionByID(ctx, tx, id)
 	if err != nil {
 		return err
 	}
@@ -240,3 +445,118 @@ func deleteSession(ctx context.Context, tx *Tx, id string) error {
 
 	return nil
 }
+
+// deleteSessionsForUser permanently deletes all sessions of a user and
+// returns the number of deleted sessions.
+func deleteSessionsForUser(ctx context.Context, tx *Tx, userID string) (int, error) {
+	result, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE users_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// purgeExpiredSessions permanently deletes all expired sessions and returns
+// the number of deleted sessions.
+func purgeExpiredSessions(ctx context.Context, tx *Tx) (int, error) {
+	result, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, tx.now)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// hashToken returns the SHA-256 hash of a session token. Tokens are random
+// and long enough that a fast hash is sufficient, the hash only protects the
+// tokens if the database leaks.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// migrateSessionTokens moves the tokens of sessions created before tokens
+// were hashed into the token_hash column. Tokens that already are a SHA-256
+// hex digest are moved as they are, all others are hashed first. The
+// plaintext token column is cleared.
+func (db *DB) migrateSessionTokens() error {
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(db.ctx, `SELECT id, token FROM sessions WHERE token_hash = '' AND token != ''`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+
+	for rows.Next() {
+		var id, token string
+
+		if err := rows.Scan(&id, &token); err != nil {
+			return err
+		}
+
+		if isTokenHash(token) {
+			hashes[id] = token
+		} else {
+			hashes[id] = hashToken(token)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows.Close()
+
+	for id, hash := range hashes {
+		if _, err := tx.ExecContext(db.ctx, `UPDATE sessions SET token = '', token_hash = ? WHERE id = ?`, hash, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// isTokenHash returns true if the value looks like a hash returned by
+// hashToken. Generated tokens are base64 encoded and practically never of this form.
+func isTokenHash(v string) bool {
+	if len(v) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(v)
+	return err == nil && strings.ToLower(v) == v
+}
+
+// migrateSessionExpiry sets the expiry of sessions created before sessions
+// expired to the default lifetime after their creation.
+func (db *DB) migrateSessionExpiry() error {
+	_, err := db.db.ExecContext(db.ctx, `
+		UPDATE sessions
+		SET expires_at = created_at + ?
+		WHERE expires_at = 0
+	`,
+		gofman.DefaultSessionTTL,
+	)
+
+	return err
+}
@@ -0,0 +1,379 @@
+package sqlite_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestSessionService_CreateSession(t *testing.T) {
+	t.Run("MaxSessionsReject", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+		s := sqlite.NewSessionService(db)
+		s.MaxSessions = 2
+		s.MaxSessionsStrategy = sqlite.SessionLimitReject
+
+		for i := 0; i < 2; i++ {
+			if err := s.CreateSession(ctx, &gofman.Session{UserID: user.ID, Token: NewToken(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.CreateSession(ctx, &gofman.Session{UserID: user.ID, Token: NewToken(2)}); gofman.ErrorCode(err) != gofman.ECONFLICT {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("MaxSessionsEvict", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+		s := sqlite.NewSessionService(db)
+		s.MaxSessions = 2
+		s.MaxSessionsStrategy = sqlite.SessionLimitEvict
+
+		var sessions []*gofman.Session
+		for i := 0; i < 3; i++ {
+			session := &gofman.Session{UserID: user.ID, Token: NewToken(i)}
+			if err := s.CreateSession(ctx, session); err != nil {
+				t.Fatal(err)
+			}
+
+			sessions = append(sessions, session)
+		}
+
+		found, n, err := s.FindSessions(ctx, gofman.SessionFilter{UserID: &user.ID})
+		if err != nil {
+			t.Fatal(err)
+		} else if n != 2 {
+			t.Fatalf("Expected two sessions, got %d", n)
+		} else if found[0].ID != sessions[1].ID || found[1].ID != sessions[2].ID {
+			t.Fatal("Expected oldest session to be evicted.")
+		}
+	})
+}
+
+func TestSessionService_FindSessions(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	s := sqlite.NewSessionService(db)
+
+	var sessions []*gofman.Session
+	for i := 0; i < gofman.MaxSessionLimit+1; i++ {
+		sessions = append(sessions, MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(i)}))
+	}
+
+	// Sessions created within the same second are ordered by ID.
+	MustExec(t, db, `UPDATE sessions SET created_at = 1`)
+
+	t.Run("DefaultLimit", func(t *testing.T) {
+		found, n, err := s.FindSessions(ctx, gofman.SessionFilter{UserID: &user.ID})
+		if err != nil {
+			t.Fatal(err)
+		} else if n != len(sessions) {
+			t.Fatalf("Unexpected total: %d", n)
+		} else if len(found) != gofman.DefaultSessionLimit {
+			t.Fatalf("Unexpected number of sessions: %d", len(found))
+		}
+
+		for i := 1; i < len(found); i++ {
+			if found[i-1].ID > found[i].ID {
+				t.Fatal("Expected sessions to be ordered by ID.")
+			}
+		}
+	})
+
+	t.Run("MaxLimit", func(t *testing.T) {
+		found, _, err := s.FindSessions(ctx, gofman.SessionFilter{UserID: &user.ID, Limit: 1000})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(found) != gofman.MaxSessionLimit {
+			t.Fatalf("Unexpected number of sessions: %d", len(found))
+		}
+	})
+
+	t.Run("SortDesc", func(t *testing.T) {
+		MustExec(t, db, `UPDATE sessions SET created_at = ? WHERE id = ?`, 2, sessions[3].ID)
+
+		found, _, err := s.FindSessions(ctx, gofman.SessionFilter{UserID: &user.ID, SortDesc: true, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if found[0].ID != sessions[3].ID {
+			t.Fatalf("Expected newest session first, got %s", found[0].ID)
+		}
+
+		again, _, err := s.FindSessions(ctx, gofman.SessionFilter{UserID: &user.ID, SortDesc: true, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if found[1].ID != again[1].ID {
+			t.Fatal("Expected stable order.")
+		}
+	})
+}
+
+func TestSessionService_FindSessionForToken(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	session := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(1)})
+
+	s := sqlite.NewSessionService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		if found, err := s.FindSessionForToken(context.Background(), session.ID, NewToken(1)); err != nil {
+			t.Fatal(err)
+		} else if found.ID != session.ID || found.UserID != user.ID {
+			t.Fatalf("Unexpected session: %#v", found)
+		}
+	})
+
+	t.Run("ErrWrongToken", func(t *testing.T) {
+		if _, err := s.FindSessionForToken(context.Background(), session.ID, NewToken(2)); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("DefaultExpiry", func(t *testing.T) {
+		if session.ExpiresAt != session.CreatedAt+gofman.DefaultSessionTTL {
+			t.Fatalf("Unexpected expiry: %d", session.ExpiresAt)
+		}
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		client := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(5), UserAgent: "Mozilla/5.0", IP: "192.0.2.1"})
+
+		if found, err := s.FindSessionForToken(context.Background(), client.ID, NewToken(5)); err != nil {
+			t.Fatal(err)
+		} else if found.UserAgent != "Mozilla/5.0" || found.IP != "192.0.2.1" {
+			t.Fatalf("Unexpected client: %#v", found)
+		}
+
+		if sessions, _, err := s.FindSessions(context.Background(), gofman.SessionFilter{ID: &client.ID}); err != nil {
+			t.Fatal(err)
+		} else if len(sessions) != 1 || sessions[0].UserAgent != "Mozilla/5.0" || sessions[0].IP != "192.0.2.1" {
+			t.Fatalf("Unexpected sessions: %#v", sessions)
+		}
+	})
+
+	t.Run("SessionTTL", func(t *testing.T) {
+		remembered := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(4), TTL: 30 * 24 * 60 * 60})
+
+		if remembered.ExpiresAt != remembered.CreatedAt+30*24*60*60 {
+			t.Fatalf("Unexpected expiry: %d", remembered.ExpiresAt)
+		}
+	})
+
+	t.Run("ErrExpired", func(t *testing.T) {
+		expired := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(3), ExpiresAt: 1})
+
+		if _, err := s.FindSessionForToken(context.Background(), expired.ID, NewToken(3)); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		if sessions, _, err := s.FindSessions(context.Background(), gofman.SessionFilter{UserID: &user.ID}); err != nil {
+			t.Fatal(err)
+		} else {
+			for _, found := range sessions {
+				if found.ID == expired.ID {
+					t.Fatal("Expected expired session to be excluded.")
+				}
+			}
+		}
+	})
+
+	t.Run("HashedToken", func(t *testing.T) {
+		if token := MustQueryString(t, db, `SELECT token FROM sessions WHERE id = ?`, session.ID); token != "" {
+			t.Fatalf("Expected plaintext token not to be stored, got %q", token)
+		}
+
+		stored := MustQueryString(t, db, `SELECT token_hash FROM sessions WHERE id = ?`, session.ID)
+		if stored == "" || stored == NewToken(1) {
+			t.Fatal("Expected token to be stored hashed.")
+		}
+
+		// The stored hash must not be accepted as a token.
+		if _, err := s.FindSessionForToken(context.Background(), session.ID, stored); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestSessionService_DeleteSession(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	s := sqlite.NewSessionService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		session := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(1)})
+
+		if err := s.DeleteSession(ctx, session.ID); err != nil {
+			t.Fatal(err)
+		} else if got := MustQueryString(t, db, `SELECT COUNT(*) FROM sessions WHERE id = ?`, session.ID); got != "0" {
+			t.Fatal("Expected session to be deleted.")
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(2), ExpiresAt: 1})
+
+		if err := s.DeleteSession(ctx, expired.ID); err != nil {
+			t.Fatal(err)
+		} else if got := MustQueryString(t, db, `SELECT COUNT(*) FROM sessions WHERE id = ?`, expired.ID); got != "0" {
+			t.Fatal("Expected expired session to be deleted.")
+		}
+	})
+
+	t.Run("ErrOtherUser", func(t *testing.T) {
+		session := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(3)})
+
+		if err := s.DeleteSession(otherCtx, session.ID); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if err := s.DeleteSession(ctx, "unknown"); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestSessionService_MigrateTokens(t *testing.T) {
+	db := MustOpenDB(t)
+
+	user, _ := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	// Sessions created before tokens were hashed.
+	now := time.Now().Unix()
+
+	MustExec(t, db, `INSERT INTO sessions (id, users_id, token, created_at) VALUES (?, ?, ?, ?)`,
+		"plaintext", user.ID, NewToken(1), now,
+	)
+	MustExec(t, db, `INSERT INTO sessions (id, users_id, token, created_at) VALUES (?, ?, ?, ?)`,
+		"hashed", user.ID, "cd2eb0837c9b4c962c22d2ff8b5441b7b45805887f051d39bf133b583baf6860", now,
+	)
+
+	MustCloseDB(t, db)
+
+	reopened := sqlite.NewDB()
+	reopened.DSN = db.DSN
+	reopened.AuthService = db.AuthService
+
+	if err := reopened.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer MustCloseDB(t, reopened)
+
+	s := sqlite.NewSessionService(reopened)
+
+	if session, err := s.FindSessionForToken(context.Background(), "plaintext", NewToken(1)); err != nil {
+		t.Fatal(err)
+	} else if session.ExpiresAt != now+gofman.DefaultSessionTTL {
+		t.Fatalf("Unexpected expiry: %d", session.ExpiresAt)
+	} else if token := MustQueryString(t, reopened, `SELECT token FROM sessions WHERE id = ?`, "plaintext"); token != "" {
+		t.Fatalf("Expected plaintext token to be cleared, got %q", token)
+	}
+
+	// The hash of a session created by an earlier version is kept as is.
+	if hash := MustQueryString(t, reopened, `SELECT token_hash FROM sessions WHERE id = ?`, "hashed"); hash != "cd2eb0837c9b4c962c22d2ff8b5441b7b45805887f051d39bf133b583baf6860" {
+		t.Fatalf("Unexpected hash: %q", hash)
+	}
+}
+
+// NewToken returns a valid session token that is unique for i.
+func NewToken(i int) string {
+	return fmt.Sprintf("%032d", i)
+}
+
+func TestSessionService_PurgeExpired(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	expired0 := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(1), ExpiresAt: 1})
+	expired1 := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(2), ExpiresAt: 2})
+	live := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(3)})
+
+	s := sqlite.NewSessionService(db)
+
+	if n, err := s.PurgeExpired(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("Unexpected purged sessions: %d", n)
+	}
+
+	for _, id := range []string{expired0.ID, expired1.ID} {
+		if got := MustQueryString(t, db, `SELECT COUNT(*) FROM sessions WHERE id = ?`, id); got != "0" {
+			t.Fatalf("Expected session %s to be purged.", id)
+		}
+	}
+
+	if got := MustQueryString(t, db, `SELECT COUNT(*) FROM sessions WHERE id = ?`, live.ID); got != "1" {
+		t.Fatal("Expected live session to be kept.")
+	}
+
+	if n, err := s.PurgeExpired(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("Unexpected purged sessions: %d", n)
+	}
+}
+
+func TestSessionService_FindSessionsForUser(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	older := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(1)})
+	newer := MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(2)})
+	MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(3), ExpiresAt: 1})
+	MustCreateSession(t, otherCtx, db, &gofman.Session{UserID: other.ID, Token: NewToken(4)})
+
+	s := sqlite.NewSessionService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		sessions, err := s.FindSessionsForUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(sessions) != 2 {
+			t.Fatalf("Unexpected sessions: %d", len(sessions))
+		} else if sessions[0].ID != newer.ID || sessions[1].ID != older.ID {
+			t.Fatalf("Unexpected order: %s, %s", sessions[0].ID, sessions[1].ID)
+		}
+
+		for _, session := range sessions {
+			if session.Token != "" {
+				t.Fatal("Expected token to be redacted.")
+			}
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, err := s.FindSessionsForUser(otherCtx, user.ID); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if _, err := s.FindSessionsForUser(context.Background(), user.ID); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
@@ -30,10 +30,66 @@ func (s *SetupService) ShouldRunSetup(ctx context.Context) (bool, error) {
 
 	defer tx.Rollback()
 
-	users, _, err := findUsers(ctx, tx, gofman.UserFilter{Limit: 1})
+	n, err := countAllUsers(ctx, tx)
 	if err != nil {
 		return false, err
 	}
 
-	return (len(users) > 0), nil
+	return n == 0, nil
+}
+
+// RunSetup creates the given user as the first admin. The setup can only be
+// run once.
+// Returns ECONFLICT if any user already exists.
+func (s *SetupService) RunSetup(ctx context.Context, user *gofman.User) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := runSetup(ctx, tx, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// runSetup creates the given user as the first admin.
+// Returns ECONFLICT if any user already exists.
+func runSetup(ctx context.Context, tx *Tx, user *gofman.User) error {
+	if n, err := countAllUsers(ctx, tx); err != nil {
+		return err
+	} else if n != 0 {
+		return gofman.NewError(gofman.ECONFLICT, "Setup has already been run.")
+	}
+
+	// Nobody is logged in during the setup, so the user is created on behalf
+	// of an admin.
+	admin := gofman.NewContextWithUser(ctx, &gofman.User{IsAdmin: true})
+
+	if err := createUser(admin, tx, user, ""); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET role = ?, is_admin = TRUE WHERE id = ?`, gofman.RoleAdmin, user.ID); err != nil {
+		return err
+	}
+
+	user.SetRole(gofman.RoleAdmin)
+
+	return nil
+}
+
+// countAllUsers returns the number of users including removed ones. It does
+// not authorize the current user, as nobody is logged in before the setup.
+func countAllUsers(ctx context.Context, tx *Tx) (int, error) {
+	var n int
+
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
 }
@@ -0,0 +1,48 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestSetupService_RunSetup(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	s := sqlite.NewSetupService(db)
+
+	user := &gofman.User{Username: "admin", Password: "password"}
+	if err := s.RunSetup(context.Background(), user); err != nil {
+		t.Fatal(err)
+	} else if !user.IsAdmin {
+		t.Fatal("Expected user to be an admin.")
+	}
+
+	if err := s.RunSetup(context.Background(), &gofman.User{Username: "other", Password: "password"}); gofman.ErrorCode(err) != gofman.ECONFLICT {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
+
+func TestSetupService_ShouldRunSetup(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	s := sqlite.NewSetupService(db)
+
+	if ok, err := s.ShouldRunSetup(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("Expected setup to run on an empty database.")
+	}
+
+	MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	if ok, err := s.ShouldRunSetup(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("Expected setup not to run once users exist.")
+	}
+}
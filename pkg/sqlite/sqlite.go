@@ -3,30 +3,46 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"embed"
 	"fmt"
 	"io/fs"
+	"log"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/dhenkes/gofman/pkg/gofman"
-	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
-//go:embed migration/*.sql
+//go:embed migration/*.sql migration/fts5/*.sql
 var migrationFS embed.FS
 
+// Database constants.
+const (
+	DefaultBusyTimeout = 5 * time.Second
+
+	// Number of times BeginTx is retried if the database is busy and the
+	// delay before the first retry. The delay doubles with every retry.
+	maxBusyRetries = 3
+	busyRetryDelay = 10 * time.Millisecond
+)
+
 // DB represents a database connection to our application.
 type DB struct {
 	db     *sql.DB
 	ctx    context.Context
 	cancel func()
 
+	// Set if the full-text search index exists, see migrateSearch.
+	search bool
+
 	// Datasource name. Is automatically generated by calling NewDB() or SetDSN()
 	DSN string
 
-	// Returns a new ID. Defaults to a UUID v4.
+	// Returns a new ID. Defaults to ULIDs, which sort by creation time and are
+	// monotonic within a millisecond, see ulidGenerator.
 	ID func() (string, error)
 
 	// Returns the current time as a unix timestamp.
@@ -35,6 +51,41 @@ type DB struct {
 	// AuthService is required to generate passwords, tokens and verify password
 	// hashes
 	AuthService gofman.AuthService
+
+	// Optional check a new password has to pass before it is hashed, for
+	// example auth.PasswordPolicy.Check.
+	CheckPassword func(password string) error
+
+	// PathTraversalService is required to compare the files on disk with the
+	// files in the database, to recompute checksums and to resolve paths
+	// relative to the storage root. Everything else works without it.
+	PathTraversalService gofman.PathTraversalService
+
+	// Settings new and changed files are validated against. Defaults to
+	// gofman.DefaultFileConfig.
+	FileConfig gofman.FileConfig
+
+	// StorageRoot is the directory relative file paths are resolved against.
+	StorageRoot string
+
+	// If set, file paths below the storage root are stored relative to it.
+	// Existing absolute paths are converted when the database is opened.
+	RelativePaths bool
+
+	// Queries taking longer than SlowQueryThreshold are logged together with
+	// the request ID of their context. Zero disables logging.
+	SlowQueryThreshold time.Duration
+
+	// Logger used for slow queries. Defaults to the standard logger.
+	Logger *log.Logger
+
+	// How long a connection waits for a lock held by another connection
+	// before failing with SQLITE_BUSY. Defaults to DefaultBusyTimeout.
+	BusyTimeout time.Duration
+
+	// Use a rollback journal instead of write-ahead logging. WAL does not
+	// work on network filesystems.
+	DisableWAL bool
 }
 
 // NewDB returns a new instance of DB.
@@ -42,6 +93,9 @@ func NewDB() *DB {
 	db := &DB{
 		ID:  id,
 		Now: now,
+
+		FileConfig:  gofman.DefaultFileConfig(),
+		BusyTimeout: DefaultBusyTimeout,
 	}
 
 	db.ctx, db.cancel = context.WithCancel(context.Background())
@@ -55,52 +109,163 @@ func (db *DB) Open() (err error) {
 		return gofman.NewError(gofman.EINVALID, "DSN required.")
 	}
 
-	if db.db, err = sql.Open("sqlite3", db.DSN); err != nil {
+	db.db = sql.OpenDB(&connector{
+		dsn:    db.DSN,
+		driver: &sqlite3.SQLiteDriver{ConnectHook: db.initConn},
+	})
+
+	// The journal mode is stored in the database file, so unlike the pragmas
+	// in initConn it only has to be set once.
+	journalMode := "wal"
+	if db.DisableWAL {
+		journalMode = "delete"
+	}
+
+	if _, err := db.db.Exec(`PRAGMA journal_mode = ` + journalMode + `;`); err != nil {
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not set journal mode %s.", journalMode)
+	}
+
+	if err := db.Migrate(db.ctx); err != nil {
 		return err
 	}
 
-	if _, err := db.db.Exec(`PRAGMA journal_mode = wal;`); err != nil {
-		return gofman.NewError(gofman.EINTERNAL, "Could not enable wal: %v", err)
+	if err := db.migrateSessionTokens(); err != nil {
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not hash session tokens.")
 	}
 
-	if _, err := db.db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
-		return gofman.NewError(gofman.EINTERNAL, "Could not enable foreign keys: %v", err)
+	if err := db.migrateSessionExpiry(); err != nil {
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not set session expiry.")
 	}
 
-	if err := db.migrate(); err != nil {
-		return err
+	if err := db.migrateUserRoles(); err != nil {
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not set user roles.")
+	}
+
+	if db.RelativePaths {
+		if err := db.migrateRelativePaths(); err != nil {
+			return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not convert file paths.")
+		}
 	}
 
 	return nil
 }
 
-// migrate runs all non-executed migration files from the sqlite/migration
-// folder.
-func (db *DB) migrate() error {
-	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY);`)
+// initConn prepares a new connection of the pool. Pragmas only apply to the
+// connection they are run on, so they must be run for every connection.
+func (db *DB) initConn(conn *sqlite3.SQLiteConn) error {
+	if _, err := conn.Exec(fmt.Sprintf(`PRAGMA busy_timeout = %d;`, db.BusyTimeout.Milliseconds()), nil); err != nil {
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not set busy timeout.")
+	}
+
+	if _, err := conn.Exec(`PRAGMA foreign_keys = ON;`, nil); err != nil {
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not enable foreign keys.")
+	}
+
+	return nil
+}
+
+// connector opens new connections to the database and prepares them using
+// the connect hook of the driver.
+type connector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+// Connect implements the driver.Connector interface.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver implements the driver.Connector interface.
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Migrate runs all non-executed migration files from the sqlite/migration
+// folder in order, each in its own transaction, and adds missing columns.
+// Executed files are tracked by name in the migrations table, so running it
+// again is a no-op. Open calls it automatically.
+func (db *DB) Migrate(ctx context.Context) error {
+	_, err := db.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY);`)
 	if err != nil {
-		return gofman.NewError(gofman.EINTERNAL, "Could not create migrations table: %v", err)
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not create migrations table.")
 	}
 
-	names, err := fs.Glob(migrationFS, "migration/*.sql")
+	names, err := migrationNames()
 	if err != nil {
 		return err
 	}
 
-	sort.Strings(names)
-
 	for _, name := range names {
-		if err := db.migrateFile(name); err != nil {
-			return gofman.NewError(gofman.EINTERNAL, "Error during migration in %q: %v", name, err)
+		if err := db.migrateFile(ctx, name); err != nil {
+			return gofman.NewWrappedError(gofman.EINTERNAL, err, "Error during migration in %q.", name)
 		}
 	}
 
+	for _, c := range migrationColumns {
+		if err := db.migrateColumn(ctx, c.table, c.name, c.definition); err != nil {
+			return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not add column %s.%s.", c.table, c.name)
+		}
+	}
+
+	if err := db.migrateSearch(ctx); err != nil {
+		return gofman.NewWrappedError(gofman.EINTERNAL, err, "Could not create search index.")
+	}
+
 	return nil
 }
 
+// migrationColumns lists columns added to existing tables. SQLite does not
+// support ADD COLUMN IF NOT EXISTS, so they are added by migrateColumn
+// instead of a migration file to keep migrations safe to run again.
+var migrationColumns = []struct {
+	table      string
+	name       string
+	definition string
+}{
+	{table: "files", name: "description", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "sessions", name: "token_hash", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "sessions", name: "expires_at", definition: "BIGINT NOT NULL DEFAULT 0"},
+	{table: "sessions", name: "user_agent", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "sessions", name: "ip", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "users", name: "role", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "files", name: "size", definition: "BIGINT NOT NULL DEFAULT 0"},
+	{table: "files", name: "folder_id", definition: "UUID REFERENCES folders(id) ON DELETE RESTRICT"},
+	{table: "files", name: "checksum_algo", definition: "TEXT NOT NULL DEFAULT 'sha256'"},
+}
+
+// migrateColumn adds a column to a table if it does not exist yet.
+func (db *DB) migrateColumn(ctx context.Context, table, name, definition string) error {
+	var n int
+
+	err := db.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, name).Scan(&n)
+	if err != nil {
+		return err
+	}
+
+	if n != 0 {
+		return nil
+	}
+
+	_, err = db.db.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+name+` `+definition)
+	return err
+}
+
+// migrationNames returns the sorted names of all embedded migration files.
+func migrationNames() ([]string, error) {
+	names, err := fs.Glob(migrationFS, "migration/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
 // migrateFile takes a migration file name and executes it's content.
-func (db *DB) migrateFile(name string) error {
-	tx, err := db.db.Begin()
+func (db *DB) migrateFile(ctx context.Context, name string) error {
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -109,7 +274,7 @@ func (db *DB) migrateFile(name string) error {
 
 	var n int
 
-	err = tx.QueryRow(`SELECT COUNT(*) FROM migrations WHERE name = ?`, name).Scan(&n)
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM migrations WHERE name = ?`, name).Scan(&n)
 	if err != nil {
 		return err
 	}
@@ -118,19 +283,72 @@ func (db *DB) migrateFile(name string) error {
 		return nil
 	}
 
+	if check := migrationChecks[name]; check != nil {
+		if err := check(ctx, tx); err != nil {
+			return err
+		}
+	}
+
 	if buf, err := fs.ReadFile(migrationFS, name); err != nil {
 		return err
-	} else if _, err := tx.Exec(string(buf)); err != nil {
+	} else if _, err := tx.ExecContext(ctx, string(buf)); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(`INSERT INTO migrations (name) VALUES (?)`, name); err != nil {
+	if _, err := tx.ExecContext(ctx, `INSERT INTO migrations (name) VALUES (?)`, name); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
+// migrationChecks lists checks run before a migration file is executed. They
+// return an error explaining how to fix data the migration cannot fix by
+// itself.
+var migrationChecks = map[string]func(ctx context.Context, tx *sql.Tx) error{
+	"migration/00000003.sql": checkUsernameCase,
+}
+
+// checkUsernameCase returns ECONFLICT listing the usernames that differ only
+// in case, as migration 00000003 makes usernames unique regardless of case.
+// Which of the users keeps the name cannot be decided automatically.
+func checkUsernameCase(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT group_concat(username, ', ')
+		FROM (SELECT username FROM users ORDER BY username)
+		GROUP BY LOWER(username)
+		HAVING COUNT(*) > 1
+		ORDER BY LOWER(username)
+	`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	var conflicts []string
+
+	for rows.Next() {
+		var usernames string
+
+		if err := rows.Scan(&usernames); err != nil {
+			return err
+		}
+
+		conflicts = append(conflicts, usernames)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	return gofman.NewError(gofman.ECONFLICT, "Usernames must be unique regardless of case. Rename all but one user of each group before upgrading: %s.", strings.Join(conflicts, "; "))
+}
+
 // Close closes the database connection.
 func (db *DB) Close() error {
 	db.cancel()
@@ -149,9 +367,26 @@ type Tx struct {
 	now int64
 }
 
-// BeginTx starts a transaction and returns a wrapper Tx type.
+// BeginTx starts a transaction and returns a wrapper Tx type. Starting the
+// transaction is retried with backoff while the database is busy. Statements
+// and Commit are never retried, as database/sql ends a transaction whose
+// commit failed. They rely on the busy timeout instead.
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
-	tx, err := db.db.BeginTx(ctx, opts)
+	var tx *sql.Tx
+	var err error
+
+	for i := 0; ; i++ {
+		if tx, err = db.db.BeginTx(ctx, opts); err == nil || !isBusyError(err) || i == maxBusyRetries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(busyRetryDelay << i):
+		}
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -159,23 +394,82 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	return &Tx{
 		Tx:  tx,
 		db:  db,
-		now: now(),
+		now: db.Now(),
 	}, nil
 }
 
-// id is a helper function returning a new UUID v4.
-func id() (string, error) {
-	var err error
+// ExecContext executes a query and logs it if it was slow.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer tx.db.trace(ctx, query, time.Now())
+	return tx.Tx.ExecContext(ctx, query, args...)
+}
 
-	defer func() {
-		if recover() != nil {
-			err = gofman.NewError(gofman.EINTERNAL, "Could not generate UUID.")
-		}
-	}()
+// QueryContext executes a query and logs it if it was slow.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer tx.db.trace(ctx, query, time.Now())
+	return tx.Tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query and logs it if it was slow.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer tx.db.trace(ctx, query, time.Now())
+	return tx.Tx.QueryRowContext(ctx, query, args...)
+}
+
+// trace logs the query together with the request ID of the context if it took
+// longer than the slow query threshold.
+func (db *DB) trace(ctx context.Context, query string, start time.Time) {
+	if db.SlowQueryThreshold <= 0 {
+		return
+	}
+
+	d := time.Since(start)
+	if d < db.SlowQueryThreshold {
+		return
+	}
+
+	db.logger().Printf("Slow query: request_id=%q duration=%s query=%q",
+		gofman.RequestIDFromContext(ctx), d, strings.Join(strings.Fields(query), " "))
+}
+
+// logger returns the logger of the database, which defaults to the standard
+// logger.
+func (db *DB) logger() *log.Logger {
+	if db.Logger == nil {
+		return log.Default()
+	}
+
+	return db.Logger
+}
 
-	id := uuid.NewString()
+// exists returns true if a row with the given ID exists in the table, has not
+// been removed and belongs to the current user. Rows of other users are
+// reported as missing. The table name is never user input.
+func exists(ctx context.Context, tx *Tx, table, id string) (bool, error) {
+	userID := gofman.UserIDFromContext(ctx)
+	if userID == "" {
+		return false, nil
+	}
+
+	var n int
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT 1
+		FROM `+table+`
+		WHERE id = ? AND users_id = ? AND removed_at = 0
+		LIMIT 1
+	`,
+		id,
+		userID,
+	).Scan(&n)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
 
-	return id, err
+	return true, nil
 }
 
 // now is a helper function returning the current unix timestamp.
@@ -183,6 +477,63 @@ func now() int64 {
 	return time.Now().Unix()
 }
 
+// clampLimit returns the default if no limit is given and caps the limit at
+// max.
+func clampLimit(limit, def, max int) int {
+	if limit <= 0 {
+		return def
+	} else if limit > max {
+		return max
+	}
+
+	return limit
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern so the string only
+// matches itself. The pattern must be used with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// sortColumns lists the columns each table may be sorted by. SortBy is user
+// input, so it must never reach a query without being checked against this
+// list.
+var sortColumns = map[string]map[string]bool{
+	"files":  {"name": true, "type": true, "size": true, "created_at": true, "updated_at": true},
+	"tags":   {"name": true, "created_at": true, "updated_at": true},
+	"actors": {"name": true, "created_at": true, "updated_at": true},
+	"users":  {"username": true, "created_at": true, "updated_at": true},
+}
+
+// formatOrderBy returns an ORDER BY clause for the given table. An empty
+// column sorts by created_at. Ties are broken by id so paging is stable.
+// Returns EINVALID if the column is not allowed for the table.
+func formatOrderBy(table, column string, desc bool) (string, error) {
+	if column == "" {
+		column = "created_at"
+	}
+
+	if !sortColumns[table][column] {
+		return "", gofman.NewError(gofman.EINVALID, "Cannot sort by %q.", column)
+	}
+
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+
+	return fmt.Sprintf(`ORDER BY %s %s, id ASC`, column, order), nil
+}
+
+// checkOffset returns EINVALID if the offset of a filter is negative.
+func checkOffset(offset int) error {
+	if offset < 0 {
+		return gofman.NewError(gofman.EINVALID, "Offset must not be negative.")
+	}
+
+	return nil
+}
+
 // formatLimitOffset returns a SQL string for a given limit & offset.
 func formatLimitOffset(limit, offset int) string {
 	if limit > 0 && offset > 0 {
@@ -0,0 +1,247 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/path_traversal"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestDB_SlowQueryThreshold(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	var buf bytes.Buffer
+	db.Logger = log.New(&buf, "", 0)
+	db.SlowQueryThreshold = time.Nanosecond
+
+	ctx := gofman.NewContextWithRequestID(context.Background(), "req-1")
+	ctx = gofman.NewContextWithUser(ctx, &gofman.User{IsAdmin: true})
+
+	if _, _, err := sqlite.NewUserService(db).FindUsers(ctx, gofman.UserFilter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if line := buf.String(); !strings.HasPrefix(line, `Slow query: request_id="req-1" `) {
+		t.Fatalf("Unexpected log line: %q", line)
+	} else if !strings.Contains(line, "FROM users") {
+		t.Fatalf("Expected query in log line: %q", line)
+	}
+}
+
+func TestDB_BusyTimeout(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+
+	tx0, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tx0.Rollback()
+
+	if _, err := tx0.ExecContext(ctx, `INSERT INTO migrations (name) VALUES ('busy0')`); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second writer has to wait for the lock of the first one instead of
+	// failing right away.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		tx0.Commit()
+	}()
+
+	tx1, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tx1.Rollback()
+
+	if _, err := tx1.ExecContext(ctx, `INSERT INTO migrations (name) VALUES ('busy1')`); err != nil {
+		t.Fatal(err)
+	} else if err := tx1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_Pragmas(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		ctx := context.Background()
+
+		// Concurrent transactions use different connections of the pool, all
+		// of them must enforce foreign keys.
+		for i := 0; i < 2; i++ {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tx.Rollback()
+
+			var foreignKeys int
+			var journalMode string
+
+			if err := tx.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+				t.Fatal(err)
+			} else if foreignKeys != 1 {
+				t.Fatalf("Expected foreign keys on connection %d", i)
+			}
+
+			if err := tx.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+				t.Fatal(err)
+			} else if journalMode != "wal" {
+				t.Fatalf("Unexpected journal mode: %q", journalMode)
+			}
+		}
+	})
+
+	t.Run("DisableWAL", func(t *testing.T) {
+		db := sqlite.NewDB()
+		db.DSN = filepath.Join(t.TempDir(), "db")
+		db.AuthService = auth.NewAuthService()
+		db.DisableWAL = true
+
+		if err := db.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		defer MustCloseDB(t, db)
+
+		if mode := MustQueryString(t, db, `PRAGMA journal_mode`); mode != "delete" {
+			t.Fatalf("Unexpected journal mode: %q", mode)
+		}
+	})
+}
+
+func TestDB_Migrate(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+
+	// Open already ran all migrations, running them again must not change
+	// anything.
+	migrations := MustQueryString(t, db, `SELECT group_concat(name) FROM migrations`)
+	schema := MustQueryString(t, db, `SELECT group_concat(sql) FROM sqlite_master`)
+
+	if migrations == "" {
+		t.Fatal("Expected executed migrations")
+	}
+
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := MustQueryString(t, db, `SELECT group_concat(name) FROM migrations`); got != migrations {
+		t.Fatalf("Unexpected migrations: %q", got)
+	} else if got := MustQueryString(t, db, `SELECT group_concat(sql) FROM sqlite_master`); got != schema {
+		t.Fatalf("Unexpected schema: %q", got)
+	}
+}
+
+// MustOpenDB returns a new, open DB in a temporary directory. The clock of the
+// DB advances by one second on every call so rows are ordered by creation.
+// Fatal on error.
+func MustOpenDB(tb testing.TB) *sqlite.DB {
+	tb.Helper()
+
+	var now int64 = 1000000000
+
+	db := sqlite.NewDB()
+	db.DSN = filepath.Join(tb.TempDir(), "db")
+	db.AuthService = auth.NewAuthService()
+	db.PathTraversalService = path_traversal.NewPathTraversalService()
+	db.Now = func() int64 { now++; return now }
+
+	if err := db.Open(); err != nil {
+		tb.Fatal(err)
+	}
+
+	return db
+}
+
+// MustCloseDB closes the DB. Fatal on error.
+func MustCloseDB(tb testing.TB, db *sqlite.DB) {
+	tb.Helper()
+
+	if err := db.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// MustCreateUser creates a user in the database and returns a context with
+// that user set as the current user. Fatal on error.
+func MustCreateUser(tb testing.TB, ctx context.Context, db *sqlite.DB, user *gofman.User) (*gofman.User, context.Context) {
+	tb.Helper()
+
+	admin := gofman.NewContextWithUser(ctx, &gofman.User{IsAdmin: true})
+
+	if err := sqlite.NewUserService(db).CreateUser(admin, user); err != nil {
+		tb.Fatal(err)
+	}
+
+	return user, gofman.NewContextWithUser(ctx, user)
+}
+
+// MustCreateSession creates a session for the given user. Fatal on error.
+func MustCreateSession(tb testing.TB, ctx context.Context, db *sqlite.DB, session *gofman.Session) *gofman.Session {
+	tb.Helper()
+
+	if err := sqlite.NewSessionService(db).CreateSession(ctx, session); err != nil {
+		tb.Fatal(err)
+	}
+
+	return session
+}
+
+// MustExec executes a raw query against the database file of db. This allows
+// tests to seed rows that are not reachable through a service. Fatal on error.
+func MustExec(tb testing.TB, db *sqlite.DB, query string, args ...interface{}) {
+	tb.Helper()
+
+	conn, err := sql.Open("sqlite3", db.DSN)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.Exec(query, args...); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// MustQueryString runs a raw query against the database file of db and returns
+// the single string it selects. Fatal on error.
+func MustQueryString(tb testing.TB, db *sqlite.DB, query string, args ...interface{}) string {
+	tb.Helper()
+
+	conn, err := sql.Open("sqlite3", db.DSN)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	var v string
+	if err := conn.QueryRow(query, args...).Scan(&v); err != nil {
+		tb.Fatal(err)
+	}
+
+	return v
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Ensure service implements interface.
+var _ gofman.StatsService = (*StatsService)(nil)
+
+// StatsService represents a service for collecting monitoring counters.
+type StatsService struct {
+	db *DB
+}
+
+// NewStatsService returns a new instance of StatsService.
+func NewStatsService(db *DB) *StatsService {
+	return &StatsService{db: db}
+}
+
+// Stats counts the users and files of all users and reads the state of the
+// connection pool. It is meant for monitoring only, so no permissions are
+// checked.
+func (s *StatsService) Stats(ctx context.Context) (*gofman.Stats, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var stats gofman.Stats
+
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE removed_at = 0`).Scan(&stats.Users); err != nil {
+		return nil, err
+	}
+
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM files WHERE removed_at = 0`).Scan(&stats.Files); err != nil {
+		return nil, err
+	}
+
+	// The transaction itself holds a connection, so at least one is in use.
+	pool := s.db.db.Stats()
+	stats.OpenConnections = pool.OpenConnections
+	stats.InUseConnections = pool.InUse
+	stats.IdleConnections = pool.Idle
+
+	return &stats, nil
+}
@@ -0,0 +1,49 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestStatsService_Stats(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	_, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	fs := sqlite.NewFileService(db)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		file := &gofman.File{
+			UserID:   gofman.UserIDFromContext(ctx),
+			Name:     name,
+			Type:     "text/plain",
+			Path:     "/" + name,
+			Checksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		}
+
+		if err := fs.CreateFile(ctx, file); err != nil {
+			t.Fatal(err)
+		}
+
+		if name == "b.txt" {
+			if err := fs.RemoveFile(ctx, file.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	stats, err := sqlite.NewStatsService(db).Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Users != 2 || stats.Files != 1 {
+		t.Fatalf("Unexpected counts: %#v", stats)
+	} else if stats.OpenConnections < 1 || stats.InUseConnections < 1 {
+		t.Fatalf("Unexpected connections: %#v", stats)
+	}
+}
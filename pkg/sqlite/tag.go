@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"strings"
 
 	"github.com/dhenkes/gofman/pkg/gofman"
@@ -72,6 +73,29 @@ func (s *TagService) CreateTag(ctx context.Context, tag *gofman.Tag) error {
 	return tx.Commit()
 }
 
+// EnsureTagByName retrieves the tag of the current user with the given
+// name or creates it if it does not exist.
+// Returns EUNAUTHORIZED if the current user is not allowed to create tags.
+func (s *TagService) EnsureTagByName(ctx context.Context, name string) (*gofman.Tag, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	tag, err := ensureTagByName(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
 // UpdateTag updates a tag object.
 // Returns EUNAUTHORIZED if current user is not the creator of the tag.
 // Returns ENOTFOUND if tag does not exist.
@@ -114,10 +138,44 @@ func (s *TagService) RemoveTag(ctx context.Context, id string) error {
 	return tx.Commit()
 }
 
+// RestoreTag resets the removed timestamp of a removed tag.
+// Returns EUNAUTHORIZED if current user is not the creator of the tag.
+// Returns ENOTFOUND if tag does not exist or has not been removed.
+func (s *TagService) RestoreTag(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := restoreTag(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Exists returns true if the tag exists, has not been removed and belongs
+// to the current user. It does not load the tag.
+func (s *TagService) Exists(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	return exists(ctx, tx, "tags", id)
+}
+
 // findTagByID retrieves a tag by ID.
+// Only tags of the current user are found.
 // Returns ENOTFOUND if tag does not exist.
 func findTagByID(ctx context.Context, tx *Tx, id string) (*gofman.Tag, error) {
-	tags, _, err := findTags(ctx, tx, gofman.TagFilter{ID: &id, Limit: 1})
+	userID := gofman.UserIDFromContext(ctx)
+
+	tags, _, err := findTags(ctx, tx, gofman.TagFilter{ID: &id, UserID: &userID, Limit: 1})
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +194,10 @@ func findTags(ctx context.Context, tx *Tx, filter gofman.TagFilter) ([]*gofman.T
 		return nil, 0, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to search using this filter.")
 	}
 
+	if err := checkOffset(filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
 	where, args := []string{"1 = 1"}, []interface{}{}
 
 	if v := filter.ID; v != nil {
@@ -146,7 +208,26 @@ func findTags(ctx context.Context, tx *Tx, filter gofman.TagFilter) ([]*gofman.T
 		where, args = append(where, "users_id = ?"), append(args, *v)
 	}
 
-	where = append(where, "removed_at = 0")
+	if v := filter.NameLike; v != nil {
+		where, args = append(where, `name LIKE '%' || ? || '%' ESCAPE '\'`), append(args, escapeLike(*v))
+	}
+
+	if v := filter.CreatedAfter; v != nil {
+		where, args = append(where, "created_at >= ?"), append(args, *v)
+	}
+
+	if v := filter.CreatedBefore; v != nil {
+		where, args = append(where, "created_at <= ?"), append(args, *v)
+	}
+
+	if !filter.IncludeRemoved {
+		where = append(where, "removed_at = 0")
+	}
+
+	orderBy, err := formatOrderBy("tags", filter.SortBy, filter.SortDesc)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
@@ -159,8 +240,8 @@ func findTags(ctx context.Context, tx *Tx, filter gofman.TagFilter) ([]*gofman.T
 			COUNT(*) OVER()
 		FROM tags
 		WHERE `+strings.Join(where, " AND ")+`
-		ORDER BY created_at ASC
-		`+formatLimitOffset(filter.Limit, filter.Offset),
+		`+orderBy+`
+		`+formatLimitOffset(clampLimit(filter.Limit, gofman.DefaultLimit, gofman.MaxLimit), filter.Offset),
 		args...,
 	)
 
@@ -194,6 +275,103 @@ func findTags(ctx context.Context, tx *Tx, filter gofman.TagFilter) ([]*gofman.T
 	return tags, n, nil
 }
 
+// findTagByName retrieves a tag of the given user by name.
+// Returns ENOTFOUND if tag does not exist.
+func findTagByName(ctx context.Context, tx *Tx, userID string, name string) (*gofman.Tag, error) {
+	var tag gofman.Tag
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			users_id,
+			name,
+			created_at,
+			updated_at,
+			removed_at
+		FROM tags
+		WHERE users_id = ? AND name = ? AND removed_at = 0
+	`,
+		userID,
+		name,
+	).Scan(
+		&tag.ID, &tag.UserID, &tag.Name,
+		&tag.CreatedAt, &tag.UpdatedAt, &tag.RemovedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, gofman.NewError(gofman.ENOTFOUND, "Tag not found.")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// ensureTagByName retrieves the tag of the current user with the given
+// name or creates it if it does not exist. If another transaction created the
+// tag in the meantime the insert is skipped and the tag is looked up again.
+func ensureTagByName(ctx context.Context, tx *Tx, name string) (*gofman.Tag, error) {
+	tag := &gofman.Tag{
+		UserID: gofman.UserIDFromContext(ctx),
+		Name:   name,
+	}
+
+	if err := tag.Validate(); err != nil {
+		return nil, err
+	}
+
+	if gofman.CanUpdateTag(ctx, tag) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to create this tag.")
+	}
+
+	if existing, err := findTagByName(ctx, tx, tag.UserID, name); err == nil {
+		return existing, nil
+	} else if gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		return nil, err
+	}
+
+	if id, err := tx.db.ID(); err != nil {
+		return nil, err
+	} else {
+		tag.ID = id
+	}
+
+	tag.CreatedAt = tx.now
+	tag.UpdatedAt = tag.CreatedAt
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO tags (
+			id,
+			users_id,
+			name,
+			created_at,
+			updated_at,
+			removed_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`,
+		tag.ID,
+		tag.UserID,
+		tag.Name,
+		tag.CreatedAt,
+		tag.UpdatedAt,
+		0,
+	)
+
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return findTagByName(ctx, tx, tag.UserID, name)
+	}
+
+	return tag, nil
+}
+
 // createTag creates a new tag.
 func createTag(ctx context.Context, tx *Tx, tag *gofman.Tag) error {
 	if err := tag.Validate(); err != nil {
@@ -233,7 +411,7 @@ func createTag(ctx context.Context, tx *Tx, tag *gofman.Tag) error {
 	)
 
 	if err != nil {
-		return err
+		return mapSQLiteError(err)
 	}
 
 	return nil
@@ -256,6 +434,8 @@ func updateTag(ctx context.Context, tx *Tx, id string, update gofman.TagUpdate)
 		tag.Name = *v
 	}
 
+	tag.UpdatedAt = tx.now
+
 	if err := tag.Validate(); err != nil {
 		return tag, err
 	}
@@ -263,14 +443,16 @@ func updateTag(ctx context.Context, tx *Tx, id string, update gofman.TagUpdate)
 	_, err = tx.ExecContext(ctx, `
 		UPDATE tags
 		SET name = ?,
+			updated_at = ?
 		WHERE id = ?
 	`,
 		tag.Name,
+		tag.UpdatedAt,
 		id,
 	)
 
 	if err != nil {
-		return tag, err
+		return tag, mapSQLiteError(err)
 	}
 
 	return tag, nil
@@ -305,3 +487,40 @@ func removeTag(ctx context.Context, tx *Tx, id string) error {
 
 	return nil
 }
+
+// restoreTag resets the removed timestamp of a removed tag.
+// Returns EUNAUTHORIZED if current user is not the creator of the tag.
+// Returns ENOTFOUND if tag does not exist or has not been removed.
+// Returns ECONFLICT if another tag uses the name by now.
+func restoreTag(ctx context.Context, tx *Tx, id string) error {
+	userID := gofman.UserIDFromContext(ctx)
+
+	tags, _, err := findTags(ctx, tx, gofman.TagFilter{ID: &id, UserID: &userID, IncludeRemoved: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	if len(tags) == 0 || tags[0].RemovedAt == 0 {
+		return gofman.NewError(gofman.ENOTFOUND, "Removed tag not found.")
+	}
+
+	if gofman.CanUpdateTag(ctx, tags[0]) == false {
+		return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to restore this tag.")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tags
+		SET removed_at = 0
+		WHERE id = ?
+	`,
+		id,
+	)
+
+	if isUniqueConstraintError(err) {
+		return gofman.NewError(gofman.ECONFLICT, "Name already taken.")
+	} else if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,389 @@
+package sqlite_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestTagService_EnsureTagByName(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	ctx := context.Background()
+	user, ctx := MustCreateUser(t, ctx, db, &gofman.User{Username: "jane", Password: "password"})
+
+	s := sqlite.NewTagService(db)
+
+	t.Run("Create", func(t *testing.T) {
+		if tag, err := s.EnsureTagByName(ctx, "new"); err != nil {
+			t.Fatal(err)
+		} else if tag.ID == "" || tag.UserID != user.ID || tag.Name != "new" {
+			t.Fatalf("Unexpected tag: %#v", tag)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		existing := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "existing"})
+
+		if tag, err := s.EnsureTagByName(ctx, "existing"); err != nil {
+			t.Fatal(err)
+		} else if tag.ID != existing.ID {
+			t.Fatalf("Expected existing tag, got %#v", tag)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		// Simulate a concurrent transaction creating the same tag right before
+		// our insert.
+		MustExec(t, db, `
+			CREATE TRIGGER race BEFORE INSERT ON tags WHEN NEW.name = 'race'
+			BEGIN
+				INSERT INTO tags (id, users_id, name, created_at, updated_at, removed_at)
+				VALUES ('other', NEW.users_id, NEW.name, 0, 0, 0);
+			END
+		`)
+
+		if tag, err := s.EnsureTagByName(ctx, "race"); err != nil {
+			t.Fatal(err)
+		} else if tag.ID != "other" {
+			t.Fatalf("Expected concurrently created tag, got %#v", tag)
+		}
+
+		if tag, err := s.EnsureTagByName(ctx, "race"); err != nil {
+			t.Fatal(err)
+		} else if tag.ID != "other" {
+			t.Fatalf("Expected a single tag, got %#v", tag)
+		}
+	})
+}
+
+// MustCreateTag creates a tag in the database. Fatal on error.
+func MustCreateTag(tb testing.TB, ctx context.Context, db *sqlite.DB, tag *gofman.Tag) *gofman.Tag {
+	tb.Helper()
+
+	if err := sqlite.NewTagService(db).CreateTag(ctx, tag); err != nil {
+		tb.Fatal(err)
+	}
+
+	return tag
+}
+
+func TestTagService_Exists(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, ctx2 := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	tag := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "holiday"})
+	removed := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "removed"})
+	MustExec(t, db, `UPDATE tags SET removed_at = 1 WHERE id = ?`, removed.ID)
+
+	s := sqlite.NewTagService(db)
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		id   string
+		want bool
+	}{
+		{name: "OK", ctx: ctx, id: tag.ID, want: true},
+		{name: "NotFound", ctx: ctx, id: "00000000-0000-0000-0000-000000000000"},
+		{name: "Removed", ctx: ctx, id: removed.ID},
+		{name: "OtherUser", ctx: ctx2, id: tag.ID},
+		{name: "Unauthenticated", ctx: context.Background(), id: tag.ID},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := s.Exists(tt.ctx, tt.id); err != nil {
+				t.Fatal(err)
+			} else if ok != tt.want {
+				t.Fatalf("Unexpected result: %v", ok)
+			}
+		})
+	}
+}
+
+func TestTagService_FindTags(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	tag0 := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "blue"})
+	tag1 := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "red"})
+	MustCreateTag(t, otherCtx, db, &gofman.Tag{UserID: other.ID, Name: "green"})
+
+	s := sqlite.NewTagService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		if tags, n, err := s.FindTags(ctx, gofman.TagFilter{UserID: &user.ID}); err != nil {
+			t.Fatal(err)
+		} else if n != 2 || len(tags) != 2 {
+			t.Fatalf("Unexpected tags: n=%d len=%d", n, len(tags))
+		} else if tags[0].ID != tag0.ID || tags[1].ID != tag1.ID {
+			t.Fatalf("Unexpected tags: %#v", tags)
+		}
+	})
+
+	t.Run("ByID", func(t *testing.T) {
+		if tag, err := s.FindTagByID(ctx, tag1.ID); err != nil {
+			t.Fatal(err)
+		} else if tag.ID != tag1.ID {
+			t.Fatalf("Unexpected tag: %#v", tag)
+		}
+	})
+
+	t.Run("NameLike", func(t *testing.T) {
+		MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "100%"})
+		MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "1000"})
+		MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "x_y"})
+		MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "xzy"})
+
+		for _, tt := range []struct {
+			like string
+			want []string
+		}{
+			{like: "0%", want: []string{"100%"}},
+			{like: "_", want: []string{"x_y"}},
+			{like: "X", want: []string{"x_y", "xzy"}},
+			{like: "none", want: nil},
+		} {
+			tags, _, err := s.FindTags(ctx, gofman.TagFilter{UserID: &user.ID, NameLike: &tt.like})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, tag := range tags {
+				names = append(names, tag.Name)
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("Unexpected names for %q: %v", tt.like, names)
+			}
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, _, err := s.FindTags(otherCtx, gofman.TagFilter{UserID: &user.ID}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if _, _, err := s.FindTags(ctx, gofman.TagFilter{}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		if _, err := s.FindTagByID(otherCtx, tag0.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestTagService_FindTags_CreatedRange(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	db.Now = func() int64 { return 100 }
+	MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "old"})
+
+	db.Now = func() int64 { return 200 }
+	recent := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "new"})
+
+	after := int64(150)
+	if tags, n, err := sqlite.NewTagService(db).FindTags(ctx, gofman.TagFilter{UserID: &user.ID, CreatedAfter: &after}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 || tags[0].ID != recent.ID {
+		t.Fatalf("Unexpected tags: %#v", tags)
+	}
+}
+
+func TestTagService_FindTags_Sort(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "b"})
+	MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "c"})
+	MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "a"})
+
+	s := sqlite.NewTagService(db)
+
+	for _, tt := range []struct {
+		name   string
+		filter gofman.TagFilter
+		want   []string
+	}{
+		{name: "Default", filter: gofman.TagFilter{UserID: &user.ID}, want: []string{"b", "c", "a"}},
+		{name: "Desc", filter: gofman.TagFilter{UserID: &user.ID, SortDesc: true}, want: []string{"a", "c", "b"}},
+		{name: "Name", filter: gofman.TagFilter{UserID: &user.ID, SortBy: "name"}, want: []string{"a", "b", "c"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, _, err := s.FindTags(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, tag := range tags {
+				names = append(names, tag.Name)
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("Unexpected tags: %v", names)
+			}
+		})
+	}
+
+	t.Run("ErrSortBy", func(t *testing.T) {
+		if _, _, err := s.FindTags(ctx, gofman.TagFilter{UserID: &user.ID, SortBy: "type"}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestTagService_UpdateTag(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	tag := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "old"})
+
+	s := sqlite.NewTagService(db)
+
+	name := "new"
+	if updated, err := s.UpdateTag(ctx, tag.ID, gofman.TagUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	} else if updated.Name != "new" || updated.UpdatedAt <= tag.UpdatedAt {
+		t.Fatalf("Unexpected tag: %#v", updated)
+	}
+
+	if other, err := s.FindTagByID(ctx, tag.ID); err != nil {
+		t.Fatal(err)
+	} else if other.Name != "new" || other.UpdatedAt <= tag.UpdatedAt || other.CreatedAt != tag.CreatedAt {
+		t.Fatalf("Unexpected tag: %#v", other)
+	}
+}
+
+func TestTagService_CreateTag_ErrConflict(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "a"})
+
+	if err := sqlite.NewTagService(db).CreateTag(ctx, &gofman.Tag{UserID: user.ID, Name: "a"}); gofman.ErrorCode(err) != gofman.ECONFLICT {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
+
+func TestTagService_RestoreTag(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	_, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	tag := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "holiday"})
+
+	s := sqlite.NewTagService(db)
+
+	t.Run("ErrNotRemoved", func(t *testing.T) {
+		if err := s.RestoreTag(ctx, tag.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	if err := s.RemoveTag(ctx, tag.ID); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.FindTagByID(ctx, tag.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+
+	t.Run("IncludeRemoved", func(t *testing.T) {
+		if tags, _, err := s.FindTags(ctx, gofman.TagFilter{UserID: &user.ID, IncludeRemoved: true}); err != nil {
+			t.Fatal(err)
+		} else if len(tags) != 1 || tags[0].RemovedAt == 0 {
+			t.Fatalf("Unexpected tags: %#v", tags)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		// Other users cannot see the tag.
+		if err := s.RestoreTag(otherCtx, tag.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNameTaken", func(t *testing.T) {
+		recreated := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: user.ID, Name: "holiday"})
+
+		if err := s.RestoreTag(ctx, tag.ID); gofman.ErrorCode(err) != gofman.ECONFLICT {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if err := s.RemoveTag(ctx, recreated.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if err := s.RestoreTag(ctx, tag.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if other, err := s.FindTagByID(ctx, tag.ID); err != nil {
+			t.Fatal(err)
+		} else if other.RemovedAt != 0 {
+			t.Fatalf("Unexpected removed at: %d", other.RemovedAt)
+		}
+	})
+}
+
+func TestTagService_FindTags_Limit(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	MustExec(t, db, `
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO tags (id, users_id, name, created_at, updated_at, removed_at)
+		SELECT 'tag' || i, ?, 'tag' || i, i, i, 0 FROM n
+	`, gofman.MaxLimit+1, user.ID)
+
+	s := sqlite.NewTagService(db)
+
+	for _, tt := range []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{name: "Zero", limit: 0, want: gofman.DefaultLimit},
+		{name: "Negative", limit: -1, want: gofman.DefaultLimit},
+		{name: "Small", limit: 2, want: 2},
+		{name: "OverMax", limit: gofman.MaxLimit + 1, want: gofman.MaxLimit},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, n, err := s.FindTags(ctx, gofman.TagFilter{UserID: &user.ID, Limit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			} else if len(tags) != tt.want {
+				t.Fatalf("Unexpected number of tags: %d", len(tags))
+			} else if n != gofman.MaxLimit+1 {
+				t.Fatalf("Unexpected total: %d", n)
+			}
+		})
+	}
+
+	t.Run("ErrNegativeOffset", func(t *testing.T) {
+		if _, _, err := s.FindTags(ctx, gofman.TagFilter{UserID: &user.ID, Offset: -1}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
@@ -0,0 +1,301 @@
+package sqlite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+)
+
+// Ensure service implements interface.
+var _ gofman.TrashService = (*TrashService)(nil)
+
+// TrashService represents a service for permanently removing the removed
+// content of the current user.
+type TrashService struct {
+	db *DB
+}
+
+// NewTrashService returns a new instance of TrashService.
+func NewTrashService(db *DB) *TrashService {
+	return &TrashService{db: db}
+}
+
+// EmptyTrash permanently removes all removed files, folders, actors and tags
+// of the current user together with their relations, and deletes the
+// uploaded files from disk. If dryRun is set nothing is changed and only the
+// number of affected rows and files is returned.
+// Returns EUNAUTHORIZED if no user is logged in.
+func (s *TrashService) EmptyTrash(ctx context.Context, dryRun bool) (*gofman.TrashResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	result, paths, err := emptyTrash(ctx, tx, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		result.DiskFiles = s.db.removeDiskFiles(paths)
+	}
+
+	return result, nil
+}
+
+// PurgeRemoved permanently removes all files, folders, actors and tags of all
+// users that were removed before the given unix timestamp, together with
+// their relations, and deletes the uploaded files from disk. If dryRun is set
+// nothing is changed and only the number of affected rows and files is
+// returned.
+// Returns EUNAUTHORIZED if current user is not an admin.
+func (s *TrashService) PurgeRemoved(ctx context.Context, olderThan int64, dryRun bool) (*gofman.TrashResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	result, paths, err := purgeRemoved(ctx, tx, olderThan, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		result.DiskFiles = s.db.removeDiskFiles(paths)
+	}
+
+	return result, nil
+}
+
+// emptyTrash permanently removes all removed files, folders, actors and tags
+// of the current user and returns the paths of the files to delete from disk.
+// Returns EUNAUTHORIZED if no user is logged in.
+func emptyTrash(ctx context.Context, tx *Tx, dryRun bool) (*gofman.TrashResult, []string, error) {
+	if gofman.CanEmptyTrash(ctx) == false {
+		return nil, nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to empty the trash.")
+	}
+
+	return deleteRemoved(ctx, tx, "users_id = ? AND removed_at != 0", gofman.UserIDFromContext(ctx), dryRun)
+}
+
+// purgeRemoved permanently removes all files, folders, actors and tags removed
+// before the given timestamp and returns the paths of the files to delete
+// from disk.
+// Returns EUNAUTHORIZED if current user is not an admin.
+func purgeRemoved(ctx context.Context, tx *Tx, olderThan int64, dryRun bool) (*gofman.TrashResult, []string, error) {
+	if gofman.CanManageUsers(ctx) == false {
+		return nil, nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to purge removed content.")
+	}
+
+	return deleteRemoved(ctx, tx, "removed_at != 0 AND removed_at < ?", olderThan, dryRun)
+}
+
+// deleteRemoved permanently removes the files, folders, actors and tags
+// matching the condition, which takes a single argument. Relations are
+// deleted first as they restrict the deletion of the rows they reference.
+// The table names and the condition are never user input.
+//
+// Files are only deleted from disk once the transaction is committed, so the
+// paths of the files to delete are returned. If dryRun is set their number is
+// reported instead.
+func deleteRemoved(ctx context.Context, tx *Tx, cond string, arg interface{}, dryRun bool) (*gofman.TrashResult, []string, error) {
+	var result gofman.TrashResult
+
+	for table, n := range map[string]*int{
+		"files":   &result.Files,
+		"folders": &result.Folders,
+		"actors":  &result.Actors,
+		"tags":    &result.Tags,
+	} {
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM `+table+`
+			WHERE `+cond+`
+		`,
+			arg,
+		).Scan(n); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	paths, err := diskFilesOfRemoved(ctx, tx, cond, arg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if dryRun {
+		result.DiskFiles = len(paths)
+		return &result, nil, nil
+	}
+
+	if err := unindexFiles(ctx, tx, cond, arg); err != nil {
+		return nil, nil, err
+	}
+
+	for _, join := range []struct{ table, left, right string }{
+		{"files_actors", "files", "actors"},
+		{"files_tags", "files", "tags"},
+		{"actors_tags", "actors", "tags"},
+	} {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM `+join.table+`
+			WHERE `+join.left+`_id IN (
+				SELECT id FROM `+join.left+` WHERE `+cond+`
+			) OR `+join.right+`_id IN (
+				SELECT id FROM `+join.right+` WHERE `+cond+`
+			)
+		`,
+			arg,
+			arg,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM files
+		WHERE `+cond+`
+	`,
+		arg,
+	); err != nil {
+		return nil, nil, err
+	}
+
+	// Files and folders left in a deleted folder, including restored ones,
+	// are moved to the top level.
+	for _, ref := range []struct{ table, column string }{
+		{"files", "folder_id"},
+		{"folders", "parent_id"},
+	} {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE `+ref.table+`
+			SET `+ref.column+` = NULL
+			WHERE `+ref.column+` IN (
+				SELECT id FROM folders WHERE `+cond+`
+			)
+		`,
+			arg,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, table := range []string{"folders", "actors", "tags"} {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM `+table+`
+			WHERE `+cond+`
+		`,
+			arg,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &result, paths, nil
+}
+
+// diskFilesOfRemoved returns the paths of the files matching the condition
+// that were uploaded below the storage root and are not referenced by any
+// other file.
+func diskFilesOfRemoved(ctx context.Context, tx *Tx, cond string, arg interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT path
+		FROM files
+		WHERE `+cond+` AND path NOT IN (
+			SELECT path FROM files WHERE NOT (`+cond+`)
+		)
+	`,
+		arg,
+		arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var paths []string
+
+	for rows.Next() {
+		var path string
+
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+
+		if path, err = tx.db.resolvePath(path); err != nil {
+			return nil, err
+		}
+
+		if ok, err := tx.db.isBelowStorageRoot(path); err != nil {
+			return nil, err
+		} else if ok {
+			paths = append(paths, path)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// isBelowStorageRoot returns true if the path is below the storage root,
+// where uploaded files are stored. Files outside of it were imported from
+// elsewhere and are never deleted.
+func (db *DB) isBelowStorageRoot(path string) (bool, error) {
+	if db.StorageRoot == "" {
+		return false, nil
+	}
+
+	root, err := db.storageRoot()
+	if err != nil {
+		return false, err
+	}
+
+	if root, err = filepath.Abs(root); err != nil {
+		return false, err
+	} else if path, err = filepath.Abs(path); err != nil {
+		return false, err
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// removeDiskFiles deletes the files at the given paths and returns how many
+// were deleted. Their rows are gone already, so failures are only logged and
+// the file is left behind.
+func (db *DB) removeDiskFiles(paths []string) int {
+	var n int
+
+	for _, path := range paths {
+		if err := os.Remove(path); err == nil {
+			n++
+		} else if !os.IsNotExist(err) {
+			db.logger().Printf("Could not delete file %q: %s", path, err)
+		}
+	}
+
+	return n
+}
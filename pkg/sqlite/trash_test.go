@@ -0,0 +1,207 @@
+package sqlite_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestTrashService_EmptyTrash(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	jane, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	john, ctx2 := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	active := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "active.jpg"})
+	removed := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "removed.jpg"})
+	tag := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: jane.ID, Name: "holiday"})
+	removedTag := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: jane.ID, Name: "old"})
+	removedActor := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: jane.ID, Name: "alice"})
+	other := MustCreateFile(t, ctx2, db, &gofman.File{UserID: john.ID, Name: "other.jpg"})
+
+	MustExec(t, db, `INSERT INTO files_tags (files_id, tags_id) VALUES (?, ?), (?, ?), (?, ?)`,
+		active.ID, tag.ID, removed.ID, tag.ID, active.ID, removedTag.ID)
+	MustExec(t, db, `INSERT INTO files_actors (files_id, actors_id) VALUES (?, ?)`, active.ID, removedActor.ID)
+	MustExec(t, db, `UPDATE files SET removed_at = 1 WHERE id IN (?, ?)`, removed.ID, other.ID)
+	MustExec(t, db, `UPDATE tags SET removed_at = 1 WHERE id = ?`, removedTag.ID)
+	MustExec(t, db, `UPDATE actors SET removed_at = 1 WHERE id = ?`, removedActor.ID)
+
+	s := sqlite.NewTrashService(db)
+
+	t.Run("DryRun", func(t *testing.T) {
+		if result, err := s.EmptyTrash(ctx, true); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{Files: 1, Actors: 1, Tags: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if result, err := s.EmptyTrash(ctx, false); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{Files: 1, Actors: 1, Tags: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+
+		if result, err := s.EmptyTrash(ctx, true); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{}) {
+			t.Fatalf("Expected empty trash, got %#v", result)
+		}
+
+		// Active rows and their relations to active rows are kept.
+		groups, err := sqlite.NewFileService(db).GroupFilesByTag(ctx, gofman.FileGroupFilter{UserID: jane.ID})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(groups) != 1 || groups[0].Tag.ID != tag.ID {
+			t.Fatalf("Unexpected groups: %#v", groups)
+		} else if len(groups[0].Files) != 1 || groups[0].Files[0].ID != active.ID {
+			t.Fatalf("Unexpected files: %#v", groups[0].Files)
+		}
+
+		// The trash of other users is untouched.
+		if result, err := s.EmptyTrash(ctx2, true); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{Files: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, err := s.EmptyTrash(context.Background(), false); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestTrashService_EmptyTrash_FoldersAndDiskFiles(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	db.StorageRoot = t.TempDir()
+
+	jane, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	parent := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: jane.ID, Name: "parent"})
+	child := MustCreateFolder(t, ctx, db, &gofman.Folder{UserID: jane.ID, Name: "child", ParentID: &parent.ID})
+
+	uploadedPath := filepath.Join(db.StorageRoot, jane.ID, "uploaded.jpg")
+	importedPath := filepath.Join(t.TempDir(), "imported.jpg")
+
+	if err := os.MkdirAll(filepath.Dir(uploadedPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{uploadedPath, importedPath} {
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploaded := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "uploaded.jpg", Path: uploadedPath, FolderID: &child.ID})
+	imported := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "imported.jpg", Path: importedPath})
+	restored := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "restored.jpg", FolderID: &child.ID})
+
+	MustExec(t, db, `UPDATE files SET removed_at = 1 WHERE id IN (?, ?)`, uploaded.ID, imported.ID)
+	MustExec(t, db, `UPDATE folders SET removed_at = 1 WHERE id IN (?, ?)`, parent.ID, child.ID)
+
+	s := sqlite.NewTrashService(db)
+
+	t.Run("DryRun", func(t *testing.T) {
+		if result, err := s.EmptyTrash(ctx, true); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{Files: 2, Folders: 2, DiskFiles: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+
+		if _, err := os.Stat(uploadedPath); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if result, err := s.EmptyTrash(ctx, false); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{Files: 2, Folders: 2, DiskFiles: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+
+		// Only uploaded files below the storage root are deleted from disk.
+		if _, err := os.Stat(uploadedPath); !os.IsNotExist(err) {
+			t.Fatalf("Expected uploaded file to be deleted, got %v", err)
+		} else if _, err := os.Stat(importedPath); err != nil {
+			t.Fatalf("Expected imported file to be kept, got %v", err)
+		}
+
+		// Files left in a deleted folder are moved to the top level.
+		if n := MustQueryString(t, db, `SELECT COUNT(*) FROM folders`); n != "0" {
+			t.Fatalf("Unexpected number of folders: %s", n)
+		} else if v := MustQueryString(t, db, `SELECT COALESCE(folder_id, 'null') FROM files WHERE id = ?`, restored.ID); v != "null" {
+			t.Fatalf("Unexpected folder: %s", v)
+		}
+	})
+}
+
+func TestTrashService_PurgeRemoved(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+	jane, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	john, ctx2 := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	active := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "active.jpg"})
+	old := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "old.jpg"})
+	recent := MustCreateFile(t, ctx, db, &gofman.File{UserID: jane.ID, Name: "recent.jpg"})
+	other := MustCreateFile(t, ctx2, db, &gofman.File{UserID: john.ID, Name: "other.jpg"})
+	tag := MustCreateTag(t, ctx, db, &gofman.Tag{UserID: jane.ID, Name: "holiday"})
+	oldActor := MustCreateActor(t, ctx, db, &gofman.Actor{UserID: jane.ID, Name: "alice"})
+
+	MustExec(t, db, `INSERT INTO files_tags (files_id, tags_id) VALUES (?, ?), (?, ?)`, active.ID, tag.ID, old.ID, tag.ID)
+	MustExec(t, db, `INSERT INTO files_actors (files_id, actors_id) VALUES (?, ?)`, active.ID, oldActor.ID)
+	MustExec(t, db, `UPDATE files SET removed_at = 100 WHERE id IN (?, ?)`, old.ID, other.ID)
+	MustExec(t, db, `UPDATE files SET removed_at = 300 WHERE id = ?`, recent.ID)
+	MustExec(t, db, `UPDATE actors SET removed_at = 100 WHERE id = ?`, oldActor.ID)
+
+	s := sqlite.NewTrashService(db)
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, err := s.PurgeRemoved(ctx, 200, true); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		if result, err := s.PurgeRemoved(admin, 200, true); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{Files: 2, Actors: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if result, err := s.PurgeRemoved(admin, 200, false); err != nil {
+			t.Fatal(err)
+		} else if *result != (gofman.TrashResult{Files: 2, Actors: 1}) {
+			t.Fatalf("Unexpected result: %#v", result)
+		}
+
+		// Live and recently removed rows survive.
+		if names := MustQueryString(t, db, `SELECT group_concat(name) FROM (SELECT name FROM files ORDER BY name)`); names != "active.jpg,recent.jpg" {
+			t.Fatalf("Unexpected files: %q", names)
+		}
+
+		if n := MustQueryString(t, db, `SELECT COUNT(*) FROM files_tags`); n != "1" {
+			t.Fatalf("Unexpected number of file tags: %s", n)
+		} else if n := MustQueryString(t, db, `SELECT COUNT(*) FROM files_actors`); n != "0" {
+			t.Fatalf("Unexpected number of file actors: %s", n)
+		}
+	})
+}
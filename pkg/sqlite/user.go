@@ -2,17 +2,30 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"strings"
 
 	"github.com/dhenkes/gofman/pkg/gofman"
 )
 
+// Strategies for reusing the username of a removed user.
+const (
+	UsernameReuseRelease    = "release"
+	UsernameReuseReactivate = "reactivate"
+)
+
 // Ensure service implements interface.
 var _ gofman.UserService = (*UserService)(nil)
 
 // UserService represents a service for managing users.
 type UserService struct {
 	db *DB
+
+	// Strategy used when a new user is created with the username of a
+	// removed user. Either renames the removed user to release the username
+	// or reactivates the removed user with the password and role of the new
+	// one. Defaults to UsernameReuseRelease.
+	UsernameReuseStrategy string
 }
 
 // NewUserService returns a new instance of UserService.
@@ -82,15 +95,17 @@ func (s *UserService) CreateUser(ctx context.Context, user *gofman.User) error {
 
 	defer tx.Rollback()
 
-	if err := createUser(ctx, tx, user); err != nil {
+	if err := createUser(ctx, tx, user, s.UsernameReuseStrategy); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-// UpdateUser updates a user. Returns EUNAUTHORIZED if current user is not
-// user being updated. Returns ENOTFOUND if user does not exist.
+// UpdateUser updates a user. All sessions of the user are deleted if the
+// role changes or RevokeSessions is set. Only admins can change roles.
+// Returns EUNAUTHORIZED if current user is not user being updated. Returns
+// ENOTFOUND if user does not exist.
 func (s *UserService) UpdateUser(ctx context.Context, id string, update gofman.UserUpdate) (*gofman.User, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -129,6 +144,49 @@ func (s *UserService) RemoveUser(ctx context.Context, id string) error {
 	return tx.Commit()
 }
 
+// RestoreUser resets the removed timestamp of a removed user. Only admins
+// can restore users. Returns ENOTFOUND if user does not exist or has not
+// been removed.
+func (s *UserService) RestoreUser(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := restoreUser(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveUserWithContent removes a user together with all files, actors and
+// tags of the user and deletes all sessions of the user. If dryRun is set
+// nothing is changed and only the number of affected rows is returned.
+// Returns EUNAUTHORIZED if current user is not the user being removed or an
+// admin. Returns ENOTFOUND if user does not exist.
+func (s *UserService) RemoveUserWithContent(ctx context.Context, id string, dryRun bool) (*gofman.UserContent, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	content, err := removeUserWithContent(ctx, tx, id, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
 // findUserByID is a helper function to fetch a user by ID.
 // Returns ENOTFOUND if user does not exist.
 func findUserByID(ctx context.Context, tx *Tx, id string) (*gofman.User, error) {
@@ -165,6 +223,10 @@ func findUsers(ctx context.Context, tx *Tx, filter gofman.UserFilter) ([]*gofman
 		return nil, 0, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to search using this filter.")
 	}
 
+	if err := checkOffset(filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
 	where, args := []string{"1 = 1"}, []interface{}{}
 
 	if v := filter.ID; v != nil {
@@ -175,22 +237,41 @@ func findUsers(ctx context.Context, tx *Tx, filter gofman.UserFilter) ([]*gofman
 		where, args = append(where, "username = ?"), append(args, *v)
 	}
 
-	where = append(where, "removed_at = 0")
+	if v := filter.NameLike; v != nil {
+		where, args = append(where, `username LIKE '%' || ? || '%' ESCAPE '\'`), append(args, escapeLike(*v))
+	}
+
+	if v := filter.CreatedAfter; v != nil {
+		where, args = append(where, "created_at >= ?"), append(args, *v)
+	}
+
+	if v := filter.CreatedBefore; v != nil {
+		where, args = append(where, "created_at <= ?"), append(args, *v)
+	}
+
+	if !filter.IncludeRemoved {
+		where = append(where, "removed_at = 0")
+	}
+
+	orderBy, err := formatOrderBy("users", filter.SortBy, filter.SortDesc)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	rows, err := tx.QueryContext(ctx, `
 		SELECT
 			id,
 			username,
 			password,
-			is_admin,
+			role,
 			created_at,
 			updated_at,
 			removed_at,
 			COUNT(*) OVER()
 		FROM users
 		WHERE `+strings.Join(where, " AND ")+`
-		ORDER BY created_at ASC
-		`+formatLimitOffset(filter.Limit, filter.Offset),
+		`+orderBy+`
+		`+formatLimitOffset(clampLimit(filter.Limit, gofman.DefaultLimit, gofman.MaxLimit), filter.Offset),
 		args...,
 	)
 
@@ -205,15 +286,18 @@ func findUsers(ctx context.Context, tx *Tx, filter gofman.UserFilter) ([]*gofman
 
 	for rows.Next() {
 		var user gofman.User
+		var role string
 
 		if err = rows.Scan(
-			&user.ID, &user.Username, &user.Password, &user.IsAdmin,
+			&user.ID, &user.Username, &user.Password, &role,
 			&user.CreatedAt, &user.UpdatedAt, &user.RemovedAt,
 			&n,
 		); err != nil {
 			return nil, 0, err
 		}
 
+		user.SetRole(role)
+
 		users = append(users, &user)
 	}
 
@@ -224,8 +308,17 @@ func findUsers(ctx context.Context, tx *Tx, filter gofman.UserFilter) ([]*gofman
 	return users, n, nil
 }
 
-// createUser creates a new user.
-func createUser(ctx context.Context, tx *Tx, user *gofman.User) error {
+// createUser creates a new user. Users are created as regular users unless
+// a readonly role is requested, admins are only made by the setup. The
+// username of a removed user is reused according to strategy, see
+// reuseRemovedUsername.
+func createUser(ctx context.Context, tx *Tx, user *gofman.User, strategy string) error {
+	if user.Role == "" || user.Role == gofman.RoleAdmin {
+		user.SetRole(gofman.RoleUser)
+	} else {
+		user.SetRole(user.Role)
+	}
+
 	if err := user.Validate(); err != nil {
 		return err
 	}
@@ -247,40 +340,137 @@ func createUser(ctx context.Context, tx *Tx, user *gofman.User) error {
 	}
 
 	user.Username = strings.ToLower(user.Username)
-	user.IsAdmin = false
 	user.CreatedAt = tx.now
 	user.UpdatedAt = user.CreatedAt
 
+	if reactivated, err := reuseRemovedUsername(ctx, tx, user, strategy); err != nil {
+		return err
+	} else if reactivated {
+		return nil
+	}
+
+	if err := checkUsernameAvailable(ctx, tx, user.Username, ""); err != nil {
+		return err
+	}
+
 	_, err := tx.ExecContext(ctx, `
 		INSERT INTO users (
 			id,
 			username,
 			password,
+			role,
 			is_admin,
 			created_at,
 			updated_at,
 			removed_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		user.ID,
 		user.Username,
 		user.Password,
+		user.Role,
 		user.IsAdmin,
 		user.CreatedAt,
 		user.UpdatedAt,
 		0,
 	)
 
-	if err != nil {
+	if isUniqueConstraintError(err) {
+		return gofman.NewError(gofman.ECONFLICT, "Username already taken.")
+	} else if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	return nil
+}
+
+// reuseRemovedUsername makes the username of a removed user available to the
+// new user. With UsernameReuseReactivate the removed user is reactivated with
+// the password and role of the new user and all its sessions are deleted. The
+// new user takes over its ID and creation time. Otherwise the removed user is
+// renamed to release the username. Returns true if a user was reactivated.
+func reuseRemovedUsername(ctx context.Context, tx *Tx, user *gofman.User, strategy string) (bool, error) {
+	var id string
+	var createdAt int64
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, created_at
+		FROM users
+		WHERE LOWER(username) = ? AND removed_at != 0
+	`,
+		user.Username,
+	).Scan(&id, &createdAt)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if strategy != UsernameReuseReactivate {
+		// Usernames cannot contain colons, so the new name cannot clash with
+		// any valid username.
+		_, err := tx.ExecContext(ctx, `UPDATE users SET username = ? WHERE id = ?`, "removed:"+id, id)
+		return false, err
+	}
+
+	user.ID = id
+	user.CreatedAt = createdAt
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET password = ?,
+			role = ?,
+			is_admin = ?,
+			updated_at = ?,
+			removed_at = 0
+		WHERE id = ?
+	`,
+		user.Password,
+		user.Role,
+		user.IsAdmin,
+		user.UpdatedAt,
+		user.ID,
+	); err != nil {
+		return false, mapSQLiteError(err)
+	}
+
+	if _, err := deleteSessionsForUser(ctx, tx, user.ID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// checkUsernameAvailable returns ECONFLICT if another user has the lowercased
+// username. Removed users keep their username until it is reused by
+// createUser, so it cannot be taken over by renaming another user.
+func checkUsernameAvailable(ctx context.Context, tx *Tx, username, exceptID string) error {
+	var n int
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM users
+		WHERE LOWER(username) = ? AND id != ?
+	`,
+		strings.ToLower(username),
+		exceptID,
+	).Scan(&n); err != nil {
 		return err
 	}
 
+	if n > 0 {
+		return gofman.NewError(gofman.ECONFLICT, "Username already taken.")
+	}
+
 	return nil
 }
 
-// updateUser updates a user. Returns EUNAUTHORIZED if current user is not
-// user being updated. Returns ENOTFOUND if user does not exist.
+// updateUser updates a user. All sessions of the user are deleted if the
+// role changes or RevokeSessions is set. Only admins can change roles.
+// Returns EUNAUTHORIZED if current user is not user being updated. Returns
+// ENOTFOUND if user does not exist.
 func updateUser(ctx context.Context, tx *Tx, id string, update gofman.UserUpdate) (*gofman.User, error) {
 	user, err := findUserByID(ctx, tx, id)
 	if err != nil {
@@ -299,8 +489,27 @@ func updateUser(ctx context.Context, tx *Tx, id string, update gofman.UserUpdate
 		user.Password = *v
 	}
 
-	if v := update.IsAdmin; v != nil {
-		user.IsAdmin = *v
+	role := user.Role
+
+	if v := update.IsAdmin; v != nil && *v {
+		role = gofman.RoleAdmin
+	} else if v != nil && user.Role == gofman.RoleAdmin {
+		role = gofman.RoleUser
+	}
+
+	if v := update.Role; v != nil {
+		role = *v
+	}
+
+	revoke := update.RevokeSessions
+
+	if role != user.Role {
+		if gofman.CanManageUsers(ctx) == false {
+			return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to change the role of this user.")
+		}
+
+		revoke = true
+		user.SetRole(role)
 	}
 
 	user.UpdatedAt = tx.now
@@ -311,6 +520,12 @@ func updateUser(ctx context.Context, tx *Tx, id string, update gofman.UserUpdate
 
 	user.Username = strings.ToLower(user.Username)
 
+	if update.Username != nil {
+		if err := checkUsernameAvailable(ctx, tx, user.Username, user.ID); err != nil {
+			return user, err
+		}
+	}
+
 	if v := update.Password; v != nil {
 		if user.Password, err = hashPassword(ctx, tx, user.Password); err != nil {
 			return nil, err
@@ -321,19 +536,29 @@ func updateUser(ctx context.Context, tx *Tx, id string, update gofman.UserUpdate
 		UPDATE users
 		SET username = ?,
 			password = ?,
+			role = ?,
 			is_admin = ?,
 			updated_at = ?
 		WHERE id = ?
 	`,
 		user.Username,
 		user.Password,
+		user.Role,
 		user.IsAdmin,
 		user.UpdatedAt,
 		id,
 	)
 
-	if err != nil {
-		return user, err
+	if isUniqueConstraintError(err) {
+		return user, gofman.NewError(gofman.ECONFLICT, "Username already taken.")
+	} else if err != nil {
+		return user, mapSQLiteError(err)
+	}
+
+	if revoke {
+		if _, err := deleteSessionsForUser(ctx, tx, id); err != nil {
+			return user, err
+		}
 	}
 
 	return user, nil
@@ -368,13 +593,132 @@ func removeUser(ctx context.Context, tx *Tx, id string) error {
 	return nil
 }
 
-// hashPassword is a helper function that takes a password, generates a salt
-// and returns the hashed password or an error.
+// restoreUser resets the removed timestamp of a removed user. Only admins
+// can restore users. Returns ECONFLICT if the username of the user was
+// released for a new user. Returns ENOTFOUND if user does not exist or has
+// not been removed.
+func restoreUser(ctx context.Context, tx *Tx, id string) error {
+	if gofman.CanManageUsers(ctx) == false {
+		return gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to restore this user.")
+	}
+
+	users, _, err := findUsers(ctx, tx, gofman.UserFilter{ID: &id, IncludeRemoved: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	if len(users) == 0 || users[0].RemovedAt == 0 {
+		return gofman.NewError(gofman.ENOTFOUND, "Removed user not found.")
+	}
+
+	// See reuseRemovedUsername.
+	if strings.HasPrefix(users[0].Username, "removed:") {
+		return gofman.NewError(gofman.ECONFLICT, "Username was reused by another user.")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET removed_at = 0
+		WHERE id = ?
+	`,
+		id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// removeUserWithContent removes a user together with all files, actors and
+// tags of the user and deletes all sessions of the user. If dryRun is set
+// nothing is changed and only the number of affected rows is returned.
+// Returns EUNAUTHORIZED if current user is not the user being removed or an
+// admin. Returns ENOTFOUND if user does not exist.
+func removeUserWithContent(ctx context.Context, tx *Tx, id string, dryRun bool) (*gofman.UserContent, error) {
+	user, err := findUserByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if gofman.CanUpdateUser(ctx, user) == false {
+		return nil, gofman.NewError(gofman.EUNAUTHORIZED, "You are not allowed to remove this user.")
+	}
+
+	var content gofman.UserContent
+
+	if !dryRun {
+		if err := unindexFiles(ctx, tx, "users_id = ?", id); err != nil {
+			return nil, err
+		}
+	}
+
+	for table, n := range map[string]*int{
+		"files":  &content.Files,
+		"actors": &content.Actors,
+		"tags":   &content.Tags,
+	} {
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM `+table+`
+			WHERE users_id = ? AND removed_at = 0
+		`,
+			id,
+		).Scan(n); err != nil {
+			return nil, err
+		}
+
+		if dryRun {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE `+table+`
+			SET removed_at = ?
+			WHERE users_id = ? AND removed_at = 0
+		`,
+			tx.now,
+			id,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE users_id = ?`, id).Scan(&content.Sessions)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return &content, nil
+	}
+
+	if _, err := deleteSessionsForUser(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	if err := removeUser(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	return &content, nil
+}
+
+// hashPassword is a helper function that takes a password, checks it if a
+// password check is set, generates a salt and returns the hashed password or
+// an error.
 func hashPassword(ctx context.Context, tx *Tx, password string) (string, error) {
 	if tx.db.AuthService == nil {
 		return "", gofman.NewError(gofman.EINVALID, "AuthService required.")
 	}
 
+	if tx.db.CheckPassword != nil {
+		if err := tx.db.CheckPassword(password); err != nil {
+			return "", err
+		}
+	}
+
 	salt, err := tx.db.AuthService.NewSalt()
 	if err != nil {
 		return "", err
@@ -387,3 +731,18 @@ func hashPassword(ctx context.Context, tx *Tx, password string) (string, error)
 
 	return hash, nil
 }
+
+// migrateUserRoles sets the role of users created before roles existed from
+// their admin flag.
+func (db *DB) migrateUserRoles() error {
+	_, err := db.db.ExecContext(db.ctx, `
+		UPDATE users
+		SET role = CASE WHEN is_admin THEN ? ELSE ? END
+		WHERE role = ''
+	`,
+		gofman.RoleAdmin,
+		gofman.RoleUser,
+	)
+
+	return err
+}
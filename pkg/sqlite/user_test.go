@@ -0,0 +1,462 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhenkes/gofman/pkg/auth"
+	"github.com/dhenkes/gofman/pkg/gofman"
+	"github.com/dhenkes/gofman/pkg/sqlite"
+)
+
+func TestUserService_RemoveUserWithContent(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+	jane, janeCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	MustCreateFile(t, janeCtx, db, &gofman.File{UserID: jane.ID, Name: "a.jpg"})
+	MustCreateFile(t, janeCtx, db, &gofman.File{UserID: jane.ID, Name: "b.jpg"})
+	MustCreateActor(t, janeCtx, db, &gofman.Actor{UserID: jane.ID, Name: "alice"})
+	MustCreateTag(t, janeCtx, db, &gofman.Tag{UserID: jane.ID, Name: "holiday"})
+	MustCreateSession(t, janeCtx, db, &gofman.Session{UserID: jane.ID, Token: NewToken(0)})
+
+	john, johnCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+	MustCreateFile(t, johnCtx, db, &gofman.File{UserID: john.ID, Name: "c.jpg"})
+
+	s := sqlite.NewUserService(db)
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if _, err := s.RemoveUserWithContent(johnCtx, jane.ID, false); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		content, err := s.RemoveUserWithContent(admin, jane.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		} else if *content != (gofman.UserContent{Files: 2, Actors: 1, Tags: 1, Sessions: 1}) {
+			t.Fatalf("Unexpected content: %#v", content)
+		}
+
+		if _, err := s.FindUserByID(admin, jane.ID); err != nil {
+			t.Fatalf("Expected user to remain after dry run: %#v", err)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		content, err := s.RemoveUserWithContent(admin, jane.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		} else if *content != (gofman.UserContent{Files: 2, Actors: 1, Tags: 1, Sessions: 1}) {
+			t.Fatalf("Unexpected content: %#v", content)
+		}
+
+		if _, err := s.FindUserByID(admin, jane.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		content, err = s.RemoveUserWithContent(admin, john.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		} else if *content != (gofman.UserContent{Files: 1}) {
+			t.Fatalf("Expected other users to be untouched, got %#v", content)
+		}
+	})
+}
+
+func TestUserService_CheckPassword(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	db.CheckPassword = auth.DefaultPasswordPolicy().Check
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+	s := sqlite.NewUserService(db)
+
+	t.Run("CreateWeak", func(t *testing.T) {
+		if err := s.CreateUser(admin, &gofman.User{Username: "jane", Password: "password"}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	user := &gofman.User{Username: "john", Password: "correct-Horse-battery"}
+	if err := s.CreateUser(admin, user); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := gofman.NewContextWithUser(context.Background(), user)
+
+	t.Run("UpdateWeak", func(t *testing.T) {
+		password := "1234567"
+		if _, err := s.UpdateUser(ctx, user.ID, gofman.UserUpdate{Password: &password}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("UpdateWithoutPassword", func(t *testing.T) {
+		username := "johnny"
+		if _, err := s.UpdateUser(ctx, user.ID, gofman.UserUpdate{Username: &username}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestUserService_UpdateUser_RevokeSessions(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	s := sqlite.NewUserService(db)
+
+	countSessions := func() string {
+		return MustQueryString(t, db, `SELECT COUNT(*) FROM sessions WHERE users_id = ?`, user.ID)
+	}
+
+	t.Run("Keep", func(t *testing.T) {
+		MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(1)})
+
+		username := "janet"
+		if _, err := s.UpdateUser(admin, user.ID, gofman.UserUpdate{Username: &username}); err != nil {
+			t.Fatal(err)
+		} else if n := countSessions(); n != "1" {
+			t.Fatalf("Unexpected sessions: %s", n)
+		}
+
+		isAdmin := user.IsAdmin
+		if _, err := s.UpdateUser(admin, user.ID, gofman.UserUpdate{IsAdmin: &isAdmin}); err != nil {
+			t.Fatal(err)
+		} else if n := countSessions(); n != "1" {
+			t.Fatalf("Unexpected sessions: %s", n)
+		}
+	})
+
+	t.Run("AdminChange", func(t *testing.T) {
+		MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(2)})
+
+		isAdmin := !user.IsAdmin
+		if _, err := s.UpdateUser(admin, user.ID, gofman.UserUpdate{IsAdmin: &isAdmin}); err != nil {
+			t.Fatal(err)
+		} else if n := countSessions(); n != "0" {
+			t.Fatalf("Unexpected sessions: %s", n)
+		}
+	})
+
+	t.Run("Flag", func(t *testing.T) {
+		MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(3)})
+
+		if _, err := s.UpdateUser(admin, user.ID, gofman.UserUpdate{RevokeSessions: true}); err != nil {
+			t.Fatal(err)
+		} else if n := countSessions(); n != "0" {
+			t.Fatalf("Unexpected sessions: %s", n)
+		}
+	})
+
+	t.Run("DeleteSessionsForUser", func(t *testing.T) {
+		MustCreateSession(t, ctx, db, &gofman.Session{UserID: user.ID, Token: NewToken(4)})
+
+		other, otherCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+		sessions := sqlite.NewSessionService(db)
+
+		if _, err := sessions.DeleteSessionsForUser(otherCtx, user.ID); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		} else if n, err := sessions.DeleteSessionsForUser(otherCtx, other.ID); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("Unexpected deleted sessions: %d", n)
+		} else if n, err := sessions.DeleteSessionsForUser(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatalf("Unexpected deleted sessions: %d", n)
+		}
+	})
+}
+
+func TestUserService_Roles(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{Role: gofman.RoleAdmin})
+	user, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	s := sqlite.NewUserService(db)
+
+	t.Run("Default", func(t *testing.T) {
+		if user.Role != gofman.RoleUser || user.IsAdmin {
+			t.Fatalf("Unexpected role: %q", user.Role)
+		}
+	})
+
+	t.Run("ErrSelfPromotion", func(t *testing.T) {
+		role := gofman.RoleAdmin
+		if _, err := s.UpdateUser(ctx, user.ID, gofman.UserUpdate{Role: &role}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		isAdmin := true
+		if _, err := s.UpdateUser(ctx, user.ID, gofman.UserUpdate{IsAdmin: &isAdmin}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrInvalidRole", func(t *testing.T) {
+		role := "root"
+		if _, err := s.UpdateUser(admin, user.ID, gofman.UserUpdate{Role: &role}); gofman.ErrorCode(err) != gofman.EINVALID {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Promote", func(t *testing.T) {
+		role := gofman.RoleAdmin
+		if updated, err := s.UpdateUser(admin, user.ID, gofman.UserUpdate{Role: &role}); err != nil {
+			t.Fatal(err)
+		} else if updated.Role != gofman.RoleAdmin || !updated.IsAdmin {
+			t.Fatalf("Unexpected user: %#v", updated)
+		}
+
+		if got := MustQueryString(t, db, `SELECT is_admin FROM users WHERE id = ?`, user.ID); got != "true" {
+			t.Fatalf("Expected is_admin to be kept in sync, got %q", got)
+		}
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		readonly, readonlyCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password", Role: gofman.RoleReadOnly})
+		if readonly.Role != gofman.RoleReadOnly {
+			t.Fatalf("Unexpected role: %q", readonly.Role)
+		}
+
+		if err := sqlite.NewTagService(db).CreateTag(readonlyCtx, &gofman.Tag{UserID: readonly.ID, Name: "red"}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		MustExec(t, db, `INSERT INTO users (id, username, password, is_admin, created_at, updated_at, removed_at) VALUES ('legacy', 'legacy', 'x', TRUE, 0, 0, 0)`)
+
+		reopened := sqlite.NewDB()
+		reopened.DSN = db.DSN
+		reopened.AuthService = db.AuthService
+
+		if err := reopened.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		defer MustCloseDB(t, reopened)
+
+		if got := MustQueryString(t, reopened, `SELECT role FROM users WHERE id = 'legacy'`); got != gofman.RoleAdmin {
+			t.Fatalf("Unexpected role: %q", got)
+		} else if got := MustQueryString(t, reopened, `SELECT role FROM users WHERE id = ?`, user.ID); got != gofman.RoleAdmin {
+			t.Fatalf("Unexpected role: %q", got)
+		}
+	})
+}
+
+func TestUserService_FindUsers_NameLike(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{Role: gofman.RoleAdmin})
+	MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane_doe", Password: "password"})
+	MustCreateUser(t, context.Background(), db, &gofman.User{Username: "janexdoe", Password: "password"})
+	_, ctx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	s := sqlite.NewUserService(db)
+
+	t.Run("OK", func(t *testing.T) {
+		like := "E_D"
+		if users, n, err := s.FindUsers(admin, gofman.UserFilter{NameLike: &like}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 || users[0].Username != "jane_doe" {
+			t.Fatalf("Unexpected users: %#v", users)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		like := "jane"
+		if _, _, err := s.FindUsers(ctx, gofman.UserFilter{NameLike: &like}); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestUserService_UsernameTaken(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+	jane, _ := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+	john, johnCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "john", Password: "password"})
+
+	s := sqlite.NewUserService(db)
+
+	t.Run("Create", func(t *testing.T) {
+		for _, username := range []string{"jane", "JANE"} {
+			if err := s.CreateUser(admin, &gofman.User{Username: username, Password: "password"}); gofman.ErrorCode(err) != gofman.ECONFLICT {
+				t.Fatalf("Unexpected error for %q: %#v", username, err)
+			} else if gofman.ErrorMessage(err) != "Username already taken." {
+				t.Fatalf("Unexpected message: %q", gofman.ErrorMessage(err))
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		username := "Jane"
+		if _, err := s.UpdateUser(johnCtx, john.ID, gofman.UserUpdate{Username: &username}); gofman.ErrorCode(err) != gofman.ECONFLICT {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+
+		// Users can keep their own username.
+		username = "John"
+		if user, err := s.UpdateUser(johnCtx, john.ID, gofman.UserUpdate{Username: &username}); err != nil {
+			t.Fatal(err)
+		} else if user.Username != "john" {
+			t.Fatalf("Unexpected username: %q", user.Username)
+		}
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		if err := s.RemoveUser(admin, jane.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		// Removed users keep their username unless a new user is created
+		// with it.
+		username := "jane"
+		if _, err := s.UpdateUser(johnCtx, john.ID, gofman.UserUpdate{Username: &username}); gofman.ErrorCode(err) != gofman.ECONFLICT {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestUserService_CreateUser_RemovedUsername(t *testing.T) {
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+	t.Run("Release", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		s := sqlite.NewUserService(db)
+
+		jane, _ := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+		if err := s.RemoveUser(admin, jane.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		user := &gofman.User{Username: "Jane", Password: "password"}
+		if err := s.CreateUser(admin, user); err != nil {
+			t.Fatal(err)
+		} else if user.ID == jane.ID {
+			t.Fatal("Expected a new user")
+		}
+
+		if other, err := s.FindUserByUsername(admin, "jane"); err != nil {
+			t.Fatal(err)
+		} else if other.ID != user.ID {
+			t.Fatalf("Unexpected user: %#v", other)
+		}
+
+		// The removed user was renamed.
+		if username := MustQueryString(t, db, `SELECT username FROM users WHERE id = ?`, jane.ID); username != "removed:"+jane.ID {
+			t.Fatalf("Unexpected username: %q", username)
+		}
+
+		// Removing and recreating again works the same.
+		if err := s.RemoveUser(admin, user.ID); err != nil {
+			t.Fatal(err)
+		} else if err := s.CreateUser(admin, &gofman.User{Username: "jane", Password: "password"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Reactivate", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		s := sqlite.NewUserService(db)
+		s.UsernameReuseStrategy = sqlite.UsernameReuseReactivate
+
+		jane, _ := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+		if err := s.RemoveUser(admin, jane.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		user := &gofman.User{Username: "jane", Password: "password2", Role: gofman.RoleReadOnly}
+		if err := s.CreateUser(admin, user); err != nil {
+			t.Fatal(err)
+		} else if user.ID != jane.ID {
+			t.Fatalf("Unexpected ID: %q", user.ID)
+		} else if user.CreatedAt != jane.CreatedAt {
+			t.Fatalf("Unexpected created at: %d", user.CreatedAt)
+		}
+
+		if other, err := s.FindUserByID(admin, jane.ID); err != nil {
+			t.Fatal(err)
+		} else if other.RemovedAt != 0 {
+			t.Fatalf("Unexpected removed at: %d", other.RemovedAt)
+		} else if other.Role != gofman.RoleReadOnly {
+			t.Fatalf("Unexpected role: %q", other.Role)
+		} else if other.Password == jane.Password {
+			t.Fatal("Expected new password")
+		}
+	})
+}
+
+func TestUserService_RestoreUser(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	admin := gofman.NewContextWithUser(context.Background(), &gofman.User{IsAdmin: true})
+
+	jane, janeCtx := MustCreateUser(t, context.Background(), db, &gofman.User{Username: "jane", Password: "password"})
+
+	s := sqlite.NewUserService(db)
+
+	t.Run("ErrNotRemoved", func(t *testing.T) {
+		if err := s.RestoreUser(admin, jane.ID); gofman.ErrorCode(err) != gofman.ENOTFOUND {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	if err := s.RemoveUser(admin, jane.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		if err := s.RestoreUser(janeCtx, jane.ID); gofman.ErrorCode(err) != gofman.EUNAUTHORIZED {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		if users, _, err := s.FindUsers(admin, gofman.UserFilter{IncludeRemoved: true}); err != nil {
+			t.Fatal(err)
+		} else if len(users) != 1 || users[0].RemovedAt == 0 {
+			t.Fatalf("Unexpected users: %#v", users)
+		}
+
+		if err := s.RestoreUser(admin, jane.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if user, err := s.FindUserByUsername(admin, "jane"); err != nil {
+			t.Fatal(err)
+		} else if user.ID != jane.ID || user.RemovedAt != 0 {
+			t.Fatalf("Unexpected user: %#v", user)
+		}
+	})
+
+	t.Run("ErrUsernameReused", func(t *testing.T) {
+		if err := s.RemoveUser(admin, jane.ID); err != nil {
+			t.Fatal(err)
+		} else if err := s.CreateUser(admin, &gofman.User{Username: "jane", Password: "password"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.RestoreUser(admin, jane.ID); gofman.ErrorCode(err) != gofman.ECONFLICT {
+			t.Fatalf("Unexpected error: %#v", err)
+		}
+	})
+}